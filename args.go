@@ -137,8 +137,8 @@ func (mrb *MrbState) GetArgs3(defaults ...interface{}) (Value, Value, Value) {
 
 // Args returns all arguments passed to MrbFuncT function as array of values
 func (mrb *MrbState) Args() []Value {
-	argc := int(C.mrb_get_argc(mrb.p))
-	args := C.mrb_get_argv(mrb.p)
+	argc := int(C.mrb_get_argc(mrb.state()))
+	args := C.mrb_get_argv(mrb.state())
 	items := make([]Value, argc)
 	for i := 0; i < argc; i++ {
 		items[i] = Value{C._mrb_get_arg(args, C.int(i))}
@@ -149,8 +149,8 @@ func (mrb *MrbState) Args() []Value {
 // GetArgs returns all arguments passed to MrbFuncT function
 // as RArray type
 func (mrb *MrbState) GetArgs(defaults ...interface{}) RArgs {
-	argc := int(C.mrb_get_argc(mrb.p))
-	args := C.mrb_get_argv(mrb.p)
+	argc := int(C.mrb_get_argc(mrb.state()))
+	args := C.mrb_get_argv(mrb.state())
 	items := make([]Value, argc)
 	for i := 0; i < argc; i++ {
 		items[i] = Value{C._mrb_get_arg(args, C.int(i))}
@@ -176,7 +176,7 @@ func (mrb *MrbState) GetAllArgs(defaults ...interface{}) (RArgs, RHash, RProc) {
 
 // KeywordArgs returns provided keyword args as RHash
 func (mrb *MrbState) KeywordArgs() RHash {
-	args := RValue{C._mrb_get_args_kw(mrb.p), mrb}
+	args := RValue{C._mrb_get_args_kw(mrb.state()), mrb}
 	if args.IsNil() {
 		return mrb.HashNew()
 	}
@@ -191,24 +191,24 @@ func (mrb *MrbState) GetArgs2(defaults ...interface{}) (Value, Value) {
 
 // GetArgsFirst returns first argument
 func (mrb *MrbState) GetArgsFirst() Value {
-	return Value{C._mrb_get_args_first(mrb.p)}
+	return Value{C._mrb_get_args_first(mrb.state())}
 }
 
 // GetArg1 retrieves the first and only argument from mrb_state.
 // Raises ArgumentError unless the number of arguments is exactly one.
 // Correctly handles *splat arguments.
 func (mrb *MrbState) GetArg1() Value {
-	return Value{C.mrb_get_arg1(mrb.p)}
+	return Value{C.mrb_get_arg1(mrb.state())}
 }
 
 // GetArgsCount returns numer of arguments passed to function
 func (mrb *MrbState) GetArgsCount() int {
-	return int(C.mrb_get_argc(mrb.p))
+	return int(C.mrb_get_argc(mrb.state()))
 }
 
 // GetArgsBlock returns block argument
 func (mrb *MrbState) GetArgsBlock() RProc {
-	v := Value{C._mrb_get_args_block(mrb.p)}
+	v := Value{C._mrb_get_args_block(mrb.state())}
 	return mrb.RProc(v)
 }
 
@@ -238,11 +238,11 @@ func (mrb *MrbState) GetArgsBlock() RProc {
 //	argc := mrb.ScanArgs(&v1, &v2, &v3)
 func (mrb *MrbState) ScanArgs(args ...interface{}) (int, Value) {
 	if len(args) == 0 {
-		return 0, Value{C._mrb_get_args_block(mrb.p)}
+		return 0, Value{C._mrb_get_args_block(mrb.state())}
 	}
 
-	argc := int(C.mrb_get_argc(mrb.p))
-	rets := C.mrb_get_argv(mrb.p)
+	argc := int(C.mrb_get_argc(mrb.state()))
+	rets := C.mrb_get_argv(mrb.state())
 
 	for i, arg := range args {
 		if i >= argc {
@@ -378,5 +378,5 @@ func (mrb *MrbState) ScanArgs(args ...interface{}) (int, Value) {
 
 		}
 	}
-	return argc, Value{C._mrb_get_args_block(mrb.p)}
+	return argc, Value{C._mrb_get_args_block(mrb.state())}
 }
@@ -116,19 +116,19 @@ func (a RArray) SetShared() { C._RARRAY_SET_SHARED(a.p()) }
 func (a RArray) UnsetShared() { C._RARRAY_UNSET_SHARED(a.p()) }
 
 // AryModify modify array
-func (mrb *MrbState) AryModify(a RArray) { C.mrb_ary_modify(mrb.p, a.p()) }
+func (mrb *MrbState) AryModify(a RArray) { C.mrb_ary_modify(mrb.state(), a.p()) }
 
 // AryNewCapa Set new array with capacity capa
 func (mrb *MrbState) AryNewCapa(capa int) RArray {
 	return RArray{RValue{
-		C.mrb_ary_new_capa(mrb.p, C.mrb_int(capa)),
+		C.mrb_ary_new_capa(mrb.state(), C.mrb_int(capa)),
 		mrb,
 	}}
 }
 
 // AryNew Initializes a new array
 func (mrb *MrbState) AryNew() RArray {
-	return ary(C.mrb_ary_new(mrb.p), mrb)
+	return ary(C.mrb_ary_new(mrb.state()), mrb)
 }
 
 // AryNewFromValues Initializes a new array with initial values
@@ -145,25 +145,29 @@ func (mrb *MrbState) AryNewFromMrbValues(args ...MrbValue) RArray {
 
 // AssocNew Initializes a new array with two initial values
 func (mrb *MrbState) AssocNew(car, cdr MrbValue) RArray {
-	return ary(C.mrb_assoc_new(mrb.p, car.Value().v, cdr.Value().v), mrb)
+	return ary(C.mrb_assoc_new(mrb.state(), car.Value().v, cdr.Value().v), mrb)
 }
 
 // AryConcat Concatenate two arrays. The target array will be modified
 func (mrb *MrbState) AryConcat(self, other MrbValue) {
-	C.mrb_ary_concat(mrb.p, self.Value().v, other.Value().v)
+	C.mrb_ary_concat(mrb.state(), self.Value().v, other.Value().v)
 }
 
 // ArySplat Create an array from the input. Tries calling to_a on the value.
 // If value does not respond to that, it creates a new array with just this value.
 func (mrb *MrbState) ArySplat(value MrbValue) Value {
-	return Value{C.mrb_ary_splat(mrb.p, value.Value().v)}
+	return Value{C.mrb_ary_splat(mrb.state(), value.Value().v)}
 }
 
 // AryPush pushes value to array
-func (mrb *MrbState) AryPush(ary, val MrbValue) { C.mrb_ary_push(mrb.p, ary.Value().v, val.Value().v) }
+func (mrb *MrbState) AryPush(ary, val MrbValue) {
+	C.mrb_ary_push(mrb.state(), ary.Value().v, val.Value().v)
+}
 
 // AryPop pops the last element from the array
-func (mrb *MrbState) AryPop(ary MrbValue) Value { return Value{C.mrb_ary_pop(mrb.p, ary.Value().v)} }
+func (mrb *MrbState) AryPop(ary MrbValue) Value {
+	return Value{C.mrb_ary_pop(mrb.state(), ary.Value().v)}
+}
 
 // AryRef returns a reference to an element of the array on the given index
 func (mrb *MrbState) AryRef(ary MrbValue, n int) Value {
@@ -172,11 +176,13 @@ func (mrb *MrbState) AryRef(ary MrbValue, n int) Value {
 
 // ArySet Sets a value on an array at the given index
 func (mrb *MrbState) ArySet(ary MrbValue, n int, val MrbValue) {
-	C.mrb_ary_set(mrb.p, ary.Value().v, C.mrb_int(n), val.Value().v)
+	C.mrb_ary_set(mrb.state(), ary.Value().v, C.mrb_int(n), val.Value().v)
 }
 
 // AryReplace Replace the array with another array
-func (mrb *MrbState) AryReplace(a, b MrbValue) { C.mrb_ary_replace(mrb.p, a.Value().v, b.Value().v) }
+func (mrb *MrbState) AryReplace(a, b MrbValue) {
+	C.mrb_ary_replace(mrb.state(), a.Value().v, b.Value().v)
+}
 
 // EnsureArrayType checks array value
 func (mrb *MrbState) EnsureArrayType(v MrbValue) RArray {
@@ -190,12 +196,12 @@ func (mrb *MrbState) EnsureArrayType(v MrbValue) RArray {
 
 // CheckArrayType checks array value
 func (mrb *MrbState) CheckArrayType(ary MrbValue) Value {
-	return Value{C.mrb_check_array_type(mrb.p, ary.Value().v)}
+	return Value{C.mrb_check_array_type(mrb.state(), ary.Value().v)}
 }
 
 // AryUnshift unshift an element into the array
 func (mrb *MrbState) AryUnshift(ary, item MrbValue) Value {
-	return Value{C.mrb_ary_unshift(mrb.p, ary.Value().v, item.Value().v)}
+	return Value{C.mrb_ary_unshift(mrb.state(), ary.Value().v, item.Value().v)}
 }
 
 // AryEntry get nth element in the array
@@ -205,25 +211,25 @@ func (mrb *MrbState) AryEntry(ary MrbValue, offset int) Value {
 
 // ArySplice replace subsequence of an array
 func (mrb *MrbState) ArySplice(ary MrbValue, head, length int, rpl MrbValue) Value {
-	return Value{C.mrb_ary_splice(mrb.p, ary.Value().v, C.mrb_int(head), C.mrb_int(length), rpl.Value().v)}
+	return Value{C.mrb_ary_splice(mrb.state(), ary.Value().v, C.mrb_int(head), C.mrb_int(length), rpl.Value().v)}
 }
 
 // AryShift shifts the first element from the array
 func (mrb *MrbState) AryShift(ary MrbValue) Value {
-	return Value{C.mrb_ary_shift(mrb.p, ary.Value().v)}
+	return Value{C.mrb_ary_shift(mrb.state(), ary.Value().v)}
 }
 
 // AryClear removes all elements from the array
 func (mrb *MrbState) AryClear(ary MrbValue) Value {
-	return Value{C.mrb_ary_clear(mrb.p, ary.Value().v)}
+	return Value{C.mrb_ary_clear(mrb.state(), ary.Value().v)}
 }
 
 // AryJoin join array items to string using separator sep
 func (mrb *MrbState) AryJoin(ary, sep MrbValue) Value {
-	return Value{C.mrb_ary_join(mrb.p, ary.Value().v, sep.Value().v)}
+	return Value{C.mrb_ary_join(mrb.state(), ary.Value().v, sep.Value().v)}
 }
 
 // AryResize update the capacity of the array
 func (mrb *MrbState) AryResize(ary MrbValue, newLen int) Value {
-	return Value{C.mrb_ary_resize(mrb.p, ary.Value().v, C.mrb_int(newLen))}
+	return Value{C.mrb_ary_resize(mrb.state(), ary.Value().v, C.mrb_int(newLen))}
 }
@@ -0,0 +1,196 @@
+package oruby
+
+// #include "go-mrb.h"
+// #include "../mrbgems/mruby-compiler/core/node.h"
+import "C"
+import (
+	"strconv"
+	"unsafe"
+)
+
+// NodeType is type of parser AST node, as in mruby node_type enum
+type NodeType int
+
+// node_type enum
+const (
+	NodeScope     = NodeType(C.NODE_SCOPE)
+	NodeBlock     = NodeType(C.NODE_BLOCK)
+	NodeIf        = NodeType(C.NODE_IF)
+	NodeCase      = NodeType(C.NODE_CASE)
+	NodeWhen      = NodeType(C.NODE_WHEN)
+	NodeWhile     = NodeType(C.NODE_WHILE)
+	NodeUntil     = NodeType(C.NODE_UNTIL)
+	NodeIter      = NodeType(C.NODE_ITER)
+	NodeFor       = NodeType(C.NODE_FOR)
+	NodeBreak     = NodeType(C.NODE_BREAK)
+	NodeNext      = NodeType(C.NODE_NEXT)
+	NodeRedo      = NodeType(C.NODE_REDO)
+	NodeRetry     = NodeType(C.NODE_RETRY)
+	NodeBegin     = NodeType(C.NODE_BEGIN)
+	NodeRescue    = NodeType(C.NODE_RESCUE)
+	NodeEnsure    = NodeType(C.NODE_ENSURE)
+	NodeAnd       = NodeType(C.NODE_AND)
+	NodeOr        = NodeType(C.NODE_OR)
+	NodeNot       = NodeType(C.NODE_NOT)
+	NodeMasgn     = NodeType(C.NODE_MASGN)
+	NodeAsgn      = NodeType(C.NODE_ASGN)
+	NodeCdecl     = NodeType(C.NODE_CDECL)
+	NodeCvasgn    = NodeType(C.NODE_CVASGN)
+	NodeOpAsgn    = NodeType(C.NODE_OP_ASGN)
+	NodeCall      = NodeType(C.NODE_CALL)
+	NodeSCall     = NodeType(C.NODE_SCALL)
+	NodeFCall     = NodeType(C.NODE_FCALL)
+	NodeSuper     = NodeType(C.NODE_SUPER)
+	NodeZSuper    = NodeType(C.NODE_ZSUPER)
+	NodeArray     = NodeType(C.NODE_ARRAY)
+	NodeZArray    = NodeType(C.NODE_ZARRAY)
+	NodeHash      = NodeType(C.NODE_HASH)
+	NodeReturn    = NodeType(C.NODE_RETURN)
+	NodeYield     = NodeType(C.NODE_YIELD)
+	NodeLVar      = NodeType(C.NODE_LVAR)
+	NodeDVar      = NodeType(C.NODE_DVAR)
+	NodeGVar      = NodeType(C.NODE_GVAR)
+	NodeIVar      = NodeType(C.NODE_IVAR)
+	NodeConst     = NodeType(C.NODE_CONST)
+	NodeCVar      = NodeType(C.NODE_CVAR)
+	NodeNthRef    = NodeType(C.NODE_NTH_REF)
+	NodeBackRef   = NodeType(C.NODE_BACK_REF)
+	NodeMatch     = NodeType(C.NODE_MATCH)
+	NodeInt       = NodeType(C.NODE_INT)
+	NodeFloat     = NodeType(C.NODE_FLOAT)
+	NodeNegate    = NodeType(C.NODE_NEGATE)
+	NodeLambda    = NodeType(C.NODE_LAMBDA)
+	NodeSym       = NodeType(C.NODE_SYM)
+	NodeStr       = NodeType(C.NODE_STR)
+	NodeDStr      = NodeType(C.NODE_DSTR)
+	NodeXStr      = NodeType(C.NODE_XSTR)
+	NodeDXStr     = NodeType(C.NODE_DXSTR)
+	NodeRegx      = NodeType(C.NODE_REGX)
+	NodeDRegx     = NodeType(C.NODE_DREGX)
+	NodeArg       = NodeType(C.NODE_ARG)
+	NodeSplat     = NodeType(C.NODE_SPLAT)
+	NodeBlockArg  = NodeType(C.NODE_BLOCK_ARG)
+	NodeDef       = NodeType(C.NODE_DEF)
+	NodeSDef      = NodeType(C.NODE_SDEF)
+	NodeAlias     = NodeType(C.NODE_ALIAS)
+	NodeUndef     = NodeType(C.NODE_UNDEF)
+	NodeClass     = NodeType(C.NODE_CLASS)
+	NodeModule    = NodeType(C.NODE_MODULE)
+	NodeSClass    = NodeType(C.NODE_SCLASS)
+	NodeColon2    = NodeType(C.NODE_COLON2)
+	NodeColon3    = NodeType(C.NODE_COLON3)
+	NodeDot2      = NodeType(C.NODE_DOT2)
+	NodeDot3      = NodeType(C.NODE_DOT3)
+	NodeSelf      = NodeType(C.NODE_SELF)
+	NodeNil       = NodeType(C.NODE_NIL)
+	NodeTrue      = NodeType(C.NODE_TRUE)
+	NodeFalse     = NodeType(C.NODE_FALSE)
+	NodeDefined   = NodeType(C.NODE_DEFINED)
+	NodePostExe   = NodeType(C.NODE_POSTEXE)
+	NodeDSym      = NodeType(C.NODE_DSYM)
+	NodeWords     = NodeType(C.NODE_WORDS)
+	NodeSymbols   = NodeType(C.NODE_SYMBOLS)
+	NodeHeredoc   = NodeType(C.NODE_HEREDOC)
+	nodeTypeLimit = NodeType(C.NODE_LAST)
+)
+
+var nodeTypeNames = map[NodeType]string{
+	NodeScope: "scope", NodeBlock: "block", NodeIf: "if", NodeCase: "case", NodeWhen: "when",
+	NodeWhile: "while", NodeUntil: "until", NodeIter: "iter", NodeFor: "for", NodeBreak: "break",
+	NodeNext: "next", NodeRedo: "redo", NodeRetry: "retry", NodeBegin: "begin", NodeRescue: "rescue",
+	NodeEnsure: "ensure", NodeAnd: "and", NodeOr: "or", NodeNot: "not", NodeMasgn: "masgn",
+	NodeAsgn: "asgn", NodeCdecl: "cdecl", NodeCvasgn: "cvasgn", NodeOpAsgn: "op_asgn",
+	NodeCall: "call", NodeSCall: "scall", NodeFCall: "fcall", NodeSuper: "super",
+	NodeZSuper: "zsuper", NodeArray: "array", NodeZArray: "zarray", NodeHash: "hash",
+	NodeReturn: "return", NodeYield: "yield", NodeLVar: "lvar", NodeDVar: "dvar",
+	NodeGVar: "gvar", NodeIVar: "ivar", NodeConst: "const", NodeCVar: "cvar",
+	NodeNthRef: "nth_ref", NodeBackRef: "back_ref", NodeMatch: "match", NodeInt: "int",
+	NodeFloat: "float", NodeNegate: "negate", NodeLambda: "lambda", NodeSym: "sym",
+	NodeStr: "str", NodeDStr: "dstr", NodeXStr: "xstr", NodeDXStr: "dxstr", NodeRegx: "regx",
+	NodeDRegx: "dregx", NodeArg: "arg", NodeSplat: "splat", NodeBlockArg: "block_arg",
+	NodeDef: "def", NodeSDef: "sdef", NodeAlias: "alias", NodeUndef: "undef",
+	NodeClass: "class", NodeModule: "module", NodeSClass: "sclass", NodeColon2: "colon2",
+	NodeColon3: "colon3", NodeDot2: "dot2", NodeDot3: "dot3", NodeSelf: "self", NodeNil: "nil",
+	NodeTrue: "true", NodeFalse: "false", NodeDefined: "defined", NodePostExe: "postexe",
+	NodeDSym: "dsym", NodeWords: "words", NodeSymbols: "symbols",
+	NodeHeredoc: "heredoc",
+}
+
+// String returns lowercase node type name, as in S-expression dump
+func (t NodeType) String() string {
+	if name, ok := nodeTypeNames[t]; ok {
+		return name
+	}
+	return "node" + strconv.Itoa(int(t))
+}
+
+// IsNode reports if AST cons cell is node, with node type in car.
+// Cons cells of lists have pointer or nil in car
+func (n *MrbAstNode) IsNode() bool {
+	t := uintptr(unsafe.Pointer(n.p.car))
+	return t > 0 && t < uintptr(nodeTypeLimit)
+}
+
+// NodeType returns type of AST node. Type is valid only when IsNode is true
+func (n *MrbAstNode) NodeType() NodeType { return NodeType(uintptr(unsafe.Pointer(n.p.car))) }
+
+// Len returns length of AST list starting with n
+func (n *MrbAstNode) Len() int {
+	l := 0
+	for ; n != nil; n = n.Cdr() {
+		l++
+	}
+	return l
+}
+
+// value returns raw value stored in car of AST cell, used for symbols and integers
+func (n *MrbAstNode) value() uintptr { return uintptr(unsafe.Pointer(n.p.car)) }
+
+// cdrValue returns raw value stored in cdr of AST cell
+func (n *MrbAstNode) cdrValue() uintptr { return uintptr(unsafe.Pointer(n.p.cdr)) }
+
+// cstr returns C string stored in car of AST cell
+func (n *MrbAstNode) cstr() string { return C.GoString((*C.char)(unsafe.Pointer(n.p.car))) }
+
+// cdrCstr returns C string stored in cdr of AST cell
+func (n *MrbAstNode) cdrCstr() string { return C.GoString((*C.char)(unsafe.Pointer(n.p.cdr))) }
+
+// cstrN returns C string of length l stored in car of AST cell
+func (n *MrbAstNode) cstrN(l int) string {
+	return C.GoStringN((*C.char)(unsafe.Pointer(n.p.car)), C.int(l))
+}
+
+// cell returns i-th cell of AST list, or nil
+func (n *MrbAstNode) cell(i int) *MrbAstNode {
+	for ; i > 0 && n != nil; i-- {
+		n = n.Cdr()
+	}
+	return n
+}
+
+// nth returns car of i-th cell of AST list, or nil
+func (n *MrbAstNode) nth(i int) *MrbAstNode {
+	if c := n.cell(i); c != nil {
+		return c.Car()
+	}
+	return nil
+}
+
+// heredoc returns string parts of heredoc node, which keeps heredoc info in cdr
+func (n *MrbAstNode) heredoc() *MrbAstNode {
+	info := (*C.struct_mrb_parser_heredoc_info)(unsafe.Pointer(n.p.cdr))
+	if info == nil {
+		return nil
+	}
+	return astNode(info.doc)
+}
+
+// FilenameAt returns filename from parser filename table, as referenced by AST nodes
+func (p MrbParserState) FilenameAt(index int) string {
+	mrb := p.State()
+	if p.p.filename_table == nil || index < 0 || index >= int(p.p.filename_table_length) {
+		return mrb.SymString(p.Filename())
+	}
+	table := unsafe.Slice(p.p.filename_table, int(p.p.filename_table_length))
+	return mrb.SymString(MrbSym(table[index]))
+}
@@ -0,0 +1,152 @@
+package oruby
+
+import (
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestParseAST(t *testing.T) {
+	mrb, _ := NewCore()
+	defer mrb.Close()
+
+	src := "class Order < Base\n  def total(tax)\n    items.sum * tax\n  end\nend\n\nLimit::MAX > 1 ? puts('ok') : nil\n"
+	tree, err := mrb.ParseAST(src, "order.rb")
+	ExpectNilError(t, err)
+
+	scope, ok := tree.(*AstScope)
+	Expect(t, ok, "scope expected, got %T", tree)
+	Expect(t, scope.Type() == NodeScope, "scope type expected, got %v", scope.Type())
+
+	var class *AstClass
+	var def *AstDef
+	var calls, consts []string
+	WalkAST(tree, func(n AstNode) bool {
+		switch n := n.(type) {
+		case *AstClass:
+			class = n
+		case *AstDef:
+			def = n
+		case *AstCall:
+			calls = append(calls, n.Name)
+		case *AstVar:
+			if n.Type() == NodeConst {
+				consts = append(consts, n.Name)
+			}
+		case *AstColon:
+			consts = append(consts, n.Name)
+		}
+		return true
+	})
+
+	Expect(t, class != nil, "class expected")
+	ExpectEql(t, class.Name, "Order")
+	ExpectEql(t, class.Pos(), AstPos{"order.rb", 1})
+	super, ok := class.Super.(*AstVar)
+	Expect(t, ok && super.Name == "Base", "superclass Base expected, got %v", DumpAST(class.Super))
+
+	Expect(t, def != nil, "def expected")
+	ExpectEql(t, def.Name, "total")
+	ExpectEql(t, def.Pos().Line, 2)
+	Expect(t, len(def.Locals) > 0 && def.Locals[0] == "tax", "tax local expected, got %v", def.Locals)
+
+	sort.Strings(calls)
+	sort.Strings(consts)
+	ExpectEql(t, calls, []string{"*", ">", "items", "puts", "sum"})
+	ExpectEql(t, consts, []string{"Base", "Limit", "MAX"})
+}
+
+func TestDumpAST(t *testing.T) {
+	mrb, _ := NewCore()
+	defer mrb.Close()
+
+	tree, err := mrb.ParseAST("a = 1\na + 2.5", "")
+	ExpectNilError(t, err)
+
+	dump := DumpAST(tree)
+	for _, s := range []string{"(scope", "(asgn (lvar a) (int 1))", "(call :+ (lvar a) (float 2.5))"} {
+		Expect(t, strings.Contains(dump, s), "dump should contain %v, got %v", s, dump)
+	}
+}
+
+func TestParseAST_Error(t *testing.T) {
+	mrb, _ := NewCore()
+	defer mrb.Close()
+
+	_, err := mrb.ParseAST("def x(", "broken.rb")
+	ExpectErr(t, err, "syntax error expected")
+}
+
+func TestParseAST_Rescue(t *testing.T) {
+	mrb, _ := NewCore()
+	defer mrb.Close()
+
+	src := "begin\n  foo\nrescue Err, Other => e\n  bar\nelse\n  baz\nensure\n  qux\nend\n" +
+		"def m(a, b = Default.x, *r)\n  y\nrescue\n  z\nend\n" +
+		"p, (q, *s) = list\n"
+	tree, err := mrb.ParseAST(src, "")
+	ExpectNilError(t, err)
+
+	var calls, consts, args []string
+	var rescue *AstRescue
+	var ensure *AstEnsure
+	var defaults int
+	WalkAST(tree, func(n AstNode) bool {
+		switch n := n.(type) {
+		case *AstCall:
+			calls = append(calls, n.Name)
+		case *AstVar:
+			if n.Type() == NodeConst {
+				consts = append(consts, n.Name)
+			}
+		case *AstArg:
+			args = append(args, n.Name)
+			if n.Default != nil {
+				defaults++
+			}
+		case *AstRescue:
+			if rescue == nil {
+				rescue = n
+			}
+		case *AstEnsure:
+			ensure = n
+		}
+		return true
+	})
+
+	sort.Strings(calls)
+	sort.Strings(consts)
+	ExpectEql(t, calls, []string{"bar", "baz", "foo", "list", "qux", "x", "y", "z"})
+	ExpectEql(t, consts, []string{"Default", "Err", "Other"})
+	ExpectEql(t, args, []string{"a", "b", "r"})
+	ExpectEql(t, defaults, 1)
+
+	Expect(t, ensure != nil, "ensure expected")
+	Expect(t, rescue != nil && len(rescue.Clauses) == 1, "rescue clause expected")
+	ExpectEql(t, len(rescue.Clauses[0].Classes), 2)
+	Expect(t, rescue.Clauses[0].Var != nil, "rescue variable expected")
+	Expect(t, rescue.Else != nil, "else expected")
+
+	dump := DumpAST(tree)
+	Expect(t, strings.Contains(dump, "(masgn (array (lvar p) (masgn (array (lvar q) (splat (lvar s)))))"),
+		"masgn targets expected, got %v", dump)
+}
+
+func TestParseAST_Strings(t *testing.T) {
+	mrb, _ := NewCore()
+	defer mrb.Close()
+
+	src := "r = /a#{one}b/\nw = %W(x #{two})\ns = %I(y #{three})\nh = <<~EOS\n  #{four}\nEOS\n"
+	tree, err := mrb.ParseAST(src, "")
+	ExpectNilError(t, err)
+
+	var calls []string
+	WalkAST(tree, func(n AstNode) bool {
+		if call, ok := n.(*AstCall); ok {
+			calls = append(calls, call.Name)
+		}
+		return true
+	})
+	sort.Strings(calls)
+	ExpectEql(t, calls, []string{"four", "one", "three", "two"})
+}
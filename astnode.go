@@ -0,0 +1,694 @@
+package oruby
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// AstPos is source position of AST node
+type AstPos struct {
+	Filename string
+	Line     int
+}
+
+func (pos AstPos) String() string { return fmt.Sprintf("%s:%d", pos.Filename, pos.Line) }
+
+// AstNode is typed parser AST node. Concrete node is one of Ast* structs, with node kind
+// returned by Type, as same struct is used for similar kinds, like AstVar for all variables
+type AstNode interface {
+	Type() NodeType
+	Pos() AstPos
+	Children() []AstNode
+}
+
+// astBase is common part of AST nodes
+type astBase struct {
+	kind NodeType
+	pos  AstPos
+}
+
+// Type returns node kind
+func (b *astBase) Type() NodeType { return b.kind }
+
+// Pos returns node source position
+func (b *astBase) Pos() AstPos { return b.pos }
+
+// AstScope is top level scope of script, with its local variables
+type AstScope struct {
+	astBase
+	Locals []string
+	Body   AstNode
+}
+
+// AstBegin is sequence of statements, or parenthesized expression
+type AstBegin struct {
+	astBase
+	Body []AstNode
+}
+
+// AstIf is if, unless, modifier if and ternary operator
+type AstIf struct {
+	astBase
+	Cond AstNode
+	Then AstNode
+	Else AstNode
+}
+
+// AstCase is case expression
+type AstCase struct {
+	astBase
+	Subject AstNode
+	Whens   []*AstWhen
+}
+
+// AstWhen is when clause of case expression. Else clause has no conditions
+type AstWhen struct {
+	astBase
+	Conds []AstNode
+	Body  AstNode
+}
+
+// AstLoop is while, until and for loop. Iter is iterated object of for loop
+type AstLoop struct {
+	astBase
+	Cond AstNode
+	Iter AstNode
+	Body AstNode
+}
+
+// AstLogical is && and || operator, and =~ match
+type AstLogical struct {
+	astBase
+	Left  AstNode
+	Right AstNode
+}
+
+// AstUnary is node with single expression, like not, negate, defined?, splat and block argument
+type AstUnary struct {
+	astBase
+	Expr AstNode
+}
+
+// AstAsgn is assignment. Op is operator of operator assignment, like "+" for +=
+type AstAsgn struct {
+	astBase
+	Target AstNode
+	Op     string
+	Value  AstNode
+}
+
+// AstCall is method call. Recv is self for function calls, and Safe is set for &. calls
+type AstCall struct {
+	astBase
+	Recv  AstNode
+	Name  string
+	Args  []AstNode
+	Block AstNode
+	Safe  bool
+}
+
+// AstSuper is super and yield call
+type AstSuper struct {
+	astBase
+	Args  []AstNode
+	Block AstNode
+}
+
+// AstJump is return, break, next, redo and retry
+type AstJump struct {
+	astBase
+	Value AstNode
+}
+
+// AstArray is array literal, %w and %i word lists and targets of multiple assignment
+type AstArray struct {
+	astBase
+	Elems []AstNode
+}
+
+// AstHash is hash literal, with values in order of keys
+type AstHash struct {
+	astBase
+	Keys   []AstNode
+	Values []AstNode
+}
+
+// AstVar is variable or constant reference: local, global, instance, class variable,
+// constant and regexp match references
+type AstVar struct {
+	astBase
+	Name string
+}
+
+// AstColon is scoped constant reference Base::Name, or ::Name when Base is nil
+type AstColon struct {
+	astBase
+	Base AstNode
+	Name string
+}
+
+// AstLiteral is number, string, symbol and regexp literal. Base is base of integer literal
+type AstLiteral struct {
+	astBase
+	Value string
+	Base  int
+}
+
+// AstKeyword is nil, true, false and self
+type AstKeyword struct{ astBase }
+
+// AstDStr is string, symbol, command and regexp with interpolation, and heredoc
+type AstDStr struct {
+	astBase
+	Parts []AstNode
+}
+
+// AstRange is range literal
+type AstRange struct {
+	astBase
+	Low  AstNode
+	High AstNode
+}
+
+// AstDef is method definition. Recv is set for singleton methods
+type AstDef struct {
+	astBase
+	Recv   AstNode
+	Name   string
+	Locals []string
+	Args   []AstNode
+	Body   AstNode
+}
+
+// AstArg is method or block parameter. Default is value of optional parameter, and rest
+// parameter has splat type. Destructured block parameters are masgn AstAsgn nodes
+type AstArg struct {
+	astBase
+	Name    string
+	Default AstNode
+}
+
+// AstRescue is body with rescue clauses, of begin block, method or block
+type AstRescue struct {
+	astBase
+	Body    AstNode
+	Clauses []*AstRescueClause
+	Else    AstNode
+}
+
+// AstRescueClause is rescue clause, with rescued exception classes and exception
+// variable, as in rescue A, B => e. Clause has rescue type
+type AstRescueClause struct {
+	astBase
+	Classes []AstNode
+	Var     AstNode
+	Body    AstNode
+}
+
+// AstEnsure is body with ensure clause
+type AstEnsure struct {
+	astBase
+	Body   AstNode
+	Ensure AstNode
+}
+
+// AstClass is class and module definition. Scope is set for A::B names
+type AstClass struct {
+	astBase
+	Scope  AstNode
+	Name   string
+	Super  AstNode
+	Locals []string
+	Body   AstNode
+}
+
+// AstSClass is singleton class definition, class << obj
+type AstSClass struct {
+	astBase
+	Object AstNode
+	Locals []string
+	Body   AstNode
+}
+
+// AstBlock is block and lambda body
+type AstBlock struct {
+	astBase
+	Locals []string
+	Args   []AstNode
+	Body   AstNode
+}
+
+// AstAlias is method alias
+type AstAlias struct {
+	astBase
+	New string
+	Old string
+}
+
+// AstUndef is undef of methods
+type AstUndef struct {
+	astBase
+	Names []string
+}
+
+// AstRaw is node which is not decoded, like literal delimiters of word lists
+type AstRaw struct{ astBase }
+
+// Children of nodes
+
+func (n *AstScope) Children() []AstNode { return astNodes(n.Body) }
+func (n *AstBegin) Children() []AstNode { return astNodes(n.Body...) }
+func (n *AstIf) Children() []AstNode    { return astNodes(n.Cond, n.Then, n.Else) }
+func (n *AstWhen) Children() []AstNode {
+	return astNodes(append(append([]AstNode(nil), n.Conds...), n.Body)...)
+}
+func (n *AstLoop) Children() []AstNode    { return astNodes(n.Cond, n.Iter, n.Body) }
+func (n *AstLogical) Children() []AstNode { return astNodes(n.Left, n.Right) }
+func (n *AstUnary) Children() []AstNode   { return astNodes(n.Expr) }
+func (n *AstAsgn) Children() []AstNode    { return astNodes(n.Target, n.Value) }
+func (n *AstJump) Children() []AstNode    { return astNodes(n.Value) }
+func (n *AstArray) Children() []AstNode   { return astNodes(n.Elems...) }
+func (n *AstVar) Children() []AstNode     { return nil }
+func (n *AstColon) Children() []AstNode   { return astNodes(n.Base) }
+func (n *AstLiteral) Children() []AstNode { return nil }
+func (n *AstKeyword) Children() []AstNode { return nil }
+func (n *AstDStr) Children() []AstNode    { return astNodes(n.Parts...) }
+func (n *AstRange) Children() []AstNode   { return astNodes(n.Low, n.High) }
+func (n *AstClass) Children() []AstNode   { return astNodes(n.Scope, n.Super, n.Body) }
+func (n *AstSClass) Children() []AstNode  { return astNodes(n.Object, n.Body) }
+func (n *AstArg) Children() []AstNode     { return astNodes(n.Default) }
+func (n *AstEnsure) Children() []AstNode  { return astNodes(n.Body, n.Ensure) }
+func (n *AstAlias) Children() []AstNode   { return nil }
+func (n *AstUndef) Children() []AstNode   { return nil }
+func (n *AstRaw) Children() []AstNode     { return nil }
+
+func (n *AstCase) Children() []AstNode {
+	nodes := astNodes(n.Subject)
+	for _, w := range n.Whens {
+		nodes = append(nodes, w)
+	}
+	return nodes
+}
+
+func (n *AstCall) Children() []AstNode {
+	return astNodes(append(append([]AstNode{n.Recv}, n.Args...), n.Block)...)
+}
+
+func (n *AstSuper) Children() []AstNode {
+	return astNodes(append(append([]AstNode(nil), n.Args...), n.Block)...)
+}
+
+func (n *AstDef) Children() []AstNode {
+	return astNodes(append(append([]AstNode{n.Recv}, n.Args...), n.Body)...)
+}
+
+func (n *AstBlock) Children() []AstNode {
+	return astNodes(append(append([]AstNode(nil), n.Args...), n.Body)...)
+}
+
+func (n *AstRescue) Children() []AstNode {
+	nodes := astNodes(n.Body)
+	for _, c := range n.Clauses {
+		nodes = append(nodes, c)
+	}
+	return astNodes(append(nodes, n.Else)...)
+}
+
+func (n *AstRescueClause) Children() []AstNode {
+	return astNodes(append(append([]AstNode(nil), n.Classes...), n.Var, n.Body)...)
+}
+
+func (n *AstHash) Children() []AstNode {
+	nodes := make([]AstNode, 0, 2*len(n.Keys))
+	for i := range n.Keys {
+		nodes = append(nodes, n.Keys[i], n.Values[i])
+	}
+	return astNodes(nodes...)
+}
+
+// astNodes returns nodes without nil nodes
+func astNodes(nodes ...AstNode) []AstNode {
+	ret := make([]AstNode, 0, len(nodes))
+	for _, n := range nodes {
+		if n != nil {
+			ret = append(ret, n)
+		}
+	}
+	return ret
+}
+
+// AST returns typed tree of parsed script, or nil if nothing is parsed
+func (p MrbParserState) AST() AstNode {
+	d := astDecoder{p, p.State()}
+	return d.node(p.Tree())
+}
+
+// ParseAST parses script and returns its typed tree
+func (mrb *MrbState) ParseAST(source, filename string) (AstNode, error) {
+	cxt := mrb.MrbcContextNew()
+	defer cxt.Free()
+	cxt.SetCaptureErrors(true)
+	if filename != "" {
+		cxt.Filename(filename)
+	}
+
+	p, err := mrb.ParseString(source, cxt)
+	if err != nil {
+		return nil, err
+	}
+	defer p.Free()
+
+	if err := parserErrors(p, filename); err != nil {
+		return nil, ESyntaxError("%v", err)
+	}
+	return p.AST(), nil
+}
+
+// astDecoder converts raw parser cons cells into typed nodes
+type astDecoder struct {
+	p   MrbParserState
+	mrb *MrbState
+}
+
+func (d *astDecoder) sym(n *MrbAstNode) string {
+	if n == nil || n.value() == 0 {
+		return ""
+	}
+	return d.mrb.SymString(MrbSym(n.value()))
+}
+
+// syms returns names from list of symbols, as in locals list
+func (d *astDecoder) syms(n *MrbAstNode) []string {
+	var names []string
+	for ; n != nil; n = n.Cdr() {
+		if name := d.sym(n); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// list decodes list of nodes
+func (d *astDecoder) list(n *MrbAstNode) []AstNode {
+	var nodes []AstNode
+	for ; n != nil; n = n.Cdr() {
+		if node := d.node(n.Car()); node != nil {
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes
+}
+
+// args decodes call arguments (args . block), where block may be preceded by keyword arguments
+func (d *astDecoder) args(n *MrbAstNode) ([]AstNode, AstNode) {
+	if n == nil {
+		return nil, nil
+	}
+	args := d.list(n.Car())
+	rest := n.Cdr()
+	if rest != nil && !rest.IsNode() {
+		if kw := d.node(rest.Car()); kw != nil {
+			args = append(args, kw)
+		}
+		rest = rest.Cdr()
+	}
+	return args, d.node(rest)
+}
+
+// params decodes parameters (m . (opt . (rest . (m2 . tail)))) of method or block.
+// Keyword and block parameters in tail are not decoded
+func (d *astDecoder) params(n *MrbAstNode) []AstNode {
+	if n == nil {
+		return nil
+	}
+	params := d.reqParams(n.Car())
+	for opt := n.nth(1); opt != nil; opt = opt.Cdr() {
+		// (name . value), or (name . (locals . value)) when default value has own locals
+		item := opt.Car()
+		value := item.Cdr()
+		if value != nil && !value.IsNode() {
+			value = value.Cdr()
+		}
+		params = append(params, &AstArg{astBase{NodeArg, d.pos(item)}, d.sym(item), d.node(value)})
+	}
+	if rest := n.cell(2); rest != nil && rest.value() != 0 {
+		name := d.sym(rest)
+		if name == "" {
+			name = "*"
+		}
+		params = append(params, &AstArg{astBase: astBase{NodeSplat, d.pos(rest)}, Name: name})
+	}
+	return append(params, d.reqParams(n.nth(3))...)
+}
+
+// reqParams decodes required parameters, which are arg nodes, or (masgn mlhs . locals)
+// for destructured parameters
+func (d *astDecoder) reqParams(n *MrbAstNode) []AstNode {
+	var params []AstNode
+	for ; n != nil; n = n.Cdr() {
+		item := n.Car()
+		if item != nil && item.IsNode() && item.NodeType() == NodeMasgn {
+			params = append(params, &AstAsgn{astBase: astBase{NodeMasgn, d.pos(item)}, Target: d.mlhs(item.nth(1), item)})
+		} else if param := d.node(item); param != nil {
+			params = append(params, param)
+		}
+	}
+	return params
+}
+
+// mlhs decodes targets (pre rest post) of multiple assignment into array, with splat
+// for rest target. Anonymous rest target is -1
+func (d *astDecoder) mlhs(n, at *MrbAstNode) AstNode {
+	targets := &AstArray{astBase{NodeArray, d.pos(at)}, d.list(n.nth(0))}
+	if rest := n.cell(1); rest != nil && rest.value() != 0 {
+		splat := &AstUnary{astBase: astBase{NodeSplat, d.pos(at)}}
+		if rest.value() != ^uintptr(0) {
+			splat.Expr = d.node(rest.Car())
+		}
+		targets.Elems = append(targets.Elems, splat)
+	}
+	targets.Elems = append(targets.Elems, d.list(n.nth(2))...)
+	return targets
+}
+
+// node decodes single node, recursively
+func (d *astDecoder) node(n *MrbAstNode) AstNode {
+	if n == nil {
+		return nil
+	}
+	if !n.IsNode() {
+		// parenthesized list of nodes
+		return &AstBegin{astBase{NodeBegin, d.pos(n)}, d.list(n)}
+	}
+
+	b := astBase{n.NodeType(), d.pos(n)}
+	switch b.kind {
+	case NodeScope:
+		return &AstScope{b, d.syms(n.nth(1)), d.node(n.cell(1).Cdr())}
+	case NodeBegin:
+		return &AstBegin{b, d.list(n.Cdr())}
+	case NodeIf:
+		return &AstIf{b, d.node(n.nth(1)), d.node(n.nth(2)), d.node(n.nth(3))}
+	case NodeCase:
+		c := &AstCase{astBase: b, Subject: d.node(n.nth(1))}
+		for w := n.cell(1).Cdr(); w != nil; w = w.Cdr() {
+			clause := w.Car()
+			c.Whens = append(c.Whens, &AstWhen{astBase{NodeWhen, d.pos(clause)}, d.list(clause.Car()), d.node(clause.Cdr())})
+		}
+		return c
+	case NodeWhile, NodeUntil:
+		return &AstLoop{astBase: b, Cond: d.node(n.nth(1)), Body: d.node(n.cell(1).Cdr())}
+	case NodeFor:
+		return &AstLoop{astBase: b, Iter: d.node(n.nth(2)), Body: d.node(n.nth(3))}
+	case NodeAnd, NodeOr:
+		return &AstLogical{b, d.node(n.nth(1)), d.node(n.cell(1).Cdr())}
+	case NodeNot, NodeNegate, NodeDefined, NodeSplat, NodeBlockArg, NodePostExe:
+		return &AstUnary{b, d.node(n.Cdr())}
+	case NodeAsgn:
+		return &AstAsgn{astBase: b, Target: d.node(n.nth(1)), Value: d.node(n.cell(1).Cdr())}
+	case NodeMasgn:
+		return &AstAsgn{astBase: b, Target: d.mlhs(n.nth(1), n), Value: d.node(n.cell(1).Cdr())}
+	case NodeRescue:
+		// (rescue body clauses else), with clause (classes var body)
+		r := &AstRescue{astBase: b, Body: d.node(n.nth(1)), Else: d.node(n.nth(3))}
+		for c := n.nth(2); c != nil; c = c.Cdr() {
+			clause := c.Car()
+			r.Clauses = append(r.Clauses, &AstRescueClause{astBase{NodeRescue, d.pos(clause)},
+				d.list(clause.nth(0)), d.node(clause.nth(1)), d.node(clause.nth(2))})
+		}
+		return r
+	case NodeEnsure:
+		// (ensure body nil . ensure)
+		return &AstEnsure{b, d.node(n.nth(1)), d.node(n.cell(2).Cdr())}
+	case NodeMatch:
+		return &AstLogical{b, d.node(n.nth(1)), d.node(n.cell(1).Cdr())}
+	case NodeOpAsgn:
+		return &AstAsgn{b, d.node(n.nth(1)), d.sym(n.cell(2)), d.node(n.nth(3))}
+	case NodeCdecl, NodeCvasgn:
+		return &AstAsgn{astBase: b, Target: d.node(n.nth(1)), Value: d.node(n.cell(1).Cdr())}
+	case NodeCall, NodeSCall, NodeFCall:
+		args, block := d.args(n.nth(3))
+		return &AstCall{b, d.node(n.nth(1)), d.sym(n.cell(2)), args, block, b.kind == NodeSCall}
+	case NodeSuper, NodeZSuper:
+		args, block := d.args(n.Cdr())
+		return &AstSuper{b, args, block}
+	case NodeYield:
+		return &AstSuper{astBase: b, Args: d.list(n.Cdr())}
+	case NodeReturn, NodeBreak, NodeNext:
+		return &AstJump{b, d.node(n.Cdr())}
+	case NodeRedo, NodeRetry:
+		return &AstJump{astBase: b}
+	case NodeArray:
+		return &AstArray{b, d.list(n.Cdr())}
+	case NodeZArray:
+		return &AstArray{astBase: b}
+	case NodeHash:
+		h := &AstHash{astBase: b}
+		for pair := n.Cdr(); pair != nil; pair = pair.Cdr() {
+			h.Keys = append(h.Keys, d.node(pair.Car().Car()))
+			h.Values = append(h.Values, d.node(pair.Car().Cdr()))
+		}
+		return h
+	case NodeLVar, NodeDVar, NodeGVar, NodeIVar, NodeCVar, NodeConst:
+		return &AstVar{b, d.mrb.SymString(MrbSym(n.cdrValue()))}
+	case NodeNthRef:
+		return &AstVar{b, "$" + strconv.Itoa(int(n.cdrValue()))}
+	case NodeBackRef:
+		return &AstVar{b, "$" + string(rune(n.cdrValue()))}
+	case NodeColon2:
+		return &AstColon{b, d.node(n.nth(1)), d.mrb.SymString(MrbSym(n.cell(1).cdrValue()))}
+	case NodeColon3:
+		return &AstColon{astBase: b, Name: d.mrb.SymString(MrbSym(n.cdrValue()))}
+	case NodeInt:
+		return &AstLiteral{b, n.cell(1).cstr(), int(n.cell(2).value())}
+	case NodeFloat:
+		return &AstLiteral{astBase: b, Value: n.cdrCstr()}
+	case NodeStr, NodeXStr:
+		s := n.Cdr()
+		return &AstLiteral{astBase: b, Value: s.cstrN(int(s.cdrValue()))}
+	case NodeSym:
+		return &AstLiteral{astBase: b, Value: d.mrb.SymString(MrbSym(n.cdrValue()))}
+	case NodeRegx:
+		return &AstLiteral{astBase: b, Value: n.cell(1).cstr()}
+	case NodeDStr, NodeDXStr:
+		return &AstDStr{b, d.list(n.Cdr())}
+	case NodeDRegx:
+		return &AstDStr{b, d.list(n.nth(1))}
+	case NodeHeredoc:
+		return &AstDStr{b, d.list(n.heredoc())}
+	case NodeWords, NodeSymbols:
+		return &AstArray{b, d.list(n.Cdr())}
+	case NodeDSym:
+		if s, ok := d.node(n.Cdr()).(*AstDStr); ok {
+			return &AstDStr{b, s.Parts}
+		}
+		return &AstDStr{astBase: b}
+	case NodeDot2, NodeDot3:
+		return &AstRange{b, d.node(n.nth(1)), d.node(n.cell(1).Cdr())}
+	case NodeNil, NodeTrue, NodeFalse, NodeSelf:
+		return &AstKeyword{b}
+	case NodeDef:
+		return &AstDef{b, nil, d.sym(n.cell(1)), d.syms(n.nth(2)), d.params(n.nth(3)), d.node(n.nth(4))}
+	case NodeSDef:
+		return &AstDef{b, d.node(n.nth(1)), d.sym(n.cell(2)), d.syms(n.nth(3)), d.params(n.nth(4)), d.node(n.nth(5))}
+	case NodeClass, NodeModule:
+		// (class cpath super (locals . body)), (module cpath (locals . body))
+		cpath, body, super := n.nth(1), n.nth(2), AstNode(nil)
+		if b.kind == NodeClass {
+			super, body = d.node(n.nth(2)), n.nth(3)
+		}
+		var scope AstNode
+		if cpath.value() > 1 {
+			scope = d.node(cpath.Car())
+		}
+		return &AstClass{b, scope, d.mrb.SymString(MrbSym(cpath.cdrValue())), super, d.syms(body.Car()), d.node(body.Cdr())}
+	case NodeSClass:
+		body := n.nth(2)
+		return &AstSClass{b, d.node(n.nth(1)), d.syms(body.Car()), d.node(body.Cdr())}
+	case NodeBlock, NodeIter, NodeLambda:
+		return &AstBlock{b, d.syms(n.nth(1)), d.params(n.nth(2)), d.node(n.nth(3))}
+	case NodeArg:
+		return &AstArg{astBase: b, Name: d.mrb.SymString(MrbSym(n.cdrValue()))}
+	case NodeAlias:
+		names := n.Cdr()
+		return &AstAlias{b, d.sym(names), d.mrb.SymString(MrbSym(names.cdrValue()))}
+	case NodeUndef:
+		return &AstUndef{b, d.syms(n.Cdr())}
+	default:
+		return &AstRaw{b}
+	}
+}
+
+func (d *astDecoder) pos(n *MrbAstNode) AstPos {
+	return AstPos{d.p.FilenameAt(n.FilenameIndex()), n.LineNo()}
+}
+
+// WalkAST calls f for node and its children, depth first.
+// Children of node are skipped when f returns false
+func WalkAST(node AstNode, f func(AstNode) bool) {
+	if node == nil || !f(node) {
+		return
+	}
+	for _, child := range node.Children() {
+		WalkAST(child, f)
+	}
+}
+
+// DumpAST returns S-expression of node, for debugging
+func DumpAST(node AstNode) string {
+	var sb strings.Builder
+	dumpAST(&sb, node)
+	return sb.String()
+}
+
+func dumpAST(sb *strings.Builder, node AstNode) {
+	if node == nil {
+		sb.WriteString("nil")
+		return
+	}
+
+	sb.WriteString("(")
+	sb.WriteString(node.Type().String())
+	atom := func(s string) {
+		sb.WriteString(" ")
+		sb.WriteString(s)
+	}
+	switch n := node.(type) {
+	case *AstCall:
+		atom(":" + n.Name)
+	case *AstVar:
+		atom(n.Name)
+	case *AstColon:
+		atom(":" + n.Name)
+	case *AstLiteral:
+		if n.Type() == NodeSym {
+			atom(":" + n.Value)
+		} else if n.Type() == NodeInt || n.Type() == NodeFloat {
+			atom(n.Value)
+		} else {
+			atom(strconv.Quote(n.Value))
+		}
+	case *AstAsgn:
+		if n.Op != "" {
+			atom(":" + n.Op)
+		}
+	case *AstDef:
+		atom(":" + n.Name)
+	case *AstArg:
+		atom(n.Name)
+	case *AstClass:
+		atom(":" + n.Name)
+	case *AstAlias:
+		atom(":" + n.New + " :" + n.Old)
+	case *AstUndef:
+		for _, name := range n.Names {
+			atom(":" + name)
+		}
+	}
+	for _, child := range node.Children() {
+		sb.WriteString(" ")
+		dumpAST(sb, child)
+	}
+	sb.WriteString(")")
+}
@@ -0,0 +1,184 @@
+package oruby
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// bundleMagic identifies signed bundle of compiled scripts
+const bundleMagic = "ORBUNDL1"
+
+// ErrBundleSignature is returned by LoadBundle for unsigned or tampered bundles,
+// and bundles not signed by any of trusted keys
+var ErrBundleSignature = errors.New("bundle signature is not valid")
+
+// BundleManifest describes bundle content
+type BundleManifest struct {
+	Name    string   `json:"name"`
+	Version string   `json:"version"`
+	Gems    []string `json:"gems,omitempty"` // gems required before scripts are run
+}
+
+// BundleScript is named script of bundle
+type BundleScript struct {
+	Name    string
+	Program *Program
+}
+
+// Bundle is set of precompiled scripts with manifest, distributed as signed binary.
+// Bundle layout is magic, manifest JSON, scripts RITE bytecode and ed25519 signature
+// of all previous bytes. Lengths are big endian, as in RITE format
+type Bundle struct {
+	Manifest BundleManifest
+	Scripts  []BundleScript
+}
+
+// NewBundle creates empty bundle with manifest
+func NewBundle(manifest BundleManifest) *Bundle {
+	return &Bundle{Manifest: manifest}
+}
+
+// Add adds compiled script to bundle. Scripts are run in order they are added
+func (b *Bundle) Add(name string, prog *Program) {
+	b.Scripts = append(b.Scripts, BundleScript{name, prog})
+}
+
+// Script returns program of named script, or nil if bundle has no such script
+func (b *Bundle) Script(name string) *Program {
+	for _, s := range b.Scripts {
+		if s.Name == name {
+			return s.Program
+		}
+	}
+	return nil
+}
+
+// Sign encodes bundle and signs it with private key
+func (b *Bundle) Sign(key ed25519.PrivateKey) ([]byte, error) {
+	if len(key) != ed25519.PrivateKeySize {
+		return nil, EArgumentError("invalid ed25519 private key size %d", len(key))
+	}
+
+	manifest, err := json.Marshal(b.Manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(bundleMagic)
+	binary.Write(&buf, binary.BigEndian, uint32(len(manifest)))
+	buf.Write(manifest)
+	binary.Write(&buf, binary.BigEndian, uint32(len(b.Scripts)))
+	for _, s := range b.Scripts {
+		if len(s.Name) > 0xffff {
+			return nil, EArgumentError("script name too long: %.32v...", s.Name)
+		}
+		binary.Write(&buf, binary.BigEndian, uint16(len(s.Name)))
+		buf.WriteString(s.Name)
+		binary.Write(&buf, binary.BigEndian, uint32(len(s.Program.code)))
+		buf.Write(s.Program.code)
+	}
+
+	buf.Write(ed25519.Sign(key, buf.Bytes()))
+	return buf.Bytes(), nil
+}
+
+// LoadBundle decodes bundle, checking it is signed by one of trusted keys and
+// that bytecode of its scripts is valid. Unsigned and tampered bundles are refused
+func LoadBundle(data []byte, trustedKeys []ed25519.PublicKey) (*Bundle, error) {
+	if len(data) < len(bundleMagic)+ed25519.SignatureSize || string(data[:len(bundleMagic)]) != bundleMagic {
+		return nil, errors.New("data is not oruby bundle")
+	}
+
+	body, sig := data[:len(data)-ed25519.SignatureSize], data[len(data)-ed25519.SignatureSize:]
+	trusted := false
+	for _, key := range trustedKeys {
+		if len(key) == ed25519.PublicKeySize && ed25519.Verify(key, body, sig) {
+			trusted = true
+			break
+		}
+	}
+	if !trusted {
+		return nil, ErrBundleSignature
+	}
+
+	r := bytes.NewReader(body[len(bundleMagic):])
+	chunk := func(size int) ([]byte, error) {
+		if size > r.Len() {
+			return nil, errors.New("truncated bundle")
+		}
+		p := make([]byte, size)
+		r.Read(p)
+		return p, nil
+	}
+
+	var n32 uint32
+	var n16 uint16
+	b := &Bundle{}
+
+	if err := binary.Read(r, binary.BigEndian, &n32); err != nil {
+		return nil, errors.New("truncated bundle")
+	}
+	manifest, err := chunk(int(n32))
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(manifest, &b.Manifest); err != nil {
+		return nil, fmt.Errorf("bundle manifest: %v", err)
+	}
+
+	if err := binary.Read(r, binary.BigEndian, &n32); err != nil {
+		return nil, errors.New("truncated bundle")
+	}
+	for i := uint32(0); i < n32; i++ {
+		if err := binary.Read(r, binary.BigEndian, &n16); err != nil {
+			return nil, errors.New("truncated bundle")
+		}
+		name, err := chunk(int(n16))
+		if err != nil {
+			return nil, err
+		}
+
+		var size uint32
+		if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+			return nil, errors.New("truncated bundle")
+		}
+		code, err := chunk(int(size))
+		if err != nil {
+			return nil, err
+		}
+		if err := VerifyIrep(code); err != nil {
+			return nil, fmt.Errorf("bundle script %s: %w", name, err)
+		}
+		b.Add(string(name), &Program{filename: string(name), code: code})
+	}
+
+	if r.Len() != 0 {
+		return nil, errors.New("unexpected data after bundle scripts")
+	}
+	return b, nil
+}
+
+// Run requires gems listed in manifest and runs bundle scripts in order.
+// Result of last script is returned
+func (b *Bundle) Run(mrb *MrbState) (Value, error) {
+	for _, gem := range b.Manifest.Gems {
+		if _, err := mrb.Resolve(gem); err != nil {
+			return nilValue, fmt.Errorf("bundle %s requires gem %s: %v", b.Manifest.Name, gem, err)
+		}
+	}
+
+	ret := mrb.NilValue()
+	for _, s := range b.Scripts {
+		v, err := s.Program.Run(mrb, nil)
+		if err != nil {
+			return nilValue, err
+		}
+		ret = v
+	}
+	return ret, nil
+}
@@ -0,0 +1,81 @@
+package oruby
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"testing"
+)
+
+func signedBundle(t *testing.T, key ed25519.PrivateKey) []byte {
+	t.Helper()
+	lib, err := Compile("def greet(name)\n  \"hello #{name}\"\nend", "lib.rb")
+	ExpectNilError(t, err)
+	main, err := Compile("greet('edge')", "main.rb")
+	ExpectNilError(t, err)
+
+	b := NewBundle(BundleManifest{Name: "greeter", Version: "1.0.0"})
+	b.Add("lib.rb", lib)
+	b.Add("main.rb", main)
+
+	data, err := b.Sign(key)
+	ExpectNilError(t, err)
+	return data
+}
+
+func TestLoadBundle(t *testing.T) {
+	pub, key, _ := ed25519.GenerateKey(rand.Reader)
+	data := signedBundle(t, key)
+
+	b, err := LoadBundle(data, []ed25519.PublicKey{pub})
+	ExpectNilError(t, err)
+	ExpectEql(t, b.Manifest, BundleManifest{Name: "greeter", Version: "1.0.0"})
+	ExpectEql(t, len(b.Scripts), 2)
+	Expect(t, b.Script("main.rb") != nil, "main.rb script expected")
+
+	mrb, _ := NewCore()
+	defer mrb.Close()
+
+	v, err := b.Run(mrb)
+	ExpectNilError(t, err)
+	ExpectEql(t, v.String(), "hello edge")
+}
+
+func TestLoadBundle_Refused(t *testing.T) {
+	pub, key, _ := ed25519.GenerateKey(rand.Reader)
+	other, _, _ := ed25519.GenerateKey(rand.Reader)
+	data := signedBundle(t, key)
+
+	_, err := LoadBundle(data, nil)
+	Expect(t, errors.Is(err, ErrBundleSignature), "bundle without trusted keys should be refused, got %v", err)
+
+	_, err = LoadBundle(data, []ed25519.PublicKey{other})
+	Expect(t, errors.Is(err, ErrBundleSignature), "bundle signed by untrusted key should be refused, got %v", err)
+
+	tampered := append([]byte(nil), data...)
+	tampered[len(tampered)/2] ^= 0xff
+	_, err = LoadBundle(tampered, []ed25519.PublicKey{pub})
+	Expect(t, errors.Is(err, ErrBundleSignature), "tampered bundle should be refused, got %v", err)
+
+	_, err = LoadBundle(data[:len(data)-ed25519.SignatureSize], []ed25519.PublicKey{pub})
+	ExpectErr(t, err, "unsigned bundle should be refused")
+}
+
+func TestBundle_RunRequiresGems(t *testing.T) {
+	pub, key, _ := ed25519.GenerateKey(rand.Reader)
+	prog, err := Compile("1", "one.rb")
+	ExpectNilError(t, err)
+
+	b := NewBundle(BundleManifest{Name: "needs", Gems: []string{"no-such-gem"}})
+	b.Add("one.rb", prog)
+	data, err := b.Sign(key)
+	ExpectNilError(t, err)
+
+	loaded, err := LoadBundle(data, []ed25519.PublicKey{pub})
+	ExpectNilError(t, err)
+
+	mrb, _ := NewCore()
+	defer mrb.Close()
+	_, err = loaded.Run(mrb)
+	ExpectErr(t, err, "missing gem should fail")
+}
@@ -49,7 +49,7 @@ func RClassSuper(c RClass) RClass { return RClass{c.p.super, c.mrb} }
 // ClassOf returns class of value as RClass
 // Note: this function calls mrb_class API
 func (mrb *MrbState) ClassOf(v MrbValue) RClass {
-	return RClass{C.mrb_class(mrb.p, v.Value().v), mrb}
+	return RClass{C.mrb_class(mrb.state(), v.Value().v), mrb}
 }
 
 // ClassPtr returns class of value as RClass
@@ -57,7 +57,7 @@ func (mrb *MrbState) ClassPtr(v Value) RClass {
 	if v.Type() == MrbTTClass || v.Type() == MrbTTModule || v.Type() == MrbTTSClass {
 		return RClass{(*C.struct_RClass)(C._mrb_ptr(v.Value().v)), mrb}
 	}
-	return RClass{C.mrb_class(mrb.p, v.v), mrb}
+	return RClass{C.mrb_class(mrb.state(), v.v), mrb}
 }
 
 // Class returns class of value as RClass
@@ -90,23 +90,23 @@ func MrbInstanceTT(c RClass) Type { return Type(C._MRB_INSTANCE_TT(c.p)) }
 
 // DefineClassID define class by symbol
 func (mrb *MrbState) DefineClassID(id MrbSym, c RClass) RClass {
-	return RClass{C.mrb_define_class_id(mrb.p, C.mrb_sym(id), c.p), mrb}
+	return RClass{C.mrb_define_class_id(mrb.state(), C.mrb_sym(id), c.p), mrb}
 }
 
 // DefineModuleID define module by symbol
 func (mrb *MrbState) DefineModuleID(id MrbSym) RClass {
-	return RClass{C.mrb_define_module_id(mrb.p, C.mrb_sym(id)), mrb}
+	return RClass{C.mrb_define_module_id(mrb.state(), C.mrb_sym(id)), mrb}
 }
 
 // DefineMethodRaw define method via symbol and RProc
 func (mrb *MrbState) DefineMethodRaw(c RClass, id MrbSym, methodID MrbMethodT) {
-	C.mrb_define_method_raw(mrb.p, c.p, C.mrb_sym(id), methodID.m)
+	C.mrb_define_method_raw(mrb.state(), c.p, C.mrb_sym(id), methodID.m)
 }
 
 // DefineMethodID define method via symbol and function type
 func (mrb *MrbState) DefineMethodID(c RClass, mid MrbSym, f MrbFuncT, aspec MrbAspec) {
 	idx := mrb.registerFuncIndex(f)
-	C._mrb_method_new_cfunc(mrb.p, c.p, C.mrb_sym(mid), C.int(idx), C.mrb_aspec(aspec))
+	C._mrb_method_new_cfunc(mrb.state(), c.p, C.mrb_sym(mid), C.int(idx), C.mrb_aspec(aspec))
 }
 
 // DefineMethodFuncID Define method as oruby func
@@ -132,7 +132,7 @@ func (mrb *MrbState) DefineMethodFuncID(c RClass, mid MrbSym, f interface{}) {
 		env = mrb.registerFuncIndex(func() interface{} { return f })
 	}
 
-	C._mrb_proc_new_cfunc(mrb.p, c.p, C.mrb_sym(mid), C.int(env), C.mrb_aspec(aspec))
+	C._mrb_proc_new_cfunc(mrb.state(), c.p, C.mrb_sym(mid), C.int(env), C.mrb_aspec(aspec))
 	// C.mrb_define_method_id() never called
 }
 
@@ -143,17 +143,17 @@ func (mrb *MrbState) DefineClassFuncID(klass RClass, mid MrbAspec, f interface{}
 
 // AliasMethod creates method alias
 func (mrb *MrbState) AliasMethod(c RClass, a, b MrbSym) {
-	C.mrb_alias_method(mrb.p, c.p, C.mrb_sym(a), C.mrb_sym(b))
+	C.mrb_alias_method(mrb.state(), c.p, C.mrb_sym(a), C.mrb_sym(b))
 }
 
 // RemoveMethod removes method from class
 func (mrb *MrbState) RemoveMethod(c RClass, sym MrbSym) {
-	C.mrb_remove_method(mrb.p, c.p, C.mrb_sym(sym))
+	C.mrb_remove_method(mrb.state(), c.p, C.mrb_sym(sym))
 }
 
 // MethodSearchVM finds VM method, method is invalid if not found
 func (mrb *MrbState) MethodSearchVM(cl RClass, id MrbSym) MrbMethodT {
-	return MrbMethodT{C.mrb_method_search_vm(mrb.p, &(cl.p), C.mrb_sym(id))}
+	return MrbMethodT{C.mrb_method_search_vm(mrb.state(), &(cl.p), C.mrb_sym(id))}
 }
 
 // MethodSearch find method using symbol, and error if not found
@@ -46,101 +46,6 @@ func p(mrb *oruby.MrbState, obj oruby.MrbValue, prompt int) {
 	println(mrb.String(val))
 }
 
-// * Guess if the user might want to enter more
-// * or if he wants an evaluation of his code now */
-func isCodeBlockOpen(parser oruby.MrbParserState) bool {
-	codeBlockOpen := false
-
-	// check for heredoc
-	if parser.ParsingHeredoc() != nil {
-		return true
-	}
-
-	// check for unterminated string
-	if parser.LexStrTerm() {
-		return true
-	}
-
-	// check if parser error are available
-	if parser.NErr() > 0 {
-		unexpectedEnd := "syntax error, unexpected $end"
-		message := parser.ErrorBuffer(0).Message
-
-		/* a parser error occur, we have to check if */
-		/* we need to read one more line or if there is */
-		/* a different issue which we have to show to */
-		/* the user */
-
-		switch message {
-		case unexpectedEnd:
-			codeBlockOpen = true
-		case "syntax error, unexpected keyword_end":
-			codeBlockOpen = false
-		case "syntax error, unexpected tREGEXP_BEG":
-			codeBlockOpen = false
-		}
-		return codeBlockOpen
-	}
-
-	switch parser.LState() {
-	/* all states which need more code */
-
-	case oruby.ExprBeg:
-		/* beginning of a statement, */
-		/* that means previous line ended */
-		codeBlockOpen = false
-		break
-	case oruby.ExprDot:
-		/* a message dot was the last token, */
-		/* there has to come more */
-		codeBlockOpen = true
-		break
-	case oruby.ExprClass:
-		/* a class keyword is not enough! */
-		/* we need also a name of the class */
-		codeBlockOpen = true
-		break
-	case oruby.ExprFname:
-		/* a method name is necessary */
-		codeBlockOpen = true
-		break
-	case oruby.ExprValue:
-		/* if, elsif, etc. without condition */
-		codeBlockOpen = true
-		break
-
-		/* now all the states which are closed */
-	case oruby.ExprArg:
-		/* an argument is the last token */
-		codeBlockOpen = false
-		break
-
-	/* all states which are unsure */
-	case oruby.ExprCmdarg:
-		break
-	case oruby.ExprEnd:
-		/* an expression was ended */
-		break
-	case oruby.ExprEndarg:
-		/* closing parenthese */
-		break
-	case oruby.ExprEndfn:
-		/* definition end */
-		break
-	case oruby.ExprMid:
-		/* jump keyword like break, return, ... */
-		break
-	case oruby.ExprMaxState:
-		/* don't know what to do with this token */
-		break
-	default:
-		/* this state is unexpected! */
-		break
-	}
-
-	return codeBlockOpen
-}
-
 type Args struct {
 	rfp     string
 	alib    string
@@ -380,22 +285,17 @@ func main() {
 		strFree := parser.SetS(rubyCode)
 		parser.SetLineNo(cxt.LineNo())
 		parser.Parse(cxt)
-		codeBlockOpen = isCodeBlockOpen(parser)
+		codeBlockOpen = parser.IsCodeBlockOpen()
 		strFree()
 
 		if codeBlockOpen {
 			/* no evaluation of code */
 		} else {
-			if parser.NWarn() > 0 {
-				/* warning */
-				warn := parser.WarnBuffer(0)
-				fmt.Printf("line %d: %s\n", warn.LineNo, warn.Message)
+			/* warnings and syntax errors */
+			for _, d := range parser.Diagnostics() {
+				fmt.Printf("line %d: %s\n", d.Line, d.Message)
 			}
-			if parser.NErr() > 0 {
-				/* syntax error */
-				er := parser.ErrorBuffer(0)
-				fmt.Printf("line %d: %s\n", er.LineNo, er.Message)
-			} else {
+			if parser.NErr() == 0 {
 				/* generate bytecode */
 				proc, err := mrb.GenerateCode(parser)
 				if err != nil {
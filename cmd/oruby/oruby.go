@@ -20,6 +20,10 @@ type Args struct {
 	verbose     bool
 	debug       bool
 	libs        []string
+	profile     string
+	coverage    string
+	coverFormat string
+	dap         string
 	// args      []string // os.Args used instead
 	eline string
 }
@@ -34,6 +38,10 @@ func usage(name string) {
 		"-r library   load the library before executing your script",
 		"-v           print version number, then run in verbose mode",
 		"--verbose    run in verbose mode",
+		"--profile f  write pprof CPU profile of script to file f",
+		"--coverage f write coverage of script to file f on exit",
+		"--coverage-format lcov|cover  format of coverage file (default lcov)",
+		"--dap addr   debug script with Debug Adapter Protocol client on stdio or local TCP addr",
 		"--version    print the version",
 		"--copyright  print the copyright",
 	}
@@ -52,6 +60,10 @@ func parseArgs(mrb *oruby.MrbState, args *Args) (bool, error) {
 	rlib := flag.String("r", "", "load the library before executing your script")
 	v := flag.Bool("v", false, "print version number, then run in verbose mode")
 	flag.BoolVar(&args.verbose, "verbose", false, "run in verbose mode")
+	flag.StringVar(&args.profile, "profile", "", "write pprof CPU profile of script to file")
+	flag.StringVar(&args.coverage, "coverage", "", "write coverage of script to file on exit")
+	flag.StringVar(&args.coverFormat, "coverage-format", "lcov", "format of coverage file, lcov or cover")
+	flag.StringVar(&args.dap, "dap", "", "debug script with Debug Adapter Protocol client on stdio or local TCP address")
 	version := flag.Bool("version", false, "print the version")
 	copyright := flag.Bool("copyright", false, "print the copyright")
 
@@ -71,6 +83,10 @@ func parseArgs(mrb *oruby.MrbState, args *Args) (bool, error) {
 		args.verbose = true
 	}
 
+	if args.coverFormat != "lcov" && args.coverFormat != "cover" {
+		return false, fmt.Errorf("%v: Unknown coverage format: %v\n", os.Args[0], args.coverFormat)
+	}
+
 	if args.eline != "" {
 		args.cmdline = args.cmdline + "\n" + args.eline
 	}
@@ -98,6 +114,105 @@ func exitFailure(format string, v ...any) int {
 	return 1
 }
 
+// checkSyntax prints syntax errors and warnings of program, without running it.
+// RiteBinary program is verified instead, as it has no source to parse
+func checkSyntax(mrb *oruby.MrbState, args *Args) int {
+	source, filename := args.cmdline, "-e"
+	if args.rfp != "" {
+		data, err := os.ReadFile(args.rfp)
+		if err != nil {
+			return exitFailure("%v: Cannot open program file: %s\n", os.Args[0], args.rfp)
+		}
+		source, filename = string(data), args.rfp
+	}
+
+	if args.mrbfile || strings.EqualFold(filepath.Ext(filename), ".mrb") {
+		if args.rfp == "" {
+			return exitFailure("%v: -c with -b requires program file\n", os.Args[0])
+		}
+		if err := oruby.VerifyIrep([]byte(source)); err != nil {
+			fmt.Fprintf(os.Stderr, "%v: %v\n", filename, err)
+			return 1
+		}
+		fmt.Println("Bytecode OK")
+		return 0
+	}
+
+	diags := mrb.Check(source, filename)
+	for _, d := range diags {
+		fmt.Fprintln(os.Stderr, d)
+	}
+	if oruby.HasErrors(diags) {
+		return 1
+	}
+
+	fmt.Println("Syntax OK")
+	return 0
+}
+
+// writeCoverage writes coverage report to coverage file in selected format
+func writeCoverage(report *oruby.CoverageReport, args *Args) error {
+	f, err := os.Create(args.coverage)
+	if err != nil {
+		return err
+	}
+	if args.coverFormat == "cover" {
+		err = report.WriteCoverProfile(f)
+	} else {
+		err = report.WriteLCOV(f)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// startDAP starts debug adapter on stdio or local TCP address, and waits until client
+// set breakpoints. With stdio, script output is sent to client as output events.
+// Returned function tells client that script exited
+func startDAP(mrb *oruby.MrbState, addr string) (func(exitCode int), error) {
+	if addr != "stdio" {
+		fmt.Fprintf(os.Stderr, "%v: waiting for debugger on %v\n", os.Args[0], addr)
+		s, err := mrb.ListenDAP(addr)
+		if err != nil {
+			return nil, err
+		}
+		go s.Serve()
+		s.WaitConfigured()
+		return s.Exited, nil
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	s := mrb.NewDAPServer(os.Stdin, os.Stdout)
+	os.Stdout = w
+
+	copied := make(chan struct{})
+	go func() {
+		defer close(copied)
+		buf := make([]byte, 4096)
+		for {
+			n, err := r.Read(buf)
+			if n > 0 {
+				s.Output("stdout", string(buf[:n]))
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	go s.Serve()
+	s.WaitConfigured()
+	return func(exitCode int) {
+		w.Close()
+		<-copied
+		s.Exited(exitCode)
+	}, nil
+}
+
 func main() {
 	args := Args{}
 	var v oruby.MrbValue
@@ -118,6 +233,51 @@ func main() {
 		return
 	}
 
+	if args.checkSyntax {
+		exitCode = checkSyntax(mrb, &args)
+		return
+	}
+
+	if args.profile != "" {
+		f, err := os.Create(args.profile)
+		if err != nil {
+			exitCode = exitFailure("%v: Cannot create profile: %v\n", os.Args[0], err)
+			return
+		}
+		defer f.Close()
+
+		if err := mrb.StartCPUProfile(f); err != nil {
+			exitCode = exitFailure("%v: %v\n", os.Args[0], err)
+			return
+		}
+		defer func() {
+			if err := mrb.StopCPUProfile(); err != nil {
+				exitCode = exitFailure("%v: Cannot write profile: %v\n", os.Args[0], err)
+			}
+		}()
+	}
+
+	if args.coverage != "" {
+		if err := mrb.StartCoverage(); err != nil {
+			exitCode = exitFailure("%v: %v\n", os.Args[0], err)
+			return
+		}
+		defer func() {
+			if err := writeCoverage(mrb.StopCoverage(), &args); err != nil {
+				exitCode = exitFailure("%v: Cannot write coverage: %v\n", os.Args[0], err)
+			}
+		}()
+	}
+
+	if args.dap != "" {
+		exited, err := startDAP(mrb, args.dap)
+		if err != nil {
+			exitCode = exitFailure("%v: Cannot start debugger: %v\n", os.Args[0], err)
+			return
+		}
+		defer func() { exited(exitCode) }()
+	}
+
 	ai := mrb.GCArenaSave()
 	mrb.DefineGlobalConst("ARGV", mrb.Value(os.Args))
 	mrb.GVSet(mrb.Intern("$DEBUG"), mrb.BoolValue(args.debug))
@@ -126,7 +286,6 @@ func main() {
 	defer c.Free()
 
 	c.SetDumpResult(args.verbose)
-	c.SetNoExec(args.checkSyntax)
 
 	/* Set $0 */
 	cmdline := args.cmdline
@@ -185,10 +344,5 @@ func main() {
 			mrb.PrintError()
 		}
 		exitCode = 1
-		return
-	}
-
-	if args.checkSyntax {
-		fmt.Println("Syntax OK")
 	}
 }
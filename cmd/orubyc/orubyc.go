@@ -1,12 +1,17 @@
 package main
 
 import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/pem"
 	"flag"
 	"fmt"
 	"io"
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/oruby/oruby"
 )
@@ -16,6 +21,7 @@ const (
 	RitebinExt = ".mrb"
 	CExt       = ".c"
 	GoExt      = ".go"
+	BundleExt  = ".orb"
 )
 
 type mrbcArgs struct {
@@ -26,6 +32,11 @@ type mrbcArgs struct {
 	idx         int
 	dumpStruct  bool
 	checkSyntax bool
+	disasm      bool
+	bundle      string
+	bundleVer   string
+	bundleGems  string
+	signKey     string
 	verbose     bool
 	removeLv    bool
 	noExtOps    bool
@@ -39,6 +50,11 @@ func usage(name string) {
 	msg := []string{
 		"switches:",
 		"-c           check syntax only",
+		"--disasm     print disassembled bytecode of program or .mrb file",
+		"--bundle <name>  compile programs into signed bundle <name> (requires --sign-key)",
+		"--bundle-version <version>  bundle version",
+		"--bundle-gems <gem,...>  gems required by bundle",
+		"--sign-key <file>  ed25519 private key in PEM (PKCS #8) format to sign bundle",
 		"-o<outfile>  place the output into <outfile>",
 		"-v           print version number, then turn on verbose mode",
 		"-g           produce debugging information",
@@ -75,6 +91,11 @@ func getOutfilename(infile, ext string) string {
 func parseArgs(mrb *oruby.MrbState, args *mrbcArgs) (bool, error) {
 	args.prog = os.Args[0]
 	flag.BoolVar(&args.checkSyntax, "c", false, "check syntax only")
+	flag.BoolVar(&args.disasm, "disasm", false, "print disassembled bytecode of program or .mrb file")
+	flag.StringVar(&args.bundle, "bundle", "", "compile programs into signed bundle <name>")
+	flag.StringVar(&args.bundleVer, "bundle-version", "", "bundle version")
+	flag.StringVar(&args.bundleGems, "bundle-gems", "", "gems required by bundle, separated by comma")
+	flag.StringVar(&args.signKey, "sign-key", "", "ed25519 private key in PEM format to sign bundle")
 	flag.StringVar(&args.outfile, "o", "", "place the output into <outfile>")
 	v := flag.Bool("v", false, "print version number, then run in verbose mode")
 	debugInfo := flag.Bool("g", false, "produce debugging information")
@@ -116,7 +137,16 @@ func parseArgs(mrb *oruby.MrbState, args *mrbcArgs) (bool, error) {
 
 	}
 
-	if args.outfile == "" && !args.checkSyntax {
+	if args.bundle != "" {
+		if args.signKey == "" {
+			return false, fmt.Errorf("%v: bundle requires signing key, use --sign-key\n", args.prog)
+		}
+		if args.outfile == "" {
+			args.outfile = args.bundle + BundleExt
+		}
+	}
+
+	if args.outfile == "" && !args.checkSyntax && !args.disasm {
 		if len(args.argv) == 1 {
 			args.outfile = getOutfilename(args.argv[0], filepath.Ext(args.initname))
 		} else {
@@ -194,6 +224,134 @@ func loadFile(mrb *oruby.MrbState, args *mrbcArgs) oruby.Value {
 	return result
 }
 
+// checkSyntax prints syntax errors and warnings of all program files, without compiling them
+func checkSyntax(mrb *oruby.MrbState, args *mrbcArgs) int {
+	exitCode := 0
+	for _, input := range args.argv {
+		var data []byte
+		var err error
+		if input == "-" {
+			data, err = io.ReadAll(os.Stdin)
+		} else {
+			data, err = os.ReadFile(input)
+		}
+		if err != nil {
+			fmt.Printf("%s: cannot open program file %v: %v)\n", args.prog, input, err)
+			exitCode = 1
+			continue
+		}
+
+		diags := mrb.Check(string(data), input)
+		for _, d := range diags {
+			fmt.Fprintln(os.Stderr, d)
+		}
+		if oruby.HasErrors(diags) {
+			exitCode = 1
+			continue
+		}
+		log.Printf("%s:%s:Syntax OK\n", args.prog, input)
+	}
+	return exitCode
+}
+
+// disasm prints disassembled bytecode of .mrb files, or of compiled program files
+func disasm(mrb *oruby.MrbState, args *mrbcArgs) int {
+	exitCode := 0
+	for _, input := range args.argv {
+		data, err := os.ReadFile(input)
+		if err != nil {
+			fmt.Printf("%s: cannot open program file %v: %v)\n", args.prog, input, err)
+			exitCode = 1
+			continue
+		}
+
+		if !bytes.HasPrefix(data, []byte(oruby.RiteBinaryIdent)) {
+			prog, err := oruby.Compile(string(data), input)
+			if err != nil {
+				fmt.Printf("%s: parsing error: %v\n", args.prog, err)
+				exitCode = 1
+				continue
+			}
+			data = prog.Bytecode()
+		}
+
+		d, err := mrb.DisassembleBytecode(data)
+		if err != nil {
+			fmt.Printf("%s: %v: %v\n", args.prog, input, err)
+			exitCode = 1
+			continue
+		}
+		d.WriteTo(os.Stdout)
+	}
+	return exitCode
+}
+
+// readSignKey reads ed25519 private key from PEM file
+func readSignKey(fileName string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%v: no PEM data found", fileName)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	edKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%v: not ed25519 private key", fileName)
+	}
+	return edKey, nil
+}
+
+// bundle compiles programs, or reads .mrb files, into signed bundle
+func bundle(args *mrbcArgs) int {
+	key, err := readSignKey(args.signKey)
+	if err != nil {
+		return exitFailure("%v: cannot read signing key: %v\n", args.prog, err)
+	}
+
+	manifest := oruby.BundleManifest{Name: args.bundle, Version: args.bundleVer}
+	for _, gem := range strings.Split(args.bundleGems, ",") {
+		if gem = strings.TrimSpace(gem); gem != "" {
+			manifest.Gems = append(manifest.Gems, gem)
+		}
+	}
+	b := oruby.NewBundle(manifest)
+
+	for _, input := range args.argv {
+		data, err := os.ReadFile(input)
+		if err != nil {
+			return exitFailure("%s: cannot open program file %v: %v\n", args.prog, input, err)
+		}
+
+		var prog *oruby.Program
+		if bytes.HasPrefix(data, []byte(oruby.RiteBinaryIdent)) {
+			if err = oruby.VerifyIrep(data); err == nil {
+				prog, err = oruby.NewProgram(data, input)
+			}
+		} else {
+			prog, err = oruby.Compile(string(data), input)
+		}
+		if err != nil {
+			return exitFailure("%s: %v: %v\n", args.prog, input, err)
+		}
+		b.Add(input, prog)
+	}
+
+	data, err := b.Sign(key)
+	if err != nil {
+		return exitFailure("%s: cannot sign bundle: %v\n", args.prog, err)
+	}
+	if err := os.WriteFile(args.outfile, data, 0o644); err != nil {
+		return exitFailure("%v: cannot write bundle %v: %v\n", args.prog, args.outfile, err)
+	}
+	return 0
+}
+
 func dumpFile(mrb *oruby.MrbState, wfp *os.File, outfile string, proc oruby.RProc, args *mrbcArgs) int {
 	n := oruby.MrbDumpOK
 	irep := proc.IRep()
@@ -250,6 +408,21 @@ func main() {
 		return
 	}
 
+	if args.checkSyntax {
+		exitCode = checkSyntax(mrb, &args)
+		return
+	}
+
+	if args.disasm {
+		exitCode = disasm(mrb, &args)
+		return
+	}
+
+	if args.bundle != "" {
+		exitCode = bundle(&args)
+		return
+	}
+
 	args.idx = 0
 	load := loadFile(mrb, &args)
 	if load.IsNil() {
@@ -257,11 +430,6 @@ func main() {
 		return
 	}
 
-	if args.checkSyntax {
-		log.Printf("%s:%s:Syntax OK\n", args.prog, args.argv[0])
-		return
-	}
-
 	var wfp *os.File
 
 	if args.outfile == "" {
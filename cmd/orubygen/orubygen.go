@@ -0,0 +1,1077 @@
+// Command orubygen generates static oruby bindings for Go types and functions.
+//
+// Generated bindings are typed MrbFuncT wrappers, so methods are called without
+// reflection. Names follow the same rules as RClass.Populate: methods and fields
+// are snake_cased, "is_x" is aliased to "x?" and "x_bang" to "x!".
+//
+// Usage with go generate:
+//
+//	//go:generate orubygen -types Point,Rect -funcs
+//
+// For each type T, RegisterT(c oruby.RClass) is generated, which defines methods and
+// field accessors on class registered for *T:
+//
+//	c := mrb.DefineClass("Point", mrb.ObjectClass())
+//	c.RegisterGoClass(NewPoint)
+//	c.DefineAlias("initialize", "init_go")
+//	RegisterPoint(c)
+//
+// With -funcs, Register<Module>Module(m oruby.RClass) defines exported package functions
+// as module functions. Methods and functions with argument types which can not be converted
+// statically are bound with reflection, as Populate does.
+//
+// With -table, <Module>Package table with exported functions, constants, variables and
+// struct types is generated, to be defined as module with MrbState.DefineGoPackage.
+// Functions in table use static wrappers when -funcs is given, and are named with
+// oruby.PackageSnakeCase, as DefineGoPackage names them.
+//
+// With -import, table of other package, like standard library package, is generated into
+// package named with -package, with members qualified by imported package name:
+//
+//	//go:generate orubygen -import math -package gopkg -module math -table -o math_oruby.go
+//
+// With -adapters, adapter types are generated for listed interfaces and registered
+// with oruby.RegisterAdapter, so MrbState.Implement can bind oruby objects to them.
+// Each method calls snake_cased oruby method with RubyObject.Call, and converts its
+// results to Go results. Failed call is returned as last error result, if there is one.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/constant"
+	"go/format"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/fs"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/oruby/oruby"
+)
+
+const orubyImport = "github.com/oruby/oruby"
+
+type config struct {
+	dir        string
+	types      []string
+	adapters   []string
+	funcs      bool
+	table      bool
+	module     string
+	output     string
+	importPath string
+	pkgName    string
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: orubygen [flags] [package directory]\n")
+	flag.PrintDefaults()
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("orubygen: ")
+
+	var cfg config
+	typeList := flag.String("types", "", "comma separated list of type names, all exported struct types if empty")
+	adapterList := flag.String("adapters", "", "comma separated list of interface names to generate adapters for")
+	flag.BoolVar(&cfg.funcs, "funcs", false, "bind exported package functions as module functions")
+	flag.BoolVar(&cfg.table, "table", false, "generate package table for DefineGoPackage")
+	flag.StringVar(&cfg.module, "module", "", "module name used for functions registration, CamelCased package name if empty")
+	flag.StringVar(&cfg.output, "o", "", "output file, <package>_oruby.go if empty")
+	flag.StringVar(&cfg.importPath, "import", "", "import path of package to generate table for, instead of package directory")
+	flag.StringVar(&cfg.pkgName, "package", "", "package name of generated code, with -import")
+	flag.Usage = usage
+	flag.Parse()
+
+	cfg.dir = "."
+	if flag.NArg() > 0 {
+		cfg.dir = flag.Arg(0)
+	}
+	if *typeList != "" {
+		cfg.types = strings.Split(*typeList, ",")
+	}
+	if *adapterList != "" {
+		cfg.adapters = strings.Split(*adapterList, ",")
+	}
+
+	outDir := cfg.dir
+	if cfg.importPath != "" {
+		bp, err := build.Import(cfg.importPath, ".", build.FindOnly)
+		if err != nil {
+			log.Fatal(err)
+		}
+		cfg.dir, outDir = bp.Dir, "."
+	}
+
+	pkg, err := loadPackage(cfg.dir, cfg.output)
+	if err != nil {
+		log.Fatal(err)
+	}
+	pkg.importPath = cfg.importPath
+
+	if cfg.output == "" {
+		cfg.output = pkg.name + "_oruby.go"
+	}
+	if !filepath.IsAbs(cfg.output) {
+		cfg.output = filepath.Join(outDir, cfg.output)
+	}
+
+	g := newGenerator(pkg, func(msg string) { log.Print(msg) })
+	src, err := g.generate(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := os.WriteFile(cfg.output, src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// pkgInfo holds declarations of parsed package
+type pkgInfo struct {
+	name    string
+	types   map[string]*ast.TypeSpec
+	order   []string
+	methods map[string][]*ast.FuncDecl
+	funcs   []*ast.FuncDecl
+	consts  []string
+	vars    []string
+
+	importPath string         // import path, when package is imported by generated code
+	typed      *types.Package // type checked package, for constant values
+}
+
+// loadPackage parses Go package in dir, skipping tests, files excluded by build constraints
+// and previously generated output
+func loadPackage(dir, output string) (*pkgInfo, error) {
+	fset := token.NewFileSet()
+	filter := func(fi fs.FileInfo) bool {
+		name := fi.Name()
+		if strings.HasSuffix(name, "_test.go") || strings.HasSuffix(name, "_oruby.go") {
+			return false
+		}
+		if ok, err := build.Default.MatchFile(dir, name); err == nil && !ok {
+			return false
+		}
+		return output == "" || name != filepath.Base(output)
+	}
+
+	pkgs, err := parser.ParseDir(fset, dir, filter, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for name := range pkgs {
+		names = append(names, name)
+	}
+	if len(names) != 1 {
+		return nil, fmt.Errorf("expected one package in %v, found %v", dir, names)
+	}
+
+	pkg := &pkgInfo{
+		name:    names[0],
+		types:   map[string]*ast.TypeSpec{},
+		methods: map[string][]*ast.FuncDecl{},
+	}
+
+	files := make([]string, 0, len(pkgs[pkg.name].Files))
+	for name := range pkgs[pkg.name].Files {
+		files = append(files, name)
+	}
+	sort.Strings(files)
+
+	astFiles := make([]*ast.File, len(files))
+	for i, name := range files {
+		astFiles[i] = pkgs[pkg.name].Files[name]
+	}
+	pkg.typed = typeCheck(fset, pkg.name, astFiles)
+
+	for _, name := range files {
+		for _, decl := range pkgs[pkg.name].Files[name].Decls {
+			switch d := decl.(type) {
+			case *ast.GenDecl:
+				for _, spec := range d.Specs {
+					switch sp := spec.(type) {
+					case *ast.TypeSpec:
+						pkg.types[sp.Name.Name] = sp
+						pkg.order = append(pkg.order, sp.Name.Name)
+					case *ast.ValueSpec:
+						for _, id := range sp.Names {
+							if !id.IsExported() {
+								continue
+							}
+							if d.Tok == token.CONST {
+								pkg.consts = append(pkg.consts, id.Name)
+							} else {
+								pkg.vars = append(pkg.vars, id.Name)
+							}
+						}
+					}
+				}
+			case *ast.FuncDecl:
+				if !d.Name.IsExported() {
+					continue
+				}
+				if d.Recv == nil {
+					pkg.funcs = append(pkg.funcs, d)
+					continue
+				}
+				if recv := receiverName(d.Recv.List[0].Type); recv != "" {
+					pkg.methods[recv] = append(pkg.methods[recv], d)
+				}
+			}
+		}
+	}
+
+	// Methods are bound in same order as reflection lists them
+	for _, m := range pkg.methods {
+		sort.Slice(m, func(i, j int) bool { return m[i].Name.Name < m[j].Name.Name })
+	}
+	sort.Slice(pkg.funcs, func(i, j int) bool { return pkg.funcs[i].Name.Name < pkg.funcs[j].Name.Name })
+
+	return pkg, nil
+}
+
+// typeCheck type checks package for constant values. Only standard library packages are
+// imported, and type errors are ignored, so constants which do not depend on other packages
+// are known even when package can not be fully checked
+func typeCheck(fset *token.FileSet, name string, files []*ast.File) *types.Package {
+	std := importer.ForCompiler(fset, "source", nil)
+	conf := types.Config{
+		Importer: importerFunc(func(path string) (*types.Package, error) {
+			if first, _, _ := strings.Cut(path, "/"); strings.Contains(first, ".") {
+				return nil, fmt.Errorf("package %v is not imported", path)
+			}
+			return std.Import(path)
+		}),
+		Error: func(error) {},
+	}
+	typed, _ := conf.Check(name, fset, files, nil)
+	return typed
+}
+
+type importerFunc func(path string) (*types.Package, error)
+
+func (f importerFunc) Import(path string) (*types.Package, error) { return f(path) }
+
+// receiverName returns type name of method receiver, or empty string for generic types
+func receiverName(e ast.Expr) string {
+	if star, ok := e.(*ast.StarExpr); ok {
+		e = star.X
+	}
+	if id, ok := e.(*ast.Ident); ok {
+		return id.Name
+	}
+	return ""
+}
+
+// kind of Go type, as converted to and from oruby value
+type kind int
+
+const (
+	kindUnsupported kind = iota
+	kindInt
+	kindUint
+	kindFloat
+	kindString
+	kindBytes
+	kindBool
+	kindValue
+	kindIntf
+	kindData
+	kindError
+)
+
+// goType is Go type as written in source, with its conversion kind
+type goType struct {
+	expr string
+	kind kind
+	bits int
+}
+
+var basicTypes = map[string]goType{
+	"int":     {kind: kindInt},
+	"int8":    {kind: kindInt, bits: 8},
+	"int16":   {kind: kindInt, bits: 16},
+	"int32":   {kind: kindInt, bits: 32},
+	"rune":    {kind: kindInt, bits: 32},
+	"int64":   {kind: kindInt, bits: 64},
+	"uint":    {kind: kindUint},
+	"uint8":   {kind: kindUint, bits: 8},
+	"byte":    {kind: kindUint, bits: 8},
+	"uint16":  {kind: kindUint, bits: 16},
+	"uint32":  {kind: kindUint, bits: 32},
+	"uint64":  {kind: kindUint, bits: 64},
+	"float32": {kind: kindFloat, bits: 32},
+	"float64": {kind: kindFloat, bits: 64},
+	"string":  {kind: kindString},
+	"bool":    {kind: kindBool},
+	"error":   {kind: kindError},
+	"any":     {kind: kindIntf},
+}
+
+// resolve returns conversion kind of type expression
+func (p *pkgInfo) resolve(e ast.Expr) goType {
+	src := types.ExprString(e)
+
+	switch t := e.(type) {
+	case *ast.Ident:
+		if b, ok := basicTypes[t.Name]; ok {
+			b.expr = src
+			return b
+		}
+		// Named types with basic underlying type
+		if spec, ok := p.types[t.Name]; ok && spec.TypeParams == nil {
+			if id, ok := spec.Type.(*ast.Ident); ok {
+				if b, ok := basicTypes[id.Name]; ok && b.kind != kindError && b.kind != kindIntf {
+					b.expr = src
+					return b
+				}
+			}
+		}
+
+	case *ast.StarExpr:
+		// Pointers to package structs are values of registered Go classes
+		if id, ok := t.X.(*ast.Ident); ok {
+			if spec, ok := p.types[id.Name]; ok && spec.TypeParams == nil {
+				if _, ok := spec.Type.(*ast.StructType); ok {
+					return goType{expr: src, kind: kindData}
+				}
+			}
+		}
+
+	case *ast.ArrayType:
+		if id, ok := t.Elt.(*ast.Ident); ok && t.Len == nil && (id.Name == "byte" || id.Name == "uint8") {
+			return goType{expr: src, kind: kindBytes}
+		}
+
+	case *ast.SelectorExpr:
+		if x, ok := t.X.(*ast.Ident); ok && x.Name == "oruby" && t.Sel.Name == "Value" {
+			return goType{expr: src, kind: kindValue}
+		}
+
+	case *ast.InterfaceType:
+		if len(t.Methods.List) == 0 {
+			return goType{expr: src, kind: kindIntf}
+		}
+	}
+
+	return goType{expr: src}
+}
+
+// fieldTypes flattens field list, so each name has its own entry
+func fieldTypes(fl *ast.FieldList) []ast.Expr {
+	if fl == nil {
+		return nil
+	}
+	var ret []ast.Expr
+	for _, f := range fl.List {
+		n := len(f.Names)
+		if n == 0 {
+			n = 1
+		}
+		for i := 0; i < n; i++ {
+			ret = append(ret, f.Type)
+		}
+	}
+	return ret
+}
+
+type generator struct {
+	pkg      *pkgInfo
+	buf      bytes.Buffer
+	warn     func(msg string)
+	wrappers map[string]string // static wrappers of package functions
+}
+
+func newGenerator(pkg *pkgInfo, warn func(msg string)) *generator {
+	return &generator{pkg: pkg, warn: warn, wrappers: map[string]string{}}
+}
+
+func (g *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+// generate returns formatted source of bindings
+func (g *generator) generate(cfg config) ([]byte, error) {
+	names := cfg.types
+	if len(names) == 0 {
+		for _, name := range g.pkg.order {
+			spec := g.pkg.types[name]
+			if _, ok := spec.Type.(*ast.StructType); ok && ast.IsExported(name) && spec.TypeParams == nil {
+				names = append(names, name)
+			}
+		}
+	}
+
+	pkgName, imports := g.pkg.name, []string{orubyImport}
+	if g.pkg.importPath != "" {
+		if cfg.pkgName == "" || len(cfg.types) > 0 || len(cfg.adapters) > 0 || cfg.funcs {
+			return nil, fmt.Errorf("-import requires -package, and generates only -table")
+		}
+		names = nil
+		pkgName, imports = cfg.pkgName, []string{g.pkg.importPath, orubyImport}
+	}
+
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		spec, ok := g.pkg.types[name]
+		if !ok {
+			return nil, fmt.Errorf("type %v not found in package %v", name, g.pkg.name)
+		}
+		if spec.TypeParams != nil {
+			return nil, fmt.Errorf("generic type %v is not supported", name)
+		}
+		g.genType(name, spec)
+	}
+
+	module := cfg.module
+	if module == "" {
+		module = camelCase(g.pkg.name)
+	}
+	if cfg.funcs {
+		g.genFuncs(module)
+	}
+	if cfg.table {
+		g.genTable(module)
+	}
+	if err := g.genAdapters(cfg.adapters); err != nil {
+		return nil, err
+	}
+
+	// oruby is not used by table of constants and functions only
+	body := g.buf.String()
+	if !strings.Contains(body, "oruby.") {
+		imports = imports[:len(imports)-1]
+	}
+	g.buf.Reset()
+	g.printf("// Code generated by orubygen; DO NOT EDIT.\n\n")
+	g.printf("package %v\n\n", pkgName)
+	switch len(imports) {
+	case 1:
+		g.printf("import %q\n", imports[0])
+	case 2:
+		g.printf("import (\n\t%q\n\n\t%q\n)\n", imports[0], imports[1])
+	}
+	g.printf("%v", body)
+
+	src, err := format.Source(g.buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated code: %v", err)
+	}
+	return src, nil
+}
+
+// binding is single oruby method definition in registration function
+type binding struct {
+	name    string
+	wrapper string // MrbFuncT wrapper, empty when bound with reflection
+	aspec   string
+	goFunc  string // Go function or method expression for reflection binding
+}
+
+func (g *generator) genType(name string, spec *ast.TypeSpec) {
+	var bindings []binding
+
+	for _, m := range g.pkg.methods[name] {
+		if m.Type.TypeParams != nil {
+			continue
+		}
+		rname := oruby.SnakeCase(m.Name.Name)
+		b := binding{name: rname, goFunc: fmt.Sprintf("(*%v).%v", name, m.Name.Name)}
+		wrapper := "orubygen" + name + m.Name.Name
+		if aspec, ok := g.genWrapper(wrapper, name, "recv."+m.Name.Name, rname, m); ok {
+			b.wrapper, b.aspec = wrapper, aspec
+		} else {
+			g.warn(fmt.Sprintf("%v.%v: unsupported signature, bound with reflection", name, m.Name.Name))
+		}
+		bindings = append(bindings, b)
+	}
+
+	if st, ok := spec.Type.(*ast.StructType); ok {
+		for _, f := range st.Fields.List {
+			for _, id := range f.Names {
+				if !id.IsExported() {
+					continue
+				}
+				bindings = append(bindings, g.genField(name, id.Name, g.pkg.resolve(f.Type))...)
+			}
+		}
+	}
+
+	g.printf("\n// Register%v defines methods and field accessors of *%v on class c,\n", name, name)
+	g.printf("// which must be registered for *%v with RegisterGoClass\n", name)
+	g.printf("func Register%v(c oruby.RClass) {\n", name)
+	g.genBindings(bindings, "DefineMethod", "DefineMethodFunc")
+	g.printf("}\n")
+}
+
+func (g *generator) genFuncs(module string) {
+	var bindings []binding
+
+	for _, f := range g.pkg.funcs {
+		if f.Type.TypeParams != nil {
+			g.warn(fmt.Sprintf("%v: generic functions are not supported", f.Name.Name))
+			continue
+		}
+		rname := oruby.SnakeCase(f.Name.Name)
+		b := binding{name: rname, goFunc: f.Name.Name}
+		wrapper := "orubygen" + module + f.Name.Name
+		if aspec, ok := g.genWrapper(wrapper, "", f.Name.Name, rname, f); ok {
+			b.wrapper, b.aspec = wrapper, aspec
+			g.wrappers[f.Name.Name] = wrapper
+		} else {
+			g.warn(fmt.Sprintf("%v: unsupported signature, bound with reflection", f.Name.Name))
+		}
+		bindings = append(bindings, b)
+	}
+
+	g.printf("\n// Register%vModule defines exported functions of package %v as module functions of m\n", module, g.pkg.name)
+	g.printf("func Register%vModule(m oruby.RClass) {\n", module)
+	g.genBindings(bindings, "DefineModuleFunction", "DefineModuleFunc")
+	g.printf("}\n")
+}
+
+// member returns expression of package member, qualified when package is imported
+func (g *generator) member(name string) string {
+	if g.pkg.importPath == "" {
+		return name
+	}
+	return g.pkg.name + "." + name
+}
+
+// constExpr returns expression of constant in package table. Untyped integer constants
+// which do not fit in 32 bits are converted to int64, so they compile on all platforms.
+// Constants above int64 range do not fit in Integer, so they are converted to Float
+func (g *generator) constExpr(name string) string {
+	expr := g.member(name)
+	if g.pkg.typed == nil {
+		return expr
+	}
+	c, ok := g.pkg.typed.Scope().Lookup(name).(*types.Const)
+	if !ok || c.Type() != types.Typ[types.UntypedInt] || c.Val().Kind() != constant.Int {
+		return expr
+	}
+	if _, exact := constant.Int64Val(c.Val()); !exact {
+		return "float64(" + expr + ")"
+	}
+	if v, _ := constant.Int64Val(c.Val()); v < math.MinInt32 || v > math.MaxInt32 {
+		return "int64(" + expr + ")"
+	}
+	return expr
+}
+
+// genTable prints package table for DefineGoPackage
+func (g *generator) genTable(module string) {
+	var entries []string
+	for _, name := range g.pkg.consts {
+		entries = append(entries, fmt.Sprintf("%q: %v,", name, g.constExpr(name)))
+	}
+	for _, name := range g.pkg.vars {
+		entries = append(entries, fmt.Sprintf("%q: oruby.GoVar(&%v),", name, g.member(name)))
+	}
+	for _, f := range g.pkg.funcs {
+		if f.Type.TypeParams != nil {
+			continue
+		}
+		fn := g.member(f.Name.Name)
+		if wrapper, ok := g.wrappers[f.Name.Name]; ok {
+			fn = "oruby.MrbFuncT(" + wrapper + ")"
+		}
+		entries = append(entries, fmt.Sprintf("%q: %v,", oruby.PackageSnakeCase(f.Name.Name), fn))
+	}
+	for _, name := range g.pkg.order {
+		spec := g.pkg.types[name]
+		if _, ok := spec.Type.(*ast.StructType); !ok || !ast.IsExported(name) || spec.TypeParams != nil {
+			continue
+		}
+		constructor := fmt.Sprintf("(*%v)(nil)", g.member(name))
+		if g.isConstructor("New" + name) {
+			constructor = g.member("New" + name)
+		}
+		entries = append(entries, fmt.Sprintf("%q: oruby.GoType(%v),", name, constructor))
+	}
+
+	g.printf("\n// %vPackage is table of package %v members for MrbState.DefineGoPackage\n", module, g.pkg.name)
+	g.printf("var %vPackage = map[string]interface{}{\n", module)
+	for _, e := range entries {
+		g.printf("\t%v\n", e)
+	}
+	g.printf("}\n")
+}
+
+// genAdapters prints adapter types of interfaces, and their registration
+func (g *generator) genAdapters(names []string) error {
+	var adapters []string
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		spec, ok := g.pkg.types[name]
+		if !ok {
+			return fmt.Errorf("interface %v not found in package %v", name, g.pkg.name)
+		}
+		if _, ok := spec.Type.(*ast.InterfaceType); !ok || spec.TypeParams != nil {
+			return fmt.Errorf("%v is not interface type", name)
+		}
+
+		methods, err := g.interfaceMethods(name)
+		if err != nil {
+			return err
+		}
+
+		adapter := "orubygen" + name + "Adapter"
+		g.printf("\n// %v implements %v by calling methods of oruby object\n", adapter, name)
+		g.printf("type %v struct{ *oruby.RubyObject }\n", adapter)
+		for _, m := range methods {
+			g.genAdapterMethod(adapter, m.Names[0].Name, m.Type.(*ast.FuncType))
+		}
+		adapters = append(adapters, name)
+	}
+
+	if len(adapters) == 0 {
+		return nil
+	}
+
+	g.printf("\nfunc init() {\n")
+	for _, name := range adapters {
+		g.printf("\toruby.RegisterAdapter((*%v)(nil), oruby.Adapter{New: func(o *oruby.RubyObject) interface{} {\n", name)
+		g.printf("\t\treturn orubygen%vAdapter{o}\n\t}})\n", name)
+	}
+	g.printf("}\n")
+	return nil
+}
+
+// interfaceMethods returns methods of interface, including methods of embedded package interfaces
+func (g *generator) interfaceMethods(name string) ([]*ast.Field, error) {
+	spec, ok := g.pkg.types[name]
+	if !ok {
+		return nil, fmt.Errorf("embedded interface %v is not declared in package %v", name, g.pkg.name)
+	}
+	it, ok := spec.Type.(*ast.InterfaceType)
+	if !ok {
+		return nil, fmt.Errorf("%v is not interface type", name)
+	}
+
+	var methods []*ast.Field
+	for _, f := range it.Methods.List {
+		if len(f.Names) > 0 {
+			if _, ok := f.Type.(*ast.FuncType); ok {
+				methods = append(methods, f)
+			}
+			continue
+		}
+
+		id, ok := f.Type.(*ast.Ident)
+		if !ok {
+			return nil, fmt.Errorf("%v: embedded %v is not supported", name, types.ExprString(f.Type))
+		}
+		embedded, err := g.interfaceMethods(id.Name)
+		if err != nil {
+			return nil, err
+		}
+		methods = append(methods, embedded...)
+	}
+	return methods, nil
+}
+
+// genAdapterMethod prints method of adapter type, which calls oruby method with RubyObject.Call
+func (g *generator) genAdapterMethod(adapter, name string, ft *ast.FuncType) {
+	params := fieldTypes(ft.Params)
+	results := fieldTypes(ft.Results)
+
+	in := make([]string, len(params))
+	args := make([]string, len(params))
+	variadic := false
+	for i, p := range params {
+		in[i] = fmt.Sprintf("p%d %v", i, types.ExprString(p))
+		args[i] = fmt.Sprintf("p%d", i)
+		_, variadic = p.(*ast.Ellipsis)
+	}
+
+	hasErr := len(results) > 0 && g.pkg.resolve(results[len(results)-1]).kind == kindError
+	out := make([]string, len(results))
+	var ptrs []string
+	for i, r := range results {
+		if hasErr && i == len(results)-1 {
+			out[i] = "err " + types.ExprString(r)
+			continue
+		}
+		out[i] = fmt.Sprintf("r%d %v", i, types.ExprString(r))
+		ptrs = append(ptrs, fmt.Sprintf("&r%d", i))
+	}
+
+	argList := "nil"
+	if variadic {
+		argList = "args"
+	} else if len(args) > 0 {
+		argList = "[]interface{}{" + strings.Join(args, ", ") + "}"
+	}
+	call := fmt.Sprintf("a.Call(%q, %v", name, argList)
+	if len(ptrs) > 0 {
+		call += ", " + strings.Join(ptrs, ", ")
+	}
+	call += ")"
+
+	ret := ""
+	if len(out) > 0 {
+		ret = " (" + strings.Join(out, ", ") + ")"
+	}
+	g.printf("\nfunc (a %v) %v(%v)%v {\n", adapter, name, strings.Join(in, ", "), ret)
+
+	// variadic arguments are passed as separate arguments of oruby method
+	if variadic {
+		last := args[len(args)-1]
+		g.printf("\targs := []interface{}{%v}\n", strings.Join(args[:len(args)-1], ", "))
+		g.printf("\tfor _, v := range %v {\n\t\targs = append(args, v)\n\t}\n", last)
+	}
+
+	if hasErr {
+		g.printf("\terr = %v\n", call)
+	} else {
+		g.printf("\t_ = %v\n", call)
+	}
+	if len(out) > 0 {
+		g.printf("\treturn\n")
+	}
+	g.printf("}\n")
+}
+
+// isConstructor reports if package function returns pointer to package type as first result
+func (g *generator) isConstructor(name string) bool {
+	for _, f := range g.pkg.funcs {
+		if f.Name.Name != name || f.Type.TypeParams != nil {
+			continue
+		}
+		results := fieldTypes(f.Type.Results)
+		return len(results) > 0 && g.pkg.resolve(results[0]).kind == kindData
+	}
+	return false
+}
+
+// genBindings prints method definitions, with aliases as Populate defines them
+func (g *generator) genBindings(bindings []binding, define, defineFunc string) {
+	recv := "c"
+	if define == "DefineModuleFunction" {
+		recv = "m"
+	}
+
+	for _, b := range bindings {
+		if b.wrapper != "" {
+			g.printf("\t%v.%v(%q, %v, %v)\n", recv, define, b.name, b.wrapper, b.aspec)
+		} else {
+			g.printf("\t%v.%v(%q, %v)\n", recv, defineFunc, b.name, b.goFunc)
+		}
+
+		// "is_method" is also aliased to "method?", "method_bang" to "method!"
+		if strings.HasPrefix(b.name, "is_") {
+			g.printf("\t%v.DefineAlias(%q, %q)\n", recv, b.name[3:]+"?", b.name)
+		}
+		if strings.HasSuffix(b.name, "_bang") {
+			g.printf("\t%v.DefineAlias(%q, %q)\n", recv, b.name[:len(b.name)-5]+"!", b.name)
+		}
+	}
+}
+
+// genField prints getter and setter of struct field. Setter is skipped for
+// field types which can not be converted statically
+func (g *generator) genField(typeName, field string, t goType) []binding {
+	rname := oruby.SnakeCase(field)
+	getter := "orubygen" + typeName + "Get" + field
+	setter := "orubygen" + typeName + "Set" + field
+
+	g.printf("\nfunc %v(mrb *oruby.MrbState, self oruby.Value) oruby.MrbValue {\n", getter)
+	g.genReceiver(typeName)
+	g.printf("\treturn %v\n}\n", valueExpr(t, "recv."+field))
+
+	ret := []binding{{name: rname, wrapper: getter, aspec: "oruby.ArgsNone()"}}
+
+	if t.kind == kindUnsupported || t.kind == kindError {
+		g.warn(fmt.Sprintf("%v.%v: unsupported field type %v, setter is not generated", typeName, field, t.expr))
+		return ret
+	}
+
+	g.printf("\nfunc %v(mrb *oruby.MrbState, self oruby.Value) oruby.MrbValue {\n", setter)
+	g.genReceiver(typeName)
+	g.printf("\targ := mrb.GetArgsFirst()\n")
+	expr := g.genArg(t, "a0", "arg", rname+"=", "1", true)
+	g.printf("\trecv.%v = %v\n", field, expr)
+	g.printf("\treturn arg\n}\n")
+
+	return append(ret, binding{name: rname + "=", wrapper: setter, aspec: "oruby.ArgsReq(1)"})
+}
+
+func (g *generator) genReceiver(typeName string) {
+	g.printf("\trecv, ok := mrb.DataGetInterface(self).(*%v)\n", typeName)
+	g.printf("\tif !ok {\n\t\treturn mrb.Raise(mrb.ETypeError(), \"receiver is not *%v\")\n\t}\n", typeName)
+}
+
+// genWrapper prints MrbFuncT wrapper calling fn, declared by decl, and returns its aspec.
+// Nothing is printed and false is returned when signature is not supported
+func (g *generator) genWrapper(wrapper, typeName, fn, rname string, decl *ast.FuncDecl) (string, bool) {
+	ft := decl.Type
+	params := fieldTypes(ft.Params)
+	results := fieldTypes(ft.Results)
+	names := fieldNames(ft.Params)
+
+	variadic := false
+	in := make([]goType, len(params))
+	nilOK := make([]bool, len(params))
+	for i, p := range params {
+		if ell, ok := p.(*ast.Ellipsis); ok {
+			variadic = true
+			p = ell.Elt
+		}
+		in[i] = g.pkg.resolve(p)
+		if in[i].kind == kindUnsupported || in[i].kind == kindError {
+			return "", false
+		}
+		nilOK[i] = in[i].kind == kindData && handlesNil(decl.Body, names[i])
+	}
+
+	out := make([]goType, len(results))
+	for i, r := range results {
+		out[i] = g.pkg.resolve(r)
+	}
+
+	g.printf("\nfunc %v(mrb *oruby.MrbState, self oruby.Value) oruby.MrbValue {\n", wrapper)
+	if typeName != "" {
+		g.genReceiver(typeName)
+	}
+
+	var callArgs []string
+	if len(in) > 0 {
+		g.printf("\targs := mrb.GetArgs()\n")
+	}
+	for i, t := range in {
+		dst := fmt.Sprintf("a%d", i)
+		if variadic && i == len(in)-1 {
+			g.printf("\t%v := make([]%v, 0, args.Len())\n", dst, t.expr)
+			g.printf("\tfor i := %d; i < args.Len(); i++ {\n", i)
+			expr := g.genArg(t, "v", "args.Item(i)", rname, "i+1", nilOK[i])
+			g.printf("\t%v = append(%v, %v)\n\t}\n", dst, dst, expr)
+			callArgs = append(callArgs, dst+"...")
+			continue
+		}
+		callArgs = append(callArgs, g.genArg(t, dst, fmt.Sprintf("args.Item(%d)", i), rname, fmt.Sprint(i+1), nilOK[i]))
+	}
+
+	call := fmt.Sprintf("%v(%v)", fn, strings.Join(callArgs, ", "))
+	g.genResults(out, call)
+	g.printf("}\n")
+
+	// Trailing pointer parameters are optional, as in reflection binding, when function
+	// checks them for nil. Other pointer parameters are required and nil is rejected
+	req := len(in)
+	if variadic {
+		req--
+	}
+	opt := 0
+	for req > 0 && nilOK[req-1] {
+		req--
+		opt++
+	}
+
+	aspec := fmt.Sprintf("oruby.ArgsArg(%d, %d)", req, opt)
+	if variadic {
+		aspec += " | oruby.ArgsRest()"
+	}
+	return aspec, true
+}
+
+// genArg prints conversion of oruby value src to Go variable dst,
+// and returns expression of converted value. Nil pointer is accepted when nilOK is set
+func (g *generator) genArg(t goType, dst, src, rname, pos string, nilOK bool) string {
+	checkErr := func() {
+		g.printf("\tif err != nil {\n\t\treturn mrb.RaiseArgError(err, %q, %v)\n\t}\n", rname, pos)
+	}
+	conv := func(native string) string {
+		if t.expr == native {
+			return dst
+		}
+		return fmt.Sprintf("%v(%v)", t.expr, dst)
+	}
+
+	switch t.kind {
+	case kindInt:
+		g.printf("\t%v, err := mrb.IntArg(%v, %d)\n", dst, src, t.bits)
+		checkErr()
+		return conv("int64")
+	case kindUint:
+		g.printf("\t%v, err := mrb.UintArg(%v, %d)\n", dst, src, t.bits)
+		checkErr()
+		return conv("uint64")
+	case kindFloat:
+		g.printf("\t%v, err := mrb.FloatArg(%v, %d)\n", dst, src, t.bits)
+		checkErr()
+		return conv("float64")
+	case kindString:
+		g.printf("\t%v, err := mrb.StringArg(%v)\n", dst, src)
+		checkErr()
+		return conv("string")
+	case kindBytes:
+		g.printf("\t%v, err := mrb.BytesArg(%v)\n", dst, src)
+		checkErr()
+		return dst
+	case kindBool:
+		g.printf("\t%v := oruby.MrbTest(%v)\n", dst, src)
+		return conv("bool")
+	case kindValue:
+		g.printf("\t%v := %v\n", dst, src)
+		return dst
+	case kindIntf:
+		g.printf("\t%v := mrb.Intf(%v)\n", dst, src)
+		return dst
+	case kindData:
+		g.printf("\t%vd, err := mrb.DataArg(%v)\n", dst, src)
+		checkErr()
+		g.printf("\t%v, ok := %vd.(%v)\n", dst, dst, t.expr)
+		if nilOK {
+			g.printf("\tif !ok && %vd != nil {\n", dst)
+		} else {
+			g.printf("\tif !ok {\n")
+		}
+		g.printf("\t\treturn mrb.RaiseArgError(oruby.ETypeError(\"expected %v, got %%T\", %vd), %q, %v)\n\t}\n",
+			t.expr, dst, rname, pos)
+		return dst
+	}
+	panic("unsupported argument type " + t.expr)
+}
+
+// genResults prints call and conversion of its results. Last error result is raised,
+// multiple results are returned as Array, as in reflection binding
+func (g *generator) genResults(out []goType, call string) {
+	hasErr := len(out) > 0 && out[len(out)-1].kind == kindError
+	if hasErr {
+		out = out[:len(out)-1]
+	}
+
+	if len(out) == 0 {
+		if hasErr {
+			g.printf("\tif err := %v; err != nil {\n\t\treturn mrb.RaiseError(err)\n\t}\n", call)
+		} else {
+			g.printf("\t%v\n", call)
+		}
+		g.printf("\treturn mrb.NilValue()\n")
+		return
+	}
+
+	names := make([]string, len(out))
+	values := make([]string, len(out))
+	for i, t := range out {
+		names[i] = fmt.Sprintf("r%d", i)
+		values[i] = valueExpr(t, names[i])
+	}
+
+	if hasErr {
+		g.printf("\t%v, err := %v\n", strings.Join(names, ", "), call)
+		g.printf("\tif err != nil {\n\t\treturn mrb.RaiseError(err)\n\t}\n")
+	} else {
+		g.printf("\t%v := %v\n", strings.Join(names, ", "), call)
+	}
+
+	if len(out) == 1 {
+		g.printf("\treturn %v\n", values[0])
+		return
+	}
+	g.printf("\treturn mrb.AryNewFromValues(%v)\n", strings.Join(values, ", "))
+}
+
+// valueExpr returns expression converting Go value to oruby value
+func valueExpr(t goType, v string) string {
+	switch t.kind {
+	case kindInt:
+		if t.expr != "int64" {
+			v = "int64(" + v + ")"
+		}
+		return "oruby.Int64(" + v + ")"
+	case kindUint:
+		return "mrb.Value(uint64(" + v + "))"
+	case kindFloat:
+		if t.expr != "float64" {
+			v = "float64(" + v + ")"
+		}
+		return "mrb.FloatValue(" + v + ")"
+	case kindString:
+		if t.expr != "string" {
+			v = "string(" + v + ")"
+		}
+		return "mrb.StrNew(" + v + ")"
+	case kindBytes:
+		return "mrb.BytesValue(" + v + ")"
+	case kindBool:
+		if t.expr != "bool" {
+			v = "bool(" + v + ")"
+		}
+		return "oruby.Bool(" + v + ")"
+	case kindValue:
+		return v
+	}
+	return "mrb.Value(" + v + ")"
+}
+
+// fieldNames flattens names of field list as fieldTypes, unnamed fields have empty name
+func fieldNames(fl *ast.FieldList) []string {
+	if fl == nil {
+		return nil
+	}
+	var ret []string
+	for _, f := range fl.List {
+		if len(f.Names) == 0 {
+			ret = append(ret, "")
+		}
+		for _, id := range f.Names {
+			ret = append(ret, id.Name)
+		}
+	}
+	return ret
+}
+
+// handlesNil reports if function body compares parameter name with nil
+func handlesNil(body *ast.BlockStmt, name string) bool {
+	if body == nil || name == "" || name == "_" {
+		return false
+	}
+	found := false
+	ast.Inspect(body, func(n ast.Node) bool {
+		if e, ok := n.(*ast.BinaryExpr); ok && (e.Op == token.EQL || e.Op == token.NEQ) {
+			found = found || isIdent(e.X, name) && isIdent(e.Y, "nil") || isIdent(e.X, "nil") && isIdent(e.Y, name)
+		}
+		return !found
+	})
+	return found
+}
+
+func isIdent(e ast.Expr, name string) bool {
+	id, ok := e.(*ast.Ident)
+	return ok && id.Name == name
+}
+
+// camelCase converts package name to module name: "geo_util" -> "GeoUtil"
+func camelCase(s string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range s {
+		if r == '_' {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
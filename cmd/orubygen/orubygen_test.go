@@ -0,0 +1,101 @@
+package main
+
+import (
+	"flag"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+func TestGenerate(t *testing.T) {
+	pkg, err := loadPackage("testdata/geo", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var warnings []string
+	g := newGenerator(pkg, func(msg string) { warnings = append(warnings, msg) })
+	src, err := g.generate(config{funcs: true, table: true, adapters: []string{"Shape"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if *update {
+		if err := os.WriteFile("testdata/geo_oruby.golden", src, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	golden, err := os.ReadFile("testdata/geo_oruby.golden")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(src) != string(golden) {
+		t.Errorf("generated code differs from testdata/geo_oruby.golden, run go test -update:\n%s", src)
+	}
+
+	// Unsupported signatures are reported and bound with reflection
+	if len(warnings) != 2 || !strings.Contains(warnings[0], "Point.Merge") {
+		t.Errorf("unexpected warnings: %v", warnings)
+	}
+}
+
+// TestGoldenTypeChecks checks generated code together with package it is generated for
+func TestGoldenTypeChecks(t *testing.T) {
+	fset := token.NewFileSet()
+	imp := importer.ForCompiler(fset, "source", nil)
+	if _, err := imp.Import("github.com/oruby/oruby"); err != nil {
+		t.Skipf("oruby package can not be type checked: %v", err)
+	}
+
+	var files []*ast.File
+	for _, name := range []string{"testdata/geo/geo.go", "testdata/geo_oruby.golden"} {
+		f, err := parser.ParseFile(fset, name, nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, f)
+	}
+
+	conf := types.Config{Importer: imp}
+	if _, err := conf.Check("geo", fset, files, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGenerateUnknownType(t *testing.T) {
+	pkg, err := loadPackage("testdata/geo", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := newGenerator(pkg, func(string) {})
+	if _, err := g.generate(config{types: []string{"Missing"}}); err == nil {
+		t.Error("expected error for unknown type")
+	}
+}
+
+func TestGenerateAdapterOfStruct(t *testing.T) {
+	pkg, err := loadPackage("testdata/geo", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := newGenerator(pkg, func(string) {})
+	if _, err := g.generate(config{types: []string{"Point"}, adapters: []string{"Point"}}); err == nil {
+		t.Error("expected error for adapter of struct type")
+	}
+}
+
+func TestNaming(t *testing.T) {
+	if got := camelCase("geo_util"); got != "GeoUtil" {
+		t.Errorf("camelCase = %q", got)
+	}
+}
@@ -0,0 +1,76 @@
+package geo
+
+import (
+	"errors"
+	"math"
+
+	"github.com/oruby/oruby"
+)
+
+// Celsius is named basic type
+type Celsius float64
+
+// Point is bound as Go class
+type Point struct {
+	X, Y  float64
+	Label string
+	Tags  map[string]string
+	next  *Point
+}
+
+// NewPoint constructor
+func NewPoint(x, y float64) *Point { return &Point{X: x, Y: y} }
+
+// Dist returns distance to other point
+func (p *Point) Dist(o *Point) float64 { return math.Hypot(p.X-o.X, p.Y-o.Y) }
+
+// Mid returns middle point, or copy of point when o is nil
+func (p *Point) Mid(o *Point) *Point {
+	if o == nil {
+		return &Point{X: p.X, Y: p.Y}
+	}
+	return &Point{X: (p.X + o.X) / 2, Y: (p.Y + o.Y) / 2}
+}
+
+// IsZero reports zero point
+func (p *Point) IsZero() bool { return p.X == 0 && p.Y == 0 }
+
+// ScaleBang scales point in place
+func (p *Point) ScaleBang(f float64, c ...int) { p.X *= f; p.Y *= f }
+
+// Div returns coordinates divided by n
+func (p Point) Div(n int32) (float64, float64, error) {
+	if n == 0 {
+		return 0, 0, errors.New("division by zero")
+	}
+	return p.X / float64(n), p.Y / float64(n), nil
+}
+
+// Merge uses unsupported argument type
+func (p *Point) Merge(m map[string]int) {}
+
+// Inspect uses oruby value
+func (p *Point) Inspect(v oruby.Value, t Celsius) string { return "" }
+
+// Named is implemented by oruby objects
+type Named interface {
+	Name() string
+}
+
+// Shape is implemented by oruby objects, with generated adapter
+type Shape interface {
+	Named
+	Area() float64
+	Scale(f float64, c ...int) (*Point, error)
+	Reset() error
+	Touch()
+}
+
+// Parse is package function
+func Parse(s string, data []byte) (*Point, error) { return nil, nil }
+
+// Origin is package constant
+const Origin = "origin"
+
+// Precision is package variable
+var Precision = 2
@@ -4,8 +4,10 @@ package oruby
 import "C"
 import (
 	"errors"
+	"fmt"
 	"os"
 	"runtime"
+	"strings"
 	"unsafe"
 )
 
@@ -53,19 +55,45 @@ func (c *MrbcContext) SetNoOptimize(b bool) { C._mrbc_set_no_optimize(c.p, iifmb
 // SetNoExtOps sets NoExtOps on or off
 func (c *MrbcContext) SetNoExtOps(b bool) { C._mrbc_set_no_ext_ops(c.p, iifmb(b)) }
 
+// SetLocals declares local variables known to parser, as they were assigned before parsed code.
+// Locals are allocated in given order, from register 1 of top level code
+func (c *MrbcContext) SetLocals(names ...string) {
+	syms := make([]C.mrb_sym, len(names)+1)
+	for i, name := range names {
+		syms[i] = C.mrb_sym(c.mrb.Intern(name))
+	}
+	C._mrbc_set_syms(c.mrb.state(), c.p, &syms[0], C.int(len(names)))
+}
+
+// Locals returns local variables known to parser. With KeepLV set,
+// locals assigned by parsed code are added after parsing
+func (c *MrbcContext) Locals() []string {
+	names := make([]string, int(c.p.slen))
+	for i := range names {
+		names[i] = c.mrb.SymName(MrbSym(C._mrbc_sym(c.p, C.int(i))))
+	}
+	return names
+}
+
+// KeepLV returns if local variables are kept in context
+func (c *MrbcContext) KeepLV() bool { return C._mrbc_keep_lv(c.p) != 0 }
+
+// SetKeepLV sets keeping of local variables in context on or off
+func (c *MrbcContext) SetKeepLV(b bool) { C._mrbc_set_keep_lv(c.p, iifmb(b)) }
+
 // MrbcContextNew create new context
 func (mrb *MrbState) MrbcContextNew() *MrbcContext {
-	return &MrbcContext{C.mrbc_context_new(mrb.p), mrb}
+	return &MrbcContext{C.mrbc_context_new(mrb.state()), mrb}
 }
 
 // Free MrbcContext
-func (c *MrbcContext) Free() { C.mrbc_context_free(c.mrb.p, c.p) }
+func (c *MrbcContext) Free() { c.mrb.MrbcContextFree(c) }
 
 // Filename set for MrbcContext
 func (c *MrbcContext) Filename(filename string) string {
 	cfn := C.CString(filename)
 	defer C.free(unsafe.Pointer(cfn))
-	return C.GoString(C.mrbc_filename(c.mrb.p, c.p, cfn))
+	return C.GoString(C.mrbc_filename(c.mrb.state(), c.p, cfn))
 }
 
 // PartialHook set parser hook
@@ -75,7 +103,7 @@ func (c *MrbcContext) PartialHook(partialHook PartialHookF) {
 
 // CleanupLocalVariables clears local variables
 func (c *MrbcContext) CleanupLocalVariables() {
-	C.mrbc_cleanup_local_variables(c.mrb.p, c.p)
+	C.mrbc_cleanup_local_variables(c.mrb.state(), c.p)
 }
 
 // LoadFile loads file into oruby context
@@ -95,14 +123,15 @@ func (c *MrbcContext) LoadBytes(buf []byte) (Value, error) {
 
 // MrbcContextFree free context
 func (mrb *MrbState) MrbcContextFree(context *MrbcContext) {
-	C.mrbc_context_free(mrb.p, context.p)
+	mrb.setHook(unsafe.Pointer(context.p), nil)
+	C.mrbc_context_free(mrb.state(), context.p)
 }
 
 // MrbcFilename return filename
 func (mrb *MrbState) MrbcFilename(context *MrbcContext, filename string) string {
 	cfn := C.CString(filename)
 	defer C.free(unsafe.Pointer(cfn))
-	return C.GoString(C.mrbc_filename(mrb.p, context.p, cfn))
+	return C.GoString(C.mrbc_filename(mrb.state(), context.p, cfn))
 }
 
 // PartialHookF type of function for hook
@@ -126,7 +155,7 @@ func (mrb *MrbState) MrbcContextPartialHook(cxt *MrbcContext, partialHook Partia
 
 // MrbcContextCleanupLocalVariables clear local variables
 func (mrb *MrbState) MrbcContextCleanupLocalVariables(cxt *MrbcContext) {
-	C.mrbc_cleanup_local_variables(mrb.p, cxt.p)
+	C.mrbc_cleanup_local_variables(mrb.state(), cxt.p)
 }
 
 // MrbAstNode AST node structure
@@ -245,6 +274,23 @@ func (p MrbParserState) ErrorBuffer(i int) MrbParserMessage {
 		C.GoString(p.p.error_buffer[i].message)}
 }
 
+// ErrorBufferLen returns number of messages in error buffer. Parser keeps only first
+// messages, so it can be less than NErr
+func (p MrbParserState) ErrorBufferLen() int {
+	if n := p.NErr(); n < len(p.p.error_buffer) {
+		return n
+	}
+	return len(p.p.error_buffer)
+}
+
+// WarnBufferLen returns number of messages in warning buffer
+func (p MrbParserState) WarnBufferLen() int {
+	if n := p.NWarn(); n < len(p.p.warn_buffer) {
+		return n
+	}
+	return len(p.p.warn_buffer)
+}
+
 // WarnBuffer creates warning parser message
 func (p MrbParserState) WarnBuffer(i int) MrbParserMessage {
 	return MrbParserMessage{
@@ -275,6 +321,32 @@ func (p MrbParserState) Tree() *MrbAstNode { return astNode(p.p.tree) }
 // LState lexer state constant from lexer state enum (ExprBeg..ExprMaxState)
 func (p MrbParserState) LState() int { return int(p.p.lstate) }
 
+// IsCodeBlockOpen guesses if parsed code needs more lines, like unterminated string,
+// heredoc, block or statement, or if it can be evaluated now. Used by REPLs to read
+// code of more lines
+func (p MrbParserState) IsCodeBlockOpen() bool {
+	// unterminated heredoc or string
+	if p.ParsingHeredoc() != nil || p.LexStrTerm() {
+		return true
+	}
+
+	// code ended where more code was expected, other errors are shown to user
+	if p.NErr() > 0 {
+		switch p.ErrorBuffer(0).Message {
+		case "syntax error, unexpected $end", "syntax error, unexpected end of file":
+			return true
+		}
+		return false
+	}
+
+	switch p.LState() {
+	case ExprDot, ExprClass, ExprFname, ExprValue:
+		// message dot, class keyword, method definition or condition needs more code
+		return true
+	}
+	return false
+}
+
 // IsNil checks if parser state exists
 func (p MrbParserState) IsNil() bool { return p.p == nil }
 
@@ -312,7 +384,7 @@ func (p MrbParserState) SetB(buf []byte) func() {
 
 // ParserNew creates new parser state
 func (mrb *MrbState) ParserNew() MrbParserState {
-	return MrbParserState{C.mrb_parser_new(mrb.p)}
+	return MrbParserState{C.mrb_parser_new(mrb.state())}
 }
 
 // Free releases parser state
@@ -359,7 +431,7 @@ func (mrb *MrbState) ParseFile(fileName string, context *MrbcContext) (MrbParser
 func (mrb *MrbState) ParseString(s string, context *MrbcContext) (MrbParserState, error) {
 	cs := C.CString(s)
 	defer C.free(unsafe.Pointer(cs))
-	p := C.mrb_parse_nstring(mrb.p, cs, C.size_t(len(s)), context.p)
+	p := C.mrb_parse_nstring(mrb.state(), cs, C.size_t(len(s)), context.p)
 	if p == nil {
 		return MrbParserState{nil}, errors.New("create parser state error")
 	}
@@ -369,7 +441,7 @@ func (mrb *MrbState) ParseString(s string, context *MrbcContext) (MrbParserState
 
 // GenerateCode generates RPros
 func (mrb *MrbState) GenerateCode(parser MrbParserState) (RProc, error) {
-	p := C.mrb_generate_code(mrb.p, parser.p)
+	p := C.mrb_generate_code(mrb.state(), parser.p)
 
 	if p == nil {
 		return RProc{nil, mrb}, errors.New("error generating parser code")
@@ -380,7 +452,7 @@ func (mrb *MrbState) GenerateCode(parser MrbParserState) (RProc, error) {
 
 // LoadExec loads and executes parser context, returning Value
 func (mrb *MrbState) LoadExec(parser MrbParserState, context *MrbcContext) Value {
-	return Value{C.mrb_load_exec(mrb.p, parser.p, context.p)}
+	return Value{C.mrb_load_exec(mrb.state(), parser.p, context.p)}
 }
 
 // LoadFile loads file to oruby value
@@ -394,12 +466,16 @@ func (mrb *MrbState) LoadFile(fileName string) (Value, error) {
 }
 
 // LoadString loads string to oruby value
-func (mrb *MrbState) LoadString(s string) (Value, error) {
+func (mrb *MrbState) LoadString(s string) (ret Value, err error) {
+	if mrb.remote(func() { ret, err = mrb.LoadString(s) }) {
+		return ret, err
+	}
+
 	cs := C.CString(s)
 	defer C.free(unsafe.Pointer(cs))
 
 	// Will not throw, sets mrb->exc exception
-	ret := Value{C.mrb_load_nstring(mrb.p, cs, C.size_t(len(s)))}
+	ret = Value{C.mrb_load_nstring(mrb.state(), cs, C.size_t(len(s)))}
 	return ret, mrb.Err()
 
 	// pure C.mrb_load_string() is never called
@@ -422,16 +498,19 @@ func (mrb *MrbState) LoadStringCxt(s string, context *MrbcContext) (Value, error
 }
 
 // LoadBytesCxt loads bytes into oruby context
-func (mrb *MrbState) LoadBytesCxt(buf []byte, context *MrbcContext) (Value, error) {
+func (mrb *MrbState) LoadBytesCxt(buf []byte, context *MrbcContext) (ret Value, err error) {
+	if mrb.remote(func() { ret, err = mrb.LoadBytesCxt(buf, context) }) {
+		return ret, err
+	}
+
 	if len(buf) == 0 {
 		return nilValue, errors.New("empty buffer")
 	}
-	var ret Value
 
 	if len(buf) == 0 {
-		ret = Value{C.mrb_load_string_cxt(mrb.p, nil, context.p)}
+		ret = Value{C.mrb_load_string_cxt(mrb.state(), nil, context.p)}
 	} else {
-		ret = Value{C.mrb_load_nstring_cxt(mrb.p, (*C.char)(unsafe.Pointer(&buf[0])), C.size_t(len(buf)), context.p)}
+		ret = Value{C.mrb_load_nstring_cxt(mrb.state(), (*C.char)(unsafe.Pointer(&buf[0])), C.size_t(len(buf)), context.p)}
 		runtime.KeepAlive(buf)
 	}
 
@@ -440,10 +519,25 @@ func (mrb *MrbState) LoadBytesCxt(buf []byte, context *MrbcContext) (Value, erro
 
 // CodedumpAll helper for oruby cmd
 func (mrb *MrbState) CodedumpAll(proc RProc) {
-	C.mrb_codedump_all(mrb.p, proc.p)
+	C.mrb_codedump_all(mrb.state(), proc.p)
 }
 
 // SetLastStackValue helper for orbi cmd
 func (mrb *MrbState) SetLastStackValue(v Value) {
-	C._set_last_stack_value(mrb.p, v.v)
+	C._set_last_stack_value(mrb.state(), v.v)
+}
+
+// parserErrors returns parser errors as one error, or nil if code was parsed without errors
+func parserErrors(p MrbParserState, filename string) error {
+	if p.NErr() == 0 {
+		return nil
+	}
+
+	var msgs []string
+	for _, d := range p.Diagnostics() {
+		if d.Severity == SeverityError {
+			msgs = append(msgs, fmt.Sprintf("%s:%d:%d: %s", filename, d.Line, d.Column, d.Message))
+		}
+	}
+	return errors.New(strings.Join(msgs, "\n"))
 }
@@ -0,0 +1,344 @@
+package oruby
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// ToRubyFunc converts Go value of registered type to oruby value
+type ToRubyFunc func(mrb *MrbState, v interface{}) (Value, error)
+
+// FromRubyFunc converts oruby value to Go value of registered type.
+// When used by Intf, converter which returns error is skipped
+type FromRubyFunc func(mrb *MrbState, v Value) (interface{}, error)
+
+// typeConverter is registered pair of conversion functions for Go type
+type typeConverter struct {
+	t        reflect.Type
+	toRuby   ToRubyFunc
+	fromRuby FromRubyFunc
+}
+
+// RegisterConverter teaches oruby state how to convert Go type to oruby value and back.
+// goType is reflect.Type or zero value of the type, as in AttachType.
+// Either of functions can be nil, if conversion is needed only in one direction.
+//
+// Converters are used by Value, Intf, Scan, attribute setters of Go classes and
+// arguments of Go functions called from oruby. When target type is known, converter
+// is looked up by type. Intf does not know the target, so it tries fromRuby functions
+// in registration order for objects, data and istruct values, skipping ones returning error.
+// When toRuby fails, Value returns nil, ValueErr returns the error and Go function
+// returning the value raises it.
+func (mrb *MrbState) RegisterConverter(goType interface{}, toRuby ToRubyFunc, fromRuby FromRubyFunc) {
+	t, ok := goType.(reflect.Type)
+	if !ok {
+		t = reflect.TypeOf(goType)
+	}
+	if t == nil {
+		panic("RegisterConverter: Go type is required")
+	}
+
+	mrb.Lock()
+	defer mrb.Unlock()
+
+	// Re-registration replaces converter, keeping original order
+	converters := append([]*typeConverter(nil), mrb.converterList()...)
+	for i, c := range converters {
+		if c.t == t {
+			converters[i] = &typeConverter{t, toRuby, fromRuby}
+			mrb.converters.Store(&converters)
+			return
+		}
+	}
+	converters = append(converters, &typeConverter{t, toRuby, fromRuby})
+	mrb.converters.Store(&converters)
+}
+
+// UnregisterConverter removes converter for Go type
+func (mrb *MrbState) UnregisterConverter(goType interface{}) {
+	t, ok := goType.(reflect.Type)
+	if !ok {
+		t = reflect.TypeOf(goType)
+	}
+
+	mrb.Lock()
+	defer mrb.Unlock()
+
+	var converters []*typeConverter
+	for _, c := range mrb.converterList() {
+		if c.t != t {
+			converters = append(converters, c)
+		}
+	}
+	mrb.converters.Store(&converters)
+}
+
+// converterList returns snapshot of registered converters. Registration replaces
+// the list, so snapshot can be read without lock
+func (mrb *MrbState) converterList() []*typeConverter {
+	if p := mrb.converters.Load(); p != nil {
+		return *p
+	}
+	return nil
+}
+
+// converterFor returns converter registered for type t, or nil
+func (mrb *MrbState) converterFor(t reflect.Type) *typeConverter {
+	if t == nil {
+		return nil
+	}
+
+	for _, c := range mrb.converterList() {
+		if c.t == t {
+			return c
+		}
+	}
+	return nil
+}
+
+// ValueErr converts Go value to oruby value as Value does, returning first error
+// of registered converter, including converters of nested values
+func (mrb *MrbState) ValueErr(o interface{}) (Value, error) {
+	return mrb.valueErr(func() Value { return mrb.Value(o) })
+}
+
+// valueErr runs conversion f, returning first error of registered converter
+func (mrb *MrbState) valueErr(f func() Value) (v Value, err error) {
+	prev := mrb.convertErr
+	mrb.convertErr = &err
+	defer func() { mrb.convertErr = prev }()
+
+	if v = f(); err != nil {
+		return nilValue, err
+	}
+	return v, nil
+}
+
+// convertedValue converts Go value with registered converter. Failed value is nil,
+// and error is kept for ValueErr
+func (mrb *MrbState) convertedValue(o interface{}) (Value, bool) {
+	c := mrb.converterFor(reflect.TypeOf(o))
+	if c == nil || c.toRuby == nil {
+		return nilValue, false
+	}
+
+	v, err := c.toRuby(mrb, o)
+	if err != nil {
+		if mrb.convertErr != nil && *mrb.convertErr == nil {
+			*mrb.convertErr = err
+		}
+		return nilValue, true
+	}
+	return v, true
+}
+
+// convertedIntf converts oruby value with first registered converter accepting it
+func (mrb *MrbState) convertedIntf(o MrbValue) (interface{}, bool) {
+	for _, c := range mrb.converterList() {
+		if c.fromRuby == nil {
+			continue
+		}
+		if v, err := c.fromRuby(mrb, o.Value()); err == nil {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// fromValue converts oruby value to Go value of type t
+func (c *typeConverter) fromValue(mrb *MrbState, v Value, t reflect.Type) (reflect.Value, error) {
+	if v.IsNil() {
+		return reflect.Zero(t), nil
+	}
+
+	res, err := c.fromRuby(mrb, v)
+	if err != nil {
+		return reflect.Zero(t), err
+	}
+
+	rv := reflect.ValueOf(res)
+	switch {
+	case !rv.IsValid():
+		return reflect.Zero(t), nil
+	case rv.Type() == t:
+		return rv, nil
+	case rv.Type().ConvertibleTo(t):
+		return rv.Convert(t), nil
+	}
+
+	return reflect.Zero(t), ETypeError("converter for %v returned %v", t, rv.Type())
+}
+
+// convertValue converts oruby value to Go value of type t, using registered
+// converters first, then generic Intf conversion
+func (mrb *MrbState) convertValue(v Value, t reflect.Type) (reflect.Value, error) {
+	if c := mrb.converterFor(t); c != nil && c.fromRuby != nil {
+		return c.fromValue(mrb, v, t)
+	}
+
+	// Optional argument of converted type
+	if t.Kind() == reflect.Ptr {
+		if c := mrb.converterFor(t.Elem()); c != nil && c.fromRuby != nil {
+			if v.IsNil() {
+				return reflect.Zero(t), nil
+			}
+			e, err := c.fromValue(mrb, v, t.Elem())
+			if err != nil {
+				return reflect.Zero(t), err
+			}
+			p := reflect.New(t.Elem())
+			p.Elem().Set(e)
+			return p, nil
+		}
+	}
+
+	return assignValue(mrb.Intf(v), t)
+}
+
+// argValue converts i-th argument to Go value of type t
+func (mrb *MrbState) argValue(args Arguments, i int, t reflect.Type) (reflect.Value, error) {
+	if a, ok := args.(RArgs); ok {
+		return mrb.convertValue(a.Item(i), t)
+	}
+	return assignValue(args.Get(mrb, i), t)
+}
+
+// RegisterStdConverters registers converters for common Go types:
+// time.Time as Time, time.Duration as Float seconds,
+// *big.Int as Integer and net.IP as String
+func (mrb *MrbState) RegisterStdConverters() {
+	mrb.RegisterConverter(time.Time{}, TimeToRuby, TimeFromRuby)
+	mrb.RegisterConverter(time.Duration(0), DurationToRuby, DurationFromRuby)
+	mrb.RegisterConverter((*big.Int)(nil), BigIntToRuby, BigIntFromRuby)
+	mrb.RegisterConverter(net.IP{}, IPToRuby, IPFromRuby)
+}
+
+// TimeToRuby converts time.Time to oruby Time, with microsecond precision
+func TimeToRuby(mrb *MrbState, v interface{}) (Value, error) {
+	t := v.(time.Time)
+	if !mrb.ClassDefined("Time") {
+		return nilValue, ENameError("uninitialized constant Time")
+	}
+	return mrb.Funcall(mrb.ClassGet("Time"), mrb.Intern("at"), t.Unix(), t.Nanosecond()/1000)
+}
+
+// TimeFromRuby converts oruby Time (any object responding to to_i and usec) to time.Time
+func TimeFromRuby(mrb *MrbState, v Value) (interface{}, error) {
+	if v.Type() == MrbTTCData {
+		if t, ok := mrb.Data(v).(time.Time); ok {
+			return t, nil
+		}
+	}
+
+	if !mrb.ClassDefined("Time") || !mrb.ObjIsKindOf(v, mrb.ClassGet("Time")) {
+		return nil, ETypeError("%v is not a Time", mrb.TypeName(v))
+	}
+
+	sec, err := mrb.Funcall(v, mrb.Intern("to_i"))
+	if err != nil {
+		return nil, err
+	}
+	usec, err := mrb.Funcall(v, mrb.Intern("usec"))
+	if err != nil {
+		return nil, err
+	}
+	return time.Unix(int64(sec.Int()), int64(usec.Int())*1000), nil
+}
+
+// DurationToRuby converts time.Duration to Float seconds
+func DurationToRuby(mrb *MrbState, v interface{}) (Value, error) {
+	return mrb.FloatValue(v.(time.Duration).Seconds()), nil
+}
+
+// DurationFromRuby converts Integer or Float seconds to time.Duration
+func DurationFromRuby(mrb *MrbState, v Value) (interface{}, error) {
+	switch v.Type() {
+	case MrbTTInteger:
+		return time.Duration(v.Int()) * time.Second, nil
+	case MrbTTFloat:
+		return time.Duration(v.Float64() * float64(time.Second)), nil
+	}
+	return nil, ETypeError("can't convert %v into time.Duration", mrb.TypeName(v))
+}
+
+// BigIntToRuby converts *big.Int to Integer. Values out of Integer range
+// require Integer to accept them, as with bigint gem
+func BigIntToRuby(mrb *MrbState, v interface{}) (Value, error) {
+	b := v.(*big.Int)
+	if b == nil {
+		return nilValue, nil
+	}
+	if b.IsInt64() {
+		return mrb.Value(b.Int64()), nil
+	}
+	return mrb.Funcall(mrb.KernelModule(), mrb.Intern("Integer"), b.String())
+}
+
+// BigIntFromRuby converts Integer, or any value whose to_s is an integer, to *big.Int
+func BigIntFromRuby(mrb *MrbState, v Value) (interface{}, error) {
+	switch v.Type() {
+	case MrbTTInteger:
+		return big.NewInt(int64(v.Int())), nil
+	case MrbTTBigInt, MrbTTString:
+		if b, ok := new(big.Int).SetString(mrb.String(v), 10); ok {
+			return b, nil
+		}
+	}
+	return nil, ETypeError("can't convert %v into big.Int", mrb.TypeName(v))
+}
+
+// IPToRuby converts net.IP to String
+func IPToRuby(mrb *MrbState, v interface{}) (Value, error) {
+	return mrb.StrNew(v.(net.IP).String()), nil
+}
+
+// IPFromRuby parses String as net.IP
+func IPFromRuby(mrb *MrbState, v Value) (interface{}, error) {
+	if v.Type() != MrbTTString {
+		return nil, ETypeError("can't convert %v into net.IP", mrb.TypeName(v))
+	}
+	ip := net.ParseIP(mrb.String(v))
+	if ip == nil {
+		return nil, EArgumentError("invalid IP address '%v'", mrb.String(v))
+	}
+	return ip, nil
+}
+
+// UUIDToRuby converts 16 byte array types, like UUIDs, to canonical String
+// xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx. Register it for the specific UUID type:
+//
+//	mrb.RegisterConverter(uuid.UUID{}, oruby.UUIDToRuby, oruby.UUIDFromRuby)
+func UUIDToRuby(mrb *MrbState, v interface{}) (Value, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Array || rv.Len() != 16 || rv.Type().Elem().Kind() != reflect.Uint8 {
+		return nilValue, ETypeError("%v is not a 16 byte array", rv.Type())
+	}
+
+	var b [16]byte
+	reflect.Copy(reflect.ValueOf(b[:]), rv)
+	h := hex.EncodeToString(b[:])
+	return mrb.StrNew(fmt.Sprintf("%v-%v-%v-%v-%v", h[0:8], h[8:12], h[12:16], h[16:20], h[20:])), nil
+}
+
+// UUIDFromRuby parses canonical UUID String to [16]byte, which converts to registered type
+func UUIDFromRuby(mrb *MrbState, v Value) (interface{}, error) {
+	if v.Type() != MrbTTString {
+		return nil, ETypeError("can't convert %v into UUID", mrb.TypeName(v))
+	}
+
+	var b [16]byte
+	s := strings.ReplaceAll(mrb.String(v), "-", "")
+	if len(s) != 32 {
+		return nil, EArgumentError("invalid UUID '%v'", mrb.String(v))
+	}
+	if _, err := hex.Decode(b[:], []byte(s)); err != nil {
+		return nil, errors.New("invalid UUID: " + err.Error())
+	}
+	return b, nil
+}
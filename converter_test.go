@@ -0,0 +1,137 @@
+package oruby
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+type testCelsius struct{ Deg float64 }
+
+func celsiusToRuby(mrb *MrbState, v interface{}) (Value, error) {
+	return mrb.FloatValue(v.(testCelsius).Deg), nil
+}
+
+func celsiusFromRuby(mrb *MrbState, v Value) (interface{}, error) {
+	switch v.Type() {
+	case MrbTTFloat, MrbTTInteger:
+		return testCelsius{v.Float64()}, nil
+	}
+	return nil, ETypeError("%v is not a temperature", mrb.TypeName(v))
+}
+
+func TestMrbState_RegisterConverter(t *testing.T) {
+	mrb := MrbOpen()
+	defer mrb.Close()
+
+	mrb.RegisterConverter(testCelsius{}, celsiusToRuby, celsiusFromRuby)
+
+	v := mrb.Value(testCelsius{21.5})
+	ExpectEql(t, v.Type(), MrbTTFloat)
+	ExpectEql(t, v.Float64(), 21.5)
+
+	// Nested values are converted too
+	v = mrb.Value([]testCelsius{{1}, {2}})
+	ExpectEql(t, mrb.Intf(v), []interface{}{1.0, 2.0})
+
+	// Function arguments
+	mrb.GVSet(mrb.Intern("$warm"), mrb.Value(func(c testCelsius) bool { return c.Deg > 20 }))
+	res, err := mrb.Eval(`[$warm.call(25), $warm.call(10.5)]`)
+	ExpectNilError(t, err)
+	ExpectEql(t, mrb.Intf(res), []interface{}{true, false})
+
+	_, err = mrb.Eval(`$warm.call("hot")`)
+	ExpectErr(t, err, "converter error must be raised")
+
+	// Variadic arguments
+	mrb.GVSet(mrb.Intern("$sum"), mrb.Value(func(cs ...testCelsius) float64 {
+		sum := 0.0
+		for _, c := range cs {
+			sum += c.Deg
+		}
+		return sum
+	}))
+	res, err = mrb.Eval(`$sum.call(1, 2.5)`)
+	ExpectNilError(t, err)
+	ExpectEql(t, res.Float64(), 3.5)
+
+	_, err = mrb.Eval(`$sum.call(1, "hot")`)
+	ExpectErr(t, err, "converter error of variadic argument must be raised")
+
+	// Scan
+	var c testCelsius
+	ExpectNilError(t, mrb.Scan(mrb.FloatValue(3), &c))
+	ExpectEql(t, c, testCelsius{3})
+
+	mrb.UnregisterConverter(testCelsius{})
+	ExpectEql(t, mrb.Value(testCelsius{1}).Type(), MrbTTCData)
+}
+
+type testKelvin struct{ Deg float64 }
+
+func TestMrbState_ValueErr(t *testing.T) {
+	mrb := MrbOpen()
+	defer mrb.Close()
+
+	mrb.RegisterConverter(testKelvin{}, func(mrb *MrbState, v interface{}) (Value, error) {
+		if k := v.(testKelvin); k.Deg >= 0 {
+			return mrb.FloatValue(k.Deg), nil
+		}
+		return nilValue, ERangeError("below absolute zero")
+	}, nil)
+
+	// Value converts failed value to nil, without pending exception
+	v := mrb.Value(testKelvin{-1})
+	ExpectEql(t, v.IsNil(), true)
+	Expect(t, mrb.Exc() == nil, "exception should not be pending, got %v", mrb.Exc())
+
+	v, err := mrb.ValueErr(testKelvin{1})
+	ExpectNilError(t, err)
+	ExpectEql(t, v.Float64(), 1.0)
+
+	// Nested values report error too
+	_, err = mrb.ValueErr([]testKelvin{{1}, {-1}})
+	ExpectErr(t, err, "converter error of nested value must be returned")
+
+	// Results of Go functions raise error
+	mrb.GVSet(mrb.Intern("$cool"), mrb.Value(func(d float64) testKelvin { return testKelvin{d} }))
+	_, err = mrb.Eval(`$cool.call(-5)`)
+	ExpectErr(t, err, "converter error of result must be raised")
+}
+
+func TestMrbState_RegisterStdConverters(t *testing.T) {
+	mrb := MrbOpen()
+	defer mrb.Close()
+
+	mrb.RegisterStdConverters()
+
+	v := mrb.Value(1500 * time.Millisecond)
+	ExpectEql(t, v.Float64(), 1.5)
+
+	var d time.Duration
+	ExpectNilError(t, mrb.Scan(mrb.Value(2), &d))
+	ExpectEql(t, d, 2*time.Second)
+
+	v = mrb.Value(net.ParseIP("10.0.0.1"))
+	ExpectEql(t, mrb.String(v), "10.0.0.1")
+
+	var ip net.IP
+	ExpectNilError(t, mrb.Scan(v, &ip))
+	Expect(t, ip.Equal(net.ParseIP("10.0.0.1")), "ip should be parsed, got %v", ip)
+}
+
+func TestUUIDConverter(t *testing.T) {
+	mrb := MrbOpen()
+	defer mrb.Close()
+
+	type uuid [16]byte
+	mrb.RegisterConverter(uuid{}, UUIDToRuby, UUIDFromRuby)
+
+	id := uuid{0x12, 0x3e, 0x45, 0x67, 0xe8, 0x9b, 0x12, 0xd3, 0xa4, 0x56, 0x42, 0x66, 0x14, 0x17, 0x40, 0x00}
+	v := mrb.Value(id)
+	ExpectEql(t, mrb.String(v), "123e4567-e89b-12d3-a456-426614174000")
+
+	var back uuid
+	ExpectNilError(t, mrb.Scan(v, &back))
+	ExpectEql(t, back, id)
+}
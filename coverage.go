@@ -0,0 +1,335 @@
+package oruby
+
+// #include "go-mrb.h"
+import "C"
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"unsafe"
+)
+
+// coverageFilename is filename of code compiled without filename
+const coverageFilename = "(eval)"
+
+// irepCoverage holds instruction counts of executed irep
+type irepCoverage struct {
+	irep     *C.mrb_irep
+	filename string
+	code     []Instruction
+	counts   []uint32 // C allocated execution counts, indexed by instruction pc
+}
+
+// coverage collects execution counts of ireps. Counted ireps are referenced until
+// coverage stops, so they are not freed and their addresses are not reused by other ireps
+type coverage struct {
+	ireps map[*C.mrb_irep]*irepCoverage
+	all   []*irepCoverage
+}
+
+// LineCoverage is execution count of source line
+type LineCoverage struct {
+	Line  int
+	Count int
+}
+
+// BranchCoverage is execution count of conditional branch, compiled from if, unless,
+// while, until, ternary, and, or and safe navigation operator
+type BranchCoverage struct {
+	Line     int // line of condition
+	ThenLine int
+	ElseLine int
+	Then     int // count of branch executed when condition is true
+	Else     int // count of branch executed when condition is false or nil
+}
+
+// FileCoverage is line and branch coverage of source file
+type FileCoverage struct {
+	Filename string
+	Lines    []LineCoverage   // executable lines, ordered by line
+	Branches []BranchCoverage // ordered by line
+}
+
+// CoverageReport is coverage of executed source files
+type CoverageReport struct {
+	Files []*FileCoverage // ordered by filename
+}
+
+// StartCoverage starts collecting line and branch coverage of executed code, including
+// code run by Eval, Load and require. Coverage is counted on VM debug hook, so only
+// code executed while coverage runs is reported
+func (mrb *MrbState) StartCoverage() (err error) {
+	if mrb.remote(func() { err = mrb.StartCoverage() }) {
+		return err
+	}
+	if mrb.coverage != nil {
+		return errors.New("coverage measurement is already setup")
+	}
+
+	mrb.coverage = &coverage{ireps: make(map[*C.mrb_irep]*irepCoverage)}
+	C._mrb_set_coverage(mrb.state(), iifmb(true))
+	return nil
+}
+
+// CoverageRunning returns true if coverage is being collected
+func (mrb *MrbState) CoverageRunning() (running bool) {
+	if mrb.remote(func() { running = mrb.CoverageRunning() }) {
+		return running
+	}
+	return mrb.coverage != nil
+}
+
+// PeekCoverage returns coverage collected so far, without stopping coverage.
+// It returns nil if coverage is not running
+func (mrb *MrbState) PeekCoverage() (report *CoverageReport) {
+	if mrb.remote(func() { report = mrb.PeekCoverage() }) {
+		return report
+	}
+	if mrb.coverage == nil {
+		return nil
+	}
+	return mrb.coverage.report()
+}
+
+// StopCoverage stops coverage and returns collected coverage, or nil if coverage is not running
+func (mrb *MrbState) StopCoverage() (report *CoverageReport) {
+	if mrb.remote(func() { report = mrb.StopCoverage() }) {
+		return report
+	}
+	cov := mrb.coverage
+	if cov == nil {
+		return nil
+	}
+
+	if mrb.p != nil {
+		C._mrb_set_coverage(mrb.state(), iifmb(false))
+	}
+	mrb.coverage = nil
+
+	report = cov.report()
+	cov.free(mrb)
+	return report
+}
+
+//export go_coverage_callback
+func go_coverage_callback(mrbidx C.mrb_int, p *C.mrb_irep, ilen *C.uint32_t) *C.uint32_t {
+	mrb := getMrbStateIndex(int(mrbidx))
+	if mrb.coverage == nil {
+		return nil
+	}
+	ic := mrb.coverage.add(MrbIrep{p, mrb})
+	if ic == nil {
+		return nil
+	}
+	*ilen = C.uint32_t(len(ic.counts))
+	return (*C.uint32_t)(unsafe.Pointer(&ic.counts[0]))
+}
+
+// add returns coverage of irep, adding irep and its child ireps when irep is run
+// first time. Child ireps are added so lines of methods and blocks that are never
+// called are reported too
+func (cov *coverage) add(irep MrbIrep) *irepCoverage {
+	p := irep.p
+	if p.ilen == 0 {
+		return nil
+	}
+	if ic, ok := cov.ireps[p]; ok {
+		return ic
+	}
+
+	code, err := decodeISeq(irep.ISeq())
+	if err != nil {
+		return nil
+	}
+	mrb := irep.mrb
+	for i := range code {
+		if line := int32(mrb.DebugGetLine(irep, uint32(code[i].PC))); line > 0 {
+			code[i].Line = int(line)
+		}
+	}
+
+	filename := mrb.DebugGetFilename(irep, 0)
+	if filename == "" {
+		filename = coverageFilename
+	}
+
+	counts := C.calloc(C.size_t(p.ilen), C.sizeof_uint32_t)
+	ic := &irepCoverage{
+		irep:     p,
+		filename: filename,
+		code:     code,
+		counts:   unsafe.Slice((*uint32)(counts), int(p.ilen)),
+	}
+	cov.ireps[p] = ic
+	cov.all = append(cov.all, ic)
+	irep.Incref()
+
+	for i := 0; i < irep.RLen(); i++ {
+		if child := irep.Reps(i); !child.IsNil() {
+			cov.add(child)
+		}
+	}
+	return ic
+}
+
+// free releases execution counts and references of counted ireps
+func (cov *coverage) free(mrb *MrbState) {
+	for _, ic := range cov.all {
+		C.free(unsafe.Pointer(&ic.counts[0]))
+		ic.counts = nil
+		C.mrb_irep_decref(mrb.state(), ic.irep)
+	}
+	cov.all = nil
+	cov.ireps = nil
+}
+
+// report returns coverage of executed ireps by source file. Count of line is highest
+// count of its instructions. Branch counts are counts of conditional jump target and
+// of instruction following it, limited to count of jump
+func (cov *coverage) report() *CoverageReport {
+	type fileData struct {
+		lines    map[int]int
+		branches []BranchCoverage
+	}
+	files := map[string]*fileData{}
+
+	for _, ic := range cov.all {
+		fd := files[ic.filename]
+		if fd == nil {
+			fd = &fileData{lines: map[int]int{}}
+			files[ic.filename] = fd
+		}
+
+		index := make(map[int]int, len(ic.code))
+		for i, ins := range ic.code {
+			index[ins.PC] = i
+		}
+
+		for _, ins := range ic.code {
+			if ins.Line == 0 {
+				continue
+			}
+			count := int(ic.counts[ins.PC])
+			if c, ok := fd.lines[ins.Line]; !ok || count > c {
+				fd.lines[ins.Line] = count
+			}
+
+			target := ins.Target()
+			if target < 0 || ins.Name == "JMP" || ins.Name == "JMPUW" {
+				continue
+			}
+			next, ok := index[ins.PC+ins.Size]
+			jump, ok2 := index[target]
+			if !ok || !ok2 {
+				continue
+			}
+
+			taken, through := ic.code[jump], ic.code[next]
+			takenCount, throughCount := int(ic.counts[taken.PC]), int(ic.counts[through.PC])
+			if takenCount > count {
+				takenCount = count
+			}
+			if throughCount > count {
+				throughCount = count
+			}
+
+			b := BranchCoverage{Line: ins.Line}
+			if ins.Name == "JMPIF" {
+				b.ThenLine, b.Then, b.ElseLine, b.Else = taken.Line, takenCount, through.Line, throughCount
+			} else {
+				b.ThenLine, b.Then, b.ElseLine, b.Else = through.Line, throughCount, taken.Line, takenCount
+			}
+			fd.branches = append(fd.branches, b)
+		}
+	}
+
+	report := &CoverageReport{}
+	for filename, fd := range files {
+		fc := &FileCoverage{Filename: filename, Branches: fd.branches}
+		for line, count := range fd.lines {
+			fc.Lines = append(fc.Lines, LineCoverage{line, count})
+		}
+		sort.Slice(fc.Lines, func(i, j int) bool { return fc.Lines[i].Line < fc.Lines[j].Line })
+		sort.SliceStable(fc.Branches, func(i, j int) bool { return fc.Branches[i].Line < fc.Branches[j].Line })
+		report.Files = append(report.Files, fc)
+	}
+	sort.Slice(report.Files, func(i, j int) bool { return report.Files[i].Filename < report.Files[j].Filename })
+	return report
+}
+
+// File returns coverage of source file, or nil if file was not executed
+func (r *CoverageReport) File(filename string) *FileCoverage {
+	for _, fc := range r.Files {
+		if fc.Filename == filename {
+			return fc
+		}
+	}
+	return nil
+}
+
+// WriteLCOV writes coverage in LCOV tracefile format, as read by genhtml
+func (r *CoverageReport) WriteLCOV(w io.Writer) error {
+	ew := &errWriter{w: w}
+	ew.printf("TN:\n")
+	for _, fc := range r.Files {
+		ew.printf("SF:%s\n", fc.Filename)
+
+		hit := 0
+		for _, l := range fc.Lines {
+			ew.printf("DA:%d,%d\n", l.Line, l.Count)
+			if l.Count > 0 {
+				hit++
+			}
+		}
+		ew.printf("LF:%d\nLH:%d\n", len(fc.Lines), hit)
+
+		hit = 0
+		for i, b := range fc.Branches {
+			ew.printf("BRDA:%d,%d,0,%s\n", b.Line, i, lcovBranchCount(b, b.Then))
+			ew.printf("BRDA:%d,%d,1,%s\n", b.Line, i, lcovBranchCount(b, b.Else))
+			if b.Then > 0 {
+				hit++
+			}
+			if b.Else > 0 {
+				hit++
+			}
+		}
+		ew.printf("BRF:%d\nBRH:%d\n", 2*len(fc.Branches), hit)
+		ew.printf("end_of_record\n")
+	}
+	return ew.err
+}
+
+// lcovBranchCount returns count of branch, or "-" if condition was not executed
+func lcovBranchCount(b BranchCoverage, count int) string {
+	if b.Then == 0 && b.Else == 0 {
+		return "-"
+	}
+	return fmt.Sprint(count)
+}
+
+// WriteCoverProfile writes line coverage in Go cover profile format with count mode,
+// where each executable line is block with one statement
+func (r *CoverageReport) WriteCoverProfile(w io.Writer) error {
+	ew := &errWriter{w: w}
+	ew.printf("mode: count\n")
+	for _, fc := range r.Files {
+		for _, l := range fc.Lines {
+			ew.printf("%s:%d.1,%d.1 1 %d\n", fc.Filename, l.Line, l.Line+1, l.Count)
+		}
+	}
+	return ew.err
+}
+
+// errWriter keeps first write error
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (ew *errWriter) printf(format string, args ...interface{}) {
+	if ew.err == nil {
+		_, ew.err = fmt.Fprintf(ew.w, format, args...)
+	}
+}
@@ -0,0 +1,93 @@
+package oruby
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestMrbState_StartCoverage(t *testing.T) {
+	mrb, _ := NewCore()
+	defer mrb.Close()
+
+	ExpectNilError(t, mrb.StartCoverage())
+	ExpectErr(t, mrb.StartCoverage(), "expected error when coverage is already running")
+	Expect(t, mrb.CoverageRunning(), "expected coverage to be running")
+
+	cxt := mrb.MrbcContextNew()
+	defer cxt.Free()
+	cxt.Filename("cover.rb")
+
+	code := "def unused\n  1\nend\ndef sign(n)\n  if n > 0\n    1\n  else\n    -1\n  end\nend\nsign(1)\nsign(2)\n"
+	_, err := mrb.LoadStringCxt(code, cxt)
+	ExpectNilError(t, err)
+
+	Expect(t, mrb.PeekCoverage().File("cover.rb") != nil, "expected peeked coverage of cover.rb")
+	report := mrb.StopCoverage()
+	Expect(t, !mrb.CoverageRunning(), "expected coverage to be stopped")
+	Expect(t, mrb.StopCoverage() == nil, "expected nil report when coverage is not running")
+
+	fc := report.File("cover.rb")
+	if fc == nil {
+		t.Fatal("missing coverage of cover.rb")
+	}
+
+	lines := map[int]int{}
+	for _, l := range fc.Lines {
+		lines[l.Line] = l.Count
+	}
+	ExpectEql(t, lines[2], 0)
+	ExpectEql(t, lines[5], 2)
+	ExpectEql(t, lines[6], 2)
+	ExpectEql(t, lines[8], 0)
+	ExpectEql(t, lines[11], 1)
+
+	ExpectEql(t, len(fc.Branches), 1)
+	if len(fc.Branches) == 1 {
+		b := fc.Branches[0]
+		ExpectEql(t, b.Line, 5)
+		ExpectEql(t, b.Then, 2)
+		ExpectEql(t, b.Else, 0)
+	}
+}
+
+func TestMrbState_CoverageFreedIreps(t *testing.T) {
+	mrb, _ := NewCore()
+	defer mrb.Close()
+
+	ExpectNilError(t, mrb.StartCoverage())
+
+	// ireps of evaluated code are collected by GC, so their memory can be reused
+	for i := 0; i < 20; i++ {
+		_, err := mrb.Eval(strings.Repeat("a = 1; ", 20-i) + "a")
+		ExpectNilError(t, err)
+		mrb.FullGC()
+	}
+	ExpectEql(t, len(mrb.coverage.all), 20)
+
+	report := mrb.StopCoverage()
+	fc := report.File(coverageFilename)
+	if fc == nil {
+		t.Fatal("missing coverage of evaluated code")
+	}
+	ExpectEql(t, fc.Lines, []LineCoverage{{1, 1}})
+}
+
+func TestCoverageReport_Write(t *testing.T) {
+	report := &CoverageReport{Files: []*FileCoverage{{
+		Filename: "a.rb",
+		Lines:    []LineCoverage{{1, 1}, {2, 0}},
+		Branches: []BranchCoverage{{Line: 1, ThenLine: 2, ElseLine: 3, Then: 0, Else: 1}},
+	}}}
+
+	var lcov bytes.Buffer
+	ExpectNilError(t, report.WriteLCOV(&lcov))
+	ExpectEql(t, lcov.String(), strings.Join([]string{
+		"TN:", "SF:a.rb", "DA:1,1", "DA:2,0", "LF:2", "LH:1",
+		"BRDA:1,0,0,0", "BRDA:1,0,1,1", "BRF:2", "BRH:1", "end_of_record", "",
+	}, "\n"))
+
+	var cover bytes.Buffer
+	ExpectNilError(t, report.WriteCoverProfile(&cover))
+	ExpectEql(t, cover.String(), "mode: count\na.rb:1.1,2.1 1 1\na.rb:2.1,3.1 1 0\n")
+}
@@ -0,0 +1,499 @@
+package oruby
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// dapThreadID is id of only thread reported to client
+const dapThreadID = 1
+
+// DAPServer serves Debug Adapter Protocol to debugger client, like VS Code, debugging
+// code of oruby state. Code is run by host, usually after client set breakpoints:
+//
+//	s, _ := mrb.ListenDAP("127.0.0.1:4711")
+//	go s.Serve()
+//	s.WaitConfigured()
+//	_, err := mrb.LoadString(code)
+//	s.Exited(0)
+type DAPServer struct {
+	mrb    *MrbState
+	d      *Debugger
+	r      *bufio.Reader
+	w      io.Writer
+	closer io.Closer
+
+	wmu sync.Mutex // serializes messages
+	seq int
+
+	mu         sync.Mutex
+	refs       []dapRef // variable references, valid until code is resumed
+	configured chan struct{}
+	once       sync.Once
+}
+
+// dapRef is variable reference, to locals of call frame or to items of value
+type dapRef struct {
+	frame int
+	scope bool
+	value Value
+}
+
+// dapMessage is protocol message received from client
+type dapMessage struct {
+	Seq       int             `json:"seq"`
+	Type      string          `json:"type"`
+	Command   string          `json:"command"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+// dapArguments are arguments of supported requests
+type dapArguments struct {
+	StopOnEntry bool `json:"stopOnEntry"`
+	Source      struct {
+		Path string `json:"path"`
+	} `json:"source"`
+	Breakpoints []struct {
+		Line int `json:"line"`
+	} `json:"breakpoints"`
+	Filters            []string `json:"filters"`
+	StartFrame         int      `json:"startFrame"`
+	Levels             int      `json:"levels"`
+	FrameID            int      `json:"frameId"`
+	VariablesReference int      `json:"variablesReference"`
+	Expression         string   `json:"expression"`
+}
+
+// NewDAPServer returns DAP server reading client messages from r and writing to w.
+// Debugger is attached to state until client disconnects
+func (mrb *MrbState) NewDAPServer(r io.Reader, w io.Writer) *DAPServer {
+	s := &DAPServer{
+		mrb:        mrb,
+		d:          mrb.AttachDebugger(),
+		r:          bufio.NewReader(r),
+		w:          w,
+		configured: make(chan struct{}),
+	}
+	s.d.OnStop(s.stopped)
+	return s
+}
+
+// ListenDAP waits for debugger client on TCP address, and returns DAP server of client
+// connection. Address without host is listened on 127.0.0.1. Clients evaluate code
+// without authentication, so only loopback hosts are accepted
+func (mrb *MrbState) ListenDAP(addr string) (*DAPServer, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if host == "" {
+		host = "127.0.0.1"
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return nil, fmt.Errorf("dap: %v is not loopback address, debugger is only served to local clients", host)
+	}
+
+	l, err := net.Listen("tcp", net.JoinHostPort(host, port))
+	if err != nil {
+		return nil, err
+	}
+	defer l.Close()
+
+	conn, err := l.Accept()
+	if err != nil {
+		return nil, err
+	}
+	s := mrb.NewDAPServer(conn, conn)
+	s.closer = conn
+	return s, nil
+}
+
+// Debugger returns debugger driven by server
+func (s *DAPServer) Debugger() *Debugger { return s.d }
+
+// Serve handles client requests until client disconnects. Debugger is detached when
+// Serve returns, so stopped code continues
+func (s *DAPServer) Serve() error {
+	defer func() {
+		s.configure()
+		s.d.Detach()
+		if s.closer != nil {
+			s.closer.Close()
+		}
+	}()
+
+	for {
+		msg, err := s.read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if msg.Type == "request" && s.handle(msg) {
+			return nil
+		}
+	}
+}
+
+// WaitConfigured waits until client finished configuration, like setting breakpoints,
+// or until client disconnects
+func (s *DAPServer) WaitConfigured() {
+	<-s.configured
+}
+
+func (s *DAPServer) configure() {
+	s.once.Do(func() { close(s.configured) })
+}
+
+// Output sends output of debugged code to client. Category is "console", "stdout" or "stderr"
+func (s *DAPServer) Output(category, text string) {
+	s.event("output", map[string]interface{}{"category": category, "output": text})
+}
+
+// Exited tells client that debugged code finished with exit code
+func (s *DAPServer) Exited(exitCode int) {
+	s.event("exited", map[string]interface{}{"exitCode": exitCode})
+	s.event("terminated", nil)
+}
+
+// readDAPMessage reads message content following Content-Length header
+func readDAPMessage(r *bufio.Reader) ([]byte, error) {
+	length := -1
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			if length >= 0 {
+				break
+			}
+			continue
+		}
+		if name, value, ok := strings.Cut(line, ":"); ok && strings.EqualFold(name, "Content-Length") {
+			if length, err = strconv.Atoi(strings.TrimSpace(value)); err != nil {
+				return nil, fmt.Errorf("dap: invalid Content-Length %q", value)
+			}
+		}
+	}
+
+	data := make([]byte, length)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (s *DAPServer) read() (*dapMessage, error) {
+	data, err := readDAPMessage(s.r)
+	if err != nil {
+		return nil, err
+	}
+	msg := &dapMessage{}
+	if err := json.Unmarshal(data, msg); err != nil {
+		return nil, fmt.Errorf("dap: %v", err)
+	}
+	return msg, nil
+}
+
+func (s *DAPServer) send(msg map[string]interface{}) {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+
+	s.seq++
+	msg["seq"] = s.seq
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(s.w, "Content-Length: %d\r\n\r\n%s", len(data), data)
+}
+
+func (s *DAPServer) event(name string, body interface{}) {
+	msg := map[string]interface{}{"type": "event", "event": name}
+	if body != nil {
+		msg["body"] = body
+	}
+	s.send(msg)
+}
+
+func (s *DAPServer) respond(req *dapMessage, body interface{}, err error) {
+	msg := map[string]interface{}{
+		"type":        "response",
+		"request_seq": req.Seq,
+		"command":     req.Command,
+		"success":     err == nil,
+	}
+	if err != nil {
+		msg["message"] = err.Error()
+	} else if body != nil {
+		msg["body"] = body
+	}
+	s.send(msg)
+}
+
+// handle handles request, and returns true when client disconnects
+func (s *DAPServer) handle(req *dapMessage) bool {
+	var args dapArguments
+	if len(req.Arguments) > 0 {
+		if err := json.Unmarshal(req.Arguments, &args); err != nil {
+			s.respond(req, nil, err)
+			return false
+		}
+	}
+
+	switch req.Command {
+	case "initialize":
+		s.respond(req, map[string]interface{}{
+			"supportsConfigurationDoneRequest": true,
+			"supportsEvaluateForHovers":        true,
+			"exceptionBreakpointFilters": []map[string]interface{}{
+				{"filter": "raise", "label": "Raised exceptions"},
+			},
+		}, nil)
+		s.event("initialized", nil)
+
+	case "launch", "attach":
+		if args.StopOnEntry {
+			s.d.StopOnEntry()
+		}
+		s.respond(req, nil, nil)
+
+	case "setBreakpoints":
+		lines := make([]int, len(args.Breakpoints))
+		breakpoints := make([]map[string]interface{}, len(args.Breakpoints))
+		for i, bp := range args.Breakpoints {
+			lines[i] = bp.Line
+			breakpoints[i] = map[string]interface{}{"verified": true, "line": bp.Line}
+		}
+		s.d.SetBreakpoints(args.Source.Path, lines)
+		s.respond(req, map[string]interface{}{"breakpoints": breakpoints}, nil)
+
+	case "setExceptionBreakpoints":
+		raise := false
+		for _, f := range args.Filters {
+			raise = raise || f == "raise"
+		}
+		s.d.SetBreakOnRaise(raise)
+		s.respond(req, nil, nil)
+
+	case "configurationDone":
+		s.respond(req, nil, nil)
+		s.configure()
+
+	case "threads":
+		s.respond(req, map[string]interface{}{
+			"threads": []map[string]interface{}{{"id": dapThreadID, "name": "main"}},
+		}, nil)
+
+	case "stackTrace":
+		body, err := s.stackTrace(&args)
+		s.respond(req, body, err)
+
+	case "scopes":
+		ref := s.ref(dapRef{frame: args.FrameID - 1, scope: true})
+		s.respond(req, map[string]interface{}{
+			"scopes": []map[string]interface{}{
+				{"name": "Locals", "presentationHint": "locals", "variablesReference": ref, "expensive": false},
+			},
+		}, nil)
+
+	case "variables":
+		body, err := s.variables(args.VariablesReference)
+		s.respond(req, body, err)
+
+	case "evaluate":
+		body, err := s.evaluate(&args)
+		s.respond(req, body, err)
+
+	case "continue", "next", "stepIn", "stepOut":
+		// response is sent before code continues, so it comes before next stopped event
+		if !s.d.IsStopped() {
+			s.respond(req, nil, ErrNotStopped)
+			break
+		}
+		s.respond(req, map[string]interface{}{"allThreadsContinued": true}, nil)
+		switch req.Command {
+		case "continue":
+			s.d.Continue()
+		case "next":
+			s.d.StepOver()
+		case "stepIn":
+			s.d.StepIn()
+		case "stepOut":
+			s.d.StepOut()
+		}
+
+	case "pause":
+		s.d.Pause()
+		s.respond(req, nil, nil)
+
+	case "disconnect":
+		s.respond(req, nil, nil)
+		return true
+
+	default:
+		s.respond(req, nil, fmt.Errorf("unsupported request %v", req.Command))
+	}
+	return false
+}
+
+// stopped sends stopped event, when debugger stops code
+func (s *DAPServer) stopped(stop DebugStop) {
+	s.mu.Lock()
+	s.refs = nil
+	s.mu.Unlock()
+
+	body := map[string]interface{}{
+		"reason":            string(stop.Reason),
+		"threadId":          dapThreadID,
+		"allThreadsStopped": true,
+	}
+	if stop.Reason == DebugStopException {
+		body["text"] = debugInspect(s.mrb, stop.Exception)
+	}
+	s.event("stopped", body)
+}
+
+// ref returns new variable reference
+func (s *DAPServer) ref(r dapRef) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.refs = append(s.refs, r)
+	return len(s.refs)
+}
+
+func (s *DAPServer) stackTrace(args *dapArguments) (interface{}, error) {
+	frames, err := s.d.Stack()
+	if err != nil {
+		return nil, err
+	}
+
+	stackFrames := []map[string]interface{}{}
+	for i, f := range frames {
+		if i < args.StartFrame || (args.Levels > 0 && i >= args.StartFrame+args.Levels) {
+			continue
+		}
+		frame := map[string]interface{}{"id": i + 1, "name": f.Name, "line": f.Line, "column": 1}
+		if f.Filename != "" {
+			frame["source"] = map[string]interface{}{"name": filepath.Base(f.Filename), "path": debugPath(f.Filename)}
+		} else {
+			frame["presentationHint"] = "subtle"
+		}
+		stackFrames = append(stackFrames, frame)
+	}
+	return map[string]interface{}{"stackFrames": stackFrames, "totalFrames": len(frames)}, nil
+}
+
+func (s *DAPServer) variables(ref int) (interface{}, error) {
+	s.mu.Lock()
+	if ref < 1 || ref > len(s.refs) {
+		s.mu.Unlock()
+		return nil, errors.New("invalid variables reference")
+	}
+	r := s.refs[ref-1]
+	s.mu.Unlock()
+
+	vars := []map[string]interface{}{}
+	err := s.d.Do(func(mrb *MrbState) {
+		var children []DebugVariable
+		if r.scope {
+			children = append([]DebugVariable{{"self", mrb.frameSelf(r.frame)}}, mrb.frameLocals(r.frame)...)
+		} else {
+			children = debugChildren(mrb, r.value)
+		}
+		for _, c := range children {
+			vars = append(vars, s.variable(mrb, c.Name, c.Value))
+		}
+	})
+	return map[string]interface{}{"variables": vars}, err
+}
+
+func (s *DAPServer) evaluate(args *dapArguments) (interface{}, error) {
+	frame := 0
+	if args.FrameID > 0 {
+		frame = args.FrameID - 1
+	}
+	v, err := s.d.Eval(frame, args.Expression)
+	if err != nil {
+		return nil, err
+	}
+
+	var body map[string]interface{}
+	err = s.d.Do(func(mrb *MrbState) {
+		body = s.variable(mrb, "", v)
+		body["result"] = body["value"]
+		delete(body, "value")
+		delete(body, "name")
+	})
+	return body, err
+}
+
+// variable returns DAP variable of value, with reference to its items
+func (s *DAPServer) variable(mrb *MrbState, name string, v Value) map[string]interface{} {
+	ref := 0
+	if debugHasChildren(mrb, v) {
+		ref = s.ref(dapRef{value: v})
+	}
+	return map[string]interface{}{
+		"name":               name,
+		"value":              debugInspect(mrb, v),
+		"type":               mrb.ObjClassname(v),
+		"variablesReference": ref,
+	}
+}
+
+// debugInspect returns result of inspect, or error message if inspect fails
+func debugInspect(mrb *MrbState, v Value) string {
+	s, err := mrb.Funcall(v, mrb.Intern("inspect"))
+	if err != nil {
+		return err.Error()
+	}
+	return mrb.String(s)
+}
+
+// debugChildren returns items of array, entries of hash and instance variables of value
+func debugChildren(mrb *MrbState, v Value) []DebugVariable {
+	var vars []DebugVariable
+	switch {
+	case v.IsArray():
+		for i, item := range ary(v.v, mrb).Slice() {
+			vars = append(vars, DebugVariable{fmt.Sprintf("[%d]", i), item})
+		}
+	case v.IsHash():
+		for _, key := range mrb.HashKeys(v).Slice() {
+			vars = append(vars, DebugVariable{debugInspect(mrb, key), mrb.HashGet(v, key)})
+		}
+	}
+
+	if ivars := mrb.Call(v, "instance_variables"); ivars.IsArray() {
+		for _, name := range ary(ivars.v, mrb).Slice() {
+			if name.IsSymbol() {
+				vars = append(vars, DebugVariable{mrb.SymString(name.Symbol()), mrb.IVGet(v, name.Symbol())})
+			}
+		}
+	}
+	return vars
+}
+
+// debugHasChildren returns true if value has items or instance variables
+func debugHasChildren(mrb *MrbState, v Value) bool {
+	switch {
+	case v.IsArray() && ary(v.v, mrb).Len() > 0:
+		return true
+	case v.IsHash() && !mrb.HashEmptyP(v):
+		return true
+	}
+	ivars := mrb.Call(v, "instance_variables")
+	return ivars.IsArray() && ary(ivars.v, mrb).Len() > 0
+}
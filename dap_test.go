@@ -0,0 +1,124 @@
+package oruby
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"testing"
+)
+
+// dapClient sends requests to DAP server and reads its messages
+type dapClient struct {
+	t   *testing.T
+	in  *bufio.Reader
+	out io.Writer
+	seq int
+}
+
+func (c *dapClient) request(command string, args interface{}) {
+	c.seq++
+	data, _ := json.Marshal(map[string]interface{}{"seq": c.seq, "type": "request", "command": command, "arguments": args})
+	fmt.Fprintf(c.out, "Content-Length: %d\r\n\r\n%s", len(data), data)
+}
+
+// next returns next message of type and name, skipping other messages
+func (c *dapClient) next(typ, name string) map[string]interface{} {
+	c.t.Helper()
+	for {
+		msg, err := readDAPMessage(c.in)
+		if err != nil {
+			c.t.Fatalf("reading %v %v: %v", typ, name, err)
+		}
+		var m map[string]interface{}
+		ExpectNilError(c.t, json.Unmarshal(msg, &m))
+		if m["type"] == typ && (m["command"] == name || m["event"] == name) {
+			return m
+		}
+	}
+}
+
+func (c *dapClient) response(command string, args interface{}) map[string]interface{} {
+	c.t.Helper()
+	c.request(command, args)
+	m := c.next("response", command)
+	Expect(c.t, m["success"] == true, "%v failed: %v", command, m["message"])
+	body, _ := m["body"].(map[string]interface{})
+	return body
+}
+
+func TestDAPServer(t *testing.T) {
+	mrb, _ := NewCore()
+	defer mrb.Close()
+
+	serverIn, clientOut := io.Pipe()
+	clientIn, serverOut := io.Pipe()
+	s := mrb.NewDAPServer(serverIn, serverOut)
+	c := &dapClient{t: t, in: bufio.NewReader(clientIn), out: clientOut}
+
+	served := make(chan error, 1)
+	go func() { served <- s.Serve() }()
+
+	c.response("initialize", map[string]interface{}{"adapterID": "oruby"})
+	c.next("event", "initialized")
+	c.response("launch", nil)
+	body := c.response("setBreakpoints", map[string]interface{}{
+		"source":      map[string]interface{}{"path": "dap.rb"},
+		"breakpoints": []map[string]interface{}{{"line": 3}},
+	})
+	ExpectEql(t, len(body["breakpoints"].([]interface{})), 1)
+
+	ran := make(chan error, 1)
+	go func() {
+		s.WaitConfigured()
+		cxt := mrb.MrbcContextNew()
+		defer cxt.Free()
+		cxt.Filename("dap.rb")
+		_, err := mrb.LoadStringCxt(debugCode, cxt)
+		s.Exited(0)
+		ran <- err
+	}()
+	c.response("configurationDone", nil)
+
+	stopped := c.next("event", "stopped")
+	ExpectEql(t, stopped["body"].(map[string]interface{})["reason"], "breakpoint")
+
+	body = c.response("stackTrace", map[string]interface{}{"threadId": 1})
+	frames := body["stackFrames"].([]interface{})
+	top := frames[0].(map[string]interface{})
+	ExpectEql(t, top["name"], "Object#add")
+	ExpectEql(t, top["line"], 3.0)
+
+	body = c.response("scopes", map[string]interface{}{"frameId": top["id"]})
+	scope := body["scopes"].([]interface{})[0].(map[string]interface{})
+	body = c.response("variables", map[string]interface{}{"variablesReference": scope["variablesReference"]})
+	values := map[string]interface{}{}
+	for _, v := range body["variables"].([]interface{}) {
+		v := v.(map[string]interface{})
+		values[v["name"].(string)] = v["value"]
+	}
+	ExpectEql(t, values["a"], "1")
+	ExpectEql(t, values["c"], "3")
+
+	body = c.response("evaluate", map[string]interface{}{"expression": "[a, b]", "frameId": top["id"]})
+	ExpectEql(t, body["result"], "[1, 2]")
+	Expect(t, body["variablesReference"].(float64) > 0, "expected reference to array items")
+
+	c.response("continue", map[string]interface{}{"threadId": 1})
+	c.next("event", "terminated")
+	ExpectNilError(t, <-ran)
+
+	c.response("disconnect", nil)
+	ExpectNilError(t, <-served)
+}
+
+func TestMrbState_ListenDAPLoopback(t *testing.T) {
+	mrb, _ := NewCore()
+	defer mrb.Close()
+
+	// evaluate requests run code, so debugger is not served on other interfaces
+	for _, addr := range []string{"0.0.0.0:0", "[::]:0", "10.0.0.1:0", "example.com:0"} {
+		_, err := mrb.ListenDAP(addr)
+		ExpectErr(t, err, "%v should be rejected", addr)
+	}
+}
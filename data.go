@@ -37,7 +37,7 @@ type MrbDataType struct{ p *C.mrb_data_type }
 func (mrb *MrbState) DataObjectAlloc(klass RClass, datap interface{}, dtype MrbDataType) RData {
 	data := RData{
 		C.mrb_data_object_alloc(
-			mrb.p,
+			mrb.state(),
 			klass.p,
 			unsafe.Pointer(klass.p),
 			dtype.p,
@@ -55,7 +55,7 @@ func (mrb *MrbState) DataWrapStruct(klass RClass, dtype MrbDataType, datap uintp
 }
 
 // func Data_Make_Struct(mrb mrb_state, klass RClass, size int, dtype MrbDataType, sval uintptr, data RData) {
-//   C._Data_Make_Struct(mrb.p, klass.p, C.int(size), dtype.p, unsafe.Pointer(sval), data.p )
+//   C._Data_Make_Struct(mrb.state(), klass.p, C.int(size), dtype.p, unsafe.Pointer(sval), data.p )
 // }
 
 // RDATA returns RData from MrbValue
@@ -85,7 +85,7 @@ func (d RData) Interface(mrb *MrbState) interface{} {
 
 // DataCheckType checks if obj is RData ind is of dtype
 func (mrb *MrbState) DataCheckType(obj MrbValue, dtype MrbDataType) {
-	C.mrb_data_check_type(mrb.p, obj.Value().v, dtype.p)
+	C.mrb_data_check_type(mrb.state(), obj.Value().v, dtype.p)
 }
 
 // DataGetPtr retrieves pointer from RData
@@ -108,17 +108,23 @@ func (mrb *MrbState) DataGetPtr(obj MrbValue, dtype MrbDataType) (uintptr, error
 
 // DataCheckGetPtr returns pointer to data
 func (mrb *MrbState) DataCheckGetPtr(obj MrbValue, dtype MrbDataType) uintptr {
-	return uintptr(C.mrb_data_check_get_ptr(mrb.p, obj.Value().v, dtype.p))
+	return uintptr(C.mrb_data_check_get_ptr(mrb.state(), obj.Value().v, dtype.p))
 }
 
 //func (mrb *MrbState) DATA_CHECK_GET_PTR(obj MrbValue, dtype MrbDataType, atype uintptr) uintptr {
 // (type*)mrb_data_check_get_ptr(mrb,obj,dtype) }
 
+// dataFreer is implemented by internal Go values which release resources
+// when oruby object holding them is garbage collected
+type dataFreer interface {
+	dataFree()
+}
+
 // DataWrapInterface wraps interface value into RData oruby value
 func (mrb *MrbState) DataWrapInterface(klass RClass, datap interface{}) RData {
 	data := RData{
 		C.mrb_data_object_alloc(
-			mrb.p,
+			mrb.state(),
 			klass.p,
 			unsafe.Pointer(klass.p),
 			C.mrb_interface_data_type(),
@@ -132,7 +138,7 @@ func (mrb *MrbState) DataWrapInterface(klass RClass, datap interface{}) RData {
 
 // DataCheckInterface checks if value is RData holding go interface value
 func (mrb *MrbState) DataCheckInterface(obj MrbValue) {
-	C.mrb_data_check_type(mrb.p, obj.Value().v, C.mrb_interface_data_type())
+	C.mrb_data_check_type(mrb.state(), obj.Value().v, C.mrb_interface_data_type())
 }
 
 // DataGetInterface retrieves interface from RData value without check
@@ -142,7 +148,7 @@ func (mrb *MrbState) DataGetInterface(obj MrbValue) interface{} {
 
 // DataCheckGetInterface retrieves interface value from RData value
 func (mrb *MrbState) DataCheckGetInterface(obj MrbValue) interface{} {
-	ret := C.mrb_data_check_get_ptr(mrb.p, obj.Value().v, C.mrb_interface_data_type())
+	ret := C.mrb_data_check_get_ptr(mrb.state(), obj.Value().v, C.mrb_interface_data_type())
 
 	if ret == nil {
 		return nil
@@ -163,7 +169,7 @@ func (mrb *MrbState) DataSetInterface(obj Value, datap interface{}) {
 
 	// Release existing data
 	if RDATA(obj).p.data != nil {
-		//mrb_free_goref(mrb.p, p)
+		//mrb_free_goref(mrb.state(), p)
 		mrb.setHook(p, nil)
 	}
 
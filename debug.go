@@ -32,18 +32,18 @@ type MrbIrepDebugInfo struct {
 // DebugGetFilename get line from irep's debug info and program counter
 // @return returns NULL if not found
 func (mrb *MrbState) DebugGetFilename(irep MrbIrep, pc uint32) string {
-	return C.GoString(C.mrb_debug_get_filename(mrb.p, irep.p, C.uint32_t(pc)))
+	return C.GoString(C.mrb_debug_get_filename(mrb.state(), irep.p, C.uint32_t(pc)))
 }
 
 // DebugGetLine get line from irep's debug info and program counter
 // @return returns -1 if not found
 func (mrb *MrbState) DebugGetLine(irep MrbIrep, pc uint32) uint32 {
-	return uint32(C.mrb_debug_get_line(mrb.p, irep.p, C.uint32_t(pc)))
+	return uint32(C.mrb_debug_get_line(mrb.state(), irep.p, C.uint32_t(pc)))
 }
 
 // DebugInfoAlloc allocate debug info
 func (mrb *MrbState) DebugInfoAlloc(irep MrbIrep) MrbIrepDebugInfo {
-	return MrbIrepDebugInfo{(*C.struct_mrb_irep_debug_info)(C.mrb_debug_info_alloc(mrb.p, irep.p))}
+	return MrbIrepDebugInfo{(*C.struct_mrb_irep_debug_info)(C.mrb_debug_info_alloc(mrb.state(), irep.p))}
 }
 
 // DebugInfoAppendFile append to file
@@ -51,7 +51,7 @@ func (mrb *MrbState) DebugInfoAppendFile(info MrbIrepDebugInfo, filename string,
 	cfilename := C.CString(filename)
 	defer C.free(unsafe.Pointer(cfilename))
 	clines := C.uint16_t(*lines)
-	p := C.mrb_debug_info_append_file(mrb.p, info.p, cfilename, &clines, C.uint32_t(startPos), C.uint32_t(endPos))
+	p := C.mrb_debug_info_append_file(mrb.state(), info.p, cfilename, &clines, C.uint32_t(startPos), C.uint32_t(endPos))
 
 	if p == nil {
 		return MrbIrepDebugInfoFile{nil}, errors.New("Debug_info_append_file error")
@@ -63,5 +63,5 @@ func (mrb *MrbState) DebugInfoAppendFile(info MrbIrepDebugInfo, filename string,
 
 // DebugInfoFree free debug info
 func (mrb *MrbState) DebugInfoFree(d MrbIrepDebugInfo) {
-	C.mrb_debug_info_free(mrb.p, (*C.mrb_irep_debug_info)(d.p))
+	C.mrb_debug_info_free(mrb.state(), (*C.mrb_irep_debug_info)(d.p))
 }
@@ -0,0 +1,509 @@
+package oruby
+
+// #include "go-mrb.h"
+import "C"
+import (
+	"errors"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// debugMaxLocals is maximal number of local variables read from call frame
+const debugMaxLocals = 256
+
+// DebugStopReason is reason why debugger stopped code, named as Debug Adapter Protocol stop reasons
+type DebugStopReason string
+
+// Debugger stop reasons
+const (
+	DebugStopEntry      DebugStopReason = "entry"      // first line of code
+	DebugStopBreakpoint DebugStopReason = "breakpoint" // line with breakpoint
+	DebugStopStep       DebugStopReason = "step"       // step in, over or out is finished
+	DebugStopPause      DebugStopReason = "pause"      // Pause was requested
+	DebugStopException  DebugStopReason = "exception"  // exception was raised
+)
+
+// ErrNotStopped is returned by debugger functions that need stopped code
+var ErrNotStopped = errors.New("oruby debugger: code is not stopped")
+
+// DebugStop describes where debugger stopped code
+type DebugStop struct {
+	Reason    DebugStopReason
+	Filename  string
+	Line      int
+	Exception Value // raised exception of exception stop
+}
+
+// DebugFrame is call stack frame of stopped code
+type DebugFrame struct {
+	Name     string // method name, like "Class#method", "block in <main>" or "<main>"
+	Filename string // empty for C and Go functions
+	Line     int
+}
+
+// DebugVariable is local variable of call frame, or item of inspected value
+type DebugVariable struct {
+	Name  string
+	Value Value
+}
+
+// debugStep is stepping mode of debugger
+type debugStep int
+
+const (
+	stepNone debugStep = iota
+	stepIn
+	stepOver
+	stepOut
+)
+
+// debugStopped is state of stopped code
+type debugStopped struct {
+	gid    int64 // goroutine of stopped VM
+	event  TraceEvent
+	jobs   chan func()
+	resume chan struct{}
+	done   chan struct{}
+}
+
+// Debugger stops oruby code on breakpoints, steps, raised exceptions and pause requests.
+// Code is stopped in trace hook, where VM goroutine waits until debugger is resumed.
+// While code is stopped, its call stack, locals and expressions can be inspected from
+// any goroutine
+type Debugger struct {
+	mrb  *MrbState
+	hook *TraceHook
+
+	mu           sync.Mutex
+	breakpoints  map[string]map[int]bool // breakpoint lines by absolute filename
+	paths        map[string]string       // absolute paths of traced filenames
+	breakOnRaise bool
+	entry        bool
+	pause        bool
+	detached     bool
+	step         debugStep
+	depth        int // frame depth where step started
+	stopped      *debugStopped
+	onStop       func(DebugStop)
+}
+
+// AttachDebugger attaches debugger to state. Debugger traces line and raise events
+// until it is detached
+func (mrb *MrbState) AttachDebugger() *Debugger {
+	d := &Debugger{
+		mrb:         mrb,
+		breakpoints: make(map[string]map[int]bool),
+		paths:       make(map[string]string),
+	}
+	d.hook = mrb.AddTraceHook(TraceLine|TraceRaise, d.trace)
+	return d
+}
+
+// Detach removes debugger from state, continuing stopped code
+func (d *Debugger) Detach() {
+	d.mu.Lock()
+	d.detached = true
+	d.breakpoints = make(map[string]map[int]bool)
+	d.mu.Unlock()
+
+	d.Continue()
+	d.hook.Remove()
+}
+
+// OnStop sets function called when code is stopped. f is called on VM goroutine,
+// where debugger functions can be called directly, including Continue and Step functions
+func (d *Debugger) OnStop(f func(DebugStop)) {
+	d.mu.Lock()
+	d.onStop = f
+	d.mu.Unlock()
+}
+
+// debugPath returns absolute path of filename, so breakpoints match relative filenames
+func debugPath(filename string) string {
+	if abs, err := filepath.Abs(filename); err == nil {
+		return abs
+	}
+	return filepath.Clean(filename)
+}
+
+// SetBreakpoints replaces breakpoints of source file. Breakpoints are removed with no lines
+func (d *Debugger) SetBreakpoints(filename string, lines []int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	path := debugPath(filename)
+	if len(lines) == 0 {
+		delete(d.breakpoints, path)
+		return
+	}
+	set := make(map[int]bool, len(lines))
+	for _, line := range lines {
+		set[line] = true
+	}
+	d.breakpoints[path] = set
+}
+
+// SetBreakOnRaise sets if code stops when exception is raised. As raise trace event,
+// code stops when VM continues with exception in rescue or ensure code
+func (d *Debugger) SetBreakOnRaise(enabled bool) {
+	d.mu.Lock()
+	d.breakOnRaise = enabled
+	d.mu.Unlock()
+}
+
+// StopOnEntry stops code on next executed line, with entry reason
+func (d *Debugger) StopOnEntry() {
+	d.mu.Lock()
+	d.entry = true
+	d.mu.Unlock()
+}
+
+// Pause stops running code on next executed line
+func (d *Debugger) Pause() {
+	d.mu.Lock()
+	d.pause = true
+	d.mu.Unlock()
+}
+
+// IsStopped returns true if code is stopped
+func (d *Debugger) IsStopped() bool {
+	return d.current() != nil
+}
+
+// Continue continues stopped code
+func (d *Debugger) Continue() error { return d.resume(stepNone) }
+
+// StepIn continues stopped code and stops on next line, including lines of called methods and blocks
+func (d *Debugger) StepIn() error { return d.resume(stepIn) }
+
+// StepOver continues stopped code and stops on next line of current or calling frame
+func (d *Debugger) StepOver() error { return d.resume(stepOver) }
+
+// StepOut continues stopped code and stops on next line of calling frame
+func (d *Debugger) StepOut() error { return d.resume(stepOut) }
+
+func (d *Debugger) resume(step debugStep) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	s := d.stopped
+	if s == nil {
+		return ErrNotStopped
+	}
+	d.stopped = nil
+	d.step = step
+	d.depth = s.event.Depth
+	s.resume <- struct{}{}
+	return nil
+}
+
+func (d *Debugger) current() *debugStopped {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.stopped
+}
+
+// Do runs f on VM goroutine of stopped code and waits for it to finish. ErrNotStopped
+// is returned when code is not stopped. Panic in f is raised again in calling goroutine
+func (d *Debugger) Do(f func(mrb *MrbState)) error {
+	s := d.current()
+	if s == nil {
+		return ErrNotStopped
+	}
+	if goroutineID() == s.gid {
+		f(d.mrb)
+		return nil
+	}
+
+	var p interface{}
+	done := make(chan struct{})
+	job := func() {
+		defer close(done)
+		defer func() { p = recover() }()
+		f(d.mrb)
+	}
+
+	select {
+	case s.jobs <- job:
+	case <-s.done:
+		return ErrNotStopped
+	}
+	<-done
+
+	if p != nil {
+		panic(p)
+	}
+	return nil
+}
+
+// Stack returns call stack of stopped code, current frame first
+func (d *Debugger) Stack() (frames []DebugFrame, err error) {
+	s := d.current()
+	if s == nil {
+		return nil, ErrNotStopped
+	}
+	err = d.Do(func(mrb *MrbState) {
+		for _, f := range mrb.callStack(s.event.Irep.p, uint32(s.event.PC)) {
+			frames = append(frames, DebugFrame{f.name, f.filename, f.line})
+		}
+	})
+	return frames, err
+}
+
+// Locals returns local variables of call frame of stopped code, where current frame is 0
+func (d *Debugger) Locals(frame int) (vars []DebugVariable, err error) {
+	err = d.Do(func(mrb *MrbState) { vars = mrb.frameLocals(frame) })
+	return vars, err
+}
+
+// Self returns self of call frame of stopped code
+func (d *Debugger) Self(frame int) (self Value, err error) {
+	err = d.Do(func(mrb *MrbState) { self = mrb.frameSelf(frame) })
+	return self, err
+}
+
+// Eval evaluates code in call frame of stopped code, with self and local variables of frame.
+// Trace hooks and breakpoints are not run by evaluated code. Locals assigned by code are
+// not changed in frame
+func (d *Debugger) Eval(frame int, code string) (result Value, err error) {
+	derr := d.Do(func(mrb *MrbState) {
+		result, err = mrb.evalInFrame(frame, code, "(debug)")
+	})
+	if derr != nil {
+		return nilValue, derr
+	}
+	return result, err
+}
+
+// trace stops code on traced events, when stop is requested by breakpoint, step or pause
+func (d *Debugger) trace(e TraceEvent) {
+	if reason, ok := d.shouldStop(e); ok {
+		d.stop(reason, e)
+	}
+}
+
+func (d *Debugger) shouldStop(e TraceEvent) (DebugStopReason, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	switch {
+	case d.detached:
+		return "", false
+	case e.Event == TraceRaise:
+		return DebugStopException, d.breakOnRaise
+	case d.entry:
+		d.entry = false
+		return DebugStopEntry, true
+	case d.pause:
+		d.pause = false
+		return DebugStopPause, true
+	}
+
+	if len(d.breakpoints) > 0 {
+		path, ok := d.paths[e.Filename]
+		if !ok {
+			path = debugPath(e.Filename)
+			d.paths[e.Filename] = path
+		}
+		if d.breakpoints[path][e.Line] {
+			return DebugStopBreakpoint, true
+		}
+	}
+
+	switch d.step {
+	case stepIn:
+		return DebugStopStep, true
+	case stepOver:
+		return DebugStopStep, e.Depth <= d.depth
+	case stepOut:
+		return DebugStopStep, e.Depth < d.depth
+	}
+	return "", false
+}
+
+// stop waits until stopped code is resumed, running jobs of Do meanwhile
+func (d *Debugger) stop(reason DebugStopReason, e TraceEvent) {
+	s := &debugStopped{
+		gid:    goroutineID(),
+		event:  e,
+		jobs:   make(chan func()),
+		resume: make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+	defer close(s.done)
+
+	d.mu.Lock()
+	d.step = stepNone
+	d.stopped = s
+	onStop := d.onStop
+	d.mu.Unlock()
+
+	if onStop != nil {
+		onStop(DebugStop{reason, e.Filename, e.Line, e.Exception})
+	}
+
+	for {
+		select {
+		case job := <-s.jobs:
+			job()
+		case <-s.resume:
+			return
+		}
+	}
+}
+
+// frameLocals returns local variables of call frame at level, where current frame is 0
+func (mrb *MrbState) frameLocals(level int) []DebugVariable {
+	var locals [debugMaxLocals]C.gomrb_local
+	n := int(C._mrb_frame_locals(mrb.state(), C.int(level), &locals[0], C.int(len(locals))))
+
+	vars := make([]DebugVariable, 0, n)
+	for _, l := range locals[:n] {
+		// anonymous rest and block parameters have operator names
+		if name := mrb.SymString(MrbSym(l.name)); isLocalName(name) {
+			vars = append(vars, DebugVariable{name, Value{l.value}})
+		}
+	}
+	return vars
+}
+
+// frameSelf returns self of call frame at level
+func (mrb *MrbState) frameSelf(level int) Value {
+	return Value{C._mrb_frame_self(mrb.state(), C.int(level))}
+}
+
+// frameLocalSet assigns local variable of call frame at level. Returns false if frame
+// has no such variable
+func (mrb *MrbState) frameLocalSet(level int, name string, v Value) bool {
+	return C._mrb_frame_local_set(mrb.state(), C.int(level), C.mrb_sym(mrb.Intern(name)), v.v) != 0
+}
+
+// evalInFrame evaluates code with self and local variables of call frame at level.
+// Exception of failed code is cleared, so it does not affect stopped code
+func (mrb *MrbState) evalInFrame(level int, code, filename string) (Value, error) {
+	defer mrb.ExcClear()
+
+	locals := make(map[string]interface{})
+	for _, v := range mrb.frameLocals(level) {
+		locals[v.Name] = v.Value
+	}
+	return mrb.EvalWith(code, EvalOptions{
+		Filename: filename,
+		Locals:   usedLocals(code, locals),
+		Self:     mrb.frameSelf(level),
+	})
+}
+
+// usedLocals returns locals named in code, when there are more locals than EvalWith supports
+func usedLocals(code string, locals map[string]interface{}) map[string]interface{} {
+	if len(locals) <= MaxEvalLocals {
+		return locals
+	}
+
+	used := make(map[string]interface{})
+	words := strings.FieldsFunc(code, func(r rune) bool {
+		return r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, w := range words {
+		if v, ok := locals[w]; ok {
+			used[w] = v
+		}
+	}
+	return used
+}
+
+// EvalInFrame evaluates code with self and local variables of call frame at level, where
+// current frame is 0, so Go function called from oruby code evaluates code of its caller
+// at level 1. Locals assigned by code are changed in frame. opts.Locals are additional
+// locals, which are updated with values assigned by code, including new locals, so they
+// are kept between evaluations. Self of frame is used when opts.Self is nil
+func (mrb *MrbState) EvalInFrame(level int, code string, opts EvalOptions) (result Value, err error) {
+	if mrb.remote(func() { result, err = mrb.EvalInFrame(level, code, opts) }) {
+		return result, err
+	}
+
+	frame := make(map[string]bool)
+	locals := make(map[string]interface{}, len(opts.Locals))
+	for name, v := range opts.Locals {
+		locals[name] = v
+	}
+	for _, v := range mrb.frameLocals(level) {
+		frame[v.Name] = true
+		locals[v.Name] = v.Value
+	}
+	locals = usedLocals(code, locals)
+
+	assigned, err := mrb.assignedLocals(code, locals, opts)
+	if err != nil {
+		return nilValue, err
+	}
+
+	// Code is wrapped to return values of its locals after result. Wrapped code
+	// starts on first line, so lines of errors and backtraces are not changed
+	wrapped := "__eval_result = begin;" + code + "\nend\n[" +
+		strings.Join(append([]string{"__eval_result"}, assigned...), ", ") + "]"
+	if opts.Self.IsNil() {
+		opts.Self = mrb.frameSelf(level)
+	}
+
+	values, err := mrb.EvalWith(wrapped, EvalOptions{opts.Filename, opts.Line, locals, opts.Self})
+	if err != nil {
+		return nilValue, err
+	}
+
+	for i, name := range assigned {
+		v := mrb.AryRef(values, i+1)
+		if frame[name] {
+			mrb.frameLocalSet(level, name, v)
+		} else if opts.Locals != nil {
+			opts.Locals[name] = v
+		}
+	}
+	return mrb.AryRef(values, 0), nil
+}
+
+// assignedLocals parses code with declared locals and returns declared locals followed
+// by locals assigned by code
+func (mrb *MrbState) assignedLocals(code string, locals map[string]interface{}, opts EvalOptions) ([]string, error) {
+	names := make([]string, 0, len(locals))
+	for name := range locals {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	filename := opts.Filename
+	if filename == "" {
+		filename = "(eval)"
+	}
+
+	cxt := mrb.MrbcContextNew()
+	defer cxt.Free()
+	cxt.SetCaptureErrors(true)
+	cxt.SetKeepLV(true)
+	cxt.Filename(filename)
+	if opts.Line > 0 {
+		cxt.SetLineNo(opts.Line)
+	}
+	cxt.SetLocals(names...)
+
+	p, err := mrb.ParseString(code, cxt)
+	if err != nil {
+		return nil, err
+	}
+	defer p.Free()
+
+	if err := parserErrors(p, filename); err != nil {
+		return nil, ESyntaxError("%v", err)
+	}
+
+	var assigned []string
+	for _, name := range cxt.Locals() {
+		if isLocalName(name) {
+			assigned = append(assigned, name)
+		}
+	}
+	return assigned, nil
+}
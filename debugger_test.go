@@ -0,0 +1,142 @@
+package oruby
+
+import (
+	"testing"
+)
+
+const debugCode = `def add(a, b)
+  c = a + b
+  c * 2
+end
+x = add(1, 2)
+y = [x].map { |i| i + x }
+`
+
+func TestDebugger_Breakpoint(t *testing.T) {
+	mrb, _ := NewCore()
+	defer mrb.Close()
+
+	d := mrb.AttachDebugger()
+	defer d.Detach()
+	d.SetBreakpoints("debug.rb", []int{3})
+
+	var stops []DebugStop
+	var frames []DebugFrame
+	var locals []DebugVariable
+	var sum Value
+	d.OnStop(func(s DebugStop) {
+		stops = append(stops, s)
+		if len(stops) > 1 {
+			d.Continue()
+			return
+		}
+
+		var err error
+		frames, err = d.Stack()
+		ExpectNilError(t, err)
+		locals, err = d.Locals(0)
+		ExpectNilError(t, err)
+		sum, err = d.Eval(0, "a + b + c")
+		ExpectNilError(t, err)
+		ExpectNilError(t, d.StepOut())
+	})
+
+	cxt := mrb.MrbcContextNew()
+	defer cxt.Free()
+	cxt.Filename("debug.rb")
+	_, err := mrb.LoadStringCxt(debugCode, cxt)
+	ExpectNilError(t, err)
+
+	ExpectEql(t, len(stops), 2)
+	if len(stops) == 2 {
+		ExpectEql(t, stops[0].Reason, DebugStopBreakpoint)
+		ExpectEql(t, stops[0].Line, 3)
+		ExpectEql(t, stops[1].Reason, DebugStopStep)
+		ExpectEql(t, stops[1].Line, 6)
+	}
+
+	Expect(t, len(frames) >= 2, "expected at least 2 frames, got %v", frames)
+	if len(frames) >= 2 {
+		ExpectEql(t, frames[0], DebugFrame{"Object#add", "debug.rb", 3})
+		ExpectEql(t, frames[1], DebugFrame{"<main>", "debug.rb", 5})
+	}
+
+	values := map[string]int{}
+	for _, v := range locals {
+		values[v.Name] = v.Value.Int()
+	}
+	ExpectEql(t, values, map[string]int{"a": 1, "b": 2, "c": 3})
+	ExpectEql(t, sum.Int(), 6)
+
+	_, err = d.Stack()
+	Expect(t, err == ErrNotStopped, "expected ErrNotStopped, got %v", err)
+}
+
+func TestDebugger_Step(t *testing.T) {
+	mrb, _ := NewCore()
+	defer mrb.Close()
+
+	d := mrb.AttachDebugger()
+	defer d.Detach()
+	d.StopOnEntry()
+
+	var lines []int
+	var blockLocals []DebugVariable
+	d.OnStop(func(s DebugStop) {
+		lines = append(lines, s.Line)
+		switch len(lines) {
+		case 1, 2:
+			d.StepOver()
+		case 3:
+			d.StepIn()
+		case 4:
+			// block sees locals of enclosing scope
+			blockLocals, _ = d.Locals(0)
+			d.Continue()
+		}
+	})
+
+	cxt := mrb.MrbcContextNew()
+	defer cxt.Free()
+	cxt.Filename("step.rb")
+	_, err := mrb.LoadStringCxt(debugCode, cxt)
+	ExpectNilError(t, err)
+
+	ExpectEql(t, lines, []int{1, 5, 6, 6})
+
+	names := map[string]bool{}
+	for _, v := range blockLocals {
+		names[v.Name] = true
+	}
+	Expect(t, names["i"] && names["x"], "expected block and enclosing locals, got %v", blockLocals)
+}
+
+func TestMrbState_EvalInFrame(t *testing.T) {
+	mrb, _ := NewCore()
+	defer mrb.Close()
+
+	extra := map[string]interface{}{"n": 10}
+	mrb.KernelModule().DefineMethod("peek", func(mrb *MrbState, self Value) MrbValue {
+		code := mrb.GetArgsFirst()
+		result, err := mrb.EvalInFrame(1, code.String(), EvalOptions{Filename: "(peek)", Locals: extra})
+		if err != nil {
+			return mrb.RaiseError(err)
+		}
+		return result
+	}, mrb.ArgsReq(1))
+
+	v, err := mrb.Eval(`def m(a)
+  peek("a += n")
+  peek("b = a * 2")
+  [a, peek("[self, b]")[1]]
+end
+y = 1
+[1].each { peek("y = 5") }
+[m(1), [2].map { |x| peek("x += 1"); x }, y]`)
+	ExpectNilError(t, err)
+	ExpectEql(t, mrb.Intf(v), []interface{}{[]interface{}{11, 22}, []interface{}{3}, 5})
+	ExpectEql(t, extra["b"].(Value).Int(), 22)
+
+	_, err = mrb.Eval(`peek("a +")`)
+	ExpectErr(t, err, "expected syntax error")
+}
@@ -0,0 +1,89 @@
+package oruby
+
+import "fmt"
+
+// Severity of parser diagnostic
+type Severity int
+
+// Diagnostic severities
+const (
+	SeverityError Severity = iota
+	SeverityWarning
+)
+
+func (s Severity) String() string {
+	if s == SeverityWarning {
+		return "warning"
+	}
+	return "error"
+}
+
+// MarshalText encodes severity as its name, as in JSON output of diagnostics
+func (s Severity) MarshalText() ([]byte, error) { return []byte(s.String()), nil }
+
+// Diagnostic is parser error or warning
+type Diagnostic struct {
+	Filename string   `json:"filename"`
+	Line     int      `json:"line"`
+	Column   int      `json:"column"`
+	Severity Severity `json:"severity"`
+	Message  string   `json:"message"`
+}
+
+// String formats diagnostic as "file:line:column: severity: message"
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s:%d:%d: %v: %s", d.Filename, d.Line, d.Column, d.Severity, d.Message)
+}
+
+// HasErrors reports if diagnostics contain errors
+func HasErrors(diags []Diagnostic) bool {
+	for _, d := range diags {
+		if d.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// Diagnostics returns parser errors followed by warnings
+func (p MrbParserState) Diagnostics() []Diagnostic {
+	filename := p.State().SymString(p.Filename())
+	diags := make([]Diagnostic, 0, p.ErrorBufferLen()+p.WarnBufferLen())
+
+	for i := 0; i < p.ErrorBufferLen(); i++ {
+		e := p.ErrorBuffer(i)
+		diags = append(diags, Diagnostic{filename, e.LineNo, e.Column, SeverityError, e.Message})
+	}
+	for i := 0; i < p.WarnBufferLen(); i++ {
+		w := p.WarnBuffer(i)
+		diags = append(diags, Diagnostic{filename, w.LineNo, w.Column, SeverityWarning, w.Message})
+	}
+	return diags
+}
+
+// Check parses source and returns its syntax errors and warnings. Source is not executed
+// and no bytecode is generated. Nil is returned for valid source without warnings
+func (mrb *MrbState) Check(source, filename string) (diags []Diagnostic) {
+	if mrb.remote(func() { diags = mrb.Check(source, filename) }) {
+		return diags
+	}
+
+	cxt := mrb.MrbcContextNew()
+	defer cxt.Free()
+	cxt.SetCaptureErrors(true)
+	cxt.SetNoExec(true)
+	if filename != "" {
+		cxt.Filename(filename)
+	}
+
+	p, err := mrb.ParseString(source, cxt)
+	if err != nil {
+		return []Diagnostic{{Filename: filename, Severity: SeverityError, Message: err.Error()}}
+	}
+	defer p.Free()
+
+	if diags = p.Diagnostics(); len(diags) == 0 {
+		return nil
+	}
+	return diags
+}
@@ -0,0 +1,53 @@
+package oruby
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestMrbState_Check(t *testing.T) {
+	mrb, _ := NewCore()
+	defer mrb.Close()
+
+	diags := mrb.Check("def ok\n  1\nend\n", "ok.rb")
+	ExpectEql(t, len(diags), 0)
+
+	diags = mrb.Check("$checked = 1\nputs(1 +)\n", "bad.rb")
+	Expect(t, HasErrors(diags), "syntax error expected")
+	d := diags[0]
+	ExpectEql(t, d.Filename, "bad.rb")
+	ExpectEql(t, d.Line, 2)
+	ExpectEql(t, d.Severity, SeverityError)
+	Expect(t, d.Column > 0, "column expected, got %v", d.Column)
+	Expect(t, strings.HasPrefix(d.String(), "bad.rb:2:"), "diagnostic format, got %v", d)
+
+	// Nothing is executed
+	v, err := mrb.Eval("$checked")
+	ExpectNilError(t, err)
+	Expect(t, v.IsNil(), "source should not be executed")
+}
+
+func TestMrbState_CheckWarning(t *testing.T) {
+	mrb, _ := NewCore()
+	defer mrb.Close()
+
+	diags := mrb.Check("a = 1\nfoo *a\n", "warn.rb")
+	Expect(t, !HasErrors(diags), "no errors expected, got %v", diags)
+	Expect(t, len(diags) > 0, "warning of argument prefix expected")
+	for _, d := range diags {
+		ExpectEql(t, d.Severity, SeverityWarning)
+	}
+
+	d := diags[0]
+	ExpectEql(t, d.Filename, "warn.rb")
+	ExpectEql(t, d.Line, 2)
+	Expect(t, d.Column > 0, "column expected, got %v", d.Column)
+	Expect(t, strings.Contains(d.Message, "*"), "argument prefix warning expected, got %v", d.Message)
+}
+
+func TestDiagnostic_JSON(t *testing.T) {
+	data, err := json.Marshal(Diagnostic{"a.rb", 3, 5, SeverityWarning, "ambiguous"})
+	ExpectNilError(t, err)
+	ExpectEql(t, string(data), `{"filename":"a.rb","line":3,"column":5,"severity":"warning","message":"ambiguous"}`)
+}
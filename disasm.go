@@ -0,0 +1,352 @@
+package oruby
+
+// #include "go-mrb.h"
+import "C"
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Instruction is decoded bytecode instruction. OP_EXT prefixes are folded into
+// instruction they extend, so PC of such instruction is PC of its prefix
+type Instruction struct {
+	PC       int    // offset of instruction in irep iseq
+	Size     int    // instruction size in bytes, with prefix
+	Opcode   int    // opcode number
+	Name     string // opcode name, without OP_ prefix
+	Format   string // operand format from mruby/ops.h: Z, B, BB, BBB, BS, BSS, S or W
+	Operands []int  // operand values
+	Line     int    // source line, or 0 when irep has no debug info
+}
+
+// Disasm is disassembled irep with its child ireps
+type Disasm struct {
+	Filename string
+	NLocals  int
+	NRegs    int
+	Syms     []string
+	Pool     []string // pool literals, formatted as oruby literals
+	Code     []Instruction
+	Children []*Disasm
+}
+
+// opSpec describes operands of instruction. Roles of operands are R register, S symbol,
+// P pool, I child irep, J jump, U upvar index, L upvar level and N number. last returns
+// last register used by instruction, when it uses registers following register operands
+type opSpec struct {
+	roles string
+	last  func(o []int) int
+}
+
+// operand specs of instructions. Instructions not listed have register as first
+// byte operand, and no implicit registers
+var opSpecs = map[string]opSpec{
+	"MOVE": {"RR", nil}, "LOADL": {"RP", nil}, "LOADL16": {"RP", nil},
+	"LOADSYM": {"RS", nil}, "LOADSYM16": {"RS", nil},
+	"GETGV": {"RS", nil}, "SETGV": {"RS", nil}, "GETSV": {"RS", nil}, "SETSV": {"RS", nil},
+	"GETIV": {"RS", nil}, "SETIV": {"RS", nil}, "GETCV": {"RS", nil}, "SETCV": {"RS", nil},
+	"GETCONST": {"RS", nil}, "SETCONST": {"RS", nil}, "GETMCNST": {"RS", nil}, "SETMCNST": {"RS", nextRegs(1)},
+	"GETUPVAR": {"RUL", nil}, "SETUPVAR": {"RUL", nil},
+	"GETIDX": {"R", nextRegs(1)}, "SETIDX": {"R", nextRegs(2)},
+	"JMP": {"J", nil}, "JMPUW": {"J", nil}, "JMPIF": {"RJ", nil}, "JMPNOT": {"RJ", nil}, "JMPNIL": {"RJ", nil},
+	"RESCUE": {"RR", nil},
+	"SEND":   {"RSN", sendRegs(2)}, "SENDB": {"RSN", sendRegs(2)},
+	"SSEND": {"RSN", sendRegs(2)}, "SSENDB": {"RSN", sendRegs(2)}, "SUPER": {"RN", sendRegs(1)},
+	"ARGARY": {"RN", nil}, "BLKPUSH": {"RN", nil}, "ENTER": {"N", enterRegs},
+	"KEY_P": {"RS", nil}, "KARG": {"RS", nil}, "RETURN_BLK": {"R", nil},
+	"ADD": {"R", nextRegs(1)}, "SUB": {"R", nextRegs(1)}, "MUL": {"R", nextRegs(1)}, "DIV": {"R", nextRegs(1)},
+	"EQ": {"R", nextRegs(1)}, "LT": {"R", nextRegs(1)}, "LE": {"R", nextRegs(1)},
+	"GT": {"R", nextRegs(1)}, "GE": {"R", nextRegs(1)},
+	"ARRAY":  {"RN", func(o []int) int { return o[0] + o[1] - 1 }},
+	"ARRAY2": {"RRN", func(o []int) int { return o[1] + o[2] - 1 }},
+	"ARYCAT": {"R", nextRegs(1)}, "ARYPUSH": {"R", aryPushRegs},
+	"AREF": {"RRN", nil}, "ASET": {"RRN", nil}, "APOST": {"RNN", func(o []int) int { return o[0] + o[2] }},
+	"SYMBOL": {"RP", nil}, "STRING": {"RP", nil}, "STRING16": {"RP", nil}, "STRCAT": {"R", nextRegs(1)},
+	"HASH":    {"RN", func(o []int) int { return o[0] + 2*o[1] - 1 }},
+	"HASHADD": {"RN", func(o []int) int { return o[0] + 2*o[1] }},
+	"HASHCAT": {"R", nextRegs(1)},
+	"LAMBDA":  {"RI", nil}, "BLOCK": {"RI", nil}, "METHOD": {"RI", nil}, "EXEC": {"RI", nil},
+	"LAMBDA16": {"RI", nil}, "BLOCK16": {"RI", nil}, "METHOD16": {"RI", nil}, "EXEC16": {"RI", nil},
+	"RANGE_INC": {"R", nextRegs(1)}, "RANGE_EXC": {"R", nextRegs(1)},
+	"CLASS": {"RS", nextRegs(1)}, "MODULE": {"RS", nil}, "DEF": {"RS", nextRegs(1)},
+	"ALIAS": {"SS", nil}, "UNDEF": {"S", nil}, "DEBUG": {"NNN", nil}, "ERR": {"P", nil},
+}
+
+// nextRegs returns last register of instruction using n registers after register operand
+func nextRegs(n int) func(o []int) int {
+	return func(o []int) int { return o[0] + n }
+}
+
+// sendRegs returns last register of method call with receiver at register operand and
+// argument count n|k<<4 at operand i. 15 arguments are packed in Array, 15 keywords in
+// Hash. Block is in register following arguments
+func sendRegs(i int) func(o []int) int {
+	return func(o []int) int {
+		n, k := o[i]&0xf, o[i]>>4&0xf
+		if n == 15 {
+			n = 1
+		}
+		if k == 15 {
+			k = 1
+		} else {
+			k *= 2
+		}
+		return o[0] + n + k + 1
+	}
+}
+
+// enterRegs returns block register of method with aspec operand
+func enterRegs(o []int) int {
+	a := o[0]
+	args := a>>18&0x1f + a>>13&0x1f + a>>12&1 + a>>7&0x1f
+	if a>>2&0x1f > 0 || a>>1&1 != 0 {
+		args++
+	}
+	return args + 1
+}
+
+// aryPushRegs returns last register of ARYPUSH, which pushes one register or count of them
+func aryPushRegs(o []int) int {
+	if len(o) > 1 {
+		return o[0] + o[1]
+	}
+	return o[0] + 1
+}
+
+func opcodeCount() int { return int(C._mrb_op_count()) }
+
+func opcodeInfo(op int) (name, format string) {
+	return C.GoString(C._mrb_op_name(C.int(op))), C.GoString(C._mrb_op_format(C.int(op)))
+}
+
+// Disassemble decodes irep bytecode, pool, symbols and child ireps
+func (mrb *MrbState) Disassemble(irep MrbIrep) (*Disasm, error) {
+	if irep.IsNil() {
+		return nil, EArgumentError("irep is nil")
+	}
+
+	d := &Disasm{
+		Filename: mrb.DebugGetFilename(irep, 0),
+		NLocals:  irep.NLocals(),
+		NRegs:    irep.NRegs(),
+	}
+	for i := 0; i < irep.SLen(); i++ {
+		d.Syms = append(d.Syms, mrb.SymString(irep.Syms(i)))
+	}
+	for i := 0; i < irep.PLen(); i++ {
+		d.Pool = append(d.Pool, poolLiteral(irep.Pool(i)))
+	}
+
+	code, err := decodeISeq(irep.ISeq())
+	if err != nil {
+		return nil, err
+	}
+	for i := range code {
+		if line := int32(mrb.DebugGetLine(irep, uint32(code[i].PC))); line > 0 {
+			code[i].Line = int(line)
+		}
+	}
+	d.Code = code
+
+	for i := 0; i < irep.RLen(); i++ {
+		child, err := mrb.Disassemble(irep.Reps(i))
+		if err != nil {
+			return nil, err
+		}
+		d.Children = append(d.Children, child)
+	}
+	return d, nil
+}
+
+// DisassembleBytecode disassembles RITE bytecode, as stored in .mrb files
+func (mrb *MrbState) DisassembleBytecode(code []byte) (*Disasm, error) {
+	irep, err := mrb.ReadIrep(code)
+	if err != nil {
+		return nil, err
+	}
+	defer irep.Decref()
+	return mrb.Disassemble(irep)
+}
+
+func poolLiteral(pv MrbPoolValue) string {
+	switch pv.Type() {
+	case IrepTtStr, IrepTtSstr:
+		return strconv.Quote(pv.Str())
+	case IrepTtInt32, IrepTtInt64:
+		return strconv.FormatInt(pv.Int(), 10)
+	case IrepTtFloat:
+		return strconv.FormatFloat(pv.Float(), 'g', -1, 64)
+	default:
+		return "<bigint>"
+	}
+}
+
+// decodeISeq decodes instruction sequence
+func decodeISeq(iseq []byte) ([]Instruction, error) {
+	var code []Instruction
+	opCount := opcodeCount()
+
+	for pc := 0; pc < len(iseq); {
+		start, ext := pc, 0
+		op := int(iseq[pc])
+		pc++
+		if op >= opCount {
+			return nil, fmt.Errorf("invalid opcode %d at %03d", op, start)
+		}
+		name, format := opcodeInfo(op)
+
+		// OP_EXT1, OP_EXT2 and OP_EXT3 make operand a, b or both 16 bit
+		if strings.HasPrefix(name, "EXT") && len(name) == 4 {
+			ext = int(name[3] - '0')
+			if pc >= len(iseq) {
+				return nil, fmt.Errorf("truncated instruction at %03d", start)
+			}
+			op = int(iseq[pc])
+			pc++
+			if op >= opCount {
+				return nil, fmt.Errorf("invalid opcode %d at %03d", op, start)
+			}
+			name, format = opcodeInfo(op)
+		}
+
+		ins := Instruction{PC: start, Opcode: op, Name: name, Format: format}
+		if format != "Z" {
+			for i, f := range format {
+				width := 1
+				switch {
+				case f == 'S', f == 'B' && i < 2 && ext&(1<<i) != 0:
+					width = 2
+				case f == 'W':
+					width = 3
+				}
+				if pc+width > len(iseq) {
+					return nil, fmt.Errorf("truncated instruction %v at %03d", name, start)
+				}
+				v := 0
+				for _, b := range iseq[pc : pc+width] {
+					v = v<<8 | int(b)
+				}
+				ins.Operands = append(ins.Operands, v)
+				pc += width
+			}
+		}
+		ins.Size = pc - start
+		code = append(code, ins)
+	}
+	return code, nil
+}
+
+// roles returns operand roles of instruction
+func (ins Instruction) roles() string {
+	if spec, ok := opSpecs[ins.Name]; ok {
+		return spec.roles
+	}
+	if strings.HasPrefix(ins.Format, "B") {
+		return "R" + strings.Repeat("N", len(ins.Format)-1)
+	}
+	return strings.Repeat("N", len(ins.Format))
+}
+
+// lastReg returns last register used by instruction beyond its register operands, or -1
+func (ins Instruction) lastReg() int {
+	spec, ok := opSpecs[ins.Name]
+	if !ok || spec.last == nil || len(ins.Operands) < len(spec.roles) {
+		return -1
+	}
+	return spec.last(ins.Operands)
+}
+
+// Target returns jump target of jump instruction, or -1
+func (ins Instruction) Target() int {
+	if i := strings.IndexByte(ins.roles(), 'J'); i >= 0 && i < len(ins.Operands) {
+		return ins.PC + ins.Size + int(int16(ins.Operands[i]))
+	}
+	return -1
+}
+
+// String formats instruction with raw operands, like "SEND R1 sym2 1"
+func (ins Instruction) String() string {
+	return ins.format(nil)
+}
+
+// format formats instruction, with symbols, pool literals and jump targets from irep
+func (ins Instruction) format(d *Disasm) string {
+	var sb strings.Builder
+	sb.WriteString(ins.Name)
+
+	roles := ins.roles()
+	var notes []string
+	for i, v := range ins.Operands {
+		role := byte('N')
+		if i < len(roles) {
+			role = roles[i]
+		}
+
+		sb.WriteString(" ")
+		switch role {
+		case 'R':
+			fmt.Fprintf(&sb, "R%d", v)
+		case 'J':
+			fmt.Fprintf(&sb, "%03d", ins.Target())
+		case 'S':
+			if d != nil && v < len(d.Syms) {
+				sb.WriteString(":" + d.Syms[v])
+			} else {
+				fmt.Fprintf(&sb, "sym%d", v)
+			}
+		case 'P':
+			fmt.Fprintf(&sb, "L%d", v)
+			if d != nil && v < len(d.Pool) {
+				notes = append(notes, d.Pool[v])
+			}
+		case 'I':
+			fmt.Fprintf(&sb, "I%d", v)
+		default:
+			sb.WriteString(strconv.Itoa(v))
+		}
+	}
+	if len(notes) > 0 {
+		sb.WriteString("\t; " + strings.Join(notes, ", "))
+	}
+	return sb.String()
+}
+
+// String returns text listing of disassembled irep and its children
+func (d *Disasm) String() string {
+	var buf bytes.Buffer
+	d.WriteTo(&buf)
+	return buf.String()
+}
+
+// WriteTo writes text listing of disassembled irep and its children to w
+func (d *Disasm) WriteTo(w io.Writer) (int64, error) {
+	var buf bytes.Buffer
+	d.write(&buf, "0")
+	n, err := w.Write(buf.Bytes())
+	return int64(n), err
+}
+
+func (d *Disasm) write(buf *bytes.Buffer, id string) {
+	fmt.Fprintf(buf, "irep %s nregs=%d nlocals=%d pools=%d syms=%d reps=%d", id, d.NRegs, d.NLocals, len(d.Pool), len(d.Syms), len(d.Children))
+	if d.Filename != "" {
+		fmt.Fprintf(buf, " file=%s", d.Filename)
+	}
+	buf.WriteString("\n")
+
+	for _, ins := range d.Code {
+		if ins.Line > 0 {
+			fmt.Fprintf(buf, "%5d ", ins.Line)
+		} else {
+			buf.WriteString("      ")
+		}
+		fmt.Fprintf(buf, "%03d %s\n", ins.PC, ins.format(d))
+	}
+
+	for i, child := range d.Children {
+		buf.WriteString("\n")
+		child.write(buf, id+"."+strconv.Itoa(i))
+	}
+}
@@ -0,0 +1,65 @@
+package oruby
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMrbState_Disassemble(t *testing.T) {
+	prog, err := Compile("greeting = 'hi'\nputs greeting\n[1].each { |i| i + 2.5 }\n", "hello.rb")
+	ExpectNilError(t, err)
+
+	mrb, _ := NewCore()
+	defer mrb.Close()
+
+	d, err := mrb.DisassembleBytecode(prog.Bytecode())
+	ExpectNilError(t, err)
+
+	ExpectEql(t, d.Filename, "hello.rb")
+	Expect(t, len(d.Children) == 1, "block irep expected, got %v", len(d.Children))
+	Expect(t, len(d.Code) > 0, "instructions expected")
+
+	var send *Instruction
+	for i := range d.Code {
+		if strings.HasPrefix(d.Code[i].Name, "SEND") || strings.HasPrefix(d.Code[i].Name, "SSEND") {
+			if d.Syms[d.Code[i].Operands[1]] == "puts" {
+				send = &d.Code[i]
+			}
+		}
+	}
+	Expect(t, send != nil, "puts call expected in %v", d)
+	if send != nil {
+		ExpectEql(t, send.Line, 2)
+	}
+
+	pc := 0
+	for _, ins := range d.Code {
+		ExpectEql(t, ins.PC, pc)
+		pc += ins.Size
+	}
+
+	listing := d.String()
+	for _, s := range []string{"irep 0 ", "irep 0.0 ", ":puts", `"hi"`, "2.5"} {
+		Expect(t, strings.Contains(listing, s), "listing should contain %v, got\n%v", s, listing)
+	}
+}
+
+func TestDecodeISeq_Truncated(t *testing.T) {
+	mrb, _ := NewCore()
+	defer mrb.Close()
+
+	prog, err := Compile("x = 1000\nx", "")
+	ExpectNilError(t, err)
+	d, err := mrb.DisassembleBytecode(prog.Bytecode())
+	ExpectNilError(t, err)
+
+	// cut instruction with operands in the middle
+	for _, ins := range d.Code {
+		if ins.Size > 1 {
+			iseq := []byte{byte(ins.Opcode)}
+			_, err := decodeISeq(iseq)
+			ExpectErr(t, err, "truncated %v should fail", ins.Name)
+			break
+		}
+	}
+}
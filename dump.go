@@ -23,7 +23,7 @@ const (
 
 // DumpIrep Go implementation
 func (mrb *MrbState) DumpIrep(irep MrbIrep, flags uint8, writer io.Writer) (int, error) {
-	ret := C._dump_irep(mrb.p, irep.p, C.uint8_t(flags))
+	ret := C._dump_irep(mrb.state(), irep.p, C.uint8_t(flags))
 	defer mrb.Free(Buff{unsafe.Pointer(ret.bin)})
 
 	if int(ret.result) != MrbDumpOK {
@@ -49,9 +49,9 @@ func (mrb *MrbState) ReadIrep(buffer []byte) (irep MrbIrep, err error) {
 	bufLen := len(buffer)
 
 	if bufLen == 0 {
-		irep = MrbIrep{C.mrb_read_irep(mrb.p, nil), mrb}
+		irep = MrbIrep{C.mrb_read_irep(mrb.state(), nil), mrb}
 	} else {
-		irep = MrbIrep{C.mrb_read_irep_buf(mrb.p, unsafe.Pointer(&buffer[0]), C.size_t(bufLen)), mrb}
+		irep = MrbIrep{C.mrb_read_irep_buf(mrb.state(), unsafe.Pointer(&buffer[0]), C.size_t(bufLen)), mrb}
 	}
 
 	runtime.KeepAlive(buffer)
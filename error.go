@@ -32,7 +32,7 @@ func (e RException) ExitStatus() int {
 }
 
 func (e RException) Backtrace() RValue {
-	return RValue{C.mrb_exc_backtrace(e.mrb.p, e.v), e.mrb}
+	return RValue{C.mrb_exc_backtrace(e.mrb.state(), e.v), e.mrb}
 }
 
 // MrbExcPtr returns RException
@@ -86,12 +86,12 @@ func (mrb *MrbState) SysFail(err error) Value {
 
 	return mrb.Raise(mrb.ERuntimeError(), err.Error())
 
-	// C.mrb_sys_fail(mrb.p, cmesg) never called
+	// C.mrb_sys_fail(mrb.state(), cmesg) never called
 }
 
 // ExcNewStr create new exception
 func (mrb *MrbState) ExcNewStr(c RClass, str MrbValue) Value {
-	return Value{C.mrb_exc_new_str(mrb.p, c.p, str.Value().v)}
+	return Value{C.mrb_exc_new_str(mrb.state(), c.p, str.Value().v)}
 }
 
 // MakeException from go values
@@ -99,7 +99,7 @@ func (mrb *MrbState) MakeException(args ...interface{}) Value {
 	l := len(args)
 
 	if l == 0 {
-		return Value{C.mrb_make_exception(mrb.p, 0, nil)}
+		return Value{C.mrb_make_exception(mrb.state(), 0, nil)}
 	}
 
 	argv := make([]C.mrb_value, l)
@@ -107,7 +107,7 @@ func (mrb *MrbState) MakeException(args ...interface{}) Value {
 		argv[i] = mrb.Value(args[i]).Value().v
 	}
 
-	return Value{C.mrb_make_exception(mrb.p, (C.mrb_int)(l), (*C.mrb_value)(&argv[0]))}
+	return Value{C.mrb_make_exception(mrb.state(), (C.mrb_int)(l), (*C.mrb_value)(&argv[0]))}
 }
 
 func (mrb *MrbState) NoMethodError(methodId MrbSym, args MrbValue, fmt string, fmtArgs ...interface{}) Value {
@@ -120,12 +120,12 @@ func (mrb *MrbState) NoMethodError(methodId MrbSym, args MrbValue, fmt string, f
 }
 
 // FRaise declaration for fail method
-func (mrb *MrbState) FRaise(v MrbValue) Value { return Value{C.mrb_f_raise(mrb.p, v.Value().v)} }
+func (mrb *MrbState) FRaise(v MrbValue) Value { return Value{C.mrb_f_raise(mrb.state(), v.Value().v)} }
 
 // Protect implemented in the mruby-error mrbgem
 //func (mrb *MrbState) Protect(body MrbFuncT, data MrbValue, state *bool) MrbValue {
 //  cstate := C.mrb_bool(*state)
-//  result := C.mrb_protect(mrb.p, mrb_func_t body, data.v, &cstate);
+//  result := C.mrb_protect(mrb.state(), mrb_func_t body, data.v, &cstate);
 //  state := bool(cstate)
 //  return result
 //}
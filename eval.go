@@ -0,0 +1,117 @@
+package oruby
+
+import (
+	"fmt"
+	"sort"
+	"unicode"
+)
+
+// MaxEvalLocals is maximum number of locals of EvalWith. Locals are passed to code as
+// arguments of block call, which are packed into Array when there are more of them
+const MaxEvalLocals = 14
+
+// EvalOptions are options of EvalWith
+type EvalOptions struct {
+	Filename string                 // filename shown in errors and backtraces
+	Line     int                    // line number of first code line, 1 when not set
+	Locals   map[string]interface{} // local variables set before code is run
+	Self     Value                  // self of evaluated code, top self when nil
+}
+
+// isLocalName reports if name is valid oruby local variable name
+func isLocalName(name string) bool {
+	for i, r := range name {
+		switch {
+		case r == '_', unicode.IsLower(r), r > unicode.MaxASCII && unicode.IsLetter(r):
+		case i > 0 && (unicode.IsDigit(r) || unicode.IsUpper(r)):
+		default:
+			return false
+		}
+	}
+	return name != ""
+}
+
+// sortedLocals returns local variable names in stable order, checking they are valid names
+func sortedLocals(locals map[string]interface{}) ([]string, error) {
+	names := make([]string, 0, len(locals))
+	for name := range locals {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, checkLocals(names)
+}
+
+// EvalWith evaluates code string with filename, line number, self and local variables.
+// Locals are real local variables of evaluated code, declared to parser before
+// code is parsed, so they can be read and assigned as any other local variable:
+//
+//	mrb.EvalWith("total > limit", oruby.EvalOptions{
+//		Filename: "rules.rb",
+//		Locals:   map[string]interface{}{"total": 120, "limit": 100},
+//	})
+func (mrb *MrbState) EvalWith(code string, opts EvalOptions) (result Value, err error) {
+	if mrb.remote(func() { result, err = mrb.EvalWith(code, opts) }) {
+		return result, err
+	}
+
+	names, err := sortedLocals(opts.Locals)
+	if err != nil {
+		return nilValue, err
+	}
+
+	filename := opts.Filename
+	if filename == "" {
+		filename = "(eval)"
+	}
+
+	cxt := mrb.MrbcContextNew()
+	defer cxt.Free()
+	cxt.SetCaptureErrors(true)
+	cxt.Filename(filename)
+	if opts.Line > 0 {
+		cxt.SetLineNo(opts.Line)
+	}
+	cxt.SetLocals(names...)
+
+	// only result is kept in GC arena
+	ai := mrb.GCArenaSave()
+	defer func() {
+		mrb.GCArenaRestore(ai)
+		mrb.GCProtect(result)
+	}()
+
+	p, err := mrb.ParseString(code, cxt)
+	if err != nil {
+		return nilValue, err
+	}
+	defer p.Free()
+
+	if err := parserErrors(p, filename); err != nil {
+		return nilValue, ESyntaxError("%v", err)
+	}
+
+	proc, err := mrb.GenerateCode(p)
+	if err != nil {
+		return nilValue, fmt.Errorf("%v: %v", filename, err)
+	}
+	proc.SetTargetClass(mrb.ObjectClass())
+
+	self := opts.Self
+	if self.IsNil() {
+		self = mrb.TopSelf()
+	}
+
+	// Top level code has no parameters, so yielded values are set in registers
+	// of locals, in order they were declared to parser
+	args := make([]interface{}, len(names))
+	for i, name := range names {
+		args[i] = mrb.Value(opts.Locals[name])
+	}
+
+	mrb.ExcClear()
+	result = mrb.YieldWithClass(proc, self, mrb.ObjectClass(), args...)
+	if err := mrb.Err(); err != nil {
+		return nilValue, err
+	}
+	return result, nil
+}
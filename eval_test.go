@@ -0,0 +1,52 @@
+package oruby
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMrbState_EvalWith(t *testing.T) {
+	mrb, _ := NewCore()
+	defer mrb.Close()
+
+	v, err := mrb.EvalWith("total = price * qty\ntotal > limit ? total : 0", EvalOptions{
+		Filename: "rules.rb",
+		Locals:   map[string]interface{}{"price": 40, "qty": 3, "limit": 100},
+	})
+	ExpectNilError(t, err)
+	ExpectEql(t, v.Int(), 120)
+
+	// Locals are real local variables, which can be assigned
+	v, err = mrb.EvalWith("name = name + '!'\nname", EvalOptions{Locals: map[string]interface{}{"name": "oruby"}})
+	ExpectNilError(t, err)
+	ExpectEql(t, mrb.String(v), "oruby!")
+}
+
+func TestMrbState_EvalWithSelf(t *testing.T) {
+	mrb, _ := NewCore()
+	defer mrb.Close()
+
+	obj, err := mrb.Eval("class Conf; def initialize; @port = 80; end; end; Conf.new")
+	ExpectNilError(t, err)
+
+	v, err := mrb.EvalWith("@port + offset", EvalOptions{Self: obj.Value(), Locals: map[string]interface{}{"offset": 8000}})
+	ExpectNilError(t, err)
+	ExpectEql(t, v.Int(), 8080)
+}
+
+func TestMrbState_EvalWithErrors(t *testing.T) {
+	mrb, _ := NewCore()
+	defer mrb.Close()
+
+	_, err := mrb.EvalWith("1 +", EvalOptions{Filename: "conf.rb", Line: 10})
+	ExpectErr(t, err, "syntax error expected")
+	Expect(t, strings.HasPrefix(err.Error(), "conf.rb:10:"), "syntax error should point to conf.rb:10, got %v", err)
+
+	_, err = mrb.EvalWith("\nraise 'bad'", EvalOptions{Filename: "conf.rb", Line: 20})
+	ExpectErr(t, err, "exception expected")
+	bt := mrb.String(mrb.Inspect(mrb.Exc().Backtrace()))
+	Expect(t, strings.Contains(bt, "conf.rb:21\"") || strings.Contains(bt, "conf.rb:21:"), "backtrace should point to conf.rb:21, got %v", bt)
+
+	_, err = mrb.EvalWith("x", EvalOptions{Locals: map[string]interface{}{"Bad": 1}})
+	ExpectErr(t, err, "invalid local name error expected")
+}
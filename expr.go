@@ -0,0 +1,300 @@
+package oruby
+
+import "fmt"
+
+// exprFilename is filename of expressions in errors and backtraces
+const exprFilename = "(expr)"
+
+// Expr is oruby expression compiled once for many evaluations, as used by rule engines.
+// Expression variables are real local variables declared at compilation. Other names
+// are method calls on self, so expression can be evaluated on context object:
+//
+//	expr, _ := mrb.CompileExpr("order.total > limit && vip?", "limit")
+//	ok, err := expr.EvalBool(user, map[string]interface{}{"limit": 100})
+//
+// Expr accepts single expression only. Statements like definitions, assignments,
+// loops, blocks and jumps are rejected at compilation
+type Expr struct {
+	mrb    *MrbState
+	source string
+	names  []string
+	proc   RProc
+}
+
+// exprNotAllowed names statement nodes in compilation errors
+var exprNotAllowed = map[NodeType]string{
+	NodeDef:     "method definition",
+	NodeSDef:    "method definition",
+	NodeAlias:   "alias",
+	NodeUndef:   "undef",
+	NodeClass:   "class definition",
+	NodeModule:  "module definition",
+	NodeSClass:  "class definition",
+	NodeAsgn:    "assignment",
+	NodeMasgn:   "assignment",
+	NodeOpAsgn:  "assignment",
+	NodeCdecl:   "assignment",
+	NodeCvasgn:  "assignment",
+	NodeWhile:   "loop",
+	NodeUntil:   "loop",
+	NodeFor:     "loop",
+	NodeIter:    "block",
+	NodeLambda:  "block",
+	NodeBreak:   "break",
+	NodeNext:    "next",
+	NodeRedo:    "redo",
+	NodeRetry:   "retry",
+	NodeReturn:  "return",
+	NodeYield:   "yield",
+	NodeSuper:   "super",
+	NodeZSuper:  "super",
+	NodeRescue:  "rescue",
+	NodeEnsure:  "ensure",
+	NodeXStr:    "command",
+	NodeDXStr:   "command",
+	NodePostExe: "END block",
+}
+
+// CompileExpr parses and compiles expression with given local variable names.
+// Names are sorted, so Names returns them in order of EvalValues arguments
+func (mrb *MrbState) CompileExpr(source string, names ...string) (expr *Expr, err error) {
+	if mrb.remote(func() { expr, err = mrb.CompileExpr(source, names...) }) {
+		return expr, err
+	}
+
+	locals := make(map[string]interface{}, len(names))
+	for _, name := range names {
+		locals[name] = nil
+	}
+	sorted, err := sortedLocals(locals)
+	if err != nil {
+		return nil, err
+	}
+
+	cxt := mrb.MrbcContextNew()
+	defer cxt.Free()
+	cxt.SetCaptureErrors(true)
+	cxt.Filename(exprFilename)
+	cxt.SetLocals(sorted...)
+
+	ai := mrb.GCArenaSave()
+	defer mrb.GCArenaRestore(ai)
+
+	p, err := mrb.ParseString(source, cxt)
+	if err != nil {
+		return nil, err
+	}
+	defer p.Free()
+
+	if err := parserErrors(p, exprFilename); err != nil {
+		return nil, ESyntaxError("%v", err)
+	}
+	if err := checkExprTree(p.Tree()); err != nil {
+		return nil, err
+	}
+
+	proc, err := mrb.GenerateCode(p)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", exprFilename, err)
+	}
+	proc.SetTargetClass(mrb.ObjectClass())
+	mrb.GCRegister(proc.Value())
+
+	return &Expr{mrb, source, sorted, proc}, nil
+}
+
+// checkExprTree checks that parsed tree is single expression
+func checkExprTree(tree *MrbAstNode) error {
+	// NODE_SCOPE (locals . NODE_BEGIN stmts...)
+	if tree == nil || tree.Cdr() == nil {
+		return ESyntaxError("%s: expression expected", exprFilename)
+	}
+	body := tree.Cdr().Cdr()
+	if body != nil && body.IsNode() && body.NodeType() == NodeBegin {
+		switch body.Cdr().Len() {
+		case 0:
+			return ESyntaxError("%s: expression expected", exprFilename)
+		case 1:
+			body = body.Cdr().Car()
+		default:
+			return ESyntaxError("%s:%d: single expression expected", exprFilename, body.Cdr().Cdr().Car().LineNo())
+		}
+	}
+	return checkExprNode(body)
+}
+
+// checkExprNode checks node or list of nodes, accepting only expression nodes
+func checkExprNode(n *MrbAstNode) error {
+	if n == nil {
+		return nil
+	}
+	if !n.IsNode() {
+		if err := checkExprNode(n.Car()); err != nil {
+			return err
+		}
+		return checkExprNode(n.Cdr())
+	}
+
+	switch t := n.NodeType(); t {
+	case NodeSelf, NodeNil, NodeTrue, NodeFalse, NodeInt, NodeFloat, NodeStr, NodeSym, NodeRegx,
+		NodeZArray, NodeLVar, NodeDVar, NodeGVar, NodeIVar, NodeCVar, NodeConst, NodeColon3,
+		NodeNthRef, NodeBackRef:
+		return nil
+	case NodeCall, NodeSCall, NodeFCall:
+		// (type recv mid args)
+		if err := checkExprNode(n.Cdr().Car()); err != nil {
+			return err
+		}
+		return checkExprNode(n.Cdr().Cdr().Cdr().Car())
+	case NodeColon2:
+		// (type recv . name)
+		return checkExprNode(n.Cdr().Car())
+	case NodeBegin:
+		if n.Cdr().Len() > 1 {
+			return ESyntaxError("%s:%d: single expression expected", exprFilename, n.LineNo())
+		}
+		return checkExprNode(n.Cdr())
+	case NodeIf, NodeAnd, NodeOr, NodeNot, NodeNegate, NodeArray, NodeHash, NodeDot2, NodeDot3,
+		NodeSplat, NodeBlockArg, NodeDStr, NodeDSym, NodeDefined, NodeWords, NodeSymbols:
+		return checkExprNode(n.Cdr())
+	case NodeCase:
+		// (case subject . whens), with when (conditions . body)
+		return checkExprNode(n.Cdr())
+	case NodeDRegx:
+		// (dregx parts . flags)
+		return checkExprNode(n.nth(1))
+	case NodeHeredoc:
+		return checkExprNode(n.heredoc())
+	default:
+		what, ok := exprNotAllowed[t]
+		if !ok {
+			what = "statement"
+		}
+		return ESyntaxError("%s:%d: %s is not allowed in expression", exprFilename, n.LineNo(), what)
+	}
+}
+
+// Source returns expression source
+func (expr *Expr) Source() string { return expr.source }
+
+// Names returns sorted local variable names of expression
+func (expr *Expr) Names() []string { return append([]string(nil), expr.names...) }
+
+// Free releases compiled expression. Expr can not be used after Free
+func (expr *Expr) Free() {
+	if expr.proc.p != nil {
+		expr.mrb.Do(func(mrb *MrbState) { mrb.GCUnregister(expr.proc.Value()) })
+		expr.proc.p = nil
+	}
+}
+
+// Eval evaluates expression on top self. Variables not given in vars are nil
+func (expr *Expr) Eval(vars map[string]interface{}) (Value, error) {
+	return expr.EvalOn(nil, vars)
+}
+
+// EvalOn evaluates expression with self set to context object, so names which are not
+// expression variables are called as methods of context. Nil context is top self
+func (expr *Expr) EvalOn(context interface{}, vars map[string]interface{}) (Value, error) {
+	values := make([]interface{}, len(expr.names))
+	for name, val := range vars {
+		i := expr.index(name)
+		if i < 0 {
+			return nilValue, EArgumentError("unknown expression variable '%v'", name)
+		}
+		values[i] = val
+	}
+	return expr.EvalValues(context, values...)
+}
+
+// EvalValues evaluates expression on context object with variable values given in order
+// of Names. It is faster than EvalOn, as no map is used
+func (expr *Expr) EvalValues(context interface{}, values ...interface{}) (result Value, err error) {
+	mrb := expr.mrb
+	if mrb.remote(func() { result, err = expr.EvalValues(context, values...) }) {
+		return result, err
+	}
+
+	if expr.proc.p == nil {
+		return nilValue, ERuntimeError("expression is freed")
+	}
+	if len(values) > len(expr.names) {
+		return nilValue, EArgumentError("wrong number of expression variables (%d for %d)", len(values), len(expr.names))
+	}
+
+	ai := mrb.GCArenaSave()
+	defer func() {
+		mrb.GCArenaRestore(ai)
+		mrb.GCProtect(result)
+	}()
+
+	self := mrb.TopSelf()
+	if context != nil {
+		self = mrb.Value(context)
+	}
+
+	mrb.ExcClear()
+	result = mrb.YieldWithClass(expr.proc, self, mrb.ObjectClass(), values...)
+	if err := mrb.Err(); err != nil {
+		return nilValue, err
+	}
+	return result, nil
+}
+
+// EvalBool evaluates expression and returns its truth value. Only nil and false are false
+func (expr *Expr) EvalBool(context interface{}, vars map[string]interface{}) (bool, error) {
+	v, err := expr.EvalOn(context, vars)
+	if err != nil {
+		return false, err
+	}
+	return v.Bool(), nil
+}
+
+// EvalFloat evaluates expression with Integer or Float result
+func (expr *Expr) EvalFloat(context interface{}, vars map[string]interface{}) (float64, error) {
+	v, err := expr.EvalOn(context, vars)
+	if err != nil {
+		return 0, err
+	}
+	if !v.IsFloat() && !v.IsInteger() {
+		return 0, expr.typeError(v, "Numeric")
+	}
+	return v.Float64(), nil
+}
+
+// EvalInt evaluates expression with Integer result
+func (expr *Expr) EvalInt(context interface{}, vars map[string]interface{}) (int, error) {
+	v, err := expr.EvalOn(context, vars)
+	if err != nil {
+		return 0, err
+	}
+	if !v.IsInteger() {
+		return 0, expr.typeError(v, "Integer")
+	}
+	return v.Int(), nil
+}
+
+// EvalString evaluates expression with String result
+func (expr *Expr) EvalString(context interface{}, vars map[string]interface{}) (string, error) {
+	v, err := expr.EvalOn(context, vars)
+	if err != nil {
+		return "", err
+	}
+	if !v.IsString() {
+		return "", expr.typeError(v, "String")
+	}
+	return v.String(), nil
+}
+
+func (expr *Expr) index(name string) int {
+	for i, n := range expr.names {
+		if n == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func (expr *Expr) typeError(v Value, expected string) error {
+	return ETypeError("expression '%v' returned %v, %v expected", expr.source, TypeName(v.Type()), expected)
+}
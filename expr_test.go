@@ -0,0 +1,126 @@
+package oruby
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExpr_Eval(t *testing.T) {
+	mrb, _ := NewCore()
+	defer mrb.Close()
+
+	expr, err := mrb.CompileExpr("total > limit && !blocked", "total", "limit", "blocked")
+	ExpectNilError(t, err)
+	defer expr.Free()
+	ExpectEql(t, expr.Names(), []string{"blocked", "limit", "total"})
+
+	for _, tc := range []struct {
+		total   int
+		blocked bool
+		want    bool
+	}{
+		{150, false, true},
+		{50, false, false},
+		{150, true, false},
+	} {
+		ok, err := expr.EvalBool(nil, map[string]interface{}{"total": tc.total, "limit": 100, "blocked": tc.blocked})
+		ExpectNilError(t, err)
+		ExpectEql(t, ok, tc.want)
+	}
+
+	v, err := expr.EvalValues(nil, false, 10, 20)
+	ExpectNilError(t, err)
+	ExpectEql(t, v.Bool(), true)
+
+	_, err = expr.Eval(map[string]interface{}{"unknown": 1})
+	ExpectErr(t, err, "unknown variable should fail")
+}
+
+func TestExpr_EvalOn(t *testing.T) {
+	mrb, _ := NewCore()
+	defer mrb.Close()
+
+	user, err := mrb.Eval("u = Object.new\ndef u.vip?; true; end\ndef u.name; 'Ann'; end\nu")
+	ExpectNilError(t, err)
+
+	expr, err := mrb.CompileExpr("vip? ? name + suffix : 'none'", "suffix")
+	ExpectNilError(t, err)
+	defer expr.Free()
+
+	s, err := expr.EvalString(user, map[string]interface{}{"suffix": "!"})
+	ExpectNilError(t, err)
+	ExpectEql(t, s, "Ann!")
+
+	_, err = expr.EvalString(nil, nil)
+	ExpectErr(t, err, "vip? is not defined on top self")
+}
+
+func TestExpr_Typed(t *testing.T) {
+	mrb, _ := NewCore()
+	defer mrb.Close()
+
+	expr, err := mrb.CompileExpr("price * qty", "price", "qty")
+	ExpectNilError(t, err)
+	defer expr.Free()
+
+	i, err := expr.EvalInt(nil, map[string]interface{}{"price": 5, "qty": 3})
+	ExpectNilError(t, err)
+	ExpectEql(t, i, 15)
+
+	f, err := expr.EvalFloat(nil, map[string]interface{}{"price": 2.5, "qty": 2})
+	ExpectNilError(t, err)
+	ExpectEql(t, f, 5.0)
+
+	_, err = expr.EvalInt(nil, map[string]interface{}{"price": 2.5, "qty": 2})
+	ExpectErr(t, err, "Float result should fail as Integer")
+
+	_, err = expr.EvalString(nil, map[string]interface{}{"price": 1, "qty": 1})
+	ExpectErr(t, err, "Integer result should fail as String")
+}
+
+func TestExpr_RejectsStatements(t *testing.T) {
+	mrb, _ := NewCore()
+	defer mrb.Close()
+
+	for _, src := range []string{
+		"",
+		"a = 1",
+		"x += 1",
+		"def f; end",
+		"class A; end",
+		"module M; end",
+		"while true; end",
+		"1; 2",
+		"1\n2",
+		"(a = 2) + 1",
+		"case x when 1 then y = 1 end",
+		"<<~EOS\n  #{y = x}\nEOS",
+		"[1].map { |i| i }",
+		"return 1",
+		"$g = 1",
+	} {
+		_, err := mrb.CompileExpr(src, "x")
+		ExpectErr(t, err, "statement '%v' should be rejected", src)
+	}
+
+	for _, src := range []string{
+		"x",
+		"x.nil? || x > 2",
+		"(x + 1) * 2",
+		"x ? 'yes' : \"no #{x}\"",
+		"[1, 2, 3].include?(x)",
+		"{a: 1}[:a] == x",
+		"-1.5 < x && (1..10) === x",
+		"Object::String",
+		"case x when 1, 2 then :low when Integer then :high else :other end",
+		"case when x then 1 end",
+		"<<~EOS\n  value #{x}\nEOS",
+		"/a#{x}b/i.match?('ab')",
+	} {
+		_, err := mrb.CompileExpr(src, "x")
+		ExpectNilError(t, err)
+	}
+
+	_, err := mrb.CompileExpr("1 +\ndef f; end")
+	Expect(t, err != nil && strings.Contains(err.Error(), "not allowed"), "definition should not be allowed, got %v", err)
+}
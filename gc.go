@@ -16,12 +16,12 @@ const (
 
 // MrbFreeContext free context
 func MrbFreeContext(mrb *MrbState, c *MrbContext) {
-	C.mrb_free_context(mrb.p, c.p)
+	C.mrb_free_context(mrb.state(), c.p)
 }
 
 // MrbObjectDeadP checks if object is dead
 func MrbObjectDeadP(mrb *MrbState, o RBasic) bool {
-	return C.mrb_object_dead_p(mrb.p, o.p) == true
+	return C.mrb_object_dead_p(mrb.state(), o.p) == true
 }
 
 // IsDead checks if value is garbage collected. Values that are not inherited from
@@ -59,40 +59,40 @@ func (mrb *MrbState) ObjspaceEachObjects(f MrbEachObjectCallbackT) {
 
 	mrb.setHook(p, f)
 
-	C.mrb_objspace_each_objects(mrb.p, (*C.mrb_each_object_callback)(C.set_each_object_callback), p)
+	C.mrb_objspace_each_objects(mrb.state(), (*C.mrb_each_object_callback)(C.set_each_object_callback), p)
 	runtime.KeepAlive(s)
 
 	mrb.setHook(p, nil)
 }
 
 // GCIterating flag signals if GC is iterating
-func (mrb *MrbState) GCIterating() bool { return C._gc_iterating(mrb.p) != false }
+func (mrb *MrbState) GCIterating() bool { return C._gc_iterating(mrb.state()) != false }
 
 // GCFull flag is set when performing full GC
-func (mrb *MrbState) GCFull() bool { return C._gc_full(mrb.p) != false }
+func (mrb *MrbState) GCFull() bool { return C._gc_full(mrb.state()) != false }
 
 // GCGenerational flag is set when GC is generational mode
-func (mrb *MrbState) GCGenerational() bool { return C._gc_generational(mrb.p) != false }
+func (mrb *MrbState) GCGenerational() bool { return C._gc_generational(mrb.state()) != false }
 
 // GCOutOfMemory is set when GC encounters OutOfMemory error
-func (mrb *MrbState) GCOutOfMemory() bool { return C._gc_out_of_memory(mrb.p) != false }
+func (mrb *MrbState) GCOutOfMemory() bool { return C._gc_out_of_memory(mrb.state()) != false }
 
 // GCState returns current GC state
-func (mrb *MrbState) GCState() int { return int(mrb.p.gc.state) }
+func (mrb *MrbState) GCState() int { return int(mrb.state().gc.state) }
 
 // GCLiveObjectCount returns count of "live" objects
-func (mrb *MrbState) GCLiveObjectCount() uint { return uint(mrb.p.gc.live) }
+func (mrb *MrbState) GCLiveObjectCount() uint { return uint(mrb.state().gc.live) }
 
 // GCDisabled is set when GC is disabled
-func (mrb *MrbState) GCDisabled() bool { return C._gc_disabled(mrb.p) != false }
+func (mrb *MrbState) GCDisabled() bool { return C._gc_disabled(mrb.state()) != false }
 
 // GCEnable enables GC
-func (mrb *MrbState) GCEnable() { C._gc_set_disabled(mrb.p, iifmb(false)) }
+func (mrb *MrbState) GCEnable() { C._gc_set_disabled(mrb.state(), iifmb(false)) }
 
 // GCDisable disables GC
-func (mrb *MrbState) GCDisable() { C._gc_set_disabled(mrb.p, iifmb(true)) }
+func (mrb *MrbState) GCDisable() { C._gc_set_disabled(mrb.state(), iifmb(true)) }
 
 // GCArenaPeek peeks object in GCArena, at given index
 func (mrb *MrbState) GCArenaPeek(index int) RBasic {
-	return RBasic{C._gc_arena_peek(mrb.p, C.mrb_int(index))}
+	return RBasic{C._gc_arena_peek(mrb.state(), C.mrb_int(index))}
 }
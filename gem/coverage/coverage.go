@@ -0,0 +1,128 @@
+package coverage
+
+import (
+	"github.com/oruby/oruby"
+)
+
+// Result formats selected by Coverage.start options
+const (
+	modeLines = 1 << iota
+	modeBranches
+)
+
+func init() {
+	oruby.Gem("coverage", func(mrb *oruby.MrbState) interface{} {
+		coverage := mrb.DefineModule("Coverage")
+		coverage.DefineClassMethod("start", coverageStart, mrb.ArgsOpt(1))
+		coverage.DefineClassMethod("result", coverageResult, mrb.ArgsNone())
+		coverage.DefineClassMethod("peek_result", coveragePeekResult, mrb.ArgsNone())
+		coverage.DefineClassMethod("running?", coverageRunning, mrb.ArgsNone())
+		return nil
+	})
+}
+
+// coverageStart starts coverage. Without arguments, result is legacy hash of line
+// counts by filename. With lines: true, branches: true or :all argument, result has
+// lines and branches coverage by filename
+func coverageStart(mrb *oruby.MrbState, self oruby.Value) oruby.MrbValue {
+	mode := 0
+	if arg := mrb.GetArgsFirst(); arg.IsSymbol() {
+		if mrb.SymString(arg.Symbol()) != "all" {
+			return mrb.Raisef(mrb.EArgumentError(), "unknown coverage option: %v", mrb.SymString(arg.Symbol()))
+		}
+		mode = modeLines | modeBranches
+	} else if opt := mrb.KeywordArgs(); !opt.IsNil() {
+		if mrb.HashGet(opt, mrb.Intern("lines")).Bool() {
+			mode |= modeLines
+		}
+		if mrb.HashGet(opt, mrb.Intern("branches")).Bool() {
+			mode |= modeBranches
+		}
+	}
+
+	if err := mrb.StartCoverage(); err != nil {
+		return mrb.Raisef(mrb.ERuntimeError(), "%v", err)
+	}
+	mrb.IVSet(self, mrb.Intern("__mode"), mrb.FixnumValue(mode))
+	return mrb.NilValue()
+}
+
+func coverageResult(mrb *oruby.MrbState, self oruby.Value) oruby.MrbValue {
+	report := mrb.StopCoverage()
+	if report == nil {
+		return mrb.Raisef(mrb.ERuntimeError(), "coverage measurement is not enabled")
+	}
+	return resultValue(mrb, report, mrb.IVGet(self, mrb.Intern("__mode")).Int())
+}
+
+func coveragePeekResult(mrb *oruby.MrbState, self oruby.Value) oruby.MrbValue {
+	report := mrb.PeekCoverage()
+	if report == nil {
+		return mrb.Raisef(mrb.ERuntimeError(), "coverage measurement is not enabled")
+	}
+	return resultValue(mrb, report, mrb.IVGet(self, mrb.Intern("__mode")).Int())
+}
+
+func coverageRunning(mrb *oruby.MrbState, self oruby.Value) oruby.MrbValue {
+	return mrb.BoolValue(mrb.CoverageRunning())
+}
+
+// resultValue returns coverage hash by filename in format selected by mode
+func resultValue(mrb *oruby.MrbState, report *oruby.CoverageReport, mode int) oruby.Value {
+	ret := mrb.HashNew()
+	for _, fc := range report.Files {
+		lines := linesValue(mrb, fc)
+		if mode == 0 {
+			mrb.HashSet(ret, mrb.StrNew(fc.Filename), lines)
+			continue
+		}
+
+		file := mrb.HashNew()
+		if mode&modeLines != 0 {
+			mrb.HashSet(file, mrb.SymbolValue(mrb.Intern("lines")), lines)
+		}
+		if mode&modeBranches != 0 {
+			mrb.HashSet(file, mrb.SymbolValue(mrb.Intern("branches")), branchesValue(mrb, fc))
+		}
+		mrb.HashSet(ret, mrb.StrNew(fc.Filename), file)
+	}
+	return ret.Value()
+}
+
+// linesValue returns array of line counts, indexed by line number - 1. Count of
+// line without code is nil
+func linesValue(mrb *oruby.MrbState, fc *oruby.FileCoverage) oruby.Value {
+	lines := mrb.AryNew()
+	if len(fc.Lines) == 0 {
+		return lines.Value()
+	}
+	for i := 0; i < fc.Lines[len(fc.Lines)-1].Line; i++ {
+		mrb.AryPush(lines, mrb.NilValue())
+	}
+	for _, l := range fc.Lines {
+		mrb.ArySet(lines, l.Line-1, mrb.FixnumValue(l.Count))
+	}
+	return lines.Value()
+}
+
+// branchesValue returns branch coverage as {[:if, id, line, 0, line, 0] => {[:then, ...] => count, [:else, ...] => count}}.
+// Columns are not tracked and are reported as 0
+func branchesValue(mrb *oruby.MrbState, fc *oruby.FileCoverage) oruby.Value {
+	branches := mrb.HashNew()
+	id := 0
+	key := func(kind string, line int) oruby.Value {
+		k := mrb.AryNewFromValues(mrb.SymbolValue(mrb.Intern(kind)), mrb.FixnumValue(id),
+			mrb.FixnumValue(line), mrb.FixnumValue(0), mrb.FixnumValue(line), mrb.FixnumValue(0))
+		id++
+		return k.Value()
+	}
+
+	for _, b := range fc.Branches {
+		cond := key("if", b.Line)
+		targets := mrb.HashNew()
+		mrb.HashSet(targets, key("then", b.ThenLine), mrb.FixnumValue(b.Then))
+		mrb.HashSet(targets, key("else", b.ElseLine), mrb.FixnumValue(b.Else))
+		mrb.HashSet(branches, cond, targets)
+	}
+	return branches.Value()
+}
@@ -0,0 +1,61 @@
+package coverage
+
+import (
+	"testing"
+
+	"github.com/oruby/oruby"
+	"github.com/oruby/oruby/gem/assert"
+)
+
+func TestCoverage(t *testing.T) {
+	mrb := oruby.MrbOpen()
+	defer mrb.Close()
+
+	// load_file loads code compiled with filename, as Load and require do
+	mrb.KernelModule().DefineMethod("load_file", func(mrb *oruby.MrbState, self oruby.Value) oruby.MrbValue {
+		code, filename := mrb.GetArgs2()
+		cxt := mrb.MrbcContextNew()
+		defer cxt.Free()
+		cxt.Filename(filename.String())
+		ret, err := mrb.LoadStringCxt(code.String(), cxt)
+		if err != nil {
+			return mrb.RaiseError(err)
+		}
+		return ret
+	}, mrb.ArgsReq(2))
+
+	assert.AssertCode(t, mrb, `
+		assert('Coverage legacy result') do
+		  Coverage.start
+		  assert_true Coverage.running?
+		  load_file("a = 1\nif a > 1\n  a = 2\nend\n", "covered.rb")
+		  result = Coverage.result
+		  assert_false Coverage.running?
+
+		  lines = result["covered.rb"]
+		  assert_equal 1, lines[0]
+		  assert_equal 1, lines[1]
+		  assert_equal 0, lines[2]
+		end
+
+		assert('Coverage lines and branches') do
+		  Coverage.start(lines: true, branches: true)
+		  load_file("def cov(a)\n  if a\n    1\n  else\n    2\n  end\nend\ncov(true)\ncov(true)\n", "branches.rb")
+		  peek = Coverage.peek_result
+		  assert_true Coverage.running?
+		  result = Coverage.result
+
+		  assert_equal peek["branches.rb"], result["branches.rb"]
+		  assert_equal 2, result["branches.rb"][:lines][2]
+		  assert_equal 0, result["branches.rb"][:lines][4]
+
+		  counts = result["branches.rb"][:branches].values.first.values
+		  assert_equal [2, 0], counts
+		end
+
+		assert('Coverage not started') do
+		  assert_raise(RuntimeError) { Coverage.result }
+		  assert_raise(RuntimeError) { Coverage.peek_result }
+		end
+	`)
+}
@@ -3,12 +3,15 @@ package fullcore
 import (
 	_ "github.com/oruby/oruby/gem/base64"
 	_ "github.com/oruby/oruby/gem/complex"
+	_ "github.com/oruby/oruby/gem/coverage"
 	//_ "github.com/oruby/oruby/gem/database"
 	_ "github.com/oruby/oruby/gem/erubi"
+	_ "github.com/oruby/oruby/gem/irb"
 	_ "github.com/oruby/oruby/gem/json"
 	_ "github.com/oruby/oruby/gem/load"
 	//	_ "github.com/oruby/oruby/gem/process"
 	_ "github.com/oruby/oruby/gem/regexp"
 	_ "github.com/oruby/oruby/gem/time"
+	_ "github.com/oruby/oruby/gem/tracepoint"
 	_ "github.com/oruby/oruby/gem/zlib"
 )
@@ -0,0 +1,96 @@
+// Package gopkg exposes Go standard packages to oruby as modules under Go module:
+// "go/strings" as Go::Strings, "go/strconv" as Go::Strconv, "go/path" as Go::Path
+// and "go/math" as Go::Math.
+//
+// Gems are opt-in, import package to make them available:
+//
+//	import _ "github.com/oruby/oruby/gem/gopkg"
+package gopkg
+
+//go:generate go run ../../cmd/orubygen -import math -package gopkg -module math -table -o math_oruby.go
+
+import (
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/oruby/oruby"
+)
+
+func init() {
+	oruby.Gem("go/strings", func(mrb *oruby.MrbState) interface{} {
+		return mrb.DefineGoPackageUnder(mrb.DefineModule("Go"), "Strings", stringsPackage)
+	})
+	oruby.Gem("go/strconv", func(mrb *oruby.MrbState) interface{} {
+		return mrb.DefineGoPackageUnder(mrb.DefineModule("Go"), "Strconv", strconvPackage)
+	})
+	oruby.Gem("go/path", func(mrb *oruby.MrbState) interface{} {
+		return mrb.DefineGoPackageUnder(mrb.DefineModule("Go"), "Path", pathPackage)
+	})
+	oruby.Gem("go/math", func(mrb *oruby.MrbState) interface{} {
+		return mrb.DefineGoPackageUnder(mrb.DefineModule("Go"), "Math", mathPackage)
+	})
+}
+
+var stringsPackage = map[string]interface{}{
+	"Builder":      oruby.GoType((*strings.Builder)(nil)),
+	"Reader":       oruby.GoType(strings.NewReader),
+	"Compare":      strings.Compare,
+	"Contains":     strings.Contains,
+	"ContainsAny":  strings.ContainsAny,
+	"Count":        strings.Count,
+	"Cut":          strings.Cut,
+	"EqualFold":    strings.EqualFold,
+	"Fields":       strings.Fields,
+	"HasPrefix":    strings.HasPrefix,
+	"HasSuffix":    strings.HasSuffix,
+	"Index":        strings.Index,
+	"IndexAny":     strings.IndexAny,
+	"Join":         strings.Join,
+	"LastIndex":    strings.LastIndex,
+	"LastIndexAny": strings.LastIndexAny,
+	"Repeat":       strings.Repeat,
+	"Replace":      strings.Replace,
+	"ReplaceAll":   strings.ReplaceAll,
+	"Split":        strings.Split,
+	"SplitAfter":   strings.SplitAfter,
+	"SplitN":       strings.SplitN,
+	"ToLower":      strings.ToLower,
+	"ToTitle":      strings.ToTitle,
+	"ToUpper":      strings.ToUpper,
+	"ToValidUTF8":  strings.ToValidUTF8,
+	"Trim":         strings.Trim,
+	"TrimLeft":     strings.TrimLeft,
+	"TrimPrefix":   strings.TrimPrefix,
+	"TrimRight":    strings.TrimRight,
+	"TrimSpace":    strings.TrimSpace,
+	"TrimSuffix":   strings.TrimSuffix,
+}
+
+var strconvPackage = map[string]interface{}{
+	"IntSize":      strconv.IntSize,
+	"Atoi":         strconv.Atoi,
+	"FormatBool":   strconv.FormatBool,
+	"FormatFloat":  strconv.FormatFloat,
+	"FormatInt":    strconv.FormatInt,
+	"FormatUint":   strconv.FormatUint,
+	"Itoa":         strconv.Itoa,
+	"ParseBool":    strconv.ParseBool,
+	"ParseFloat":   strconv.ParseFloat,
+	"ParseInt":     strconv.ParseInt,
+	"ParseUint":    strconv.ParseUint,
+	"Quote":        strconv.Quote,
+	"QuoteToASCII": strconv.QuoteToASCII,
+	"Unquote":      strconv.Unquote,
+}
+
+var pathPackage = map[string]interface{}{
+	"Base":  path.Base,
+	"Clean": path.Clean,
+	"Dir":   path.Dir,
+	"Ext":   path.Ext,
+	"IsAbs": path.IsAbs,
+	"Join":  path.Join,
+	"Match": path.Match,
+	"Split": path.Split,
+}
@@ -0,0 +1,52 @@
+package gopkg
+
+import (
+	"testing"
+
+	"github.com/oruby/oruby"
+	"github.com/oruby/oruby/gem/assert"
+)
+
+func testEval(t *testing.T, code string) interface{} {
+	t.Helper()
+
+	mrb := oruby.MrbOpen()
+	defer mrb.Close()
+
+	v, err := mrb.Eval(code)
+	assert.NilError(t, err)
+	return mrb.Intf(v)
+}
+
+func TestStrings(t *testing.T) {
+	assert.Equal(t, testEval(t, `Go::Strings.to_upper("oruby")`), "ORUBY")
+	assert.Equal(t, testEval(t, `Go::Strings.split("a,b", ",")`), []interface{}{"a", "b"})
+	assert.Equal(t, testEval(t, `Go::Strings.join(["a", "b"], "-")`), "a-b")
+	assert.Equal(t, testEval(t, `Go::Strings.cut("k=v", "=")`), []interface{}{"k", "v", true})
+}
+
+func TestStrconv(t *testing.T) {
+	assert.Equal(t, testEval(t, `Go::Strconv.parse_int("ff", 16, 64)`), 255)
+	assert.Equal(t, testEval(t, `Go::Strconv.quote("a\tb")`), `"a\tb"`)
+
+	mrb := oruby.MrbOpen()
+	defer mrb.Close()
+	_, err := mrb.Eval(`Go::Strconv.atoi("x")`)
+	assert.Error(t, err, "atoi error should be raised")
+}
+
+func TestPath(t *testing.T) {
+	assert.Equal(t, testEval(t, `Go::Path.join("a", "b", "../c")`), "a/c")
+	assert.Equal(t, testEval(t, `Go::Path.abs?("/a")`), true)
+}
+
+func TestMath(t *testing.T) {
+	assert.Equal(t, testEval(t, `Go::Math.sqrt(16)`), 4.0)
+	assert.Equal(t, testEval(t, `Go::Math.nan?(Go::Math.nan)`), true)
+	assert.Equal(t, testEval(t, `Go::Math::MaxInt32`), 2147483647)
+	assert.Equal(t, testEval(t, `Go::Math::MaxUint32`), 4294967295)
+	// above Integer range, defined as Float
+	assert.Equal(t, testEval(t, `Go::Math::MaxUint64`), 1.8446744073709552e19)
+	assert.Equal(t, testEval(t, `Go::Math.is_nan(1.0)`), false)
+	assert.Equal(t, testEval(t, `Go::Math.round_to_even(2.5)`), 2.0)
+}
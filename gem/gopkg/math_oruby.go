@@ -0,0 +1,106 @@
+// Code generated by orubygen; DO NOT EDIT.
+
+package gopkg
+
+import "math"
+
+// mathPackage is table of package math members for MrbState.DefineGoPackage
+var mathPackage = map[string]interface{}{
+	"E":                      math.E,
+	"Pi":                     math.Pi,
+	"Phi":                    math.Phi,
+	"Sqrt2":                  math.Sqrt2,
+	"SqrtE":                  math.SqrtE,
+	"SqrtPi":                 math.SqrtPi,
+	"SqrtPhi":                math.SqrtPhi,
+	"Ln2":                    math.Ln2,
+	"Log2E":                  math.Log2E,
+	"Ln10":                   math.Ln10,
+	"Log10E":                 math.Log10E,
+	"MaxFloat32":             math.MaxFloat32,
+	"SmallestNonzeroFloat32": math.SmallestNonzeroFloat32,
+	"MaxFloat64":             math.MaxFloat64,
+	"SmallestNonzeroFloat64": math.SmallestNonzeroFloat64,
+	"MaxInt":                 int64(math.MaxInt),
+	"MinInt":                 int64(math.MinInt),
+	"MaxInt8":                math.MaxInt8,
+	"MinInt8":                math.MinInt8,
+	"MaxInt16":               math.MaxInt16,
+	"MinInt16":               math.MinInt16,
+	"MaxInt32":               math.MaxInt32,
+	"MinInt32":               math.MinInt32,
+	"MaxInt64":               int64(math.MaxInt64),
+	"MinInt64":               int64(math.MinInt64),
+	"MaxUint":                float64(math.MaxUint),
+	"MaxUint8":               math.MaxUint8,
+	"MaxUint16":              math.MaxUint16,
+	"MaxUint32":              int64(math.MaxUint32),
+	"MaxUint64":              float64(math.MaxUint64),
+	"abs":                    math.Abs,
+	"acos":                   math.Acos,
+	"acosh":                  math.Acosh,
+	"asin":                   math.Asin,
+	"asinh":                  math.Asinh,
+	"atan":                   math.Atan,
+	"atan2":                  math.Atan2,
+	"atanh":                  math.Atanh,
+	"cbrt":                   math.Cbrt,
+	"ceil":                   math.Ceil,
+	"copysign":               math.Copysign,
+	"cos":                    math.Cos,
+	"cosh":                   math.Cosh,
+	"dim":                    math.Dim,
+	"erf":                    math.Erf,
+	"erfc":                   math.Erfc,
+	"erfcinv":                math.Erfcinv,
+	"erfinv":                 math.Erfinv,
+	"exp":                    math.Exp,
+	"exp2":                   math.Exp2,
+	"expm1":                  math.Expm1,
+	"fma":                    math.FMA,
+	"float32bits":            math.Float32bits,
+	"float32frombits":        math.Float32frombits,
+	"float64bits":            math.Float64bits,
+	"float64frombits":        math.Float64frombits,
+	"floor":                  math.Floor,
+	"frexp":                  math.Frexp,
+	"gamma":                  math.Gamma,
+	"hypot":                  math.Hypot,
+	"ilogb":                  math.Ilogb,
+	"inf":                    math.Inf,
+	"is_inf":                 math.IsInf,
+	"is_nan":                 math.IsNaN,
+	"j0":                     math.J0,
+	"j1":                     math.J1,
+	"jn":                     math.Jn,
+	"ldexp":                  math.Ldexp,
+	"lgamma":                 math.Lgamma,
+	"log":                    math.Log,
+	"log10":                  math.Log10,
+	"log1p":                  math.Log1p,
+	"log2":                   math.Log2,
+	"logb":                   math.Logb,
+	"max":                    math.Max,
+	"min":                    math.Min,
+	"mod":                    math.Mod,
+	"modf":                   math.Modf,
+	"nan":                    math.NaN,
+	"nextafter":              math.Nextafter,
+	"nextafter32":            math.Nextafter32,
+	"pow":                    math.Pow,
+	"pow10":                  math.Pow10,
+	"remainder":              math.Remainder,
+	"round":                  math.Round,
+	"round_to_even":          math.RoundToEven,
+	"signbit":                math.Signbit,
+	"sin":                    math.Sin,
+	"sincos":                 math.Sincos,
+	"sinh":                   math.Sinh,
+	"sqrt":                   math.Sqrt,
+	"tan":                    math.Tan,
+	"tanh":                   math.Tanh,
+	"trunc":                  math.Trunc,
+	"y0":                     math.Y0,
+	"y1":                     math.Y1,
+	"yn":                     math.Yn,
+}
@@ -0,0 +1,142 @@
+package irb
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/oruby/oruby"
+)
+
+func init() {
+	oruby.Gem("irb", func(mrb *oruby.MrbState) interface{} {
+		con := &Console{In: os.Stdin, Out: os.Stdout}
+		mrb.KernelModule().DefineMethod("debugger", con.irb, mrb.ArgsNone())
+		if mrb.ClassDefined("Binding") {
+			mrb.ClassGet("Binding").DefineMethod("irb", con.bindingIrb, mrb.ArgsNone())
+		}
+		return con
+	})
+}
+
+// Console is input and output of REPL opened by debugger and Binding#irb. Console is
+// gem data, so host can redirect it:
+//
+//	con := mrb.GemData("irb").(*irb.Console)
+//	con.In, con.Out = conn, conn
+type Console struct {
+	In  io.Reader
+	Out io.Writer
+
+	in *bufio.Reader // buffered In, kept between REPLs
+	rd io.Reader     // In of buffered reader
+}
+
+// irb stops calling code in REPL, and continues it when REPL exits
+func (c *Console) irb(mrb *oruby.MrbState, self oruby.Value) oruby.MrbValue {
+	c.Run(mrb, 1)
+	return mrb.NilValue()
+}
+
+// bindingIrb opens REPL evaluating code with Kernel#eval in binding, so code runs in
+// environment which binding captured, wherever irb is called from
+func (c *Console) bindingIrb(mrb *oruby.MrbState, self oruby.Value) oruby.MrbValue {
+	c.repl(mrb, func(code string, line int) (oruby.Value, error) {
+		return mrb.Funcall(mrb.TopSelf(), mrb.Intern("eval"), code, self, "(irb)", line)
+	})
+	return mrb.NilValue()
+}
+
+// Run reads and evaluates code in call frame at level, as EvalInFrame, until exit or quit
+// is entered or input ends. Code of more lines is read until it is complete. Locals which
+// are assigned in REPL and are not in frame are kept until REPL exits
+func (c *Console) Run(mrb *oruby.MrbState, level int) {
+	locals := make(map[string]interface{})
+	c.repl(mrb, func(code string, line int) (oruby.Value, error) {
+		return mrb.EvalInFrame(level, code, oruby.EvalOptions{
+			Filename: "(irb)",
+			Line:     line,
+			Locals:   locals,
+		})
+	})
+}
+
+// repl reads code and prints results of eval, until exit or quit is entered or input ends
+func (c *Console) repl(mrb *oruby.MrbState, eval func(code string, line int) (oruby.Value, error)) {
+	if c.in == nil || c.rd != c.In {
+		c.in = bufio.NewReader(c.In)
+		c.rd = c.In
+	}
+
+	lineNo := 1
+	code := ""
+	open := false
+	for {
+		if open {
+			fmt.Fprint(c.Out, "* ")
+		} else {
+			fmt.Fprint(c.Out, "> ")
+		}
+
+		line, err := c.in.ReadString('\n')
+		if line == "" && err != nil {
+			fmt.Fprintln(c.Out)
+			return
+		}
+		if !strings.HasSuffix(line, "\n") {
+			line += "\n"
+		}
+		if !open && isExit(line) {
+			return
+		}
+
+		code += line
+		if open = isCodeBlockOpen(mrb, code); open {
+			continue
+		}
+
+		result, err := eval(code, lineNo)
+		lineNo += strings.Count(code, "\n")
+		code = ""
+
+		if err != nil {
+			mrb.ExcClear()
+			fmt.Fprintln(c.Out, err)
+			continue
+		}
+		fmt.Fprintf(c.Out, " => %s\n", inspect(mrb, result))
+	}
+}
+
+// isExit checks if line is exit or quit command
+func isExit(line string) bool {
+	cmd := strings.TrimSpace(line)
+	return cmd == "exit" || cmd == "quit"
+}
+
+// isCodeBlockOpen checks if code needs more lines before it is evaluated
+func isCodeBlockOpen(mrb *oruby.MrbState, code string) bool {
+	cxt := mrb.MrbcContextNew()
+	defer cxt.Free()
+	cxt.SetCaptureErrors(true)
+
+	parser := mrb.ParserNew()
+	defer parser.Free()
+	strFree := parser.SetS(code)
+	defer strFree()
+
+	parser.Parse(cxt)
+	return parser.IsCodeBlockOpen()
+}
+
+// inspect returns inspected value, or its class name when inspect fails
+func inspect(mrb *oruby.MrbState, v oruby.Value) string {
+	s, err := mrb.Funcall(v, mrb.Intern("inspect"))
+	if err != nil || !s.IsString() {
+		mrb.ExcClear()
+		return fmt.Sprintf("#<%s>", mrb.ObjClassname(v))
+	}
+	return s.String()
+}
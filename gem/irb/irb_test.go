@@ -0,0 +1,59 @@
+package irb
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/oruby/oruby"
+	"github.com/oruby/oruby/gem/assert"
+)
+
+func TestDebugger(t *testing.T) {
+	mrb := oruby.MrbOpen()
+	defer mrb.Close()
+
+	var out strings.Builder
+	con := mrb.GemData("irb").(*Console)
+	con.In = strings.NewReader("a += 1\nb = [\n  a,\n  @x\n]\na )\nexit\nx * 2\n")
+	con.Out = &out
+
+	assert.AssertCode(t, mrb, `
+		class Point
+		  def initialize(x)
+		    @x = x
+		  end
+
+		  def peek(a)
+		    debugger
+		    a
+		  end
+		end
+
+		assert('debugger') do
+		  assert_equal 2, Point.new(5).peek(1)
+		end
+
+		# REPL of binding evaluates in binding, not in caller of irb
+		def call_irb(b)
+		  x = 0
+		  b.irb
+		end
+
+		assert('Binding#irb') do
+		  skip unless Object.const_defined?(:Binding)
+		  x = 21
+		  call_irb(binding)
+		  assert_equal 21, x
+		end
+	`)
+
+	expected := []string{" => 2\n", "> * * * ", " => [2, 5]\n", "(irb):6"}
+	if mrb.ClassDefined("Binding") {
+		expected = append(expected, " => 42\n")
+	}
+	for _, s := range expected {
+		if !strings.Contains(out.String(), s) {
+			t.Errorf("expected %q in REPL output:\n%s", s, out.String())
+		}
+	}
+}
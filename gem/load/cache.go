@@ -0,0 +1,137 @@
+package load
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+
+	"github.com/oruby/oruby"
+)
+
+// CacheDirEnv is environment variable which enables bytecode cache for new states,
+// with its value used as cache directory
+const CacheDirEnv = "ORUBY_CACHE_DIR"
+
+// cacheSourceExt is extension of cached bytecode stored next to script
+const cacheSourceExt = ".mrbc"
+
+// loadState is per state data of load gem
+type loadState struct {
+	cache *scriptCache
+}
+
+// scriptCache stores bytecode of compiled scripts. Cache files start with key, hash of script
+// content, filename and oruby bytecode version, followed by hash of bytecode and RITE bytecode.
+// Cache files with different key are stale, and files with bytecode which does not match its
+// hash or does not pass VerifyIrep are corrupted. Script is parsed again in both cases
+type scriptCache struct {
+	dir     string
+	version string
+}
+
+// SetCache enables bytecode cache of scripts loaded with load and require.
+// Bytecode is stored in dir, or next to script file with ".mrbc" extension when dir is empty.
+// Cache is enabled for new states when ORUBY_CACHE_DIR environment variable is set
+func SetCache(mrb *oruby.MrbState, dir string) {
+	if s, ok := mrb.GemData("load").(*loadState); ok {
+		s.cache = newScriptCache(mrb, dir)
+	}
+}
+
+// DisableCache disables bytecode cache of loaded scripts
+func DisableCache(mrb *oruby.MrbState) {
+	if s, ok := mrb.GemData("load").(*loadState); ok {
+		s.cache = nil
+	}
+}
+
+func newScriptCache(mrb *oruby.MrbState, dir string) *scriptCache {
+	version := oruby.RiteBinaryFormatVer + oruby.RiteVMVer
+	for _, name := range []string{"MRUBY_VERSION", "MRUBY_RELEASE_NO"} {
+		if sym := mrb.Intern(name); mrb.ConstDefined(mrb.ObjectClass(), sym) {
+			version += ":" + mrb.String(mrb.ConstGet(mrb.ObjectClass(), sym))
+		}
+	}
+	return &scriptCache{dir, version}
+}
+
+func stateCache(mrb *oruby.MrbState) *scriptCache {
+	if s, ok := mrb.GemData("load").(*loadState); ok {
+		return s.cache
+	}
+	return nil
+}
+
+// key returns cache key of script source
+func (c *scriptCache) key(fileName string, source []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte(c.version))
+	h.Write([]byte{0})
+	h.Write([]byte(fileName))
+	h.Write([]byte{0})
+	h.Write(source)
+	return h.Sum(nil)
+}
+
+// path returns cache file name of script
+func (c *scriptCache) path(fileName string) string {
+	if c.dir == "" {
+		return fileName + cacheSourceExt
+	}
+	sum := sha256.Sum256([]byte(fileName))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:16])+"-"+filepath.Base(fileName)+cacheSourceExt)
+}
+
+// load returns cached bytecode of script, or nil if it is not cached, or cache is stale
+// or corrupted
+func (c *scriptCache) load(fileName string, source []byte) []byte {
+	data, err := os.ReadFile(c.path(fileName))
+	if err != nil {
+		return nil
+	}
+
+	key := c.key(fileName, source)
+	if !bytes.HasPrefix(data, key) || len(data) < len(key)+sha256.Size {
+		return nil
+	}
+	sum, code := data[len(key):len(key)+sha256.Size], data[len(key)+sha256.Size:]
+	if codeSum := sha256.Sum256(code); !bytes.Equal(sum, codeSum[:]) {
+		return nil
+	}
+	if oruby.VerifyIrep(code) != nil {
+		return nil
+	}
+	return code
+}
+
+// store writes script bytecode to cache. Cache file is replaced atomically,
+// so concurrent states never read partially written bytecode
+func (c *scriptCache) store(fileName string, source, code []byte) error {
+	path := c.path(fileName)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	sum := sha256.Sum256(code)
+	if _, err := f.Write(append(c.key(fileName, source), sum[:]...)); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.Write(code); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), path)
+}
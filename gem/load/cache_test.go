@@ -0,0 +1,108 @@
+package load
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/oruby/oruby"
+	"github.com/oruby/oruby/gem/assert"
+)
+
+func writeScript(t *testing.T, name, code string) {
+	t.Helper()
+	assert.NilError(t, os.WriteFile(name, []byte(code), 0o644))
+}
+
+func requireScript(t *testing.T, script, cacheDir string) string {
+	t.Helper()
+	mrb := oruby.MrbOpen()
+	defer mrb.Close()
+
+	SetCache(mrb, cacheDir)
+	_, err := mrb.Eval("require '" + script + "'")
+	assert.NilError(t, err)
+
+	v, err := mrb.Eval("$cached")
+	assert.NilError(t, err)
+	return v.String()
+}
+
+func TestCacheDir(t *testing.T) {
+	dir := t.TempDir()
+	cacheDir := filepath.Join(dir, "cache")
+	script := filepath.Join(dir, "lib.rb")
+	writeScript(t, script, "$cached = 'first'")
+
+	assert.Equal(t, requireScript(t, script, cacheDir), "first")
+
+	entries, err := os.ReadDir(cacheDir)
+	assert.NilError(t, err)
+	assert.Equal(t, len(entries), 1)
+
+	// Cached bytecode is used by next state
+	mrb := oruby.MrbOpen()
+	defer mrb.Close()
+	cache := newScriptCache(mrb, cacheDir)
+	source, _ := os.ReadFile(script)
+	assert.True(t, cache.load(script, source) != nil)
+	assert.Equal(t, requireScript(t, script, cacheDir), "first")
+
+	// Changed script makes cache stale
+	writeScript(t, script, "$cached = 'second'")
+	source, _ = os.ReadFile(script)
+	assert.True(t, cache.load(script, source) == nil)
+	assert.Equal(t, requireScript(t, script, cacheDir), "second")
+	assert.True(t, cache.load(script, source) != nil)
+}
+
+func TestCacheNextToSource(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "lib.rb")
+	writeScript(t, script, "$cached = 'source'")
+
+	assert.Equal(t, requireScript(t, script, ""), "source")
+
+	_, err := os.Stat(script + cacheSourceExt)
+	assert.NilError(t, err)
+}
+
+func TestCacheCorrupted(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "lib.rb")
+	writeScript(t, script, "$cached = 'fallback'")
+
+	mrb := oruby.MrbOpen()
+	defer mrb.Close()
+	cache := newScriptCache(mrb, "")
+	source, _ := os.ReadFile(script)
+	assert.NilError(t, cache.store(script, source, []byte("RITE broken")))
+	assert.True(t, cache.load(script, source) == nil)
+
+	// Script is compiled again, and cache is replaced
+	assert.Equal(t, requireScript(t, script, ""), "fallback")
+	assert.True(t, cache.load(script, source) != nil)
+}
+
+func TestCacheTampered(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "lib.rb")
+	writeScript(t, script, "$cached = 'compiled'")
+	assert.Equal(t, requireScript(t, script, ""), "compiled")
+
+	mrb := oruby.MrbOpen()
+	defer mrb.Close()
+	cache := newScriptCache(mrb, "")
+	source, _ := os.ReadFile(script)
+
+	// Bytecode which does not match its hash is not loaded
+	path := cache.path(script)
+	data, err := os.ReadFile(path)
+	assert.NilError(t, err)
+	data[len(data)-1] ^= 0xff
+	assert.NilError(t, os.WriteFile(path, data, 0o644))
+	assert.True(t, cache.load(script, source) == nil)
+
+	assert.Equal(t, requireScript(t, script, ""), "compiled")
+	assert.True(t, cache.load(script, source) != nil)
+}
@@ -1,6 +1,7 @@
 package load
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"os"
@@ -31,17 +32,51 @@ func init() {
 		mrb.ModuleClass().DefineMethod("autoload?", loadAutoloadP, mrb.ArgsReq(1))
 		mrb.DefineGlobalFunction("autoload", loadAutoload, mrb.ArgsReq(2))
 		mrb.DefineGlobalFunction("autoload?", loadAutoloadP, mrb.ArgsReq(1))
-		return nil
+
+		state := &loadState{}
+		if dir := os.Getenv(CacheDirEnv); dir != "" {
+			state.cache = newScriptCache(mrb, dir)
+		}
+		return state
 	})
 }
 
+// loadScript compiles script, or loads its bytecode from cache when cache is enabled
 func loadScript(mrb *oruby.MrbState, fileName string) (oruby.RProc, error) {
+	source, err := os.ReadFile(fileName)
+	if err != nil {
+		return oruby.RProc{}, err
+	}
+
+	cache := stateCache(mrb)
+	if cache != nil {
+		if code := cache.load(fileName, source); code != nil {
+			if proc, err := irepProc(mrb, code); err == nil {
+				return proc, nil
+			}
+		}
+	}
+
+	proc, err := compileScript(mrb, fileName, source)
+	if err != nil || cache == nil {
+		return proc, err
+	}
+
+	// Cache write errors are ignored, script is compiled again on next load
+	var buf bytes.Buffer
+	if _, err := mrb.DumpIrep(proc.IRep(), oruby.DumpDebugInfo, &buf); err == nil {
+		cache.store(fileName, source, buf.Bytes())
+	}
+	return proc, nil
+}
+
+func compileScript(mrb *oruby.MrbState, fileName string, source []byte) (oruby.RProc, error) {
 	cxt := mrb.MrbcContextNew()
 	defer cxt.Free()
 	cxt.SetCaptureErrors(true)
 	cxt.Filename(fileName)
 
-	p, err := mrb.ParseFile(fileName, cxt)
+	p, err := mrb.ParseString(string(source), cxt)
 	if err != nil {
 		return oruby.RProc{}, err
 	}
@@ -50,9 +85,10 @@ func loadScript(mrb *oruby.MrbState, fileName string) (oruby.RProc, error) {
 	// Check parse errors
 	if p.NErr() > 0 {
 		estr := ""
-		for i := 0; i < p.NErr(); i++ {
-			e := p.ErrorBuffer(i)
-			estr += fmt.Sprintf("%s:%d:%d: %s\n", mrb.SymString(p.Filename()), e.LineNo, e.Column, e.Message)
+		for _, d := range p.Diagnostics() {
+			if d.Severity == oruby.SeverityError {
+				estr += fmt.Sprintf("%s:%d:%d: %s\n", d.Filename, d.Line, d.Column, d.Message)
+			}
 		}
 		return oruby.RProc{}, errors.New(estr)
 	}
@@ -61,8 +97,16 @@ func loadScript(mrb *oruby.MrbState, fileName string) (oruby.RProc, error) {
 }
 
 func loadIrep(mrb *oruby.MrbState, fileName string) (oruby.RProc, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return oruby.RProc{}, err
+	}
+	return irepProc(mrb, data)
+}
+
+func irepProc(mrb *oruby.MrbState, data []byte) (oruby.RProc, error) {
 	ai := mrb.GCArenaSave()
-	irep, err := mrb.ReadIrepFile(fileName)
+	irep, err := mrb.ReadIrep(data)
 	mrb.GCArenaRestore(ai)
 
 	if err != nil {
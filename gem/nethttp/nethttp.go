@@ -0,0 +1,32 @@
+// Package nethttp registers http.Handler adapter, so oruby objects responding to
+// serve_http(w, r) are served by net/http with MrbState.Implement:
+//
+//	h, err := mrb.Implement(obj, (*http.Handler)(nil))
+//	mrb.StartExecutor()
+//	go http.ListenAndServe(":8080", h.(http.Handler))
+//
+// Adapter is opt-in, so oruby does not depend on net/http:
+//
+//	import _ "github.com/oruby/oruby/gem/nethttp"
+package nethttp
+
+import (
+	"net/http"
+
+	"github.com/oruby/oruby"
+)
+
+func init() {
+	oruby.RegisterAdapter((*http.Handler)(nil), oruby.Adapter{
+		New: func(o *oruby.RubyObject) interface{} { return rubyHandler{o} },
+	})
+}
+
+type rubyHandler struct{ *oruby.RubyObject }
+
+// ServeHTTP calls serve_http(w, r), with w and r passed as Go values
+func (h rubyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := h.Invoke("serve_http", []interface{}{w, r}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,45 @@
+package nethttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/oruby/oruby"
+	"github.com/oruby/oruby/gem/assert"
+)
+
+func TestHandler(t *testing.T) {
+	mrb := oruby.MrbOpen()
+	defer mrb.Close()
+
+	v, err := mrb.Eval(`
+		class Handler
+			def serve_http(w, r)
+				$served = true
+				raise "failed" if $fail
+			end
+		end
+		Handler.new
+	`)
+	assert.NilError(t, err)
+
+	h, err := mrb.Implement(v, (*http.Handler)(nil))
+	assert.NilError(t, err)
+	defer oruby.RubyObjectOf(h).Release()
+
+	rec := httptest.NewRecorder()
+	h.(http.Handler).ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, rec.Code, http.StatusOK)
+
+	served, err := mrb.Eval("$served")
+	assert.NilError(t, err)
+	assert.Equal(t, served.Bool(), true)
+
+	_, err = mrb.Eval("$fail = true")
+	assert.NilError(t, err)
+
+	rec = httptest.NewRecorder()
+	h.(http.Handler).ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, rec.Code, http.StatusInternalServerError)
+}
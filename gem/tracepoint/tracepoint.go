@@ -0,0 +1,237 @@
+package tracepoint
+
+import (
+	"fmt"
+
+	"github.com/oruby/oruby"
+)
+
+func init() {
+	oruby.Gem("tracepoint", func(mrb *oruby.MrbState) interface{} {
+		tp := mrb.DefineGoClass("TracePoint", &tracePoint{})
+		tp.DefineMethod("initialize", tpInit, mrb.ArgsAny()+mrb.ArgsBlock())
+		tp.DefineClassMethod("trace", tpTrace, mrb.ArgsAny()+mrb.ArgsBlock())
+		tp.DefineMethod("enable", tpEnable, mrb.ArgsBlock())
+		tp.DefineMethod("disable", tpDisable, mrb.ArgsNone())
+		tp.DefineMethod("enabled?", tpIsEnabled, mrb.ArgsNone())
+		tp.DefineMethod("event", tpEvent, mrb.ArgsNone())
+		tp.DefineMethod("method_id", tpMethodID, mrb.ArgsNone())
+		tp.DefineMethod("defined_class", tpDefinedClass, mrb.ArgsNone())
+		tp.DefineMethod("self", tpSelf, mrb.ArgsNone())
+		tp.DefineMethod("path", tpPath, mrb.ArgsNone())
+		tp.DefineMethod("lineno", tpLineno, mrb.ArgsNone())
+		tp.DefineMethod("return_value", tpReturnValue, mrb.ArgsNone())
+		tp.DefineMethod("raised_exception", tpRaisedException, mrb.ArgsNone())
+		tp.DefineMethod("inspect", tpInspect, mrb.ArgsNone())
+		return nil
+	})
+}
+
+// tracePoint is TracePoint object data
+type tracePoint struct {
+	events oruby.TraceEventType
+	hook   *oruby.TraceHook
+	event  *oruby.TraceEvent // event being traced, nil outside of block
+}
+
+func tpInit(mrb *oruby.MrbState, self oruby.Value) oruby.MrbValue {
+	args, block := mrb.GetArgsWithBlock()
+	if block.IsNil() {
+		return mrb.Raisef(mrb.EArgumentError(), "must be called with a block")
+	}
+
+	var events oruby.TraceEventType
+	for _, arg := range args.Slice() {
+		event, err := oruby.ParseTraceEvent(mrb.String(arg))
+		if err != nil {
+			return mrb.RaiseError(err)
+		}
+		events |= event
+	}
+	if events == 0 {
+		events = oruby.TraceAll
+	}
+
+	mrb.IVSet(self, mrb.Intern("__block"), block)
+	mrb.DataSetInterface(self, &tracePoint{events: events})
+	return self
+}
+
+func tpTrace(mrb *oruby.MrbState, self oruby.Value) oruby.MrbValue {
+	args, block := mrb.GetArgsWithBlock()
+	tp, err := mrb.FuncallWithBlock(self, mrb.Intern("new"), append(args.SliceIntf(), block)...)
+	if err != nil {
+		return tp
+	}
+	return mrb.Call(tp, "enable")
+}
+
+// getTracePoint returns TracePoint data, or raised TypeError for uninitialized TracePoint
+func getTracePoint(mrb *oruby.MrbState, self oruby.Value) (*tracePoint, oruby.MrbValue) {
+	tp, _ := mrb.DataCheckGetInterface(self).(*tracePoint)
+	if tp == nil {
+		return nil, mrb.Raisef(mrb.ETypeError(), "uninitialized TracePoint")
+	}
+	return tp, nil
+}
+
+// enable adds trace hook calling TracePoint block. TracePoint is protected from GC while enabled
+func (tp *tracePoint) enable(mrb *oruby.MrbState, self oruby.Value) {
+	block := mrb.IVGet(self, mrb.Intern("__block"))
+	tp.hook = mrb.AddTraceHook(tp.events, func(e oruby.TraceEvent) {
+		tp.event = &e
+		mrb.YieldArgv(block, self)
+		tp.event = nil
+	})
+	mrb.GCRegister(self)
+}
+
+func (tp *tracePoint) disable(mrb *oruby.MrbState, self oruby.Value) {
+	tp.hook.Remove()
+	tp.hook = nil
+	mrb.GCUnregister(self)
+}
+
+func tpEnable(mrb *oruby.MrbState, self oruby.Value) oruby.MrbValue {
+	tp, exc := getTracePoint(mrb, self)
+	if exc != nil {
+		return exc
+	}
+	block := mrb.GetArgsBlock()
+	enabled := tp.hook != nil
+	if !enabled {
+		tp.enable(mrb, self)
+	}
+	if block.IsNil() {
+		return mrb.BoolValue(enabled)
+	}
+
+	// enable tracing only while block runs
+	ret := mrb.YieldArgv(block)
+	if !enabled && tp.hook != nil {
+		tp.disable(mrb, self)
+	}
+	return ret
+}
+
+func tpDisable(mrb *oruby.MrbState, self oruby.Value) oruby.MrbValue {
+	tp, exc := getTracePoint(mrb, self)
+	if exc != nil {
+		return exc
+	}
+	enabled := tp.hook != nil
+	if enabled {
+		tp.disable(mrb, self)
+	}
+	return mrb.BoolValue(enabled)
+}
+
+func tpIsEnabled(mrb *oruby.MrbState, self oruby.Value) oruby.MrbValue {
+	tp, exc := getTracePoint(mrb, self)
+	if exc != nil {
+		return exc
+	}
+	return mrb.BoolValue(tp.hook != nil)
+}
+
+// traceEvent returns event being traced, or raised RuntimeError outside of TracePoint block
+func traceEvent(mrb *oruby.MrbState, self oruby.Value) (*oruby.TraceEvent, oruby.MrbValue) {
+	tp, exc := getTracePoint(mrb, self)
+	if exc != nil {
+		return nil, exc
+	}
+	if tp.event == nil {
+		return nil, mrb.Raisef(mrb.ERuntimeError(), "access from outside")
+	}
+	return tp.event, nil
+}
+
+func tpEvent(mrb *oruby.MrbState, self oruby.Value) oruby.MrbValue {
+	e, exc := traceEvent(mrb, self)
+	if exc != nil {
+		return exc
+	}
+	return mrb.SymbolValue(mrb.Intern(e.Event.String()))
+}
+
+func tpMethodID(mrb *oruby.MrbState, self oruby.Value) oruby.MrbValue {
+	e, exc := traceEvent(mrb, self)
+	if exc != nil {
+		return exc
+	}
+	if e.Method == "" {
+		return mrb.NilValue()
+	}
+	return mrb.SymbolValue(mrb.Intern(e.Method))
+}
+
+func tpDefinedClass(mrb *oruby.MrbState, self oruby.Value) oruby.MrbValue {
+	e, exc := traceEvent(mrb, self)
+	if exc != nil {
+		return exc
+	}
+	if e.Class.IsNil() {
+		return mrb.NilValue()
+	}
+	return e.Class.Value()
+}
+
+func tpSelf(mrb *oruby.MrbState, self oruby.Value) oruby.MrbValue {
+	e, exc := traceEvent(mrb, self)
+	if exc != nil {
+		return exc
+	}
+	return e.Self
+}
+
+func tpPath(mrb *oruby.MrbState, self oruby.Value) oruby.MrbValue {
+	e, exc := traceEvent(mrb, self)
+	if exc != nil {
+		return exc
+	}
+	return mrb.StrNew(e.Filename)
+}
+
+func tpLineno(mrb *oruby.MrbState, self oruby.Value) oruby.MrbValue {
+	e, exc := traceEvent(mrb, self)
+	if exc != nil {
+		return exc
+	}
+	return mrb.Value(e.Line)
+}
+
+func tpReturnValue(mrb *oruby.MrbState, self oruby.Value) oruby.MrbValue {
+	e, exc := traceEvent(mrb, self)
+	if exc != nil {
+		return exc
+	}
+	if e.Event != oruby.TraceReturn {
+		return mrb.Raisef(mrb.ERuntimeError(), "not supported by this event")
+	}
+	return e.ReturnValue
+}
+
+func tpRaisedException(mrb *oruby.MrbState, self oruby.Value) oruby.MrbValue {
+	e, exc := traceEvent(mrb, self)
+	if exc != nil {
+		return exc
+	}
+	if e.Event != oruby.TraceRaise {
+		return mrb.Raisef(mrb.ERuntimeError(), "not supported by this event")
+	}
+	return e.Exception
+}
+
+func tpInspect(mrb *oruby.MrbState, self oruby.Value) oruby.MrbValue {
+	tp, exc := getTracePoint(mrb, self)
+	if exc != nil {
+		return exc
+	}
+	switch {
+	case tp.event != nil:
+		return mrb.StrNew(fmt.Sprintf("#<TracePoint:%v %v:%d>", tp.event.Event, tp.event.Filename, tp.event.Line))
+	case tp.hook != nil:
+		return mrb.StrNew("#<TracePoint:enabled>")
+	}
+	return mrb.StrNew("#<TracePoint:disabled>")
+}
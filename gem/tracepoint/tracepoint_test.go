@@ -0,0 +1,59 @@
+package tracepoint
+
+import (
+	"testing"
+
+	"github.com/oruby/oruby"
+	"github.com/oruby/oruby/gem/assert"
+)
+
+func TestTracePoint(t *testing.T) {
+	mrb := oruby.MrbOpen()
+	defer mrb.Close()
+
+	assert.AssertCode(t, mrb, `
+		def traced_add(a, b)
+		  a + b
+		end
+
+		assert('TracePoint call and return') do
+		  events = []
+		  tp = TracePoint.new(:call, :return) do |tp|
+		    if tp.method_id == :traced_add
+		      events << [tp.event, tp.method_id]
+		      events << tp.return_value if tp.event == :return
+		    end
+		  end
+		  tp.enable { traced_add(1, 2) }
+
+		  assert_equal [[:call, :traced_add], [:return, :traced_add], 3], events
+		  assert_false tp.enabled?
+		end
+
+		assert('TracePoint line') do
+		  lines = []
+		  tp = TracePoint.trace(:line) { |tp| lines << tp.lineno }
+		  a = 1
+		  b = 2
+		  tp.disable
+
+		  assert_true lines.size >= 2
+		  assert_false tp.enabled?
+		end
+
+		assert('TracePoint class') do
+		  classes = []
+		  TracePoint.new(:class) { |tp| classes << tp.self }.enable do
+		    class TracedClass; end
+		  end
+		  assert_equal [TracedClass], classes
+		end
+
+		assert('TracePoint outside of block') do
+		  tp = TracePoint.new(:line) {}
+		  assert_raise(RuntimeError) { tp.lineno }
+		  assert_raise(ArgumentError) { TracePoint.new(:unknown) {} }
+		  assert_raise(ArgumentError) { TracePoint.new(:line) }
+		end
+	`)
+}
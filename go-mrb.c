@@ -1,37 +1,89 @@
 #include <stdio.h>
+#include <string.h>
 #include "go-mrb.h"
+#include "mruby/opcode.h"
 
 // Go-C-Go proxy callback functions
 // ret values are allocated on C side
 
-mrb_value set_mrb_env_callback(mrb_state *mrb, mrb_value self) {
-  mrb_value idx = mrb_proc_cfunc_env_get(mrb, 0);
-  mrb_value ret = go_mrb_func_env_callback(_mrb_get_idx(mrb), self, (int)mrb_fixnum(idx));
+// raise_result raises exception, or continues non-local jump (break from block),
+// which was caught on Go side and returned from Go callback
+static void raise_result(mrb_state *mrb, mrb_value ret) {
+  if (mrb->exc == NULL) return;
 
   if ((mrb_type(ret) == MRB_TT_EXCEPTION) && (mrb_obj_ptr(ret) == mrb->exc)) {
     mrb_exc_raise(mrb, ret);
   }
+  if ((mrb_type(ret) == MRB_TT_BREAK) && ((struct RObject*)mrb_ptr(ret) == mrb->exc) && mrb->jmp) {
+    MRB_THROW(mrb->jmp);
+  }
+}
+
+// Go function handles
+// Go functions are registered in Go state and referenced from proc env with handle object.
+// Registry entry is released when handle is garbage collected together with its proc
+
+static void free_gohandle(mrb_state *mrb, void *p) {
+  mrb_free_gohandle(mrb, (int)((intptr_t)p - 1));
+}
+
+const struct mrb_data_type gohandle_data_type = {"GoHandle", free_gohandle};
+
+// _mrb_handle_new allocates handle of Go function registry entry. Handle is internal object
+// without class, so it is not visible to oruby code through ObjectSpace
+mrb_value _mrb_handle_new(mrb_state *mrb, int idx) {
+  struct RData *d = mrb_data_object_alloc(mrb, NULL, (void*)((intptr_t)idx + 1), &gohandle_data_type);
+  return mrb_obj_value(d);
+}
+
+int _mrb_handle_index(mrb_value v) {
+  if ((mrb_type(v) != MRB_TT_CDATA) || (DATA_TYPE(v) != &gohandle_data_type)) return -1;
+  return (int)((intptr_t)DATA_PTR(v) - 1);
+}
+
+// _mrb_proc_func_handle returns registry index of Go function called by proc, or -1.
+// Go functions have handle as first env value, MrbFuncT procs have handle after user env values
+int _mrb_proc_func_handle(const struct RProc *p) {
+  struct REnv *e;
+  mrb_int len;
+
+  if (!p || !MRB_PROC_CFUNC_P(p) || !MRB_PROC_ENV_P(p)) return -1;
+  e = MRB_PROC_ENV(p);
+  len = MRB_ENV_LEN(e);
+  if (len < 1) return -1;
+
+  if (MRB_PROC_CFUNC(p) == set_mrb_proc_callback) {
+    return _mrb_handle_index(e->stack[len - 1]);
+  }
+  if ((MRB_PROC_CFUNC(p) == set_gofunc_callback) || (MRB_PROC_CFUNC(p) == set_mrb_env_callback)) {
+    return _mrb_handle_index(e->stack[0]);
+  }
+  return -1;
+}
+
+mrb_value set_mrb_env_callback(mrb_state *mrb, mrb_value self) {
+  int idx = _mrb_proc_func_handle(mrb->c->ci->proc);
+  mrb_value ret = go_mrb_func_env_callback(_mrb_get_idx(mrb), self, idx);
+
+  raise_result(mrb, ret);
 
   return ret;
 }
 
 mrb_value set_mrb_proc_callback(mrb_state *mrb, mrb_value self) {
-  mrb_value ret = go_mrb_proc_callback(_mrb_get_idx(mrb), self);
+  int idx = _mrb_proc_func_handle(mrb->c->ci->proc);
+  mrb_value ret = go_mrb_proc_callback(_mrb_get_idx(mrb), self, idx);
 
-  if ((mrb_type(ret) == MRB_TT_EXCEPTION) && (mrb_obj_ptr(ret) == mrb->exc)) {
-    mrb_exc_raise(mrb, ret);
-  }
+  raise_result(mrb, ret);
 
   return ret;
 }
 
 mrb_value set_gofunc_callback(mrb_state *mrb, mrb_value self) {
-  mrb_value idx = mrb_proc_cfunc_env_get(mrb, 0);
-  mrb_value ret = go_gofunc_callback(_mrb_get_idx(mrb), self, (int)mrb_fixnum(idx));
+  int idx = _mrb_proc_func_handle(mrb->c->ci->proc);
+  mrb_value ret = go_gofunc_callback(_mrb_get_idx(mrb), self, idx);
 
-  if ((mrb_type(ret) == MRB_TT_EXCEPTION) && (mrb_obj_ptr(ret) == mrb->exc)) {
-    mrb_exc_raise(mrb, ret);
-  }
+  raise_result(mrb, ret);
 
   return ret;
 }
@@ -47,7 +99,7 @@ int set_each_object_callback(struct mrb_state *mrb, struct RBasic *obj, void *da
 void _mrb_proc_new_cfunc(mrb_state *mrb, struct RClass *c, mrb_sym id, int idx, mrb_aspec aspec) {
   mrb_method_t m;
   int ai = mrb_gc_arena_save(mrb);    
-  mrb_value at = mrb_fixnum_value(idx);
+  mrb_value at = _mrb_handle_new(mrb, idx);
   struct RProc *proc = mrb_proc_new_cfunc_with_env(mrb, set_gofunc_callback, 1, &at);
 
   MRB_METHOD_FROM_PROC(m, proc);
@@ -63,7 +115,7 @@ void _mrb_proc_new_cfunc(mrb_state *mrb, struct RClass *c, mrb_sym id, int idx,
 void _mrb_method_new_cfunc(mrb_state *mrb, struct RClass *c, mrb_sym id, int idx, mrb_aspec aspec) {
   mrb_method_t m;
   int ai = mrb_gc_arena_save(mrb);    
-  mrb_value at = mrb_fixnum_value(idx);
+  mrb_value at = _mrb_handle_new(mrb, idx);
   struct RProc *proc = mrb_proc_new_cfunc_with_env(mrb, set_mrb_env_callback, 1, &at);
 
   MRB_METHOD_FROM_PROC(m, proc);
@@ -83,7 +135,383 @@ void _define_class_method(mrb_state *mrb, struct RClass *c, mrb_sym id, int idx,
   _mrb_method_new_cfunc(mrb, sclass, id, idx, aspec);
 }
 
+// _mrb_proc_new_mrbfunc creates proc calling registered MrbFuncT, with env values followed by handle
+struct RProc *_mrb_proc_new_mrbfunc(mrb_state *mrb, int idx, mrb_int argc, const mrb_value *argv) {
+  struct RProc *proc;
+  mrb_value *env = (mrb_value*)mrb_malloc(mrb, sizeof(mrb_value) * (argc + 1));
+  mrb_int i;
+
+  for (i = 0; i < argc; i++) {
+    env[i] = argv ? argv[i] : mrb_nil_value();
+  }
+  env[argc] = _mrb_handle_new(mrb, idx);
+
+  proc = mrb_proc_new_cfunc_with_env(mrb, set_mrb_proc_callback, argc + 1, env);
+  mrb_free(mrb, env);
+  return proc;
+}
+
 void _define_singleton_method(mrb_state *mrb, struct RClass *c, mrb_sym id, int idx, mrb_aspec aspec) {
   struct RClass* sclass = mrb_singleton_class_ptr(mrb, mrb_obj_value(c));
   _mrb_method_new_cfunc(mrb, sclass, id, idx, aspec);
 }
+
+// VM debug hook
+// Single code fetch hook traces VM events, counts coverage and samples profiler ticks.
+// It is installed only while one of them is active, so VM runs without hook otherwise.
+// Trace events are filtered on C side, so Go callback is called only for traced events
+
+static gomrb_hook *hook_state(mrb_state *mrb) {
+  gomrb_ud *ud = (gomrb_ud *)mrb->ud;
+
+  if (!ud) return NULL;
+  if (!ud->hook) {
+    ud->hook = (gomrb_hook *)calloc(1, sizeof(gomrb_hook));
+  }
+  return ud->hook;
+}
+
+// hook_operand reads byte operand, or 16 bit operand extended by OP_EXT1-3 prefix
+static uint32_t hook_operand(const mrb_code **p, mrb_bool wide) {
+  uint32_t v = (*p)[0];
+
+  if (wide) {
+    v = v << 8 | (*p)[1];
+    (*p)++;
+  }
+  (*p)++;
+  return v;
+}
+
+static void trace_run(mrb_state *mrb, gomrb_hook *h, gomrb_trace *t) {
+  struct RObject *exc = mrb->exc;
+
+  h->running = TRUE;
+  go_trace_callback(_mrb_get_idx(mrb), t);
+  h->running = FALSE;
+
+  // exceptions raised by trace callbacks are ignored
+  mrb->exc = exc;
+}
+
+// trace_line traces line event when line of call frame changes
+static void trace_line(mrb_state *mrb, gomrb_hook *h, gomrb_trace *t) {
+  mrb_int depth = mrb->c->ci - mrb->c->cibase;
+  gomrb_line *last;
+
+  if (depth >= h->nlines) {
+    mrb_int n = depth + 16;
+    gomrb_line *lines = (gomrb_line *)realloc(h->lines, sizeof(gomrb_line) * n);
+    if (!lines) return;
+    memset(lines + h->nlines, 0, sizeof(gomrb_line) * (n - h->nlines));
+    h->lines = lines;
+    h->nlines = n;
+  }
+
+  // entered new call frames start without traced line
+  if (depth > h->depth) {
+    memset(h->lines + h->depth + 1, 0, sizeof(gomrb_line) * (depth - h->depth));
+  }
+  h->depth = depth;
+
+  last = &h->lines[depth];
+  if (t->line < 0 || (last->irep == t->irep && last->line == t->line)) return;
+  last->irep = t->irep;
+  last->line = t->line;
+
+  t->event = GOMRB_TRACE_LINE;
+  trace_run(mrb, h, t);
+}
+
+static void vm_trace(mrb_state *mrb, gomrb_hook *h, const mrb_irep *irep, const mrb_code *pc, mrb_value *regs) {
+  gomrb_trace t;
+  const mrb_code *p = pc;
+  uint32_t a, b;
+  int ext = 0;
+  mrb_code op;
+
+  memset(&t, 0, sizeof(t));
+  t.irep = irep;
+  t.pc = (uint32_t)(pc - irep->iseq);
+  t.line = mrb_debug_get_line(mrb, irep, t.pc);
+  t.self = regs[0];
+  t.value = mrb_nil_value();
+  t.depth = mrb->c->ci - mrb->c->cibase;
+
+  // new exception is pending when VM continues in rescue or ensure code
+  if (mrb->exc != h->exc) {
+    h->exc = mrb->exc;
+    if (mrb->exc && (h->events & GOMRB_TRACE_RAISE)) {
+      t.event = GOMRB_TRACE_RAISE;
+      t.value = mrb_obj_value(mrb->exc);
+      trace_run(mrb, h, &t);
+      t.value = mrb_nil_value();
+    }
+  }
+
+  if (h->events & GOMRB_TRACE_LINE) {
+    trace_line(mrb, h, &t);
+  }
+
+  op = *p++;
+  if (op == OP_EXT1 || op == OP_EXT2 || op == OP_EXT3) {
+    ext = op - OP_EXT1 + 1;
+    op = *p++;
+  }
+
+  switch (op) {
+  case OP_SEND: case OP_SSEND: case OP_SENDB: case OP_SSENDB: {
+    mrb_method_t m;
+    struct RClass *c;
+
+    if (!(h->events & (GOMRB_TRACE_CALL | GOMRB_TRACE_C_CALL))) break;
+    a = hook_operand(&p, ext & 1);
+    b = hook_operand(&p, ext & 2);
+    if (b >= irep->slen) break;
+
+    t.mid = irep->syms[b];
+    t.self = (op == OP_SSEND || op == OP_SSENDB) ? regs[0] : regs[a];
+    c = mrb_class(mrb, t.self);
+    m = mrb_method_search_vm(mrb, &c, t.mid);
+    t.klass = c;
+    t.event = (!MRB_METHOD_UNDEF_P(m) && MRB_METHOD_CFUNC_P(m)) ? GOMRB_TRACE_C_CALL : GOMRB_TRACE_CALL;
+    if (h->events & t.event) {
+      trace_run(mrb, h, &t);
+    }
+    break;
+  }
+  case OP_RETURN: case OP_RETURN_BLK:
+    if (!(h->events & GOMRB_TRACE_RETURN)) break;
+    a = hook_operand(&p, ext & 1);
+    t.mid = mrb->c->ci->mid;
+    t.klass = mrb_vm_ci_target_class(mrb->c->ci);
+    t.value = regs[a];
+    t.event = GOMRB_TRACE_RETURN;
+    trace_run(mrb, h, &t);
+    break;
+  case OP_EXEC:
+    if (!(h->events & GOMRB_TRACE_CLASS)) break;
+    a = hook_operand(&p, ext & 1);
+    if (!mrb_class_p(regs[a]) && !mrb_module_p(regs[a]) && !mrb_sclass_p(regs[a])) break;
+    t.klass = mrb_class_ptr(regs[a]);
+    t.self = regs[a];
+    t.value = regs[a];
+    t.event = GOMRB_TRACE_CLASS;
+    trace_run(mrb, h, &t);
+    break;
+  default:
+    break;
+  }
+}
+
+static void vm_hook(mrb_state *mrb, const mrb_irep *irep, const mrb_code *pc, mrb_value *regs) {
+  gomrb_ud *ud = (gomrb_ud *)mrb->ud;
+  gomrb_hook *h = ud ? ud->hook : NULL;
+
+  if (!h) return;
+  if (h->events && !h->running) {
+    vm_trace(mrb, h, irep, pc, regs);
+  }
+  if (h->coverage) {
+    // counts of irep are looked up when VM continues in other irep
+    if (irep != h->cov_irep) {
+      h->cov_irep = irep;
+      h->cov_ilen = 0;
+      h->cov_counts = go_coverage_callback(_mrb_get_idx(mrb), (mrb_irep *)irep, &h->cov_ilen);
+    }
+    if (h->cov_counts && pc >= irep->iseq && pc - irep->iseq < h->cov_ilen) {
+      h->cov_counts[pc - irep->iseq]++;
+    }
+  }
+  if (h->ticks) {
+    uint32_t ticks = __atomic_exchange_n(&h->ticks, 0, __ATOMIC_SEQ_CST);
+    go_profile_callback(_mrb_get_idx(mrb), ticks, (mrb_irep *)irep, (uint32_t)(pc - irep->iseq));
+  }
+}
+
+// update_vm_hook installs VM hook while tracing, profiling or coverage is active,
+// unless other code fetch hook is already set, and removes it when last of them stops
+static void update_vm_hook(mrb_state *mrb, gomrb_hook *h) {
+  if (h->events || h->profile || h->coverage) {
+    if (!mrb->code_fetch_hook) {
+      mrb->code_fetch_hook = vm_hook;
+    }
+  } else if (mrb->code_fetch_hook == vm_hook) {
+    mrb->code_fetch_hook = NULL;
+  }
+}
+
+// _gomrb_thread identifies current OS thread
+uintptr_t _gomrb_thread(void) {
+  static __thread char thread;
+  return (uintptr_t)&thread;
+}
+
+// _mrb_set_trace sets traced events. Tracing is disabled with zero events
+void _mrb_set_trace(mrb_state *mrb, uint32_t events) {
+  gomrb_hook *h = hook_state(mrb);
+
+  if (!h) return;
+  if (events && !h->events) {
+    h->exc = mrb->exc;
+    h->depth = -1;
+  }
+  h->events = events;
+  update_vm_hook(mrb, h);
+}
+
+// _mrb_set_profile starts or stops counting of profiler ticks
+void _mrb_set_profile(mrb_state *mrb, mrb_bool profile) {
+  gomrb_hook *h = hook_state(mrb);
+
+  if (!h) return;
+  h->profile = profile;
+  __atomic_store_n(&h->ticks, 0, __ATOMIC_SEQ_CST);
+  update_vm_hook(mrb, h);
+}
+
+// _mrb_profile_tick is called by profiler timer. Ticks are counted only while VM
+// runs code, and are sampled by VM hook on next instruction
+void _mrb_profile_tick(mrb_state *mrb) {
+  gomrb_ud *ud = (gomrb_ud *)mrb->ud;
+  gomrb_hook *h = ud ? ud->hook : NULL;
+
+  if (h && h->profile && mrb->jmp) {
+    __atomic_add_fetch(&h->ticks, 1, __ATOMIC_SEQ_CST);
+  }
+}
+
+// _mrb_profile_ticks takes ticks counted while Go function was running
+uint32_t _mrb_profile_ticks(mrb_state *mrb) {
+  gomrb_ud *ud = (gomrb_ud *)mrb->ud;
+  gomrb_hook *h = ud ? ud->hook : NULL;
+
+  if (!h || !h->ticks) return 0;
+  return __atomic_exchange_n(&h->ticks, 0, __ATOMIC_SEQ_CST);
+}
+
+// _mrb_set_coverage starts or stops counting executed instructions
+void _mrb_set_coverage(mrb_state *mrb, mrb_bool coverage) {
+  gomrb_hook *h = hook_state(mrb);
+
+  if (!h) return;
+  h->coverage = coverage;
+  h->cov_irep = NULL;
+  h->cov_counts = NULL;
+  h->cov_ilen = 0;
+  update_vm_hook(mrb, h);
+}
+
+// _mrb_callstack fills frames with call stack, starting with current frame.
+// irep and pc are current instruction, or NULL when C function is running
+int _mrb_callstack(mrb_state *mrb, const mrb_irep *irep, uint32_t pc, gomrb_frame *frames, int max) {
+  mrb_callinfo *ci;
+  int n = 0;
+
+  for (ci = mrb->c->ci; ci >= mrb->c->cibase && n < max; ci--) {
+    const struct RProc *p = ci->proc;
+    gomrb_frame *f = &frames[n++];
+
+    memset(f, 0, sizeof(*f));
+    f->mid = ci->mid;
+    f->klass = mrb_vm_ci_target_class(ci);
+    if (!p || MRB_PROC_CFUNC_P(p)) continue;
+
+    f->irep = p->body.irep;
+    f->block = !MRB_PROC_SCOPE_P(p);
+    if (ci == mrb->c->ci && irep == f->irep) {
+      f->pc = pc;
+    } else if (ci->pc && ci->pc > f->irep->iseq) {
+      // saved pc of calling frame follows call instruction
+      f->pc = (uint32_t)(ci->pc - f->irep->iseq - 1);
+    }
+  }
+  return n;
+}
+
+// frame_locals walks local variables of call frame at level, innermost scope first.
+// Without set name, locals are filled with variables not shadowed by inner scope.
+// With set name, first variable with that name is assigned value and 1 is returned
+static int frame_locals(mrb_state *mrb, int level, gomrb_local *locals, int max, mrb_sym set, mrb_value value) {
+  mrb_callinfo *ci = mrb->c->ci - level;
+  const struct RProc *p;
+  struct REnv *e = NULL;
+  mrb_value *stack;
+  mrb_int len;
+  int n = 0;
+
+  if (level < 0 || ci < mrb->c->cibase) return 0;
+  p = ci->proc;
+  stack = ci->stack;
+  if (!p || MRB_PROC_CFUNC_P(p) || !stack) return 0;
+  len = p->body.irep->nlocals;
+
+  for (;;) {
+    const mrb_irep *irep = p->body.irep;
+    int i, j;
+
+    for (i = 0; irep->lv && i + 1 < irep->nlocals && i + 1 < len; i++) {
+      mrb_sym name = irep->lv[i];
+
+      if (!name) continue;
+      if (set) {
+        if (name != set) continue;
+        stack[i + 1] = value;
+        if (e) mrb_write_barrier(mrb, (struct RBasic*)e);
+        return 1;
+      }
+      if (n >= max) return n;
+      for (j = 0; j < n && locals[j].name != name; j++)
+        ;
+      if (j < n) continue;
+      locals[n].name = name;
+      locals[n].value = stack[i + 1];
+      n++;
+    }
+    if (MRB_PROC_SCOPE_P(p)) break;
+
+    // variables of enclosing scope are kept in env of block
+    e = MRB_PROC_ENV(p);
+    p = p->upper;
+    if (!e || !p || MRB_PROC_CFUNC_P(p)) break;
+    stack = e->stack;
+    len = MRB_ENV_LEN(e);
+  }
+  return n;
+}
+
+// _mrb_frame_locals fills locals with local variables of call frame at level, where
+// current frame is level 0. Blocks include variables of enclosing scopes, not shadowed
+// by block variables
+int _mrb_frame_locals(mrb_state *mrb, int level, gomrb_local *locals, int max) {
+  return frame_locals(mrb, level, locals, max, 0, mrb_nil_value());
+}
+
+// _mrb_frame_local_set assigns local variable of call frame at level, as found by
+// _mrb_frame_locals. Returns 0 if frame has no such variable
+int _mrb_frame_local_set(mrb_state *mrb, int level, mrb_sym name, mrb_value value) {
+  if (!name) return 0;
+  return frame_locals(mrb, level, NULL, 0, name, value);
+}
+
+// _mrb_frame_self returns self of call frame at level
+mrb_value _mrb_frame_self(mrb_state *mrb, int level) {
+  mrb_callinfo *ci = mrb->c->ci - level;
+
+  if (level < 0 || ci < mrb->c->cibase || !ci->stack) return mrb_nil_value();
+  return ci->stack[0];
+}
+
+// _mrb_hook_free removes VM hook before state is closed
+void _mrb_hook_free(mrb_state *mrb) {
+  gomrb_ud *ud = (gomrb_ud *)mrb->ud;
+  gomrb_hook *h = ud ? ud->hook : NULL;
+
+  if (mrb->code_fetch_hook == vm_hook) {
+    mrb->code_fetch_hook = NULL;
+  }
+  if (!h) return;
+  ud->hook = NULL;
+  free(h->lines);
+  free(h);
+}
@@ -44,12 +44,20 @@ static mrb_int _MRUBY_RELEASE_MAJOR()  { return (mrb_int)MRUBY_RELEASE_MAJOR; }
 static mrb_int _MRUBY_RELEASE_MINOR()  { return (mrb_int)MRUBY_RELEASE_MINOR; }
 static mrb_int _MRUBY_RELEASE_TEENY()  { return (mrb_int)MRUBY_RELEASE_TEENY; }
 
+// Per state data referenced by mrb->ud. It is stored in RIStruct inline data,
+// so it must not be larger than ISTRUCT_DATA_SIZE
+typedef struct gomrb_ud {
+  mrb_int idx;               // MrbState index, must be first field
+  struct gomrb_hook *hook;   // VM debug hook state, allocated on first use
+} gomrb_ud;
+
 static void _mrb_set_idx(mrb_state *mrb, mrb_int idx) {
   struct RBasic *s = mrb_obj_alloc(mrb, MRB_TT_ISTRUCT, mrb->object_class);
   struct RIStruct *is = (struct RIStruct *)s;
-  mrb_int *i = (mrb_int *)is->inline_data;
+  gomrb_ud *ud = (gomrb_ud *)is->inline_data;
 
-  *i = idx;
+  ud->idx = idx;
+  ud->hook = NULL;
 
   MRB_SET_FROZEN_FLAG(s);
   mrb_sym sym = mrb_intern_lit(mrb, "$MRB");
@@ -57,7 +65,7 @@ static void _mrb_set_idx(mrb_state *mrb, mrb_int idx) {
 
   // Set pointer to MrbState index
   if (idx != 0) {
-    mrb->ud = i;
+    mrb->ud = ud;
   }
 }
 
@@ -70,23 +78,80 @@ static mrb_int _cmrb_get_idx(uintptr_t cmrb) {
   return mrb->ud ? *(mrb_int*)mrb->ud : 0;
 }
 
-/* Inject and run code */
-
-extern struct RProc* inject_run(mrb_int idx);
-
-static void injector(struct mrb_state* mrb, const struct mrb_irep *irep, const mrb_code *pc, mrb_value *regs) {
-  struct RProc *p = inject_run(_mrb_get_idx(mrb));
-  if (p) {
-    mrb_funcall_with_block(mrb, mrb_obj_value(p), MRB_SYM(call), 0, NULL, mrb_nil_value());
-  }
-//	mrb_raise(mrb, E_TYPE_ERROR, "break from vm_exec");
-}
-
-static void set_mrb_injector(mrb_state *mrb) {
-	if (!mrb->code_fetch_hook) {
-		mrb->code_fetch_hook = injector;
-	}
-}
+/* VM debug hook, tracing events */
+
+// traced events, mirrored as oruby.TraceEventType
+#define GOMRB_TRACE_LINE    (1 << 0)
+#define GOMRB_TRACE_CALL    (1 << 1)
+#define GOMRB_TRACE_RETURN  (1 << 2)
+#define GOMRB_TRACE_C_CALL  (1 << 3)
+#define GOMRB_TRACE_RAISE   (1 << 4)
+#define GOMRB_TRACE_CLASS   (1 << 5)
+
+// last traced line of call frame
+typedef struct gomrb_line {
+  const mrb_irep *irep;
+  int32_t line;
+} gomrb_line;
+
+typedef struct gomrb_hook {
+  mrb_bool running;          // trace callback is running, events are not traced
+  mrb_bool profile;          // profiler is running
+  uint32_t ticks;            // profiler ticks not yet sampled
+  mrb_bool coverage;         // coverage is collected
+  const mrb_irep *cov_irep;  // irep counted in cov_counts
+  uint32_t *cov_counts;      // instruction execution counts of irep
+  uint32_t cov_ilen;         // length of cov_counts
+  uint32_t events;           // traced events
+  struct RObject *exc;       // exception seen on last instruction
+  mrb_int depth;             // callinfo depth of last instruction
+  mrb_int nlines;            // size of lines
+  gomrb_line *lines;         // last traced line per callinfo depth
+} gomrb_hook;
+
+// traced event, passed to Go callback
+typedef struct gomrb_trace {
+  uint32_t event;
+  const mrb_irep *irep;
+  uint32_t pc;               // offset of instruction in irep iseq
+  int32_t line;
+  mrb_sym mid;               // called or returning method
+  struct RClass *klass;      // class defining method, or entered class
+  mrb_value self;
+  mrb_value value;           // return value, raised exception or entered class
+  mrb_int depth;             // callinfo depth of traced code
+} gomrb_trace;
+
+// call stack frame, sampled by profiler
+typedef struct gomrb_frame {
+  mrb_sym mid;
+  struct RClass *klass;
+  const mrb_irep *irep;      // NULL for C functions
+  uint32_t pc;               // offset of current instruction in irep iseq
+  mrb_bool block;
+} gomrb_frame;
+
+// local variable of call frame, read by debugger
+typedef struct gomrb_local {
+  mrb_sym name;
+  mrb_value value;
+} gomrb_local;
+
+extern void go_trace_callback(mrb_int mrbidx, gomrb_trace *t);
+extern void go_profile_callback(mrb_int mrbidx, uint32_t ticks, mrb_irep *irep, uint32_t pc);
+extern uint32_t *go_coverage_callback(mrb_int mrbidx, mrb_irep *irep, uint32_t *ilen);
+
+uintptr_t _gomrb_thread(void);
+void _mrb_set_trace(mrb_state *mrb, uint32_t events);
+void _mrb_set_profile(mrb_state *mrb, mrb_bool profile);
+void _mrb_profile_tick(mrb_state *mrb);
+uint32_t _mrb_profile_ticks(mrb_state *mrb);
+void _mrb_set_coverage(mrb_state *mrb, mrb_bool coverage);
+int _mrb_callstack(mrb_state *mrb, const mrb_irep *irep, uint32_t pc, gomrb_frame *frames, int max);
+int _mrb_frame_locals(mrb_state *mrb, int level, gomrb_local *locals, int max);
+int _mrb_frame_local_set(mrb_state *mrb, int level, mrb_sym name, mrb_value value);
+mrb_value _mrb_frame_self(mrb_state *mrb, int level);
+void _mrb_hook_free(mrb_state *mrb);
 
 // RBasic macro proxy calls
 static void _MRB_SET_FROZEN_FLAG(struct RBasic *o)   { MRB_SET_FROZEN_FLAG(o); }
@@ -282,6 +347,14 @@ static void _mrbc_set_no_optimize(mrbc_context *c, mrb_bool v)    { c->no_optimi
 static int  _mrbc_no_ext_ops(mrbc_context *c)                     { return c->no_ext_ops; }
 static void _mrbc_set_no_ext_ops(mrbc_context *c, mrb_bool v)     { c->no_ext_ops = v;  }
 
+// Local variables known to parser
+static void _mrbc_set_syms(mrb_state *mrb, mrbc_context *c, const mrb_sym *syms, int slen) {
+  c->syms = (mrb_sym*)mrb_realloc(mrb, c->syms, sizeof(mrb_sym) * (slen > 0 ? slen : 1));
+  for (int i = 0; i < slen; i++) c->syms[i] = syms[i];
+  c->slen = slen;
+}
+static mrb_sym _mrbc_sym(mrbc_context *c, int i) { return c->syms[i]; }
+
 // GC
 static mrb_bool _gc_iterating(mrb_state *mrb) { return mrb->gc.iterating; }
 static mrb_bool _gc_full(mrb_state *mrb) { return mrb->gc.full; }
@@ -474,12 +547,42 @@ static void _mrb_pool_value_migrate(mrb_pool_value *v) {
   }
 }
 
+static const char *_mrb_pool_str(const mrb_pool_value *v) { return v->u.str; }
+static int _mrb_pool_strlen(const mrb_pool_value *v) { return (int)(v->tt >> 2); }
+static int64_t _mrb_pool_int(const mrb_pool_value *v) {
+#if defined(MRB_64BIT) || defined(MRB_INT64)
+  if (v->tt == IREP_TT_INT64) return (int64_t)v->u.i64;
+#endif
+  return (int64_t)v->u.i32;
+}
+#ifndef MRB_NO_FLOAT
+static double _mrb_pool_float(const mrb_pool_value *v) { return (double)v->u.f; }
+#else
+static double _mrb_pool_float(const mrb_pool_value *v) { return 0; }
+#endif
+
+// opcode names and operand formats, as defined by mruby/ops.h
+static const char *_mrb_op_names[] = {
+#define OPCODE(x,_) #x,
+#include "mruby/ops.h"
+#undef OPCODE
+};
+static const char *_mrb_op_formats[] = {
+#define OPCODE(_,f) #f,
+#include "mruby/ops.h"
+#undef OPCODE
+};
+static int _mrb_op_count() { return (int)(sizeof(_mrb_op_names)/sizeof(_mrb_op_names[0])); }
+static const char *_mrb_op_name(int op) { return _mrb_op_names[op]; }
+static const char *_mrb_op_format(int op) { return _mrb_op_formats[op]; }
+
 // Callbacks
 extern int  go_partial_hook_callback(struct mrb_parser_state *p);
 extern int  go_hash_callback(mrb_state *mrb, mrb_value key, mrb_value val, void *data);
 extern int  go_each_object_callback(mrb_state *mrb, struct RBasic *obj, void *data);
 extern mrb_value go_gofunc_callback(mrb_int mrbidx, mrb_value self, int idx);
-extern mrb_value go_mrb_proc_callback(mrb_int mrbidx, mrb_value self);
+extern mrb_value go_mrb_proc_callback(mrb_int mrbidx, mrb_value self, int idx);
+extern void mrb_free_gohandle(mrb_state *mrb, int idx);
 extern mrb_value go_mrb_func_env_callback(mrb_int mrbidx, mrb_value self, int idx);
 
 mrb_value set_mrb_proc_callback(mrb_state *mrb, mrb_value self);
@@ -492,6 +595,10 @@ int set_each_object_callback(struct mrb_state *mrb, struct RBasic *obj, void *da
 void _mrb_proc_new_cfunc(mrb_state *mrb, struct RClass *c, mrb_sym id, int idx, mrb_aspec aspec);
 void _mrb_method_new_cfunc(mrb_state *mrb, struct RClass *c, mrb_sym id, int idx, mrb_aspec aspec);
 void _define_class_method(mrb_state *mrb, struct RClass *c, mrb_sym id, int idx, mrb_aspec aspec);
+struct RProc *_mrb_proc_new_mrbfunc(mrb_state *mrb, int idx, mrb_int argc, const mrb_value *argv);
+mrb_value _mrb_handle_new(mrb_state *mrb, int idx);
+int _mrb_handle_index(mrb_value v);
+int _mrb_proc_func_handle(const struct RProc *p);
 
 // cmd helpers
 extern void mrb_codedump_all(mrb_state*, struct RProc*);
@@ -11,31 +11,31 @@ func ary(v C.mrb_value, mrb *MrbState) RArray {
 func (a RArray) Ptr() RArrayPtr { return RArrayPtr{(*C.struct_RArray)(C._mrb_ptr(a.v))} }
 
 // Modify modify array
-func (a RArray) Modify() { C.mrb_ary_modify(a.mrb.p, a.Ptr().p) }
+func (a RArray) Modify() { C.mrb_ary_modify(a.mrb.state(), a.Ptr().p) }
 
 // Concat Concatenate two arrays. The target array will be modified
-func (a RArray) Concat(other MrbValue) { C.mrb_ary_concat(a.mrb.p, a.v, other.Value().v) }
+func (a RArray) Concat(other MrbValue) { C.mrb_ary_concat(a.mrb.state(), a.v, other.Value().v) }
 
 // Splat create an array from the input. Tries calling to_a on the value.
 // If value does not respond to that, it creates a new array with just this value.
 func (a RArray) Splat() RArray {
-	return ary(C.mrb_ary_splat(a.mrb.p, a.v), a.mrb)
+	return ary(C.mrb_ary_splat(a.mrb.state(), a.v), a.mrb)
 }
 
 // Push pushes value to array
-func (a RArray) Push(val MrbValue) { C.mrb_ary_push(a.mrb.p, a.v, val.Value().v) }
+func (a RArray) Push(val MrbValue) { C.mrb_ary_push(a.mrb.state(), a.v, val.Value().v) }
 
 // PushString pushes string to oruby array
-func (a RArray) PushString(s string) { C.mrb_ary_push(a.mrb.p, a.v, a.mrb.StrNew(s).v) }
+func (a RArray) PushString(s string) { C.mrb_ary_push(a.mrb.state(), a.v, a.mrb.StrNew(s).v) }
 
 // PushInt pushes int to oruby array
-func (a RArray) PushInt(val int) { C.mrb_ary_push(a.mrb.p, a.v, MrbFixnumValue(val).v) }
+func (a RArray) PushInt(val int) { C.mrb_ary_push(a.mrb.state(), a.v, MrbFixnumValue(val).v) }
 
 // PushFloat64 pushes int to oruby array
-func (a RArray) PushFloat64(f float64) { C.mrb_ary_push(a.mrb.p, a.v, a.mrb.FloatValue(f).v) }
+func (a RArray) PushFloat64(f float64) { C.mrb_ary_push(a.mrb.state(), a.v, a.mrb.FloatValue(f).v) }
 
 // Pop pops the last element from the array
-func (a RArray) Pop() Value { return Value{C.mrb_ary_pop(a.mrb.p, a.v)} }
+func (a RArray) Pop() Value { return Value{C.mrb_ary_pop(a.mrb.state(), a.v)} }
 
 // Ref returns a reference to an element of the array on the given index
 func (a RArray) Ref(n int) Value {
@@ -47,15 +47,15 @@ func (a RArray) Get(n int) Value { return a.Get(n) }
 
 // Set Sets a value on an array at the given index
 func (a RArray) Set(n int, val MrbValue) {
-	C.mrb_ary_set(a.mrb.p, a.v, C.mrb_int(n), val.Value().v)
+	C.mrb_ary_set(a.mrb.state(), a.v, C.mrb_int(n), val.Value().v)
 }
 
 // Replace the array with another array
-func (a RArray) Replace(b MrbValue) { C.mrb_ary_replace(a.mrb.p, a.v, b.Value().v) }
+func (a RArray) Replace(b MrbValue) { C.mrb_ary_replace(a.mrb.state(), a.v, b.Value().v) }
 
 // Unshift an element into the array
 func (a RArray) Unshift(item MrbValue) Value {
-	return Value{C.mrb_ary_unshift(a.mrb.p, a.v, item.Value().v)}
+	return Value{C.mrb_ary_unshift(a.mrb.state(), a.v, item.Value().v)}
 }
 
 // Entry get nth element in the array
@@ -66,29 +66,29 @@ func (a RArray) Entry(offset int) Value {
 // Splice replace subsequence of an array
 func (a RArray) Splice(head, length int, rpl MrbValue) RArray {
 	return RArray{RValue{
-		C.mrb_ary_splice(a.mrb.p, a.v, C.mrb_int(head), C.mrb_int(length), rpl.Value().v),
+		C.mrb_ary_splice(a.mrb.state(), a.v, C.mrb_int(head), C.mrb_int(length), rpl.Value().v),
 		a.mrb,
 	}}
 }
 
 // Shift shifts the first element from the array
 func (a RArray) Shift() Value {
-	return Value{C.mrb_ary_shift(a.mrb.p, a.v)}
+	return Value{C.mrb_ary_shift(a.mrb.state(), a.v)}
 }
 
 // Clear removes all elements from the array
 func (a RArray) Clear() RArray {
-	a.v = C.mrb_ary_clear(a.mrb.p, a.v)
+	a.v = C.mrb_ary_clear(a.mrb.state(), a.v)
 	return a
 }
 
 // Join array items to string using provided separator
 func (a RArray) Join(separator string) string {
-	return a.mrb.String(Value{C.mrb_ary_join(a.mrb.p, a.v, a.mrb.StringValue(separator).v)})
+	return a.mrb.String(Value{C.mrb_ary_join(a.mrb.state(), a.v, a.mrb.StringValue(separator).v)})
 }
 
 // Resize updates the capacity of the array
 func (a RArray) Resize(newLen int) RArray {
-	a.v = C.mrb_ary_resize(a.mrb.p, a.v, C.mrb_int(newLen))
+	a.v = C.mrb_ary_resize(a.mrb.state(), a.v, C.mrb_int(newLen))
 	return a
 }
@@ -41,7 +41,7 @@ func (c RClass) InstanceTT() Type { return Type(C._MRB_INSTANCE_TT(c.p)) }
 func (c RClass) Real() RClass { return RClass{C.mrb_class_real(c.p), c.mrb} }
 
 // ClassPath returns class path
-func (c RClass) ClassPath() Value { return Value{C.mrb_class_path(c.mrb.p, c.p)} }
+func (c RClass) ClassPath() Value { return Value{C.mrb_class_path(c.mrb.state(), c.p)} }
 
 // New creates new object instance
 func (c RClass) New(args ...interface{}) (RValue, error) {
@@ -104,7 +104,7 @@ func (mrb *MrbState) DataValue(obj interface{}) Value {
 // NewGoInstance creates new object instance for existing Go object
 // it skips "initialize" method, but calls "after_init" so IVs could be set
 func (c RClass) NewGoInstance(obj interface{}) (Value, error) {
-	mrbObjectClass := c.mrb.p.object_class
+	mrbObjectClass := c.mrb.state().object_class
 	// For non-plain ruby objects, check if class is registered with Go
 	if c.p != mrbObjectClass {
 		c.mrb.Lock()
@@ -138,7 +138,7 @@ func (mrb *MrbState) Data(obj MrbValue) interface{} {
 
 // Alias creates method alias
 func (c RClass) Alias(a, b MrbSym) {
-	C.mrb_alias_method(c.mrb.p, c.p, C.mrb_sym(a), C.mrb_sym(b))
+	C.mrb_alias_method(c.mrb.state(), c.p, C.mrb_sym(a), C.mrb_sym(b))
 }
 
 // DefineAlias for existing method in class
@@ -148,17 +148,17 @@ func (c RClass) DefineAlias(name1, name2 string) {
 
 // Include a module in another class or module.
 func (c RClass) Include(module RClass) {
-	C.mrb_include_module(c.mrb.p, c.p, module.p)
+	C.mrb_include_module(c.mrb.state(), c.p, module.p)
 }
 
 // Prepend a module in another class or module.
 func (c RClass) Prepend(module RClass) {
-	C.mrb_prepend_module(c.mrb.p, c.p, module.p)
+	C.mrb_prepend_module(c.mrb.state(), c.p, module.p)
 }
 
 // Name returns name of oruby class
 func (c RClass) Name() string {
-	cstr := C.mrb_class_name(c.mrb.p, c.p)
+	cstr := C.mrb_class_name(c.mrb.state(), c.p)
 	return C.GoString(cstr)
 }
 
@@ -129,6 +129,15 @@ func Raisef(err error, format string, args ...interface{}) error {
 	return Raise(err, msg)
 }
 
+// errorWithContext prefixes error message with context, like field or argument name.
+// Error class of RaiseError is kept
+func errorWithContext(err error, context string) error {
+	if e, ok := err.(*RaiseError); ok {
+		return &RaiseError{err: e.err, msg: context + ": " + e.msg, backtrace: e.backtrace}
+	}
+	return fmt.Errorf("%v: %w", context, err)
+}
+
 // Error implements error interface
 func (e *RaiseError) Error() string {
 	return e.msg
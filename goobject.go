@@ -24,12 +24,12 @@ func (o RObject) Class() RClassPtr { return RClassPtr{o.p.c} }
 
 // Dup duplicates object
 func (obj RValue) Dup() RValue {
-	return RValue{C.mrb_obj_dup(obj.mrb.p, obj.v), obj.mrb}
+	return RValue{C.mrb_obj_dup(obj.mrb.state(), obj.v), obj.mrb}
 }
 
 // Freeze freeze value
 func (obj RValue) Freeze() RValue {
-	return RValue{C.mrb_obj_freeze(obj.mrb.p, obj.v), obj.mrb}
+	return RValue{C.mrb_obj_freeze(obj.mrb.state(), obj.v), obj.mrb}
 }
 
 // ID returns ruby object id
@@ -37,12 +37,12 @@ func (obj RValue) ID() int { return int(C.mrb_obj_id(obj.v)) }
 
 // Classname returns class name of object
 func (obj RValue) Classname() string {
-	return C.GoString(C.mrb_obj_classname(obj.mrb.p, obj.v))
+	return C.GoString(C.mrb_obj_classname(obj.mrb.state(), obj.v))
 }
 
 // Class returns class of object
 func (obj RValue) Class() RClass {
-	return RClass{C.mrb_obj_class(obj.mrb.p, obj.v), obj.mrb}
+	return RClass{C.mrb_obj_class(obj.mrb.state(), obj.v), obj.mrb}
 }
 
 // IsKindOf checks if object is descendant of c class
@@ -50,7 +50,7 @@ func (obj RValue) IsKindOf(c RClass) bool {
 	cl := obj.mrb.ClassOf(obj.Value())
 	switch cl.Type() {
 	case MrbTTModule, MrbTTClass, MrbTTIClass, MrbTTSClass:
-		return C.mrb_obj_is_kind_of(obj.mrb.p, obj.v, c.p) != false
+		return C.mrb_obj_is_kind_of(obj.mrb.state(), obj.v, c.p) != false
 	default:
 		return false
 	}
@@ -59,24 +59,24 @@ func (obj RValue) IsKindOf(c RClass) bool {
 // Inspect object
 func (obj RValue) Inspect() RString {
 	return RString{RValue{
-		C.mrb_obj_inspect(obj.mrb.p, obj.v),
+		C.mrb_obj_inspect(obj.mrb.state(), obj.v),
 		obj.mrb,
 	}}
 }
 
 // Clone shallow object
 func (obj RValue) Clone() RValue {
-	return RValue{C.mrb_obj_clone(obj.mrb.p, obj.v), obj.mrb}
+	return RValue{C.mrb_obj_clone(obj.mrb.state(), obj.v), obj.mrb}
 }
 
 // RespondTo checks if object responds to method id
 func (obj RValue) RespondTo(mid MrbSym) bool {
-	return C.mrb_respond_to(obj.mrb.p, obj.v, C.mrb_sym(mid)) != false
+	return C.mrb_respond_to(obj.mrb.state(), obj.v, C.mrb_sym(mid)) != false
 }
 
 // IsInstanceOf checks if oruby object is direct instance of class
 func (obj RValue) IsInstanceOf(klass RClass) bool {
-	return C.mrb_obj_is_instance_of(obj.mrb.p, obj.v, klass.p) != false
+	return C.mrb_obj_is_instance_of(obj.mrb.state(), obj.v, klass.p) != false
 }
 
 // Call oruby function, return Go interface,
@@ -101,7 +101,7 @@ func (obj RValue) InstanceVariables() RArray {
 
 // IVGet get instance variable
 func (obj RValue) IVGet(sym MrbSym) Value {
-	return Value{C.mrb_iv_get(obj.mrb.p, obj.v, C.mrb_sym(sym))}
+	return Value{C.mrb_iv_get(obj.mrb.state(), obj.v, C.mrb_sym(sym))}
 }
 
 // IVSet set instance variable
@@ -120,7 +120,7 @@ func (obj RValue) IVSet(sym MrbSym, v MrbValue) error {
 	default:
 		return EArgumentError(" cannot set instance variable")
 	}
-	C.mrb_iv_set(obj.mrb.p, obj.Value().v, C.mrb_sym(sym), v.Value().v)
+	C.mrb_iv_set(obj.mrb.state(), obj.Value().v, C.mrb_sym(sym), v.Value().v)
 	return nil
 }
 
@@ -136,12 +136,12 @@ func (obj RValue) GetIV(name string) RValue {
 
 // IVDefined instance variable defined
 func (obj RValue) IVDefined(sym MrbSym) bool {
-	return C.mrb_iv_defined(obj.mrb.p, obj.v, C.mrb_sym(sym)) != false
+	return C.mrb_iv_defined(obj.mrb.state(), obj.v, C.mrb_sym(sym)) != false
 }
 
 // IVRemove remove instance variable
 func (obj RValue) IVRemove(sym MrbSym) Value {
-	return Value{C.mrb_iv_remove(obj.mrb.p, obj.v, C.mrb_sym(sym))}
+	return Value{C.mrb_iv_remove(obj.mrb.state(), obj.v, C.mrb_sym(sym))}
 }
 
 // Data returns object interface as Go interface
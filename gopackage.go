@@ -0,0 +1,114 @@
+package oruby
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// goPackageVar is package variable entry of DefineGoPackage
+type goPackageVar struct {
+	v reflect.Value
+}
+
+// goPackageType is package type entry of DefineGoPackage
+type goPackageType struct {
+	constructor interface{}
+}
+
+// GoVar marks pointer to Go variable in DefineGoPackage members.
+// Variable is exposed as read-only module function returning its current value
+func GoVar(ptr interface{}) interface{} {
+	v := reflect.ValueOf(ptr)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		panic(fmt.Sprintf("GoVar: pointer to variable is required, got %T", ptr))
+	}
+	return goPackageVar{v.Elem()}
+}
+
+// GoType marks Go type in DefineGoPackage members. Constructor is function returning
+// pointer to type, or pointer to type, as in SetAsGoClass
+func GoType(constructor interface{}) interface{} {
+	return goPackageType{constructor}
+}
+
+// DefineGoPackage defines oruby module with Go package members:
+//
+//	mrb.DefineGoPackage("Strings", map[string]interface{}{
+//		"ToUpper": strings.ToUpper,
+//		"Builder": oruby.GoType((*strings.Builder)(nil)),
+//	})
+//
+// Functions are defined as module functions with snake_case names, or with member name
+// when it starts with lowercase letter. "is_x" functions are also aliased to "x?".
+// MrbFuncT functions accept any arguments. Variables marked with GoVar are defined as read-only module functions.
+// Types marked with GoType are defined as Go classes under module. Other values are
+// defined as module constants, so their names must start with uppercase letter.
+func (mrb *MrbState) DefineGoPackage(name string, members map[string]interface{}) RClass {
+	return mrb.DefineGoPackageUnder(mrb.ObjectClass(), name, members)
+}
+
+// DefineGoPackageUnder defines oruby module with Go package members under outer class or module
+func (mrb *MrbState) DefineGoPackageUnder(outer RClass, name string, members map[string]interface{}) RClass {
+	module := mrb.DefineModuleUnder(outer, name)
+	meta := mrb.SingletonClass(module)
+
+	// Members are defined in stable order
+	names := make([]string, 0, len(members))
+	for key := range members {
+		names = append(names, key)
+	}
+	sort.Strings(names)
+
+	for _, key := range names {
+		switch m := members[key].(type) {
+		case goPackageType:
+			mrb.DefineGoClassUnder(module, key, m.constructor)
+
+		case goPackageVar:
+			meta.DefineMethod(packageFuncName(key), func(mrb *MrbState, self Value) MrbValue {
+				return mrb.valueValue(m.v)
+			}, ArgsNone())
+
+		case MrbFuncT:
+			mrb.definePackageFunc(meta, key, func(mid MrbSym) { mrb.DefineMethodID(meta, mid, m, ArgsAny()) })
+
+		case func(*MrbState, Value) MrbValue:
+			mrb.definePackageFunc(meta, key, func(mid MrbSym) { mrb.DefineMethodID(meta, mid, m, ArgsAny()) })
+
+		default:
+			if reflect.TypeOf(m) != nil && reflect.TypeOf(m).Kind() == reflect.Func {
+				mrb.definePackageFunc(meta, key, func(mid MrbSym) { mrb.DefineMethodFuncID(meta, mid, m) })
+				continue
+			}
+
+			if r := []rune(key); len(r) == 0 || !unicode.IsUpper(r[0]) {
+				panic(fmt.Sprintf("DefineGoPackage: constant name '%v' must start with uppercase letter", key))
+			}
+			mrb.DefineConst(module, key, mrb.Value(m))
+		}
+	}
+
+	return module
+}
+
+// packageFuncName returns oruby name of package member function
+func packageFuncName(key string) string {
+	if r := []rune(key); len(r) > 0 && unicode.IsLower(r[0]) {
+		return key
+	}
+	return PackageSnakeCase(key)
+}
+
+// definePackageFunc defines function with define, and aliases "is_x" to "x?"
+func (mrb *MrbState) definePackageFunc(meta RClass, key string, define func(mid MrbSym)) {
+	name := packageFuncName(key)
+	mid := mrb.Intern(name)
+	define(mid)
+
+	if strings.HasPrefix(name, "is_") {
+		mrb.AliasMethod(meta, mrb.Intern(name[3:]+"?"), mid)
+	}
+}
@@ -0,0 +1,67 @@
+package oruby
+
+import (
+	"strings"
+	"testing"
+)
+
+type testPackagePoint struct {
+	X, Y int
+}
+
+func newTestPackagePoint(x, y int) *testPackagePoint { return &testPackagePoint{x, y} }
+
+func TestMrbState_DefineGoPackage(t *testing.T) {
+	mrb := MrbOpen()
+	defer mrb.Close()
+
+	counter := 1
+	mrb.DefineGoPackage("GoStrings", map[string]interface{}{
+		"ToUpper":   strings.ToUpper,
+		"HasPrefix": strings.HasPrefix,
+		"is_blank":  func(s string) bool { return strings.TrimSpace(s) == "" },
+		"Answer":    42,
+		"Counter":   GoVar(&counter),
+		"Point":     GoType(newTestPackagePoint),
+		"Native":    MrbFuncT(func(mrb *MrbState, self Value) MrbValue { return Int(len(mrb.GetArgs().Slice())) }),
+		"Separator": "/",
+	})
+
+	v, err := mrb.Eval(`GoStrings.to_upper("abc")`)
+	ExpectNilError(t, err)
+	ExpectEql(t, mrb.String(v), "ABC")
+
+	v, _ = mrb.Eval(`GoStrings.has_prefix("oruby", "or")`)
+	ExpectEql(t, v.Type(), MrbTTTrue)
+
+	v, _ = mrb.Eval(`GoStrings.blank?("  ")`)
+	ExpectEql(t, v.Type(), MrbTTTrue)
+
+	v, _ = mrb.Eval(`[GoStrings::Answer, GoStrings::Separator]`)
+	ExpectEql(t, mrb.Intf(v), []interface{}{42, "/"})
+
+	// Variables are read at call time
+	counter = 5
+	v, _ = mrb.Eval(`GoStrings.counter`)
+	ExpectEql(t, mrb.Intf(v), 5)
+
+	_, err = mrb.Eval(`GoStrings.counter = 1`)
+	ExpectErr(t, err, "variables must be read-only")
+
+	v, err = mrb.Eval(`GoStrings::Point.new(1, 2).y`)
+	ExpectNilError(t, err)
+	ExpectEql(t, mrb.Intf(v), 2)
+
+	v, _ = mrb.Eval(`GoStrings.native(1, 2, 3)`)
+	ExpectEql(t, mrb.Intf(v), 3)
+}
+
+func TestMrbState_DefineGoPackageConstName(t *testing.T) {
+	mrb := MrbOpen()
+	defer mrb.Close()
+
+	defer func() {
+		Expect(t, recover() != nil, "lowercase constant name should panic")
+	}()
+	mrb.DefineGoPackage("GoBad", map[string]interface{}{"answer": 42})
+}
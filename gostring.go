@@ -8,7 +8,7 @@ import "unsafe"
 func (s RString) Index(str string, offset int) int {
 	cstr := C.CString(str)
 	defer C.free(unsafe.Pointer(cstr))
-	return int(C.mrb_str_index(s.mrb.p, s.v, cstr, C.mrb_int(len(str)), C.mrb_int(offset)))
+	return int(C.mrb_str_index(s.mrb.state(), s.v, cstr, C.mrb_int(len(str)), C.mrb_int(offset)))
 }
 
 // Len Returns string len
@@ -18,7 +18,7 @@ func (s RString) Len() int { return int(C._RSTRING_LEN(s.v)) }
 func (s RString) Capa() int { return int(C._RSTRING_CAPA(s.v)) }
 
 // Modify string
-func (s RString) Modify() { C.mrb_str_modify(s.mrb.p, s.Ptr().p) }
+func (s RString) Modify() { C.mrb_str_modify(s.mrb.state(), s.Ptr().p) }
 
 // Flags return string object flags
 func (s RString) Flags() int { return int(C._mrb_value_flags(s.v)) }
@@ -36,18 +36,18 @@ func (s RString) IsFShared() bool { return s.Flags()&MrbStrFShared != 0 }
 func (s RString) IsNoFree() bool { return s.Flags()&MrbStrNofree != 0 }
 
 // ModifyKeepASCII modify stringwith keeping ASCII flag if set
-func (s RString) ModifyKeepASCII() { C.mrb_str_modify_keep_ascii(s.mrb.p, s.Ptr().p) }
+func (s RString) ModifyKeepASCII() { C.mrb_str_modify_keep_ascii(s.mrb.state(), s.Ptr().p) }
 
 // Clone returns copy of string
 func (s RString) Clone() RString {
 	return RString{RValue{
-		C.mrb_str_dup(s.mrb.p, s.v),
+		C.mrb_str_dup(s.mrb.state(), s.v),
 		s.mrb,
 	}}
 }
 
 // Concat appends str. self as a concatenated string
-func (s RString) Concat(str MrbValue) { C.mrb_str_concat(s.mrb.p, s.v, str.Value().v) }
+func (s RString) Concat(str MrbValue) { C.mrb_str_concat(s.mrb.state(), s.v, str.Value().v) }
 
 // cloneV clone string with new C mrb_value
 func (s RString) cloneV(v C.mrb_value) RString {
@@ -56,42 +56,42 @@ func (s RString) cloneV(v C.mrb_value) RString {
 
 // Plus Adds two strings together.
 func (s RString) Plus(str MrbValue) RString {
-	return s.cloneV(C.mrb_str_plus(s.mrb.p, s.v, str.Value().v))
+	return s.cloneV(C.mrb_str_plus(s.mrb.state(), s.v, str.Value().v))
 }
 
 // Resize Resizes the string. Returns the amount of characters in the specified by len
 func (s RString) Resize(len int) RString {
-	return s.cloneV(C.mrb_str_resize(s.mrb.p, s.v, C.mrb_int(len)))
+	return s.cloneV(C.mrb_str_resize(s.mrb.state(), s.v, C.mrb_int(len)))
 }
 
 // Substr returns a sub string.
 func (s RString) Substr(beg, len int) RString {
-	return s.cloneV(C.mrb_str_substr(s.mrb.p, s.v, C.mrb_int(beg), C.mrb_int(len)))
+	return s.cloneV(C.mrb_str_substr(s.mrb.state(), s.v, C.mrb_int(beg), C.mrb_int(len)))
 }
 
 // CheckStringType checks string type, returns nil value is not of string type
 func (s RString) CheckStringType(str MrbValue) Value {
-	return Value{C.mrb_check_string_type(s.mrb.p, str.Value().v)}
+	return Value{C.mrb_check_string_type(s.mrb.state(), str.Value().v)}
 }
 
 // Dup Duplicates a string object.
 func (s RString) Dup() RString {
-	return s.cloneV(C.mrb_str_dup(s.mrb.p, s.v))
+	return s.cloneV(C.mrb_str_dup(s.mrb.state(), s.v))
 }
 
 // Intern Returns a symbol from a passed in Ruby string.
 func (s RString) Intern() Value {
-	return Value{C.mrb_str_intern(s.mrb.p, s.v)}
+	return Value{C.mrb_str_intern(s.mrb.state(), s.v)}
 }
 
 // ToSym Returns a symbol from a passed in Ruby string.
 func (s RString) ToSym() Value {
-	return Value{C.mrb_str_intern(s.mrb.p, s.v)}
+	return Value{C.mrb_str_intern(s.mrb.state(), s.v)}
 }
 
 // ToInteger str value to integer
 func (s RString) ToInteger(base int, badcheck bool) Value {
-	return Value{C.mrb_str_to_integer(s.mrb.p, s.v, C.mrb_int(base), iifmb(badcheck))}
+	return Value{C.mrb_str_to_integer(s.mrb.state(), s.v, C.mrb_int(base), iifmb(badcheck))}
 }
 
 // ToInum alias for ToInteger (deprecated)
@@ -101,38 +101,38 @@ func (s RString) ToInum(base int, badcheck bool) Value {
 
 // ToDbl str value to float64
 func (s RString) ToDbl(badcheck bool) float64 {
-	return float64(C.mrb_str_to_dbl(s.mrb.p, s.v, iifmb(badcheck)))
+	return float64(C.mrb_str_to_dbl(s.mrb.state(), s.v, iifmb(badcheck)))
 }
 
 // Equal  Returns true if the strings match and false if the strings don't match
 func (s RString) Equal(str2 MrbValue) bool {
-	return C.mrb_str_equal(s.mrb.p, s.v, str2.Value().v) != false
+	return C.mrb_str_equal(s.mrb.state(), s.v, str2.Value().v) != false
 }
 
 // Cat Returns a concatenated string comprised of a Ruby string and a C string.
 func (s RString) Cat(str string) RString {
 	cs := C.CString(str)
 	defer C.free(unsafe.Pointer(cs))
-	return s.cloneV(C.mrb_str_cat(s.mrb.p, s.v, cs, C.size_t(len(str))))
+	return s.cloneV(C.mrb_str_cat(s.mrb.state(), s.v, cs, C.size_t(len(str))))
 }
 
 // CatStr concat
 func (s RString) CatStr(str2 MrbValue) RString {
-	return s.cloneV(C.mrb_str_cat_str(s.mrb.p, s.v, str2.Value().v))
+	return s.cloneV(C.mrb_str_cat_str(s.mrb.state(), s.v, str2.Value().v))
 }
 
 // Append Adds str2 to the end of str1
 func (s RString) Append(str2 MrbValue) RString {
-	return s.cloneV(C.mrb_str_append(s.mrb.p, s.v, str2.Value().v))
+	return s.cloneV(C.mrb_str_append(s.mrb.state(), s.v, str2.Value().v))
 }
 
 // Cmp returns 0 if both Ruby strings are equal. Returns a value < 0 if Ruby
 // str1 is less than Ruby str2. Returns a value > 0 if Ruby str2 is greater than Ruby str1.
 func (s RString) Cmp(str2 MrbValue) int {
-	return int(C.mrb_str_cmp(s.mrb.p, s.v, str2.Value().v))
+	return int(C.mrb_str_cmp(s.mrb.state(), s.v, str2.Value().v))
 }
 
 // String Returns a newly allocated string from a Ruby string.
 func (s RString) String() string {
-	return C.GoStringN(C.mrb_str_to_cstr(s.mrb.p, s.v), C.int(s.Len()))
+	return C.GoStringN(C.mrb_str_to_cstr(s.mrb.state(), s.v), C.int(s.Len()))
 }
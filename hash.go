@@ -21,7 +21,7 @@ func MrbHashValue(hash RHash) Value { return hash.Value() }
 // HashNewCapa new hash with capacity capa
 func (mrb *MrbState) HashNewCapa(capa int) RHash {
 	return RHash{RValue{
-		C.mrb_hash_new_capa(mrb.p, C.mrb_int(capa)),
+		C.mrb_hash_new_capa(mrb.state(), C.mrb_int(capa)),
 		mrb,
 	}}
 }
@@ -41,75 +41,75 @@ func (mrb *MrbState) EnsureHashType(hash MrbValue) RHash {
 
 // CheckHashType new hash with capacity capa
 func (mrb *MrbState) CheckHashType(hash MrbValue) Value {
-	return Value{C.mrb_check_hash_type(mrb.p, hash.Value().v)}
+	return Value{C.mrb_check_hash_type(mrb.state(), hash.Value().v)}
 }
 
 // HashNew initializes a new hash
 func (mrb *MrbState) HashNew() RHash {
 	return RHash{RValue{
-		C.mrb_hash_new(mrb.p),
+		C.mrb_hash_new(mrb.state()),
 		mrb,
 	}}
 }
 
 // HashSet sets a keys and values to hashes
 func (mrb *MrbState) HashSet(hash, key, val MrbValue) {
-	C.mrb_hash_set(mrb.p, hash.Value().v, key.Value().v, val.Value().v)
+	C.mrb_hash_set(mrb.state(), hash.Value().v, key.Value().v, val.Value().v)
 }
 
 // HashGet gets a value from a key. If the key is not found, the default of the hash is used
 func (mrb *MrbState) HashGet(hash, key MrbValue) Value {
-	return Value{C.mrb_hash_get(mrb.p, hash.Value().v, key.Value().v)}
+	return Value{C.mrb_hash_get(mrb.state(), hash.Value().v, key.Value().v)}
 }
 
 // HashFetch gets a value from a key. If the key is not found, the default parameter is used
 func (mrb *MrbState) HashFetch(hash, key, def MrbValue) Value {
-	return Value{C.mrb_hash_fetch(mrb.p, hash.Value().v, key.Value().v, def.Value().v)}
+	return Value{C.mrb_hash_fetch(mrb.state(), hash.Value().v, key.Value().v, def.Value().v)}
 }
 
 // HashDeleteKey deletes hash key and value pair
 func (mrb *MrbState) HashDeleteKey(hash, key MrbValue) Value {
-	return Value{C.mrb_hash_delete_key(mrb.p, hash.Value().v, key.Value().v)}
+	return Value{C.mrb_hash_delete_key(mrb.state(), hash.Value().v, key.Value().v)}
 }
 
 // HashKeys gets an array of keys
 func (mrb *MrbState) HashKeys(hash MrbValue) RArray {
-	return ary(C.mrb_hash_keys(mrb.p, hash.Value().v), mrb)
+	return ary(C.mrb_hash_keys(mrb.state(), hash.Value().v), mrb)
 }
 
 // HashKeyP Check if the hash has the key.
 func (mrb *MrbState) HashKeyP(hash, key MrbValue) bool {
-	return C.mrb_hash_key_p(mrb.p, hash.Value().v, key.Value().v) != false
+	return C.mrb_hash_key_p(mrb.state(), hash.Value().v, key.Value().v) != false
 }
 
 // HashEmptyP check if the hash is empty
 func (mrb *MrbState) HashEmptyP(hash MrbValue) bool {
-	return C.mrb_hash_empty_p(mrb.p, hash.Value().v) != false
+	return C.mrb_hash_empty_p(mrb.state(), hash.Value().v) != false
 }
 
 // HashValues returns an array of values, equivalent to hash.values
 func (mrb *MrbState) HashValues(hash MrbValue) Value {
-	return Value{C.mrb_hash_values(mrb.p, hash.Value().v)}
+	return Value{C.mrb_hash_values(mrb.state(), hash.Value().v)}
 }
 
 // HashClear clears the hash
 func (mrb *MrbState) HashClear(hash MrbValue) Value {
-	return Value{C.mrb_hash_clear(mrb.p, hash.Value().v)}
+	return Value{C.mrb_hash_clear(mrb.state(), hash.Value().v)}
 }
 
 // HashSize get hash size
 func (mrb *MrbState) HashSize(hash MrbValue) int {
-	return int(C.mrb_hash_size(mrb.p, hash.Value().v))
+	return int(C.mrb_hash_size(mrb.state(), hash.Value().v))
 }
 
 // HashDup copies the hash
 func (mrb *MrbState) HashDup(hash MrbValue) Value {
-	return Value{C.mrb_hash_dup(mrb.p, hash.Value().v)}
+	return Value{C.mrb_hash_dup(mrb.state(), hash.Value().v)}
 }
 
 // HashMerge merges two hashes. The first hash will be modified by the second hash
 func (mrb *MrbState) HashMerge(hash1, hash2 MrbValue) {
-	C.mrb_hash_merge(mrb.p, hash1.Value().v, hash2.Value().v)
+	C.mrb_hash_merge(mrb.state(), hash1.Value().v, hash2.Value().v)
 	return
 }
 
@@ -161,6 +161,6 @@ func (mrb *MrbState) HashForEach(hash RHash, f MrbHashForeachFuncT) {
 	p := unsafe.Pointer(hash.Ptr().p)
 
 	mrb.setHook(p, f)
-	C.mrb_hash_foreach(mrb.p, hash.Ptr().p, (*C.mrb_hash_foreach_func)(C.set_hash_callback), p)
+	C.mrb_hash_foreach(mrb.state(), hash.Ptr().p, (*C.mrb_hash_foreach_func)(C.set_hash_callback), p)
 	mrb.setHook(p, nil)
 }
@@ -0,0 +1,320 @@
+package oruby
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"sync"
+)
+
+// RubyObject is oruby value used as implementation of Go interface.
+// Methods called through Invoke are run with Do, so adapters can be used
+// from any goroutine, like http.Handler served by net/http, when state lock
+// is turned on by StartExecutor.
+type RubyObject struct {
+	mrb   *MrbState
+	v     Value
+	names map[string]string // oruby names of Go methods
+	rest  []byte            // bytes returned by read which did not fit into buffer
+}
+
+// Adapter builds Go value implementing interface around oruby object.
+//
+// Go reflection can not create new method sets, so each interface needs an adapter
+// type which forwards its methods with RubyObject.Call. Adapters of package interfaces
+// are generated with orubygen -adapters, RegisterAdapter of handwritten adapter
+// overrides generated one
+type Adapter struct {
+	// New creates Go value implementing the interface
+	New func(obj *RubyObject) interface{}
+
+	// Methods maps Go method names to oruby method names,
+	// when oruby name is not snake_case of Go name
+	Methods map[string]string
+}
+
+var (
+	adaptersMu sync.Mutex
+	adapters   = map[reflect.Type]Adapter{}
+)
+
+// RegisterAdapter registers adapter for interface. iface is nil pointer to interface, as in
+//
+//	oruby.RegisterAdapter((*io.Reader)(nil), oruby.Adapter{New: newReader})
+func RegisterAdapter(iface interface{}, a Adapter) {
+	t := interfaceType(iface)
+	if t == nil {
+		panic("RegisterAdapter: pointer to interface is required")
+	}
+
+	adaptersMu.Lock()
+	adapters[t] = a
+	adaptersMu.Unlock()
+}
+
+func interfaceType(iface interface{}) reflect.Type {
+	t, ok := iface.(reflect.Type)
+	if !ok {
+		t = reflect.TypeOf(iface)
+	}
+	if t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Interface {
+		return nil
+	}
+	return t
+}
+
+// Implement returns Go value which implements interface iface by calling methods of oruby value.
+// iface is nil pointer to interface: mrb.Implement(obj, (*io.Reader)(nil)).
+// Go method names are mapped to snake_case oruby methods, and object must respond
+// to each of them. Adapters for io.Reader, io.Writer, io.Closer and their combinations,
+// fmt.Stringer, error and sort.Interface are built in, http.Handler adapter is registered
+// by gem/nethttp package. Adapters of other interfaces are generated with orubygen -adapters.
+//
+// Object is protected from garbage collection until Release is called on returned RubyObject,
+// which can be retrieved with RubyObjectOf
+func (mrb *MrbState) Implement(value MrbValue, iface interface{}) (interface{}, error) {
+	t := interfaceType(iface)
+	if t == nil {
+		return nil, ETypeError("Implement: pointer to interface is required, got %T", iface)
+	}
+
+	adaptersMu.Lock()
+	a, ok := adapters[t]
+	adaptersMu.Unlock()
+	if !ok {
+		return nil, ENotImplementedError("no adapter registered for interface %v, generate it with orubygen -adapters", t)
+	}
+
+	// Check that object responds to all methods at bind time
+	v := value.Value()
+	for i := 0; i < t.NumMethod(); i++ {
+		goName := t.Method(i).Name
+		name, ok := a.Methods[goName]
+		if !ok {
+			name = SnakeCase(goName)
+		}
+		if !mrb.RespondTo(v, mrb.Intern(name)) {
+			return nil, ENoMethodError("%v does not respond to '%v' required by %v", mrb.TypeName(v), name, t)
+		}
+	}
+
+	obj := &RubyObject{mrb, v, a.Methods, nil}
+	mrb.GCRegister(v)
+
+	return a.New(obj), nil
+}
+
+// RubyObjectOf returns RubyObject which is used by adapter created with Implement
+func RubyObjectOf(adapter interface{}) *RubyObject {
+	if o, ok := adapter.(interface{ rubyObject() *RubyObject }); ok {
+		return o.rubyObject()
+	}
+	return nil
+}
+
+func (o *RubyObject) rubyObject() *RubyObject { return o }
+
+// Value returns oruby value of the object, so adapter passed back to oruby is the original object
+func (o *RubyObject) Value() Value { return o.v }
+
+// Type for MrbValue interface
+func (o *RubyObject) Type() Type { return o.v.Type() }
+
+// IsNil for MrbValue interface
+func (o *RubyObject) IsNil() bool { return o.v.IsNil() }
+
+// Release unregisters object from garbage collection protection
+func (o *RubyObject) Release() {
+	o.mrb.Do(func(mrb *MrbState) { mrb.GCUnregister(o.v) })
+}
+
+// Invoke calls method of oruby object with Go arguments, and scans result into out pointers.
+// When more than one out pointer is given, result must be an Array which is spread over them
+func (o *RubyObject) Invoke(method string, args []interface{}, out ...interface{}) (err error) {
+	o.mrb.Do(func(mrb *MrbState) {
+		var v Value
+		if v, err = mrb.Funcall(o.v, mrb.Intern(method), args...); err != nil || len(out) == 0 {
+			return
+		}
+
+		if len(out) == 1 {
+			err = mrb.Scan(v, out[0])
+			return
+		}
+
+		if v.Type() != MrbTTArray {
+			err = ETypeError("%v: expected Array of %v results, got %v", method, len(out), mrb.TypeName(v))
+			return
+		}
+		for i := range out {
+			if err = mrb.Scan(mrb.AryRef(v, i), out[i]); err != nil {
+				return
+			}
+		}
+	})
+	return err
+}
+
+// Call calls oruby method of Go interface method goName, as Invoke does. Method name is
+// mapped by adapter, or snake_cased. It is used by adapters generated with orubygen
+func (o *RubyObject) Call(goName string, args []interface{}, out ...interface{}) error {
+	name, ok := o.names[goName]
+	if !ok {
+		name = SnakeCase(goName)
+	}
+	return o.Invoke(name, args, out...)
+}
+
+// Built-in adapters
+
+// read calls read(len) which returns String, or nil at the end of stream.
+// When more than len bytes are returned, the rest is returned by next read
+func (o *RubyObject) read(p []byte) (int, error) {
+	if len(o.rest) > 0 {
+		n := copy(p, o.rest)
+		o.rest = o.rest[n:]
+		return n, nil
+	}
+
+	var s []byte
+	if err := o.Invoke("read", []interface{}{len(p)}, &s); err != nil {
+		return 0, err
+	}
+	if len(s) == 0 && len(p) > 0 {
+		return 0, io.EOF
+	}
+
+	n := copy(p, s)
+	if n < len(s) {
+		o.rest = s[n:]
+	}
+	return n, nil
+}
+
+// write calls write(str) which returns number of bytes written
+func (o *RubyObject) write(p []byte) (int, error) {
+	var n interface{}
+	if err := o.Invoke("write", []interface{}{p}, &n); err != nil {
+		return 0, err
+	}
+	if i, ok := n.(int); ok {
+		return i, nil
+	}
+	return len(p), nil
+}
+
+// close calls close
+func (o *RubyObject) close() error { return o.Invoke("close", nil) }
+
+type rubyReader struct{ *RubyObject }
+
+func (r rubyReader) Read(p []byte) (int, error) { return r.read(p) }
+
+type rubyWriter struct{ *RubyObject }
+
+func (w rubyWriter) Write(p []byte) (int, error) { return w.write(p) }
+
+type rubyCloser struct{ *RubyObject }
+
+func (c rubyCloser) Close() error { return c.close() }
+
+type rubyReadCloser struct{ *RubyObject }
+
+func (rc rubyReadCloser) Read(p []byte) (int, error) { return rc.read(p) }
+func (rc rubyReadCloser) Close() error               { return rc.close() }
+
+type rubyWriteCloser struct{ *RubyObject }
+
+func (wc rubyWriteCloser) Write(p []byte) (int, error) { return wc.write(p) }
+func (wc rubyWriteCloser) Close() error                { return wc.close() }
+
+type rubyReadWriter struct{ *RubyObject }
+
+func (rw rubyReadWriter) Read(p []byte) (int, error)  { return rw.read(p) }
+func (rw rubyReadWriter) Write(p []byte) (int, error) { return rw.write(p) }
+
+type rubyReadWriteCloser struct{ *RubyObject }
+
+func (rwc rubyReadWriteCloser) Read(p []byte) (int, error)  { return rwc.read(p) }
+func (rwc rubyReadWriteCloser) Write(p []byte) (int, error) { return rwc.write(p) }
+func (rwc rubyReadWriteCloser) Close() error                { return rwc.close() }
+
+type rubyStringer struct{ *RubyObject }
+
+// String calls to_s
+func (s rubyStringer) String() string {
+	var str string
+	if err := s.Invoke("to_s", nil, &str); err != nil {
+		return fmt.Sprintf("#<%v>", err)
+	}
+	return str
+}
+
+type rubyError struct{ *RubyObject }
+
+// Error calls message
+func (e rubyError) Error() string {
+	var str string
+	if err := e.Invoke("message", nil, &str); err != nil {
+		return err.Error()
+	}
+	return str
+}
+
+// rubySorter panics with error of failed method, as sort.Interface can not return it,
+// so sort.Sort does not continue with wrong results
+type rubySorter struct{ *RubyObject }
+
+func (s rubySorter) invoke(method string, args []interface{}, out ...interface{}) {
+	if err := s.Invoke(method, args, out...); err != nil {
+		panic(err)
+	}
+}
+
+// Len calls len
+func (s rubySorter) Len() int {
+	var n int
+	s.invoke("len", nil, &n)
+	return n
+}
+
+// Less calls less(i, j)
+func (s rubySorter) Less(i, j int) bool {
+	var less bool
+	s.invoke("less", []interface{}{i, j}, &less)
+	return less
+}
+
+// Swap calls swap(i, j)
+func (s rubySorter) Swap(i, j int) { s.invoke("swap", []interface{}{i, j}) }
+
+func init() {
+	RegisterAdapter((*io.Reader)(nil), Adapter{New: func(o *RubyObject) interface{} { return rubyReader{o} }})
+	RegisterAdapter((*io.Writer)(nil), Adapter{New: func(o *RubyObject) interface{} { return rubyWriter{o} }})
+	RegisterAdapter((*io.Closer)(nil), Adapter{New: func(o *RubyObject) interface{} { return rubyCloser{o} }})
+	RegisterAdapter((*io.ReadCloser)(nil), Adapter{New: func(o *RubyObject) interface{} {
+		return rubyReadCloser{o}
+	}})
+	RegisterAdapter((*io.WriteCloser)(nil), Adapter{New: func(o *RubyObject) interface{} {
+		return rubyWriteCloser{o}
+	}})
+	RegisterAdapter((*io.ReadWriter)(nil), Adapter{New: func(o *RubyObject) interface{} {
+		return rubyReadWriter{o}
+	}})
+	RegisterAdapter((*io.ReadWriteCloser)(nil), Adapter{New: func(o *RubyObject) interface{} {
+		return rubyReadWriteCloser{o}
+	}})
+	RegisterAdapter((*fmt.Stringer)(nil), Adapter{
+		New:     func(o *RubyObject) interface{} { return rubyStringer{o} },
+		Methods: map[string]string{"String": "to_s"},
+	})
+	RegisterAdapter((*error)(nil), Adapter{
+		New:     func(o *RubyObject) interface{} { return rubyError{o} },
+		Methods: map[string]string{"Error": "message"},
+	})
+	RegisterAdapter((*sort.Interface)(nil), Adapter{New: func(o *RubyObject) interface{} { return rubySorter{o} }})
+}
@@ -0,0 +1,181 @@
+package oruby
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"testing"
+)
+
+func TestMrbState_Implement(t *testing.T) {
+	mrb := MrbOpen()
+	defer mrb.Close()
+
+	v, err := mrb.Eval(`
+		class ImplementReader
+			def initialize(s); @s = s; end
+			def read(n)
+				return nil if @s.empty?
+				chunk = @s[0, n]
+				@s = @s[n..-1] || ""
+				chunk
+			end
+			def to_s; "reader"; end
+		end
+		ImplementReader.new("hello oruby")
+	`)
+	ExpectNilError(t, err)
+
+	r, err := mrb.Implement(v, (*io.Reader)(nil))
+	ExpectNilError(t, err)
+
+	buf := make([]byte, 4)
+	var out bytes.Buffer
+	_, err = io.CopyBuffer(&out, r.(io.Reader), buf)
+	ExpectNilError(t, err)
+	ExpectEql(t, out.String(), "hello oruby")
+
+	s, err := mrb.Implement(v, (*fmt.Stringer)(nil))
+	ExpectNilError(t, err)
+	ExpectEql(t, s.(fmt.Stringer).String(), "reader")
+
+	// Adapter converts back to original object
+	ExpectEql(t, mrb.Value(r), v)
+
+	_, err = mrb.Implement(v, (*io.Writer)(nil))
+	ExpectErr(t, err, "object without write must not implement io.Writer")
+
+	RubyObjectOf(r).Release()
+}
+
+func TestMrbState_ImplementReadRest(t *testing.T) {
+	mrb := MrbOpen()
+	defer mrb.Close()
+
+	// read ignores requested length
+	v, err := mrb.Eval(`
+		o = Object.new
+		def o.read(n); @done ? nil : (@done = true; "hello oruby"); end
+		o
+	`)
+	ExpectNilError(t, err)
+
+	r, err := mrb.Implement(v, (*io.Reader)(nil))
+	ExpectNilError(t, err)
+
+	buf := make([]byte, 4)
+	var out bytes.Buffer
+	for {
+		n, err := r.(io.Reader).Read(buf)
+		out.Write(buf[:n])
+		if err == io.EOF {
+			break
+		}
+		ExpectNilError(t, err)
+	}
+	ExpectEql(t, out.String(), "hello oruby")
+
+	RubyObjectOf(r).Release()
+}
+
+func TestMrbState_ImplementConcurrent(t *testing.T) {
+	mrb := MrbOpen()
+	defer mrb.Close()
+
+	v, err := mrb.Eval(`
+		class ImplementCounter
+			def initialize; @n = 0; end
+			def to_s; (@n += 1).to_s; end
+		end
+		ImplementCounter.new
+	`)
+	ExpectNilError(t, err)
+
+	s, err := mrb.Implement(v, (*fmt.Stringer)(nil))
+	ExpectNilError(t, err)
+
+	// adapters are used from goroutines, as handlers served by net/http
+	mrb.StartExecutor()
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				s.(fmt.Stringer).String()
+			}
+		}()
+	}
+	for i := 0; i < 50; i++ {
+		_, err := mrb.Eval("1 + 1")
+		ExpectNilError(t, err)
+	}
+	wg.Wait()
+
+	ExpectEql(t, s.(fmt.Stringer).String(), "401")
+	RubyObjectOf(s).Release()
+}
+
+func TestMrbState_ImplementSort(t *testing.T) {
+	mrb := MrbOpen()
+	defer mrb.Close()
+
+	v, err := mrb.Eval(`
+		class ImplementSorter
+			attr_reader :a
+			def initialize(a); @a = a; end
+			def len; @a.size; end
+			def less(i, j); @a[i] < @a[j]; end
+			def swap(i, j); @a[i], @a[j] = @a[j], @a[i]; end
+		end
+		ImplementSorter.new([3, 1, 2])
+	`)
+	ExpectNilError(t, err)
+
+	s, err := mrb.Implement(v, (*sort.Interface)(nil))
+	ExpectNilError(t, err)
+	sort.Sort(s.(sort.Interface))
+
+	ExpectEql(t, mrb.Intf(mrb.Call(v, "a")), []interface{}{1, 2, 3})
+
+	// failed method panics, so sort does not continue with wrong results
+	v, err = mrb.Eval(`ImplementSorter.new([3, nil, 2])`)
+	ExpectNilError(t, err)
+	s, err = mrb.Implement(v, (*sort.Interface)(nil))
+	ExpectNilError(t, err)
+
+	defer func() {
+		_, ok := recover().(error)
+		Expect(t, ok, "sort should panic with error")
+	}()
+	sort.Sort(s.(sort.Interface))
+}
+
+type testGreeter interface {
+	Greet(name string) string
+}
+
+// rubyGreeter is adapter as generated by orubygen -adapters
+type rubyGreeter struct{ *RubyObject }
+
+func (a rubyGreeter) Greet(p0 string) (r0 string) {
+	_ = a.Call("Greet", []interface{}{p0}, &r0)
+	return
+}
+
+func TestRegisterAdapter(t *testing.T) {
+	mrb := MrbOpen()
+	defer mrb.Close()
+
+	v, _ := mrb.Eval(`o = Object.new; def o.greet(n); "hi #{n}"; end; o`)
+
+	_, err := mrb.Implement(v, (*testGreeter)(nil))
+	ExpectErr(t, err, "unregistered interface must fail")
+
+	RegisterAdapter((*testGreeter)(nil), Adapter{New: func(o *RubyObject) interface{} { return rubyGreeter{o} }})
+	g, err := mrb.Implement(v, (*testGreeter)(nil))
+	ExpectNilError(t, err)
+	ExpectEql(t, g.(testGreeter).Greet("go"), "hi go")
+}
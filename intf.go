@@ -0,0 +1,204 @@
+package oruby
+
+import (
+	"reflect"
+	"unicode/utf8"
+)
+
+// IntfOptions controls conversion of oruby values to Go values in IntfWith.
+// Zero value converts same as Intf
+type IntfOptions struct {
+	OrderedHash bool // Hashes are converted to OrderedHash, keeping key order
+	KeepSymbols bool // Symbols are converted to Symbol, so symbol and string keys stay distinct
+	Int64       bool // Integers are converted to int64 instead of int
+	BinaryBytes bool // Strings which are not valid UTF-8 are converted to []byte
+	KeepValues  bool // Objects, ranges and other values without Go counterpart are returned as Value
+}
+
+// Symbol is oruby symbol name, returned by IntfWith with KeepSymbols option.
+// Symbol is converted back to oruby symbol by Value
+type Symbol string
+
+// KeyValue is single OrderedHash entry
+type KeyValue struct {
+	Key   interface{}
+	Value interface{}
+}
+
+// OrderedHash is oruby Hash converted to Go, with insertion order of keys kept
+type OrderedHash []KeyValue
+
+// Get returns value for the key
+func (h OrderedHash) Get(key interface{}) (interface{}, bool) {
+	for _, kv := range h {
+		if keysEqual(kv.Key, key) {
+			return kv.Value, true
+		}
+	}
+	return nil, false
+}
+
+// keysEqual compares hash keys, keys of uncomparable types like converted arrays
+// are compared by content
+func keysEqual(a, b interface{}) bool {
+	if t := reflect.TypeOf(a); t != nil && !t.Comparable() {
+		return reflect.DeepEqual(a, b)
+	}
+	return a == b
+}
+
+// mapKey returns key usable in Go map. Uncomparable keys, like converted Array
+// or Hash, are replaced with inspect string of oruby key
+func (mrb *MrbState) mapKey(key Value, k interface{}) interface{} {
+	if t := reflect.TypeOf(k); t != nil && !t.Comparable() {
+		return mrb.String(mrb.Inspect(key))
+	}
+	return k
+}
+
+// Keys returns hash keys in order
+func (h OrderedHash) Keys() []interface{} {
+	keys := make([]interface{}, len(h))
+	for i, kv := range h {
+		keys[i] = kv.Key
+	}
+	return keys
+}
+
+// MigrateTo implements ValueMigrator interface, OrderedHash is converted back to Hash
+func (h OrderedHash) MigrateTo(mrb *MrbState) Value {
+	hash := mrb.HashNewCapa(len(h))
+	for _, kv := range h {
+		hash.Set(mrb.Value(kv.Key), mrb.Value(kv.Value))
+	}
+	return hash.Value()
+}
+
+// MigrateTo implements ValueMigrator interface, Symbol is converted to oruby symbol
+func (s Symbol) MigrateTo(mrb *MrbState) Value {
+	return mrb.SymbolValue(mrb.Intern(string(s)))
+}
+
+// IntfWith converts oruby value to Go interface as Intf does,
+// with conversion choices controlled by opts
+//
+// Values returned with KeepValues option are not protected from garbage
+// collection, use GCRegister to keep them alive
+func (mrb *MrbState) IntfWith(o MrbValue, opts IntfOptions) interface{} {
+	if opts == (IntfOptions{}) {
+		return mrb.Intf(o)
+	}
+
+	switch o.Type() {
+	case MrbTTInteger:
+		if opts.Int64 {
+			return int64(MrbFixnum(o))
+		}
+
+	case MrbTTSymbol:
+		if opts.KeepSymbols {
+			return Symbol(mrb.SymName(MrbSymbol(o)))
+		}
+
+	case MrbTTString:
+		if opts.BinaryBytes {
+			if b := o.Value().Bytes(); !utf8.Valid(b) {
+				return b
+			}
+		}
+
+	case MrbTTArray:
+		arry := make([]interface{}, RArrayLen(o))
+		for i := range arry {
+			arry[i] = mrb.IntfWith(mrb.AryRef(o, i), opts)
+		}
+		return arry
+
+	case MrbTTHash:
+		return mrb.hashIntfWith(o, opts)
+
+	case MrbTTObject:
+		if v, ok := mrb.convertedIntf(o); ok {
+			return v
+		}
+		if opts.KeepValues {
+			return o.Value()
+		}
+
+		vars := mrb.Call(o, "instance_variables")
+		kcnt := RArrayLen(vars)
+		hash := make(map[string]interface{}, kcnt)
+		for i := 0; i < kcnt; i++ {
+			key := mrb.AryRef(vars, i)
+			hash[mrb.String(key)] = mrb.IntfWith(mrb.IVGet(o, mrb.ObjToSym(key)), opts)
+		}
+		return hash
+
+	case MrbTTRange, MrbTTException, MrbTTEnv, MrbTTFiber:
+		if opts.KeepValues {
+			return o.Value()
+		}
+
+	case MrbTTCData, MrbTTIStruct:
+		if v := mrb.Intf(o); v != nil || !opts.KeepValues {
+			return v
+		}
+		return o.Value()
+	}
+
+	return mrb.Intf(o)
+}
+
+// hashIntfWith converts Hash to Go map or OrderedHash
+func (mrb *MrbState) hashIntfWith(o MrbValue, opts IntfOptions) interface{} {
+	keys := mrb.HashKeys(o)
+	kcnt := RArrayLen(keys)
+
+	// Symbol and String keys are merged as strings, unless symbols are kept
+	stringKeys := true
+	for i := 0; i < kcnt && stringKeys; i++ {
+		switch mrb.AryRef(keys, i).Type() {
+		case MrbTTString:
+		case MrbTTSymbol:
+			stringKeys = !opts.KeepSymbols
+		default:
+			stringKeys = false
+		}
+	}
+
+	// []byte is not usable as map key
+	keyOpts := opts
+	keyOpts.BinaryBytes = false
+
+	keyIntf := func(key Value) interface{} {
+		if stringKeys {
+			return mrb.String(key)
+		}
+		return mrb.IntfWith(key, keyOpts)
+	}
+
+	if opts.OrderedHash {
+		hash := make(OrderedHash, kcnt)
+		for i := 0; i < kcnt; i++ {
+			key := mrb.AryRef(keys, i)
+			hash[i] = KeyValue{keyIntf(key), mrb.IntfWith(mrb.HashGet(o, key), opts)}
+		}
+		return hash
+	}
+
+	if stringKeys {
+		hash := make(map[string]interface{}, kcnt)
+		for i := 0; i < kcnt; i++ {
+			key := mrb.AryRef(keys, i)
+			hash[mrb.String(key)] = mrb.IntfWith(mrb.HashGet(o, key), opts)
+		}
+		return hash
+	}
+
+	hash := make(map[interface{}]interface{}, kcnt)
+	for i := 0; i < kcnt; i++ {
+		key := mrb.AryRef(keys, i)
+		hash[mrb.mapKey(key, keyIntf(key))] = mrb.IntfWith(mrb.HashGet(o, key), opts)
+	}
+	return hash
+}
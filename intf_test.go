@@ -0,0 +1,74 @@
+package oruby
+
+import (
+	"testing"
+)
+
+func TestMrbState_IntfWith(t *testing.T) {
+	mrb := MrbOpen()
+	defer mrb.Close()
+
+	v, err := mrb.Eval(`{b: 1, "a" => 2, c: [3]}`)
+	ExpectNilError(t, err)
+
+	// Zero options are same as Intf
+	ExpectEql(t, mrb.IntfWith(v, IntfOptions{}), mrb.Intf(v))
+
+	ordered := mrb.IntfWith(v, IntfOptions{OrderedHash: true}).(OrderedHash)
+	ExpectEql(t, ordered.Keys(), []interface{}{"b", "a", "c"})
+	val, ok := ordered.Get("c")
+	Expect(t, ok, "key c should exist")
+	ExpectEql(t, val, []interface{}{3})
+
+	symbols := mrb.IntfWith(v, IntfOptions{KeepSymbols: true, Int64: true}).(map[interface{}]interface{})
+	ExpectEql(t, symbols[Symbol("b")], int64(1))
+	ExpectEql(t, symbols["a"], int64(2))
+	_, ok = symbols["b"]
+	Expect(t, !ok, "symbol key must not be merged with string key")
+
+	// Round trip keeps order and symbols
+	back := mrb.Value(mrb.IntfWith(v, IntfOptions{OrderedHash: true, KeepSymbols: true}))
+	ExpectEql(t, mrb.String(mrb.Inspect(back)), mrb.String(mrb.Inspect(v)))
+}
+
+func TestMrbState_IntfArrayKeys(t *testing.T) {
+	mrb := MrbOpen()
+	defer mrb.Close()
+
+	v, err := mrb.Eval(`{[1, 2] => "a", 3 => "b"}`)
+	ExpectNilError(t, err)
+
+	ordered := mrb.IntfWith(v, IntfOptions{OrderedHash: true}).(OrderedHash)
+	val, ok := ordered.Get([]interface{}{1, 2})
+	Expect(t, ok, "array key should exist")
+	ExpectEql(t, val, "a")
+	_, ok = ordered.Get([]interface{}{2, 1})
+	Expect(t, !ok, "other array key should not exist")
+
+	// uncomparable keys use inspected key in Go maps
+	hash := mrb.Intf(v).(map[interface{}]interface{})
+	ExpectEql(t, hash["[1, 2]"], "a")
+	ExpectEql(t, hash[3], "b")
+
+	hash = mrb.IntfWith(v, IntfOptions{Int64: true}).(map[interface{}]interface{})
+	ExpectEql(t, hash["[1, 2]"], "a")
+}
+
+func TestMrbState_IntfWithValues(t *testing.T) {
+	mrb := MrbOpen()
+	defer mrb.Close()
+
+	v, err := mrb.Eval(`class IntfWithObject; def initialize; @a = 1; end; end; [IntfWithObject.new, 1..2, "\xff\x00"]`)
+	ExpectNilError(t, err)
+
+	res := mrb.IntfWith(v, IntfOptions{KeepValues: true, BinaryBytes: true}).([]interface{})
+	obj, ok := res[0].(Value)
+	Expect(t, ok, "object should be kept as Value, got %T", res[0])
+	ExpectEql(t, obj.Type(), MrbTTObject)
+
+	rng, ok := res[1].(Value)
+	Expect(t, ok, "range should be kept as Value, got %T", res[1])
+	ExpectEql(t, rng.Type(), MrbTTRange)
+
+	ExpectEql(t, res[2], []byte{0xff, 0x00})
+}
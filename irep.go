@@ -47,6 +47,17 @@ func (pv MrbPoolValue) IsSStr() bool {
 	return pv.Type() == IrepTtSstr
 }
 
+// Str returns string pool value
+func (pv MrbPoolValue) Str() string {
+	return C.GoStringN(C._mrb_pool_str(pv.v), C.int(C._mrb_pool_strlen(pv.v)))
+}
+
+// Int returns integer pool value
+func (pv MrbPoolValue) Int() int64 { return int64(C._mrb_pool_int(pv.v)) }
+
+// Float returns float pool value
+func (pv MrbPoolValue) Float() float64 { return float64(C._mrb_pool_float(pv.v)) }
+
 func (pv MrbPoolValue) Migrate() {
 	C._mrb_pool_value_migrate(pv.v)
 }
@@ -75,7 +86,7 @@ type MrbIrep struct {
 
 // AddIrep irep api
 func (mrb *MrbState) AddIrep() MrbIrep {
-	return MrbIrep{C.mrb_add_irep(mrb.p), mrb}
+	return MrbIrep{C.mrb_add_irep(mrb.state()), mrb}
 }
 
 // LoadIrep irep from buffer bytes array
@@ -95,9 +106,9 @@ func (mrb *MrbState) LoadIrep(buffer []byte) (Value, error) {
 	var ret Value
 	bufLen := len(buffer)
 	if bufLen == 0 {
-		ret = Value{C.mrb_load_irep(mrb.p, nil)}
+		ret = Value{C.mrb_load_irep(mrb.state(), nil)}
 	} else {
-		ret = Value{C.mrb_load_irep_buf(mrb.p, unsafe.Pointer(&buffer[0]), C.size_t(bufLen))}
+		ret = Value{C.mrb_load_irep_buf(mrb.state(), unsafe.Pointer(&buffer[0]), C.size_t(bufLen))}
 		runtime.KeepAlive(buffer)
 	}
 	return ret, mrb.Err()
@@ -114,9 +125,9 @@ func (mrb *MrbState) LoadIrepCxt(buffer []byte, context *MrbcContext) (Value, er
 	bufLen := len(buffer)
 
 	if bufLen == 0 {
-		ret = Value{C.mrb_load_irep_cxt(mrb.p, nil, context.p)}
+		ret = Value{C.mrb_load_irep_cxt(mrb.state(), nil, context.p)}
 	} else {
-		ret = Value{C.mrb_load_irep_buf_cxt(mrb.p, unsafe.Pointer(&buffer[0]), C.size_t(bufLen), context.p)}
+		ret = Value{C.mrb_load_irep_buf_cxt(mrb.state(), unsafe.Pointer(&buffer[0]), C.size_t(bufLen), context.p)}
 		runtime.KeepAlive(buffer)
 	}
 
@@ -183,34 +194,34 @@ func (mrb *MrbState) LoadIrepBufCxt(buffer []byte, context *MrbcContext) (Value,
 }
 
 // IrepIncref increase reference to irep
-func (mrb *MrbState) IrepIncref(irep MrbIrep) { C.mrb_irep_incref(mrb.p, irep.p) }
+func (mrb *MrbState) IrepIncref(irep MrbIrep) { C.mrb_irep_incref(mrb.state(), irep.p) }
 
 // IrepDecref decrease reference to irep
-func (mrb *MrbState) IrepDecref(irep MrbIrep) { C.mrb_irep_decref(mrb.p, irep.p) }
+func (mrb *MrbState) IrepDecref(irep MrbIrep) { C.mrb_irep_decref(mrb.state(), irep.p) }
 
 // IrepCutref cut reference form irep
-func (mrb *MrbState) IrepCutref(irep MrbIrep) { C.mrb_irep_cutref(mrb.p, irep.p) }
+func (mrb *MrbState) IrepCutref(irep MrbIrep) { C.mrb_irep_cutref(mrb.state(), irep.p) }
 
 // IrepRemoveLV removes local variables from irep
-func (mrb *MrbState) IrepRemoveLV(irep MrbIrep) { C.mrb_irep_remove_lv(mrb.p, irep.p) }
+func (mrb *MrbState) IrepRemoveLV(irep MrbIrep) { C.mrb_irep_remove_lv(mrb.state(), irep.p) }
 
 // IsNil returns true if irep is empty
 func (irep MrbIrep) IsNil() bool { return irep.p == nil }
 
 // Free irep
-func (irep MrbIrep) Free() { C.mrb_irep_free(irep.mrb.p, irep.p) }
+func (irep MrbIrep) Free() { C.mrb_irep_free(irep.mrb.state(), irep.p) }
 
 // Incref increase reference to irep
-func (irep MrbIrep) Incref() { C.mrb_irep_incref(irep.mrb.p, irep.p) }
+func (irep MrbIrep) Incref() { C.mrb_irep_incref(irep.mrb.state(), irep.p) }
 
 // Decref decrease reference to irep
-func (irep MrbIrep) Decref() { C.mrb_irep_decref(irep.mrb.p, irep.p) }
+func (irep MrbIrep) Decref() { C.mrb_irep_decref(irep.mrb.state(), irep.p) }
 
 // Cutref cut reference form irep
-func (irep MrbIrep) Cutref() { C.mrb_irep_cutref(irep.mrb.p, irep.p) }
+func (irep MrbIrep) Cutref() { C.mrb_irep_cutref(irep.mrb.state(), irep.p) }
 
 // RemoveLV removes local variables from irep
-func (irep MrbIrep) RemoveLV() { C.mrb_irep_remove_lv(irep.mrb.p, irep.p) }
+func (irep MrbIrep) RemoveLV() { C.mrb_irep_remove_lv(irep.mrb.state(), irep.p) }
 
 // NLocals returns number of local variables
 func (irep MrbIrep) NLocals() int {
@@ -261,7 +272,7 @@ func (irep MrbIrep) Pool(index int) MrbPoolValue {
 
 // ILen returns number of ISeq MrbCode items
 func (irep MrbIrep) ILen() int {
-	return int(irep.p.plen)
+	return int(irep.p.ilen)
 }
 
 // ISeq returns MrbCode at index
@@ -307,7 +318,7 @@ func (irep MrbIrep) SLen() int {
 
 // Syms returns MrbSym at index
 func (irep MrbIrep) Syms(index int) MrbSym {
-	if index < 0 || index >= irep.ILen() {
+	if index < 0 || index >= irep.SLen() {
 		return MrbSym(0)
 	}
 
@@ -340,7 +351,7 @@ func (irep MrbIrep) SetISeq(iseq []MrbCode) {
 
 	size := C.size_t(C.sizeof_mrb_code * len(iseq))
 
-	p := C.mrb_malloc(irep.mrb.p, size)
+	p := C.mrb_malloc(irep.mrb.state(), size)
 	C.memcpy(p, unsafe.Pointer(&iseq[0]), size)
 	irep.p.iseq = (*C.mrb_code)(p)
 
@@ -357,7 +368,7 @@ func (irep MrbIrep) CopyISeq(source MrbIrep) {
 	}
 
 	size := C.size_t(C.sizeof_mrb_code * source.p.ilen)
-	p := C.mrb_malloc(irep.mrb.p, size)
+	p := C.mrb_malloc(irep.mrb.state(), size)
 	C.memcpy(p, unsafe.Pointer(source.p.iseq), size)
 
 	irep.p.iseq = (*C.mrb_code)(p)
@@ -0,0 +1,292 @@
+//go:build go1.23
+
+package oruby
+
+import (
+	"iter"
+	"reflect"
+)
+
+// goSeq is Go iterator function, iter.Seq or iter.Seq2, wrapped in GoEnumerator::Seq oruby object.
+// Values are pulled from iterator for external enumeration with next and peek
+type goSeq struct {
+	seq    reflect.Value
+	pairs  bool
+	next   func() ([]reflect.Value, bool)
+	stop   func()
+	peeked []reflect.Value
+	done   bool
+}
+
+// seqType reports if t is iterator function type, as iter.Seq or iter.Seq2 of any value type.
+// pairs is true for iter.Seq2
+func seqType(t reflect.Type) (pairs bool, ok bool) {
+	if t.Kind() != reflect.Func || t.NumIn() != 1 || t.NumOut() != 0 {
+		return false, false
+	}
+	yield := t.In(0)
+	if yield.Kind() != reflect.Func || yield.NumOut() != 1 || yield.Out(0).Kind() != reflect.Bool {
+		return false, false
+	}
+	switch yield.NumIn() {
+	case 1:
+		return false, true
+	case 2:
+		return true, true
+	}
+	return false, false
+}
+
+// all returns iterator over arguments of seq yield function
+func (s *goSeq) all() iter.Seq[[]reflect.Value] {
+	return func(yield func([]reflect.Value) bool) {
+		yt := s.seq.Type().In(0)
+		fn := reflect.MakeFunc(yt, func(args []reflect.Value) []reflect.Value {
+			return []reflect.Value{reflect.ValueOf(yield(args)).Convert(yt.Out(0))}
+		})
+		s.seq.Call([]reflect.Value{fn})
+	}
+}
+
+// pull returns next iterator values, peeked values are returned first
+func (s *goSeq) pull() ([]reflect.Value, bool) {
+	if s.peeked != nil {
+		args := s.peeked
+		s.peeked = nil
+		return args, true
+	}
+	if s.done {
+		return nil, false
+	}
+	if s.next == nil {
+		s.next, s.stop = iter.Pull(s.all())
+	}
+
+	args, ok := s.next()
+	if !ok {
+		s.done = true
+	}
+	return args, ok
+}
+
+// rewind stops pulling, so next enumeration starts from the beginning
+func (s *goSeq) rewind() {
+	if s.stop != nil {
+		s.stop()
+	}
+	s.next, s.stop, s.peeked, s.done = nil, nil, nil, false
+}
+
+// dataFree releases goroutine of pulled iterator when object is garbage collected
+func (s *goSeq) dataFree() { s.rewind() }
+
+// seqArgs converts iterator values to block arguments
+func (mrb *MrbState) seqArgs(args []reflect.Value) []interface{} {
+	ret := make([]interface{}, len(args))
+	for i, a := range args {
+		ret[i] = mrb.valueValue(a)
+	}
+	return ret
+}
+
+// seqResult converts pulled values to oruby value, iter.Seq2 pairs are returned as Array
+func (mrb *MrbState) seqResult(s *goSeq, args []reflect.Value) Value {
+	if !s.pairs {
+		return mrb.valueValue(args[0])
+	}
+	return mrb.AryNewFromValues(mrb.valueValue(args[0]), mrb.valueValue(args[1])).Value()
+}
+
+func (mrb *MrbState) stopIteration() Value {
+	if mrb.ClassDefined("StopIteration") {
+		return mrb.Raise(mrb.ClassGet("StopIteration"), "iteration reached an end")
+	}
+	return mrb.Raise(mrb.EIndexError(), "iteration reached an end")
+}
+
+// seqEach calls block for each value, iter.Seq2 pairs are yielded as two block arguments.
+// Without block enumerator is returned
+func seqEach(mrb *MrbState, self Value) MrbValue {
+	s, ok := mrb.DataGetInterface(self).(*goSeq)
+	if !ok {
+		return mrb.Raise(mrb.ETypeError(), "uninitialized Go iterator")
+	}
+
+	block := mrb.GetArgsBlock()
+	if block.IsNil() {
+		return mrb.Call(self, "to_enum")
+	}
+
+	for args := range s.all() {
+		v := mrb.YieldArgv(block, mrb.seqArgs(args)...)
+		// Exception or break from block stops Go iterator, and is raised again on return
+		if mrb.raised(v) {
+			return v
+		}
+	}
+	return self
+}
+
+// seqNext returns next value or raises StopIteration
+func seqNext(mrb *MrbState, self Value) MrbValue {
+	s, ok := mrb.DataGetInterface(self).(*goSeq)
+	if !ok {
+		return mrb.Raise(mrb.ETypeError(), "uninitialized Go iterator")
+	}
+
+	args, ok := s.pull()
+	if !ok {
+		return mrb.stopIteration()
+	}
+	return mrb.seqResult(s, args)
+}
+
+// seqPeek returns next value without advancing, or raises StopIteration
+func seqPeek(mrb *MrbState, self Value) MrbValue {
+	s, ok := mrb.DataGetInterface(self).(*goSeq)
+	if !ok {
+		return mrb.Raise(mrb.ETypeError(), "uninitialized Go iterator")
+	}
+
+	args, ok := s.pull()
+	if !ok {
+		return mrb.stopIteration()
+	}
+	s.peeked = args
+	return mrb.seqResult(s, args)
+}
+
+// seqRewind restarts external enumeration
+func seqRewind(mrb *MrbState, self Value) MrbValue {
+	if s, ok := mrb.DataGetInterface(self).(*goSeq); ok {
+		s.rewind()
+	}
+	return self
+}
+
+// enumDelegate forwards GoEnumerator method to iterator object in @obj
+func enumDelegate(f MrbFuncT) MrbFuncT {
+	return func(mrb *MrbState, self Value) MrbValue {
+		obj := mrb.IVGet(self, mrb.Intern("@obj"))
+		ret := f(mrb, obj)
+		if mrb.ObjEqual(ret, obj) {
+			return self
+		}
+		return ret
+	}
+}
+
+// seqClass returns GoEnumerator::Seq class, defining GoEnumerator classes on first use.
+// GoEnumerator is Enumerator of Seq#each with external enumeration done by pulling Go iterator,
+// as Fiber based Enumerator#next can not be resumed inside Go function
+func (mrb *MrbState) seqClass() RClass {
+	if mrb.ClassDefined("GoEnumerator") {
+		return mrb.ClassGetUnder(mrb.ClassGet("GoEnumerator"), "Seq")
+	}
+
+	super := mrb.ObjectClass()
+	if mrb.ClassDefined("Enumerator") {
+		super = mrb.ClassGet("Enumerator")
+	}
+	enum := mrb.DefineClass("GoEnumerator", super)
+	enum.DefineMethod("next", enumDelegate(seqNext), ArgsNone())
+	enum.DefineMethod("peek", enumDelegate(seqPeek), ArgsNone())
+	enum.DefineMethod("rewind", enumDelegate(seqRewind), ArgsNone())
+	enum.DefineMethod("size", func(mrb *MrbState, self Value) MrbValue { return nilValue }, ArgsNone())
+
+	seq := mrb.DefineClassUnder(enum, "Seq", mrb.ObjectClass())
+	seq.RegisterGoClass((*goSeq)(nil))
+	seq.Include(mrb.ModuleGet("Enumerable"))
+	seq.DefineMethod("each", seqEach, ArgsBlock())
+	seq.DefineMethod("next", seqNext, ArgsNone())
+	seq.DefineMethod("peek", seqPeek, ArgsNone())
+	seq.DefineMethod("rewind", seqRewind, ArgsNone())
+
+	return seq
+}
+
+// seqValue converts Go iterator function to GoEnumerator, which is lazy Enumerator
+// pulling values from Go iterator as they are needed
+func (mrb *MrbState) seqValue(v reflect.Value) (Value, bool) {
+	pairs, ok := seqType(v.Type())
+	if !ok || v.IsNil() {
+		return nilValue, false
+	}
+
+	mrb.seqClass()
+	seq := mrb.DataValue(&goSeq{seq: v, pairs: pairs})
+
+	if !mrb.ClassDefined("Enumerator") {
+		return seq, true
+	}
+	return mrb.NewInstance("GoEnumerator", seq, mrb.SymbolValue(mrb.Intern("each"))).Value(), true
+}
+
+// Iterate returns Go iterator over values of oruby Enumerable, calling each with block.
+// Multiple values yielded to block are passed to Go as Array.
+//
+//	for v := range mrb.Iterate(list) {
+//		fmt.Println(mrb.String(v))
+//	}
+//
+// Iteration stops when oruby raises an exception, which is left in state and can be
+// retrieved with Exc. Values are protected from garbage collection only during loop body
+func (mrb *MrbState) Iterate(v MrbValue) iter.Seq[Value] {
+	return mrb.IterateMethod(v, "each")
+}
+
+// IterateMethod returns Go iterator over values yielded to block by method called with args
+//
+//	for v := range mrb.IterateMethod(hash, "each_key") { ... }
+//
+// Breaking out of loop throws to catch of iteration with private tag, so oruby iteration is
+// left as with break, running ensure clauses without raising rescuable exception. Without
+// Kernel#catch, iteration is stopped with exception which is cleared on return
+func (mrb *MrbState) IterateMethod(v MrbValue, method string, args ...interface{}) iter.Seq[Value] {
+	return func(yield func(Value) bool) {
+		canThrow := mrb.ObjRespondTo(mrb.KernelModule(), mrb.Intern("catch"))
+		tag, stop := nilValue, nilValue
+		if canThrow {
+			obj, err := mrb.ObjNew(mrb.ObjectClass())
+			if err != nil {
+				return
+			}
+			tag = obj.Value()
+		}
+
+		block := mrb.ProcNewCFunc(func(mrb *MrbState, self Value) MrbValue {
+			blockArgs := mrb.GetArgs()
+
+			var item Value
+			if blockArgs.Len() == 1 {
+				item = blockArgs.Item(0)
+			} else {
+				item = mrb.AryNewFromValues(blockArgs.Slice()...).Value()
+			}
+
+			if yield(item) {
+				return nilValue
+			}
+			if canThrow {
+				return mrb.Call(mrb.TopSelf(), "throw", tag)
+			}
+			stop = mrb.Raise(mrb.ERuntimeError(), "Go iteration stopped")
+			return stop
+		})
+		call := append(args[:len(args):len(args)], block)
+
+		if canThrow {
+			body := mrb.ProcNewCFunc(func(mrb *MrbState, self Value) MrbValue {
+				ret, _ := mrb.FuncallWithBlock(v, mrb.Intern(method), call...)
+				return ret
+			})
+			mrb.FuncallWithBlock(mrb.TopSelf(), mrb.Intern("catch"), tag, body)
+			return
+		}
+
+		ret, _ := mrb.FuncallWithBlock(v, mrb.Intern(method), call...)
+		if stop.Type() == MrbTTException && mrb.raised(ret) && mrb.ObjEqual(ret, stop) {
+			mrb.ExcClear()
+		}
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !go1.23
+
+package oruby
+
+import "reflect"
+
+// seqValue converts Go iterator functions to GoEnumerator with Go 1.23 and later
+func (mrb *MrbState) seqValue(v reflect.Value) (Value, bool) {
+	return nilValue, false
+}
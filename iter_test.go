@@ -0,0 +1,136 @@
+//go:build go1.23
+
+package oruby
+
+import (
+	"iter"
+	"maps"
+	"slices"
+	"testing"
+)
+
+func countTo(n int) iter.Seq[int] {
+	return func(yield func(int) bool) {
+		for i := 1; i <= n; i++ {
+			if !yield(i) {
+				return
+			}
+		}
+	}
+}
+
+func TestMrbState_SeqValue(t *testing.T) {
+	mrb := MrbOpen()
+	defer mrb.Close()
+
+	mrb.SingletonClass(mrb.TopSelf()).DefineMethodFunc("count_to", countTo)
+
+	v, err := mrb.Eval(`count_to(5).map { |i| i * 2 }`)
+	ExpectNilError(t, err)
+	ExpectEql(t, mrb.Intf(v), []interface{}{2, 4, 6, 8, 10})
+
+	// Lazy enumeration does not exhaust iterator
+	v, err = mrb.Eval(`count_to(1_000_000_000).lazy.select(&:even?).first(2)`)
+	ExpectNilError(t, err)
+	ExpectEql(t, mrb.Intf(v), []interface{}{2, 4})
+
+	v, err = mrb.Eval(`count_to(10).each { |i| break i * 10 if i == 3 }`)
+	ExpectNilError(t, err)
+	ExpectEql(t, mrb.Intf(v), 30)
+
+	v, err = mrb.Eval(`
+		e = count_to(2)
+		r = [e.next, e.peek, e.next]
+		begin
+			e.next
+		rescue StopIteration
+			r << :stop
+		end
+		e.rewind
+		r << e.next
+	`)
+	ExpectNilError(t, err)
+	ExpectEql(t, mrb.Intf(v), []interface{}{1, 2, 2, "stop", 1})
+
+	_, err = mrb.Eval(`count_to(3).each { |i| raise "fail" if i == 2 }`)
+	ExpectErr(t, err, "exception from block must be raised")
+}
+
+func TestMrbState_SeqValuePairs(t *testing.T) {
+	mrb := MrbOpen()
+	defer mrb.Close()
+
+	m := map[string]int{"a": 1}
+	mrb.SingletonClass(mrb.TopSelf()).DefineMethodFunc("pairs", func() iter.Seq2[string, int] { return maps.All(m) })
+
+	v, err := mrb.Eval(`r = []; pairs.each { |k, v| r << k << v }; r + [pairs.next]`)
+	ExpectNilError(t, err)
+	ExpectEql(t, mrb.Intf(v), []interface{}{"a", 1, []interface{}{"a", 1}})
+}
+
+func TestMrbState_Iterate(t *testing.T) {
+	mrb := MrbOpen()
+	defer mrb.Close()
+
+	v, _ := mrb.Eval(`(1..10)`)
+
+	var got []int
+	for item := range mrb.Iterate(v) {
+		got = append(got, MrbFixnum(item))
+		if len(got) == 3 {
+			break
+		}
+	}
+	ExpectEql(t, got, []int{1, 2, 3})
+	Expect(t, mrb.Exc() == nil, "stopping iteration must not leave exception")
+
+	h, _ := mrb.Eval(`{a: 1, b: 2}`)
+	var pairs []interface{}
+	for item := range mrb.Iterate(h) {
+		pairs = append(pairs, mrb.Intf(item))
+	}
+	ExpectEql(t, pairs, []interface{}{[]interface{}{"a", 1}, []interface{}{"b", 2}})
+
+	keys := slices.Collect(func(yield func(string) bool) {
+		for k := range mrb.IterateMethod(h, "each_key") {
+			if !yield(mrb.String(k)) {
+				return
+			}
+		}
+	})
+	ExpectEql(t, keys, []string{"a", "b"})
+}
+
+func TestMrbState_IterateStop(t *testing.T) {
+	mrb := MrbOpen()
+	defer mrb.Close()
+
+	obj, err := mrb.Eval(`
+		class Guarded
+		  def each
+		    [1, 2, 3].each { |i| yield i }
+		    $finished = true
+		  rescue => e
+		    $rescued = e
+		  ensure
+		    $ensured = true
+		  end
+		end
+		Guarded.new
+	`)
+	ExpectNilError(t, err)
+
+	var got []int
+	for item := range mrb.Iterate(obj) {
+		got = append(got, MrbFixnum(item))
+		if len(got) == 2 {
+			break
+		}
+	}
+	ExpectEql(t, got, []int{1, 2})
+	Expect(t, mrb.Exc() == nil, "stopping iteration must not leave exception")
+
+	v, err := mrb.Eval(`[$finished, $rescued, $ensured]`)
+	ExpectNilError(t, err)
+	ExpectEql(t, mrb.Intf(v), []interface{}{nil, nil, true})
+}
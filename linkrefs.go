@@ -5,114 +5,227 @@ import "C"
 import (
 	"runtime"
 	"sync"
+	"sync/atomic"
 	"unsafe"
 )
 import "errors"
 
-var mu sync.Mutex
-var states []*MrbState
+// States are kept in global table, so they can be retrieved from C callbacks by index stored
+// in C state. Table is made of fixed size chunks which never move, and chunk directory is
+// replaced on growth, so lookups are lock free. Index 0 is never used
+const (
+	stateChunkBits = 8
+	stateChunkSize = 1 << stateChunkBits
+)
+
+type stateChunk [stateChunkSize]atomic.Pointer[MrbState]
 
-func init() {
-	states = make([]*MrbState, 1, 10)
+type stateTable struct {
+	mu     sync.Mutex // guards growth and free list
+	chunks atomic.Pointer[[]*stateChunk]
+	free   []int
+	next   int
 }
 
-// GetMrbState returns Go MrbState from C.mrb_state reference
-func getMrbState(cmrb *C.struct_mrb_state) *MrbState {
-	idx := int(C._mrb_get_idx(cmrb))
-	if idx >= len(states) {
-		panic("State index is out of range")
+var states = newStateTable()
+
+func newStateTable() *stateTable {
+	t := &stateTable{next: 1}
+	chunks := []*stateChunk{new(stateChunk)}
+	t.chunks.Store(&chunks)
+	return t
+}
+
+// get returns state at index, or nil when index is out of range or slot is empty
+func (t *stateTable) get(idx int) *MrbState {
+	if idx <= 0 {
+		return nil
 	}
+	chunks := *t.chunks.Load()
+	c := idx >> stateChunkBits
+	if c >= len(chunks) {
+		return nil
+	}
+	return chunks[c][idx&(stateChunkSize-1)].Load()
+}
 
-	if states[idx] == nil {
-		panic(errors.New("State does not exists"))
+// add stores state in released slot or in new one, and returns its index
+func (t *stateTable) add(mrb *MrbState) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var idx int
+	if n := len(t.free); n > 0 {
+		idx = t.free[n-1]
+		t.free = t.free[:n-1]
+	} else {
+		idx = t.next
+		t.next++
+	}
+
+	chunks := *t.chunks.Load()
+	if c := idx >> stateChunkBits; c >= len(chunks) {
+		grown := make([]*stateChunk, c+1)
+		copy(grown, chunks)
+		for i := len(chunks); i <= c; i++ {
+			grown[i] = new(stateChunk)
+		}
+		t.chunks.Store(&grown)
+		chunks = grown
 	}
 
-	return states[idx]
+	chunks[idx>>stateChunkBits][idx&(stateChunkSize-1)].Store(mrb)
+	return idx
 }
 
-// GetMrbState returns Go MrbState from C.mrb_state reference
-func getMrbStateIndex(idx int) *MrbState {
-	if idx >= len(states) {
-		panic("State index is out of range")
+// remove releases slot of state at index for reuse
+func (t *stateTable) remove(idx int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	chunks := *t.chunks.Load()
+	c := idx >> stateChunkBits
+	if idx <= 0 || c >= len(chunks) {
+		return
 	}
+	if chunks[c][idx&(stateChunkSize-1)].Swap(nil) != nil {
+		t.free = append(t.free, idx)
+	}
+}
 
-	if states[idx] == nil {
+// len returns number of registered states
+func (t *stateTable) len() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.next - 1 - len(t.free)
+}
+
+// GetMrbState returns Go MrbState from C.mrb_state reference
+func getMrbState(cmrb *C.struct_mrb_state) *MrbState {
+	return getMrbStateIndex(int(C._mrb_get_idx(cmrb)))
+}
+
+// getMrbStateIndex returns Go MrbState from state index
+func getMrbStateIndex(idx int) *MrbState {
+	mrb := states.get(idx)
+	if mrb == nil {
 		panic(errors.New("State does not exists"))
 	}
 
-	return states[idx]
+	return mrb
 }
 
 // GetMrbState returns Go MrbState from C.mrb_state reference
 func GetMrbState(cmrb uintptr) *MrbState {
-	idx := int(C._cmrb_get_idx(C.uintptr_t(cmrb)))
-	if idx >= len(states) {
-		panic("State index is out of range")
-	}
+	return getMrbStateIndex(int(C._cmrb_get_idx(C.uintptr_t(cmrb))))
+}
 
-	if states[idx] == nil {
-		panic(errors.New("State does not exists"))
+func registerState(mrb *MrbState) {
+	idx := states.add(mrb)
+	C._mrb_set_idx(mrb.state(), C.mrb_int(idx))
+}
+
+func removeStateIndex(index int) {
+	states.remove(index)
+}
+
+// funcRegistry keeps Go functions called from oruby procs. Procs reference functions
+// by handle index, and handles of garbage collected procs are reused
+type funcRegistry struct {
+	sync.Mutex
+	items []interface{}
+	free  []int
+}
+
+func (r *funcRegistry) add(f interface{}) int {
+	r.Lock()
+	defer r.Unlock()
+
+	if n := len(r.free); n > 0 {
+		idx := r.free[n-1]
+		r.free = r.free[:n-1]
+		r.items[idx] = f
+		return idx
 	}
 
-	return states[idx]
+	r.items = append(r.items, f)
+	return len(r.items) - 1
 }
 
-func registerState(mrb *MrbState) {
-	mu.Lock()
-	defer mu.Unlock()
-
-	if len(states) > 500 {
-		for idx, state := range states {
-			if (state == nil) && (idx > 0) {
-				states[idx] = state
-				C._mrb_set_idx(mrb.p, C.mrb_int(idx))
-				return
-			}
-		}
+func (r *funcRegistry) get(idx int) (interface{}, bool) {
+	r.Lock()
+	defer r.Unlock()
+
+	if idx < 0 || idx >= len(r.items) || r.items[idx] == nil {
+		return nil, false
 	}
+	return r.items[idx], true
+}
+
+func (r *funcRegistry) release(idx int) {
+	r.Lock()
+	defer r.Unlock()
 
-	states = append(states, mrb)
-	idx := len(states) - 1
-	C._mrb_set_idx(mrb.p, C.mrb_int(idx))
-	return
+	if idx < 0 || idx >= len(r.items) || r.items[idx] == nil {
+		return
+	}
+	r.items[idx] = nil
+	r.free = append(r.free, idx)
 }
 
-func removeStateIndex(index int) {
-	mu.Lock()
-	defer mu.Unlock()
-	states[index] = nil
+func (r *funcRegistry) reset() {
+	r.Lock()
+	defer r.Unlock()
+
+	r.items, r.free = nil, nil
 }
 
+// registerFunc registers function and returns handle object for proc env
 func (mrb *MrbState) registerFunc(f interface{}) C.mrb_value {
-	mrb.funcs = append(mrb.funcs, f)
-	return C.mrb_fixnum_value((C.mrb_int)(len(mrb.funcs) - 1))
+	return C._mrb_handle_new(mrb.state(), C.int(mrb.funcs.add(f)))
 }
 
+// registerFuncIndex registers function and returns handle index,
+// handle object is created by C helper defining method
 func (mrb *MrbState) registerFuncIndex(f interface{}) int {
-	mrb.funcs = append(mrb.funcs, f)
-	return len(mrb.funcs) - 1
+	return mrb.funcs.add(f)
 }
 
 func (mrb *MrbState) getFunc(index uint) (interface{}, error) {
-	if index >= uint(len(mrb.funcs)) {
+	f, ok := mrb.funcs.get(int(index))
+	if !ok {
 		return nil, errors.New("Function index is out of range")
 	}
 
-	return mrb.funcs[index], nil
+	return f, nil
 }
 
 func (mrb *MrbState) getMrbFuncT(index uint) MrbFuncT {
-	if index >= uint(len(mrb.funcs)) {
-		return nil
-	}
-	f, ok := mrb.funcs[index].(MrbFuncT)
+	f, _ := mrb.funcs.get(int(index))
+	fx, ok := f.(MrbFuncT)
 	if !ok {
 		return nil
 	}
 
+	return fx
+}
+
+// procFunc returns Go function called by proc, or nil for other procs
+func (mrb *MrbState) procFunc(p *C.struct_RProc) interface{} {
+	idx := int(C._mrb_proc_func_handle(p))
+	if idx < 0 {
+		return nil
+	}
+	f, _ := mrb.funcs.get(idx)
 	return f
 }
 
+//export mrb_free_gohandle
+func mrb_free_gohandle(cmrb *C.mrb_state, idx C.int) {
+	getMrbState(cmrb).funcs.release(int(idx))
+}
+
 func (mrb *MrbState) setHook(p unsafe.Pointer, v interface{}) {
 	mrb.Lock()
 	defer mrb.Unlock()
@@ -135,3 +248,28 @@ func (mrb *MrbState) getHook(p unsafe.Pointer) interface{} {
 
 	return mrb.hooks[p]
 }
+
+// RegistryStats is number of Go values referenced from oruby state
+type RegistryStats struct {
+	Funcs     int // Go functions referenced from live procs and methods
+	FreeFuncs int // released function handles, reused by new functions
+	Hooks     int // Go values attached to oruby objects
+}
+
+// RegistryStats returns number of Go values kept for oruby state.
+// Functions are released when their procs are garbage collected,
+// so counts of long running state do not grow without bound
+func (mrb *MrbState) RegistryStats() RegistryStats {
+	mrb.funcs.Lock()
+	stats := RegistryStats{
+		Funcs:     len(mrb.funcs.items) - len(mrb.funcs.free),
+		FreeFuncs: len(mrb.funcs.free),
+	}
+	mrb.funcs.Unlock()
+
+	mrb.Lock()
+	stats.Hooks = len(mrb.hooks)
+	mrb.Unlock()
+
+	return stats
+}
@@ -0,0 +1,153 @@
+package oruby
+
+import (
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestMrbState_RegistryStats(t *testing.T) {
+	mrb, _ := NewCore()
+	defer mrb.Close()
+
+	mrb.FullGC()
+	before := mrb.RegistryStats()
+
+	for i := 0; i < 1000; i++ {
+		ai := mrb.GCArenaSave()
+		mrb.ProcNewCFunc(func(mrb *MrbState, self Value) MrbValue { return nilValue })
+		mrb.ProcNewCFuncWithEnv(func(mrb *MrbState, self Value) MrbValue { return nilValue }, Int(i))
+		mrb.ProcNewGofunc(func(i int) int { return i })
+		mrb.Value(strings.ToUpper)
+		mrb.GCArenaRestore(ai)
+	}
+
+	mrb.FullGC()
+	after := mrb.RegistryStats()
+
+	Expect(t, after.Funcs <= before.Funcs, "registered functions should be released, got %v, expected %v", after.Funcs, before.Funcs)
+	Expect(t, after.Funcs+after.FreeFuncs <= before.Funcs+before.FreeFuncs+4, "released handles should be reused, got %v", after.Funcs+after.FreeFuncs)
+}
+
+func TestMrbState_ProcFuncAfterGC(t *testing.T) {
+	mrb, _ := NewCore()
+	defer mrb.Close()
+
+	proc := mrb.ProcNewCFuncWithEnv(func(mrb *MrbState, self Value) MrbValue {
+		return mrb.ProcCFuncEnvGet(0)
+	}, Int(42))
+	mrb.GVSet(mrb.Intern("$proc"), proc.Value())
+
+	for i := 0; i < 100; i++ {
+		ai := mrb.GCArenaSave()
+		mrb.ProcNewCFunc(func(mrb *MrbState, self Value) MrbValue { return nilValue })
+		mrb.GCArenaRestore(ai)
+	}
+	mrb.FullGC()
+
+	v, err := mrb.Eval("$proc.call")
+	ExpectNilError(t, err)
+	ExpectEql(t, v.Int(), 42)
+}
+
+func TestMrbState_HandlesHidden(t *testing.T) {
+	mrb := MrbOpen()
+	defer mrb.Close()
+
+	if !mrb.ClassDefined("ObjectSpace") {
+		t.Skip("ObjectSpace is not available")
+	}
+
+	count := func() int {
+		v, err := mrb.Eval(`n = 0; ObjectSpace.each_object(Object) { |o| n += 1 if o.class == Object }; n`)
+		ExpectNilError(t, err)
+		return v.Int()
+	}
+
+	before := count()
+	procs := mrb.AryNew()
+	for i := 0; i < 100; i++ {
+		procs.Push(mrb.ProcNewGofunc(func(i int) int { return i }).Value())
+	}
+	mrb.GVSet(mrb.Intern("$procs"), procs.Value())
+
+	// handles of Go functions are not seen as plain Objects
+	Expect(t, count() < before+100, "Go function handles should be hidden from ObjectSpace")
+}
+
+func TestMrbState_DefineModuleFunc(t *testing.T) {
+	mrb, _ := NewCore()
+	defer mrb.Close()
+
+	m := mrb.DefineModule("Texts")
+	mrb.DefineModuleFunc(m, "upper", strings.ToUpper)
+	mrb.DefineClassFunc(m, "lower", strings.ToLower)
+
+	v, err := mrb.Eval("Texts.upper('go') + Texts.lower('RUBY')")
+	ExpectNilError(t, err)
+	ExpectEql(t, mrb.String(v), "GOruby")
+}
+
+func TestStateTable(t *testing.T) {
+	tbl := newStateTable()
+	list := make([]*MrbState, 1000)
+	idx := make([]int, len(list))
+
+	for i := range list {
+		list[i] = &MrbState{}
+		idx[i] = tbl.add(list[i])
+		Expect(t, idx[i] > 0, "index 0 is reserved")
+	}
+	for i := range list {
+		Expect(t, tbl.get(idx[i]) == list[i], "state %v not found at %v", i, idx[i])
+	}
+	ExpectEql(t, tbl.len(), len(list))
+
+	tbl.remove(idx[10])
+	tbl.remove(idx[10])
+	Expect(t, tbl.get(idx[10]) == nil, "removed state should not be found")
+
+	mrb := &MrbState{}
+	ExpectEql(t, tbl.add(mrb), idx[10])
+	Expect(t, tbl.get(idx[10]) == mrb, "released slot should be reused with new state")
+	ExpectEql(t, tbl.len(), len(list))
+
+	Expect(t, tbl.get(0) == nil, "index 0 should be empty")
+	Expect(t, tbl.get(1<<20) == nil, "index out of range should be empty")
+}
+
+func TestStateTableConcurrent(t *testing.T) {
+	tbl := newStateTable()
+	var wg sync.WaitGroup
+
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				mrb := &MrbState{}
+				idx := tbl.add(mrb)
+				if tbl.get(idx) != mrb {
+					t.Errorf("state not found at %v", idx)
+					return
+				}
+				tbl.remove(idx)
+			}
+		}()
+	}
+	wg.Wait()
+
+	ExpectEql(t, tbl.len(), 0)
+	Expect(t, tbl.next <= 9, "slots should be reused, %v allocated", tbl.next-1)
+}
+
+func TestMrbState_CloseReleasesState(t *testing.T) {
+	before := states.len()
+	for i := 0; i < 600; i++ {
+		mrb, err := NewCore()
+		ExpectNilError(t, err)
+		Expect(t, getMrbState(mrb.p) == mrb, "state should be registered")
+		mrb.Close()
+	}
+	ExpectEql(t, states.len(), before)
+}
@@ -52,17 +52,21 @@ type MrbState struct {
 	sync.Mutex
 	stack        int32
 	WaitGroup    sync.WaitGroup
-	mrbProcs     map[unsafe.Pointer]MrbFuncT
 	classmap     map[reflect.Type]unsafe.Pointer
 	hooks        map[unsafe.Pointer]interface{}
-	funcs        []interface{}
+	funcs        funcRegistry
 	matrix       [][]interface{}
 	exitChan     chan struct{}
-	injectVMChan chan RProc
 	InjectChan   chan RProc
-	features     map[string]interface{} // features stash
-	callSym      MrbSym                 // cached mrb.Intern("call")
-	afterInitSym MrbSym                 // cached mrb.Intern("after_init")
+	features     map[string]interface{}           // features stash
+	callSym      MrbSym                           // cached mrb.Intern("call")
+	afterInitSym MrbSym                           // cached mrb.Intern("after_init")
+	converters   atomic.Pointer[[]*typeConverter] // registered type converters
+	convertErr   *error                           // first converter error, taken by ValueErr
+	owner        ownerState                       // owner goroutine and executor
+	trace        traceHooks                       // VM trace hooks
+	profile      atomic.Pointer[cpuProfile]       // running CPU profile
+	coverage     *coverage                        // running coverage
 }
 
 // NewCore create state is MrbState without gems,
@@ -78,17 +82,21 @@ func NewCore() (*MrbState, error) {
 		sync.Mutex{},
 		0,
 		sync.WaitGroup{},
-		make(map[unsafe.Pointer]MrbFuncT),
 		make(map[reflect.Type]unsafe.Pointer),
 		make(map[unsafe.Pointer]interface{}),
-		make([]interface{}, 0, 255),
+		funcRegistry{},
 		make([][]interface{}, 500),
 		make(chan struct{}),
 		nil,
-		nil,
 		make(map[string]interface{}),
 		0,
 		0,
+		atomic.Pointer[[]*typeConverter]{},
+		nil,
+		newOwnerState(),
+		traceHooks{},
+		atomic.Pointer[cpuProfile]{},
+		nil,
 	}
 
 	mrb.matrix[0] = make([]interface{}, 500)
@@ -227,54 +235,34 @@ func (mrb *MrbState) ExitChan() chan struct{} {
 	return mrb.exitChan
 }
 
-//export inject_run
-func inject_run(idx C.mrb_int) *C.struct_RProc {
-	mrb := getMrbStateIndex(int(idx))
-
-	select {
-	case <-mrb.exitChan:
-		return nil
-	case proc, ok := <-mrb.injectVMChan:
-		if ok {
-			// C.mrb_funcall_with_block() is done on C side
-			return proc.p
-		}
-		return nil
-	default:
-		return nil
-	}
-}
-
 // InjectFunc of MrbFuncT code from goroutine, thread or signal handler to be executed in mrb
 func (mrb *MrbState) InjectFunc(f MrbFuncT) {
-	mrb.Inject(mrb.ProcNewCFunc(f))
+	mrb.lockState()
+
+	var proc RProc
+	mrb.Do(func(mrb *MrbState) { proc = mrb.ProcNewCFunc(f) })
+	mrb.Inject(proc)
 }
 
-// Inject code from goroutine, thread or signal handler to be executed in mrb
+// Inject code from goroutine, thread or signal handler to be executed in mrb.
+// Injector turns on state lock, like StartExecutor
 func (mrb *MrbState) Inject(proc RProc) {
 	if atomic.LoadInt32(&mrb.stack) == 0 {
 		mrb.startInjector()
 	}
 
-	// mrb->jmp == 0 if no vm code is executing
-	// This will be picked from main executing goroutine
-	if mrb.p.jmp == nil {
-		mrb.InjectChan <- proc
-		return
-	}
-
-	// Execute procedure via inject_run VM debug hook
-	mrb.injectVMChan <- proc
+	// Injector runs proc holding the state lock, or passes it to
+	// goroutine running oruby code, to be run when it calls Go function
+	mrb.InjectChan <- proc
 }
 
 // startInjector for code to be executed from goroutines in main mrb
 func (mrb *MrbState) startInjector() {
 	mrb.Lock()
 	mrb.InjectChan = make(chan RProc)
-	mrb.injectVMChan = make(chan RProc)
-	C.set_mrb_injector(mrb.p)
 	atomic.StoreInt32(&mrb.stack, 1)
 	mrb.Unlock()
+	mrb.lockState()
 
 	// Main thread executor
 	go func() {
@@ -284,14 +272,13 @@ func (mrb *MrbState) startInjector() {
 				if !ok {
 					return
 				}
-				mrb.Lock()
-				f, ok := mrb.mrbProcs[unsafe.Pointer(proc.p)]
-				mrb.Unlock()
-				if ok {
-					f(mrb, proc.Value())
-					return
-				}
-				C._mrb_funcall_with_block(mrb.p, C.mrb_obj_value(unsafe.Pointer(proc.p)), C.mrb_sym(mrb.callSym), C.mrb_int(0), nil, Nil.v)
+				mrb.Do(func(mrb *MrbState) {
+					if f, ok := mrb.procFunc(proc.p).(MrbFuncT); ok {
+						f(mrb, proc.Value())
+						return
+					}
+					C._mrb_funcall_with_block(mrb.state(), C.mrb_obj_value(unsafe.Pointer(proc.p)), C.mrb_sym(mrb.callSym), C.mrb_int(0), nil, Nil.v)
+				})
 			}
 		}
 	}()
@@ -325,26 +312,30 @@ func (mrb *MrbState) Close() {
 		// mrb.WaitGroup.Done() directly or via mrb.InjectChan<-mrb.WaitGroupDone() helper
 		mrb.WaitGroup.Wait()
 
-		if mrb.injectVMChan != nil {
-			close(mrb.injectVMChan)
-		}
 		if mrb.InjectChan != nil {
 			close(mrb.InjectChan)
 		}
 
-		idx := int(C._mrb_get_idx(mrb.p))
-		C.mrb_close(mrb.p)
-
-		mu.Lock()
-		states[idx] = nil
-		mu.Unlock()
+		// C state is closed holding state lock, when oruby code is not executed
+		closeState := func() {
+			mrb.closeOwner()
+			idx := int(C._mrb_get_idx(mrb.state()))
+			C._mrb_hook_free(mrb.state())
+			if mrb.coverage != nil {
+				mrb.coverage.free(mrb)
+				mrb.coverage = nil
+			}
+			C.mrb_close(mrb.state())
+			removeStateIndex(idx)
+		}
+		mrb.exclusive(closeState)
+		mrb.stopExecutor()
 
 		mrb.p = nil
 
-		mrb.mrbProcs = nil
 		mrb.classmap = nil
 		mrb.hooks = nil
-		mrb.funcs = nil
+		mrb.funcs.reset()
 		mrb.features = nil
 	}
 }
@@ -373,8 +364,15 @@ func p(str *C.char) {
 	print(C.GoString(str))
 }
 
-// Value converts Go interface to oruby value
+// Value converts Go interface to oruby value. Values which registered converter
+// fails to convert are nil, use ValueErr to get the error
 func (mrb *MrbState) Value(o interface{}) Value {
+	if len(mrb.converterList()) > 0 {
+		if v, ok := mrb.convertedValue(o); ok {
+			return v
+		}
+	}
+
 	switch v := o.(type) {
 	case nil:
 		return nilValue
@@ -396,11 +394,11 @@ func (mrb *MrbState) Value(o interface{}) Value {
 	case int64:
 		return Value{C.mrb_fixnum_value(C.mrb_int(v))}
 	case uint:
-		return Value{C.mrb_fixnum_value(C.mrb_int(v))}
+		return mrb.uintValue(uint64(v))
 	case uint32:
 		return Value{C.mrb_fixnum_value(C.mrb_int(v))}
 	case uint64:
-		return Value{C.mrb_fixnum_value(C.mrb_int(v))}
+		return mrb.uintValue(uint64(v))
 	case uint8:
 		return Value{C.mrb_fixnum_value(C.mrb_int(v))}
 	case uint16:
@@ -414,7 +412,7 @@ func (mrb *MrbState) Value(o interface{}) Value {
 	case uintptr:
 		return mrb.CPtrValue(v)
 	case unsafe.Pointer:
-		return Value{C.mrb_cptr_value(mrb.p, v)}
+		return Value{C.mrb_cptr_value(mrb.state(), v)}
 	case []byte:
 		return mrb.BytesValue(v)
 	case map[string]interface{}:
@@ -478,21 +476,27 @@ func (mrb *MrbState) Value(o interface{}) Value {
 }
 
 func (mrb *MrbState) valueValue(v reflect.Value) Value {
+	if len(mrb.converterList()) > 0 && v.IsValid() && v.CanInterface() {
+		if cv, ok := mrb.convertedValue(v.Interface()); ok {
+			return cv
+		}
+	}
+
 	switch v.Kind() {
 	case reflect.Invalid:
 		return Value{C.mrb_nil_value()}
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32:
 		return Value{C.mrb_fixnum_value(C.mrb_int(v.Int()))}
-	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32:
 		return Value{C.mrb_fixnum_value(C.mrb_int(v.Uint()))}
 	case reflect.Int64:
 		return Value{C.mrb_fixnum_value(C.mrb_int(v.Int()))}
-	case reflect.Uint64:
-		return Value{C.mrb_fixnum_value(C.mrb_int(v.Uint()))}
+	case reflect.Uint, reflect.Uint64:
+		return mrb.uintValue(v.Uint())
 	case reflect.Uintptr:
-		return Value{C._mrb_uintptr_value(mrb.p, (C.uintptr_t)(v.Interface().(uintptr)))}
+		return Value{C._mrb_uintptr_value(mrb.state(), (C.uintptr_t)(v.Interface().(uintptr)))}
 	case reflect.UnsafePointer:
-		return Value{C._mrb_uintptr_value(mrb.p, C.uintptr_t(v.Pointer()))}
+		return Value{C._mrb_uintptr_value(mrb.state(), C.uintptr_t(v.Pointer()))}
 	case reflect.Float32, reflect.Float64:
 		return mrb.FloatValue(v.Float())
 	case reflect.Complex64, reflect.Complex128:
@@ -533,6 +537,9 @@ func (mrb *MrbState) valueValue(v reflect.Value) Value {
 		return mrb.DataValue(v.Interface())
 
 	case reflect.Func:
+		if seq, ok := mrb.seqValue(v); ok {
+			return seq
+		}
 		switch f := v.Interface().(type) {
 		case MrbFuncT:
 			return mrb.ProcNewCFunc(f).Value()
@@ -594,6 +601,13 @@ func (mrb *MrbState) String(v MrbValue) string {
 
 // Intf converts oruby value to Go interface
 func (mrb *MrbState) Intf(o MrbValue) interface{} {
+	switch o.Type() {
+	case C.MRB_TT_OBJECT, C.MRB_TT_DATA, C.MRB_TT_ISTRUCT:
+		if v, ok := mrb.convertedIntf(o); ok {
+			return v
+		}
+	}
+
 	switch o.Type() {
 	case C.MRB_TT_FALSE:
 		if C._mrb_fixnum(o.Value().v) == 0 {
@@ -628,9 +642,8 @@ func (mrb *MrbState) Intf(o MrbValue) interface{} {
 	case C.MRB_TT_CLASS, C.MRB_TT_MODULE, C.MRB_TT_SCLASS:
 		return RClass{MrbClassPtr(o).p, mrb}
 	case C.MRB_TT_PROC:
-		if C._mrb_proc_has_env(mrb.p, MrbProcPtr(o).p) != false {
-			fv := C._mrb_proc_env_get(mrb.p, MrbProcPtr(o).p, C.mrb_int(0))
-			if f, _ := mrb.getFunc(uint(C._mrb_fixnum(fv))); f != nil {
+		if f := mrb.procFunc(MrbProcPtr(o).p); f != nil {
+			if _, ok := f.(MrbFuncT); !ok {
 				return f
 			}
 		}
@@ -672,7 +685,7 @@ func (mrb *MrbState) Intf(o MrbValue) interface{} {
 		for i := 0; i < kcnt; i++ {
 			key := mrb.AryRef(keys, i)
 			val := mrb.HashGet(o, key)
-			hash[mrb.Intf(key)] = mrb.Intf(val)
+			hash[mrb.mapKey(key, mrb.Intf(key))] = mrb.Intf(val)
 		}
 		return hash
 
@@ -736,6 +749,10 @@ func (mrb *MrbState) Run(code string, args ...interface{}) RValue {
 
 // Eval evaluates code string and returns calculated result
 func (mrb *MrbState) Eval(code string) (result RValue, err error) {
+	if mrb.remote(func() { result, err = mrb.Eval(code) }) {
+		return result, err
+	}
+
 	//	defer errorHandler(&err)
 	mrb.ExcClear()
 
@@ -748,7 +765,7 @@ func (mrb *MrbState) Eval(code string) (result RValue, err error) {
 		return RValue{nilValue.v, mrb}, err
 	}
 
-	result = RValue{C.mrb_load_exec(mrb.p, p.p, cxt.p), mrb}
+	result = RValue{C.mrb_load_exec(mrb.state(), p.p, cxt.p), mrb}
 
 	// Check parse errors
 	//if p.NErr() > 0 {
@@ -760,12 +777,12 @@ func (mrb *MrbState) Eval(code string) (result RValue, err error) {
 	//	return RValue{nilValue.v, mrb}, errors.New(estr)
 	//}
 	//
-	//proc := C.mrb_generate_code(mrb.p, p.p)
+	//proc := C.mrb_generate_code(mrb.state(), p.p)
 	//if proc == nil {
 	//	return RValue{nilValue.v, mrb}, mrb.Err()
 	//}
 
-	//result = RValue{C.mrb_top_run(mrb.p, proc, C.mrb_top_self(mrb.p), 0), mrb}
+	//result = RValue{C.mrb_top_run(mrb.state(), proc, C.mrb_top_self(mrb.state()), 0), mrb}
 
 	return result, mrb.Err()
 }
@@ -777,16 +794,24 @@ func (mrb *MrbState) Eval(code string) (result RValue, err error) {
 
 // Exc returns oruby error
 func (mrb *MrbState) Exc() *RException {
-	if mrb.p.exc == nil {
+	if mrb.state().exc == nil {
 		return nil
 	}
 
-	return &RException{RValue{C.mrb_obj_value(unsafe.Pointer(mrb.p.exc)), mrb}}
+	return &RException{RValue{C.mrb_obj_value(unsafe.Pointer(mrb.state().exc)), mrb}}
+}
+
+// raised reports if v is exception or non-local jump (break from block) pending in state,
+// as returned from protected calls like Yield or FuncallWithBlock
+func (mrb *MrbState) raised(v Value) bool {
+	t := v.Type()
+	return (t == MrbTTException || t == MrbTTBreak) && mrb.state().exc != nil &&
+		C._mrb_ptr(v.v) == unsafe.Pointer(mrb.state().exc)
 }
 
 // ExcClear clear last exception
 func (mrb *MrbState) ExcClear() {
-	mrb.p.exc = nil
+	mrb.state().exc = nil
 }
 
 // Unwrap returns only Go error from oruby state
@@ -810,79 +835,79 @@ func (mrb *MrbState) Err() error {
 }
 
 // ObjectClass in state
-func (mrb *MrbState) ObjectClass() RClass { return RClass{mrb.p.object_class, mrb} }
+func (mrb *MrbState) ObjectClass() RClass { return RClass{mrb.state().object_class, mrb} }
 
 // ClassClass in state
-func (mrb *MrbState) ClassClass() RClass { return RClass{mrb.p.class_class, mrb} }
+func (mrb *MrbState) ClassClass() RClass { return RClass{mrb.state().class_class, mrb} }
 
 // ModuleClass in state
-func (mrb *MrbState) ModuleClass() RClass { return RClass{mrb.p.module_class, mrb} }
+func (mrb *MrbState) ModuleClass() RClass { return RClass{mrb.state().module_class, mrb} }
 
 // ProcClass in state
-func (mrb *MrbState) ProcClass() RClass { return RClass{mrb.p.proc_class, mrb} }
+func (mrb *MrbState) ProcClass() RClass { return RClass{mrb.state().proc_class, mrb} }
 
 // StringClass in state
-func (mrb *MrbState) StringClass() RClass { return RClass{mrb.p.string_class, mrb} }
+func (mrb *MrbState) StringClass() RClass { return RClass{mrb.state().string_class, mrb} }
 
 // ArrayClass in state
-func (mrb *MrbState) ArrayClass() RClass { return RClass{mrb.p.array_class, mrb} }
+func (mrb *MrbState) ArrayClass() RClass { return RClass{mrb.state().array_class, mrb} }
 
 // HashClass in state
-func (mrb *MrbState) HashClass() RClass { return RClass{mrb.p.hash_class, mrb} }
+func (mrb *MrbState) HashClass() RClass { return RClass{mrb.state().hash_class, mrb} }
 
 // FloatClass in state
-func (mrb *MrbState) FloatClass() RClass { return RClass{mrb.p.float_class, mrb} }
+func (mrb *MrbState) FloatClass() RClass { return RClass{mrb.state().float_class, mrb} }
 
 // FixnumClass in state
-func (mrb *MrbState) FixnumClass() RClass { return RClass{mrb.p.integer_class, mrb} }
+func (mrb *MrbState) FixnumClass() RClass { return RClass{mrb.state().integer_class, mrb} }
 
 // IntegerClass in state
-func (mrb *MrbState) IntegerClass() RClass { return RClass{mrb.p.integer_class, mrb} }
+func (mrb *MrbState) IntegerClass() RClass { return RClass{mrb.state().integer_class, mrb} }
 
 // TrueClass in state
-func (mrb *MrbState) TrueClass() RClass { return RClass{mrb.p.true_class, mrb} }
+func (mrb *MrbState) TrueClass() RClass { return RClass{mrb.state().true_class, mrb} }
 
 // FalseClass in state
-func (mrb *MrbState) FalseClass() RClass { return RClass{mrb.p.false_class, mrb} }
+func (mrb *MrbState) FalseClass() RClass { return RClass{mrb.state().false_class, mrb} }
 
 // NilClass in state
-func (mrb *MrbState) NilClass() RClass { return RClass{mrb.p.nil_class, mrb} }
+func (mrb *MrbState) NilClass() RClass { return RClass{mrb.state().nil_class, mrb} }
 
 // SymbolClass in state
-func (mrb *MrbState) SymbolClass() RClass { return RClass{mrb.p.symbol_class, mrb} }
+func (mrb *MrbState) SymbolClass() RClass { return RClass{mrb.state().symbol_class, mrb} }
 
 // KernelModule class in state
-func (mrb *MrbState) KernelModule() RClass { return RClass{mrb.p.kernel_module, mrb} }
+func (mrb *MrbState) KernelModule() RClass { return RClass{mrb.state().kernel_module, mrb} }
 
 // EExceptionClass in state
 func (mrb *MrbState) EExceptionClass() RClass {
-	return RClass{mrb.p.eException_class, mrb}
+	return RClass{mrb.state().eException_class, mrb}
 }
 
 // EStandardErrorClass in state
 func (mrb *MrbState) EStandardErrorClass() RClass {
 	return mrb.ExcGet("StandardError")
-	// return RClass{mrb.p.eStandardError_class, mrb}
+	// return RClass{mrb.state().eStandardError_class, mrb}
 }
 
 // DefineClass defines new oruby class
 func (mrb *MrbState) DefineClass(name string, parent RClass) RClass {
 	cname := C.CString(name)
 	defer C.free(unsafe.Pointer(cname))
-	return RClass{C.mrb_define_class(mrb.p, cname, parent.p), mrb}
+	return RClass{C.mrb_define_class(mrb.state(), cname, parent.p), mrb}
 }
 
 // DefineModule defines new oruby module
 func (mrb *MrbState) DefineModule(name string) RClass {
 	cname := C.CString(name)
 	defer C.free(unsafe.Pointer(cname))
-	return RClass{C.mrb_define_module(mrb.p, cname), mrb}
+	return RClass{C.mrb_define_module(mrb.state(), cname), mrb}
 }
 
 // SingletonClass returns the singleton class of an object
 // Returns `NULL` for immediate values,
 func (mrb *MrbState) SingletonClass(obj MrbValue) RClass {
-	return RClass{C.mrb_singleton_class_ptr(mrb.p, obj.Value().v), mrb}
+	return RClass{C.mrb_singleton_class_ptr(mrb.state(), obj.Value().v), mrb}
 	// C.mrb_singleton_class() never called
 }
 
@@ -894,39 +919,45 @@ func (mrb *MrbState) SingletonClassPtr(obj MrbValue) RClass {
 
 // IncludeModule Include a module in another class or module.
 func (mrb *MrbState) IncludeModule(Parent1, Parent2 RClass) {
-	C.mrb_include_module(mrb.p, Parent1.p, Parent2.p)
+	C.mrb_include_module(mrb.state(), Parent1.p, Parent2.p)
 }
 
 // PrependModule prepends a module in another class or module.
 func (mrb *MrbState) PrependModule(cla, prepend RClass) {
-	C.mrb_prepend_module(mrb.p, cla.p, prepend.p)
+	C.mrb_prepend_module(mrb.state(), cla.p, prepend.p)
 }
 
 //export go_mrb_func_env_callback
 func go_mrb_func_env_callback(mrbidx C.mrb_int, self C.mrb_value, idx C.int) C.mrb_value {
-	mrb := states[int(mrbidx)]
+	mrb := getMrbStateIndex(int(mrbidx))
+	mrb.runJobs()
 
 	fx := mrb.getMrbFuncT(uint(idx))
 	if fx == nil {
 		method := mrb.SymString(mrb.GetMID())
 		return mrb.Raisef(mrb.ERuntimeError(), "go_mrb_func_env_callback: Function '%v' reference not found.", method).v
 	}
+	if p := mrb.profile.Load(); p != nil {
+		defer p.sampleGo(fx)
+	}
 
 	return fx(mrb, Value{self}).Value().v
 }
 
 //export go_mrb_proc_callback
-func go_mrb_proc_callback(mrbidx C.mrb_int, self C.mrb_value) C.mrb_value {
-	mrb := states[int(mrbidx)]
+func go_mrb_proc_callback(mrbidx C.mrb_int, self C.mrb_value, idx C.int) C.mrb_value {
+	mrb := getMrbStateIndex(int(mrbidx))
+	mrb.runJobs()
 
-	mrb.Lock()
-	f := mrb.mrbProcs[C._mrb_ptr(self)]
-	mrb.Unlock()
+	f := mrb.getMrbFuncT(uint(idx))
 
 	if f == nil {
 		method := mrb.SymString(mrb.GetMID())
 		return mrb.Raisef(mrb.ETypeError(), "go_mrb_proc_callback: Function '%v' reference not found.", method).v
 	}
+	if p := mrb.profile.Load(); p != nil {
+		defer p.sampleGo(f)
+	}
 
 	return f(mrb, Value{self}).Value().v
 }
@@ -935,21 +966,21 @@ func go_mrb_proc_callback(mrbidx C.mrb_int, self C.mrb_value) C.mrb_value {
 func (mrb *MrbState) DefineMethod(klass RClass, name string, f MrbFuncT, aspec MrbAspec) {
 	// function reference is set as oruby function env
 	idx := mrb.registerFuncIndex(f)
-	C._mrb_method_new_cfunc(mrb.p, klass.p, C.mrb_sym(mrb.Intern(name)), C.int(idx), C.mrb_aspec(aspec))
+	C._mrb_method_new_cfunc(mrb.state(), klass.p, C.mrb_sym(mrb.Intern(name)), C.int(idx), C.mrb_aspec(aspec))
 	// C.mrb_define_method() called through helper
 }
 
 // DefineClassMethod creates new oruby class method
 func (mrb *MrbState) DefineClassMethod(klass RClass, name string, f MrbFuncT, aspec MrbAspec) {
 	idx := mrb.registerFuncIndex(f)
-	C._define_class_method(mrb.p, klass.p, C.mrb_sym(mrb.Intern(name)), C.int(idx), C.mrb_aspec(aspec))
+	C._define_class_method(mrb.state(), klass.p, C.mrb_sym(mrb.Intern(name)), C.int(idx), C.mrb_aspec(aspec))
 	// C.mrb_define_class_method() called through helper
 }
 
 // DefineClassMethodID creates new oruby class method
 func (mrb *MrbState) DefineClassMethodID(klass RClass, name MrbSym, f MrbFuncT, aspec MrbAspec) {
 	idx := mrb.registerFuncIndex(f)
-	C._define_class_method(mrb.p, klass.p, C.mrb_sym(name), C.int(idx), C.mrb_aspec(aspec))
+	C._define_class_method(mrb.state(), klass.p, C.mrb_sym(name), C.int(idx), C.mrb_aspec(aspec))
 	// C.mrb_define_class_method_id() called through helper
 }
 
@@ -960,7 +991,7 @@ func (mrb *MrbState) DefineSingletonMethod(obj RValue, name string, f MrbFuncT,
 	}
 	klass := MrbClassPtr(obj)
 	idx := mrb.registerFuncIndex(f)
-	C._define_class_method(mrb.p, klass.p, C.mrb_sym(mrb.Intern(name)), C.int(idx), C.mrb_aspec(aspec))
+	C._define_class_method(mrb.state(), klass.p, C.mrb_sym(mrb.Intern(name)), C.int(idx), C.mrb_aspec(aspec))
 	// C.mrb_define_singleton_method() called through helper
 }
 
@@ -971,7 +1002,7 @@ func (mrb *MrbState) DefineSingletonMethodID(obj RValue, name MrbSym, f MrbFuncT
 	}
 	klass := MrbClassPtr(obj)
 	idx := mrb.registerFuncIndex(f)
-	C._define_class_method(mrb.p, klass.p, C.mrb_sym(name), C.int(idx), C.mrb_aspec(aspec))
+	C._define_class_method(mrb.state(), klass.p, C.mrb_sym(name), C.int(idx), C.mrb_aspec(aspec))
 	// C.mrb_define_singleton_method_id() called through helper
 }
 
@@ -992,12 +1023,12 @@ func (mrb *MrbState) DefineModuleFunctionID(klass RClass, name MrbSym, f MrbFunc
 func (mrb *MrbState) DefineConst(klass RClass, name string, value MrbValue) {
 	cname := C.CString(name)
 	defer C.free(unsafe.Pointer(cname))
-	C.mrb_define_const(mrb.p, klass.p, cname, value.Value().v)
+	C.mrb_define_const(mrb.state(), klass.p, cname, value.Value().v)
 }
 
 // DefineConstID creates new oruby class const
 func (mrb *MrbState) DefineConstID(klass RClass, name MrbSym, value MrbValue) {
-	C.mrb_define_const_id(mrb.p, klass.p, C.mrb_sym(name), value.Value().v)
+	C.mrb_define_const_id(mrb.state(), klass.p, C.mrb_sym(name), value.Value().v)
 }
 
 // UndefMethod removes method from oruby class
@@ -1006,13 +1037,13 @@ func (mrb *MrbState) UndefMethod(klass RClass, name string) {
 	cname := C.CString(name)
 	defer C.free(unsafe.Pointer(cname))
 
-	C.mrb_undef_method(mrb.p, klass.p, cname)
+	C.mrb_undef_method(mrb.state(), klass.p, cname)
 }
 
 // UndefMethodID removes method from oruby class
 // note: function reference stays in matrix
 func (mrb *MrbState) UndefMethodID(klass RClass, name MrbSym) {
-	C.mrb_undef_method_id(mrb.p, klass.p, C.mrb_sym(name))
+	C.mrb_undef_method_id(mrb.state(), klass.p, C.mrb_sym(name))
 }
 
 // UndefClassMethod removes method from oruby class
@@ -1021,13 +1052,13 @@ func (mrb *MrbState) UndefClassMethod(klass RClass, name string) {
 	cname := C.CString(name)
 	defer C.free(unsafe.Pointer(cname))
 
-	C.mrb_undef_class_method(mrb.p, klass.p, cname)
+	C.mrb_undef_class_method(mrb.state(), klass.p, cname)
 }
 
 // UndefClassMethodID removes method from oruby class
 // note: function reference stays in matrix
 func (mrb *MrbState) UndefClassMethodID(klass RClass, name MrbSym) {
-	C.mrb_undef_class_method_id(mrb.p, klass.p, C.mrb_sym(name))
+	C.mrb_undef_class_method_id(mrb.state(), klass.p, C.mrb_sym(name))
 }
 
 // ObjNew creates new oruby object
@@ -1047,7 +1078,7 @@ func (mrb *MrbState) ObjNew(c RClass, args ...interface{}) (RValue, error) {
 	}
 
 	ret := RValue{C.mrb_obj_new(
-		mrb.p,
+		mrb.state(),
 		c.p,
 		C.mrb_int(len(args)),
 		&argv[0],
@@ -1083,7 +1114,7 @@ func (mrb *MrbState) checkInheritable(super RClass) error {
 	if super.Type() != MrbTTClass {
 		return ETypeError("superclass must be a Class (%v given)", super.Name())
 	}
-	if super.p == mrb.p.class_class {
+	if super.p == mrb.state().class_class {
 		return ETypeError("can't make subclass of Class")
 	}
 	return nil
@@ -1093,13 +1124,13 @@ func (mrb *MrbState) checkInheritable(super RClass) error {
 func (mrb *MrbState) ClassNew(super ...RClass) (RClass, error) {
 	switch len(super) {
 	case 0:
-		return RClass{C.mrb_class_new(mrb.p, nil), mrb}, nil
+		return RClass{C.mrb_class_new(mrb.state(), nil), mrb}, nil
 	case 1:
 		if err := mrb.checkInheritable(super[0]); err != nil {
 			return RClass{}, err
 		}
 
-		return RClass{C.mrb_class_new(mrb.p, super[0].p), mrb}, nil
+		return RClass{C.mrb_class_new(mrb.state(), super[0].p), mrb}, nil
 	default:
 		return RClass{}, EArgumentError("only one superclass allowed")
 	}
@@ -1107,19 +1138,19 @@ func (mrb *MrbState) ClassNew(super ...RClass) (RClass, error) {
 
 // ModuleNew creates new module
 func (mrb *MrbState) ModuleNew() RClass {
-	return RClass{C.mrb_module_new(mrb.p), mrb}
+	return RClass{C.mrb_module_new(mrb.state()), mrb}
 }
 
 // ClassDefined checks if oruby class is defined
 func (mrb *MrbState) ClassDefined(name string) bool {
 	cname := C.CString(name)
 	defer C.free(unsafe.Pointer(cname))
-	return C.mrb_class_defined(mrb.p, cname) != false
+	return C.mrb_class_defined(mrb.state(), cname) != false
 }
 
 // ClassDefinedID checks if oruby class is defined
 func (mrb *MrbState) ClassDefinedID(name MrbSym) bool {
-	return C.mrb_class_defined_id(mrb.p, C.mrb_sym(name)) != false
+	return C.mrb_class_defined_id(mrb.state(), C.mrb_sym(name)) != false
 }
 
 // ClassGet returns class by name
@@ -1127,20 +1158,20 @@ func (mrb *MrbState) ClassGet(name string) RClass {
 	cname := C.CString(name)
 	defer C.free(unsafe.Pointer(cname))
 
-	if C.mrb_class_defined(mrb.p, cname) == false {
+	if C.mrb_class_defined(mrb.state(), cname) == false {
 		panic("Unknown class: " + name)
 	}
 
-	return RClass{C.mrb_class_get(mrb.p, cname), mrb}
+	return RClass{C.mrb_class_get(mrb.state(), cname), mrb}
 }
 
 // ClassGetID returns class by name
 func (mrb *MrbState) ClassGetID(name MrbSym) RClass {
-	if C.mrb_class_defined_id(mrb.p, C.mrb_sym(name)) == false {
+	if C.mrb_class_defined_id(mrb.state(), C.mrb_sym(name)) == false {
 		panic("Unknown class: " + mrb.SymString(name))
 	}
 
-	return RClass{C.mrb_class_get_id(mrb.p, C.mrb_sym(name)), mrb}
+	return RClass{C.mrb_class_get_id(mrb.state(), C.mrb_sym(name)), mrb}
 }
 
 // ExcGet returns exception class by name
@@ -1150,19 +1181,19 @@ func (mrb *MrbState) ExcGet(name string) RClass {
 
 // ExcGetID returns exception class by name
 func (mrb *MrbState) ExcGetID(excID MrbSym) RClass {
-	return RClass{C.mrb_exc_get_id(mrb.p, C.mrb_sym(excID)), mrb}
+	return RClass{C.mrb_exc_get_id(mrb.state(), C.mrb_sym(excID)), mrb}
 }
 
 // ClassDefinedUnder  Returns true if inner class was defined, and false if the inner class was not defined
 func (mrb *MrbState) ClassDefinedUnder(outer RClass, name string) bool {
 	cname := C.CString(name)
 	defer C.free(unsafe.Pointer(cname))
-	return C.mrb_class_defined_under(mrb.p, outer.p, cname) != false
+	return C.mrb_class_defined_under(mrb.state(), outer.p, cname) != false
 }
 
 // ClassDefinedUnderID  Returns true if inner class was defined, and false if the inner class was not defined
 func (mrb *MrbState) ClassDefinedUnderID(outer RClass, name MrbSym) bool {
-	return C.mrb_class_defined_under_id(mrb.p, outer.p, C.mrb_sym(name)) != false
+	return C.mrb_class_defined_under_id(mrb.state(), outer.p, C.mrb_sym(name)) != false
 }
 
 // ClassGetUnder finds class by name under outer class
@@ -1173,7 +1204,7 @@ func (mrb *MrbState) ClassGetUnder(outer RClass, name string) RClass {
 
 	cname := C.CString(name)
 	defer C.free(unsafe.Pointer(cname))
-	return RClass{C.mrb_class_get_under(mrb.p, outer.p, cname), mrb}
+	return RClass{C.mrb_class_get_under(mrb.state(), outer.p, cname), mrb}
 }
 
 // ClassGetUnderID finds class by name under outer class
@@ -1181,31 +1212,31 @@ func (mrb *MrbState) ClassGetUnderID(outer RClass, name MrbSym) RClass {
 	if !mrb.ClassDefinedUnderID(outer, name) {
 		panic("Unknown class: " + mrb.SymString(name))
 	}
-	return RClass{C.mrb_class_get_under_id(mrb.p, outer.p, C.mrb_sym(name)), mrb}
+	return RClass{C.mrb_class_get_under_id(mrb.state(), outer.p, C.mrb_sym(name)), mrb}
 }
 
 // ModuleGet returns module by name
 func (mrb *MrbState) ModuleGet(name string) RClass {
 	cname := C.CString(name)
 	defer C.free(unsafe.Pointer(cname))
-	return RClass{C.mrb_module_get(mrb.p, cname), mrb}
+	return RClass{C.mrb_module_get(mrb.state(), cname), mrb}
 }
 
 // ModuleGetID returns module by name
 func (mrb *MrbState) ModuleGetID(name MrbSym) RClass {
-	return RClass{C.mrb_module_get_id(mrb.p, C.mrb_sym(name)), mrb}
+	return RClass{C.mrb_module_get_id(mrb.state(), C.mrb_sym(name)), mrb}
 }
 
 // ModuleGetUnder returns module by name under outer class
 func (mrb *MrbState) ModuleGetUnder(outer RClass, name string) RClass {
 	cname := C.CString(name)
 	defer C.free(unsafe.Pointer(cname))
-	return RClass{C.mrb_module_get_under(mrb.p, outer.p, cname), mrb}
+	return RClass{C.mrb_module_get_under(mrb.state(), outer.p, cname), mrb}
 }
 
 // ModuleGetUnderID returns module by name under outer class
 func (mrb *MrbState) ModuleGetUnderID(outer RClass, name MrbSym) RClass {
-	return RClass{C.mrb_module_get_under_id(mrb.p, outer.p, C.mrb_sym(name)), mrb}
+	return RClass{C.mrb_module_get_under_id(mrb.state(), outer.p, C.mrb_sym(name)), mrb}
 }
 
 // NotImplemented function to raise NotImplementedError with current method name
@@ -1220,12 +1251,12 @@ func (mrb *MrbState) NotImplemented(*MrbState, Value) MrbValue {
 
 // ObjDup duplicates MrbValue object
 func (mrb *MrbState) ObjDup(obj MrbValue) Value {
-	return Value{C.mrb_obj_dup(mrb.p, obj.Value().v)}
+	return Value{C.mrb_obj_dup(mrb.state(), obj.Value().v)}
 }
 
 // ObjRespondTo checks if object responds to method
 func (mrb *MrbState) ObjRespondTo(c RClass, mid MrbSym) bool {
-	return C.mrb_obj_respond_to(mrb.p, c.p, C.mrb_sym(mid)) != false
+	return C.mrb_obj_respond_to(mrb.state(), c.p, C.mrb_sym(mid)) != false
 }
 
 // DefineClassUnder defines a class under the namespace of outer.
@@ -1254,24 +1285,24 @@ func (mrb *MrbState) ObjRespondTo(c RClass, mid MrbSym) bool {
 func (mrb *MrbState) DefineClassUnder(outer RClass, name string, super RClass) RClass {
 	cname := C.CString(name)
 	defer C.free(unsafe.Pointer(cname))
-	return RClass{C.mrb_define_class_under(mrb.p, outer.p, cname, super.p), mrb}
+	return RClass{C.mrb_define_class_under(mrb.state(), outer.p, cname, super.p), mrb}
 }
 
 // DefineClassUnderID defines a class under the namespace of outer.
 func (mrb *MrbState) DefineClassUnderID(outer RClass, name MrbSym, super RClass) RClass {
-	return RClass{C.mrb_define_class_under_id(mrb.p, outer.p, C.mrb_sym(name), super.p), mrb}
+	return RClass{C.mrb_define_class_under_id(mrb.state(), outer.p, C.mrb_sym(name), super.p), mrb}
 }
 
 // DefineModuleUnder defines module under class
 func (mrb *MrbState) DefineModuleUnder(outer RClass, name string) RClass {
 	cname := C.CString(name)
 	defer C.free(unsafe.Pointer(cname))
-	return RClass{C.mrb_define_module_under(mrb.p, outer.p, cname), mrb}
+	return RClass{C.mrb_define_module_under(mrb.state(), outer.p, cname), mrb}
 }
 
 // DefineModuleUnderID defines module under class
 func (mrb *MrbState) DefineModuleUnderID(outer RClass, name MrbSym) RClass {
-	return RClass{C.mrb_define_module_under_id(mrb.p, outer.p, C.mrb_sym(name)), mrb}
+	return RClass{C.mrb_define_module_under_id(mrb.state(), outer.p, C.mrb_sym(name)), mrb}
 }
 
 // ArgsReq required arguments
@@ -1335,7 +1366,7 @@ func (mrb *MrbState) ArgsNone() MrbAspec { return ArgsNone() }
 
 // GetMID get method symbol
 func (mrb *MrbState) GetMID() MrbSym {
-	return MrbSym(mrb.p.c.ci.mid)
+	return MrbSym(mrb.state().c.ci.mid)
 }
 
 // Call oruby function, return Go interface,
@@ -1346,18 +1377,21 @@ func (mrb *MrbState) Call(self MrbValue, name string, args ...interface{}) Value
 }
 
 // Funcall call oruby function
-func (mrb *MrbState) Funcall(self MrbValue, nameSym MrbSym, args ...interface{}) (Value, error) {
-	var err error
+func (mrb *MrbState) Funcall(self MrbValue, nameSym MrbSym, args ...interface{}) (ret Value, err error) {
+	if mrb.remote(func() { ret, err = mrb.Funcall(self, nameSym, args...) }) {
+		return ret, err
+	}
+
 	var f reflect.Value
 	l := len(args)
 
 	//print("funcall ", mrb.ClassOf(self).Name(), ":", mrb.String(name), "(")
 
 	if (self.Type() == C.MRB_TT_PROC) && mrb.RProc(self).IsCFunc() && (nameSym == mrb.callSym) {
-		if C._mrb_proc_has_env(mrb.p, MrbProcPtr(self).p) != false {
-			fv := C._mrb_proc_env_get(mrb.p, MrbProcPtr(self).p, C.mrb_int(0))
-			ff, _ := mrb.getFunc(uint(C._mrb_fixnum(fv)))
-			f = reflect.ValueOf(ff)
+		if ff := mrb.procFunc(MrbProcPtr(self).p); ff != nil {
+			if _, ok := ff.(MrbFuncT); !ok {
+				f = reflect.ValueOf(ff)
+			}
 		}
 
 		if f.IsValid() {
@@ -1372,7 +1406,7 @@ func (mrb *MrbState) Funcall(self MrbValue, nameSym MrbSym, args ...interface{})
 	}
 
 	v := Value{C._mrb_funcall_with_block(
-		mrb.p,
+		mrb.state(),
 		self.Value().v,
 		C.mrb_sym(nameSym),
 		C.mrb_int(l),
@@ -1393,7 +1427,11 @@ func (mrb *MrbState) Funcall(self MrbValue, nameSym MrbSym, args ...interface{})
 
 // FuncallWithBlock call function with arguments. Last argument passed should be block
 // Valid values for block are RProc types, or Go functions which get converted to RProc value
-func (mrb *MrbState) FuncallWithBlock(self MrbValue, nameSym MrbSym, args ...interface{}) (Value, error) {
+func (mrb *MrbState) FuncallWithBlock(self MrbValue, nameSym MrbSym, args ...interface{}) (ret Value, err error) {
+	if mrb.remote(func() { ret, err = mrb.FuncallWithBlock(self, nameSym, args...) }) {
+		return ret, err
+	}
+
 	block := nilValue
 	argc := len(args)
 
@@ -1411,9 +1449,8 @@ func (mrb *MrbState) FuncallWithBlock(self MrbValue, nameSym MrbSym, args ...int
 		a[i] = mrb.Value(args[i]).v
 	}
 
-	var err error
 	v := Value{C._mrb_funcall_with_block(
-		mrb.p,
+		mrb.state(),
 		self.Value().v,
 		C.mrb_sym(nameSym),
 		C.mrb_int(argc),
@@ -1437,7 +1474,7 @@ func (mrb *MrbState) FuncallWithBlock(self MrbValue, nameSym MrbSym, args ...int
 func (mrb *MrbState) Sym(name string) MrbSym {
 	cname := C.CString(name)
 	defer C.free(unsafe.Pointer(cname))
-	sym := C.mrb_intern(mrb.p, cname, C.size_t(len(name)))
+	sym := C.mrb_intern(mrb.state(), cname, C.size_t(len(name)))
 	return MrbSym(sym)
 	// C.mrb_intern_cstr() is never called
 }
@@ -1449,7 +1486,7 @@ func (mrb *MrbState) Intern(name string) MrbSym {
 
 // InternStr converts string oruby value to symbol
 func (mrb *MrbState) InternStr(val MrbValue) MrbSym {
-	return MrbSym(C.mrb_intern_str(mrb.p, val.Value().v))
+	return MrbSym(C.mrb_intern_str(mrb.state(), val.Value().v))
 }
 
 // InternCheck returns 0 if the symbol is not defined
@@ -1458,13 +1495,13 @@ func (mrb *MrbState) InternCheck(name string) MrbSym {
 	size := len(name)
 	defer C.free(unsafe.Pointer(cname))
 
-	return MrbSym(C.mrb_intern_check(mrb.p, cname, C.size_t(size)))
+	return MrbSym(C.mrb_intern_check(mrb.state(), cname, C.size_t(size)))
 	// C.mrb_intern_check_cstr() is never called
 }
 
 // InternCheckStr returns symbol if string val exists, or 0 value if symbol val is not defined
 func (mrb *MrbState) InternCheckStr(val MrbValue) MrbSym {
-	return MrbSym(C.mrb_intern_check_str(mrb.p, val.Value().v))
+	return MrbSym(C.mrb_intern_check_str(mrb.state(), val.Value().v))
 }
 
 // CheckIntern returns nil value if the symbol is not defined, or symbol value of name
@@ -1473,43 +1510,43 @@ func (mrb *MrbState) CheckIntern(name string) Value {
 	size := len(name)
 	defer C.free(unsafe.Pointer(cname))
 
-	return Value{C.mrb_check_intern(mrb.p, cname, C.size_t(size))}
+	return Value{C.mrb_check_intern(mrb.state(), cname, C.size_t(size))}
 	// C.mrb_check_intern_cstr() is never called
 }
 
 // CheckInternStr returns symbol value if string val exists, or nil value if symbol of val does not exist
 func (mrb *MrbState) CheckInternStr(val MrbValue) Value {
-	return Value{C.mrb_check_intern_str(mrb.p, val.Value().v)}
+	return Value{C.mrb_check_intern_str(mrb.state(), val.Value().v)}
 }
 
 // SymName returns name of oruby symbol
 func (mrb *MrbState) SymName(sym MrbSym) string {
-	return C.GoString(C.mrb_sym_name(mrb.p, C.mrb_sym(sym)))
+	return C.GoString(C.mrb_sym_name(mrb.state(), C.mrb_sym(sym)))
 }
 
 // SymNameLen symbol to string
 func (mrb *MrbState) SymNameLen(sym MrbSym, size uint) string {
 	var s C.mrb_int = C.mrb_int(size)
-	cs := C.mrb_sym_name_len(mrb.p, C.mrb_sym(sym), (*C.mrb_int)(&s))
+	cs := C.mrb_sym_name_len(mrb.state(), C.mrb_sym(sym), (*C.mrb_int)(&s))
 	return C.GoStringN(cs, C.int(s))
 }
 
 // SymDump symbol to string dump
 func (mrb *MrbState) SymDump(sym MrbSym) string {
-	cs := C.mrb_sym_dump(mrb.p, C.mrb_sym(sym))
+	cs := C.mrb_sym_dump(mrb.state(), C.mrb_sym(sym))
 	return C.GoString(cs)
 }
 
 // SymString symbol to string
 func (mrb *MrbState) SymString(sym MrbSym) string {
 	var s C.mrb_int
-	cs := C.mrb_sym_name_len(mrb.p, C.mrb_sym(sym), (*C.mrb_int)(&s))
+	cs := C.mrb_sym_name_len(mrb.state(), C.mrb_sym(sym), (*C.mrb_int)(&s))
 	return C.GoStringN(cs, C.int(s))
 }
 
 // SymStr symbol to string value
 func (mrb *MrbState) SymStr(sym MrbSym) Value {
-	return Value{C.mrb_sym_str(mrb.p, C.mrb_sym(sym))}
+	return Value{C.mrb_sym_str(mrb.state(), C.mrb_sym(sym))}
 }
 
 // InternLit in Go does the same as Intern
@@ -1528,7 +1565,7 @@ func (mrb *MrbState) InternStatic(str string) MrbSym {
 
 // SymIdx returns current symbol index from MrbState
 func (mrb *MrbState) SymIdx() int {
-	return int(mrb.p.symidx)
+	return int(mrb.state().symidx)
 }
 
 // Buff represents memory allocated by mruby C API
@@ -1541,39 +1578,39 @@ func (b Buff) Uintptr() uintptr { return uintptr(b.p) }
 
 // Malloc allocates C side memory using oruby allocator
 func (mrb *MrbState) Malloc(size uint) Buff {
-	return Buff{C.mrb_malloc(mrb.p, C.size_t(size))}
+	return Buff{C.mrb_malloc(mrb.state(), C.size_t(size))}
 }
 
 // Calloc allocates C side memory using oruby allocator
 func (mrb *MrbState) Calloc(num, size uint) Buff {
-	return Buff{C.mrb_calloc(mrb.p, C.size_t(num), C.size_t(size))}
+	return Buff{C.mrb_calloc(mrb.state(), C.size_t(num), C.size_t(size))}
 }
 
 // Realloc reallocates C side memory using oruby allocator
 func (mrb *MrbState) Realloc(buffer Buff, size uint) Buff {
-	return Buff{C.mrb_realloc(mrb.p, buffer.p, C.size_t(size))}
+	return Buff{C.mrb_realloc(mrb.state(), buffer.p, C.size_t(size))}
 }
 
 // ReallocSimple simple version return NULL if no memory available
 func (mrb *MrbState) ReallocSimple(buffer Buff, size uint) Buff {
-	return Buff{C.mrb_realloc_simple(mrb.p, buffer.p, C.size_t(size))}
+	return Buff{C.mrb_realloc_simple(mrb.state(), buffer.p, C.size_t(size))}
 }
 
 // MallocSimple simple version return NULL if no memory available
 func (mrb *MrbState) MallocSimple(size uint) Buff {
-	return Buff{C.mrb_malloc_simple(mrb.p, C.size_t(size))}
+	return Buff{C.mrb_malloc_simple(mrb.state(), C.size_t(size))}
 }
 
 // ObjAlloc allocate memory for oruby basic object
 func (mrb *MrbState) ObjAlloc(vtype Type, klass RClass) RBasic {
-	return RBasic{C.mrb_obj_alloc(mrb.p, uint32(vtype), klass.p)}
+	return RBasic{C.mrb_obj_alloc(mrb.state(), uint32(vtype), klass.p)}
 }
 
 // Free calls oruby free to release C side memory
 func (mrb *MrbState) Free(buffer Buff) {
 	p := buffer.p
 	buffer.p = nil
-	C.mrb_free(mrb.p, p)
+	C.mrb_free(mrb.state(), p)
 }
 
 // StrNew Allocates new C string from go string
@@ -1581,7 +1618,7 @@ func (mrb *MrbState) StrNew(s string) Value {
 	cs := C.CString(s)
 	size := len(s)
 	defer C.free(unsafe.Pointer(cs))
-	return Value{C.mrb_str_new(mrb.p, cs, C.mrb_int(size))}
+	return Value{C.mrb_str_new(mrb.state(), cs, C.mrb_int(size))}
 	// C.mrb_str_new_cstr() is never called
 }
 
@@ -1591,13 +1628,13 @@ func (mrb *MrbState) StrNewStatic(s string) Value {
 	size := len(s)
 	defer C.free(unsafe.Pointer(cs))
 
-	return Value{C.mrb_str_new(mrb.p, cs, C.mrb_int(size))}
+	return Value{C.mrb_str_new(mrb.state(), cs, C.mrb_int(size))}
 	// C.mrb_str_new_static() is unsupported in Go
 }
 
 // ObjFreeze freeze value
 func (mrb *MrbState) ObjFreeze(v MrbValue) Value {
-	return Value{C.mrb_obj_freeze(mrb.p, v.Value().v)}
+	return Value{C.mrb_obj_freeze(mrb.state(), v.Value().v)}
 }
 
 // StrNewFrozen create frozen string value
@@ -1623,28 +1660,28 @@ func MrbOpen() *MrbState {
 //func C.mrb_default_allocf(mrb_state*, void*, size_t, void*) is unsupported
 
 // TopSelf value
-func (mrb *MrbState) TopSelf() Value { return Value{C.mrb_top_self(mrb.p)} }
+func (mrb *MrbState) TopSelf() Value { return Value{C.mrb_top_self(mrb.state())} }
 
 // TopAdjustStackLength of toplevel environment. Used in imrb
 func (mrb *MrbState) TopAdjustStackLength(nlocals int) {
-	e := REnv{C.mrb_vm_ci_env(mrb.p.c.cibase), mrb}
+	e := REnv{C.mrb_vm_ci_env(mrb.state().c.cibase), mrb}
 	e.AdjustStackLength(nlocals)
 }
 
 // TopRun execution
 func (mrb *MrbState) TopRun(proc RProc, self MrbValue, stackKeep int) Value {
-	return Value{C.mrb_top_run(mrb.p, proc.p, self.Value().v, C.mrb_int(stackKeep))}
+	return Value{C.mrb_top_run(mrb.state(), proc.p, self.Value().v, C.mrb_int(stackKeep))}
 }
 
 // VMRun run proc in VM
 func (mrb *MrbState) VMRun(proc RProc, self MrbValue, stackKeep int) Value {
-	return Value{C.mrb_vm_run(mrb.p, proc.p, self.Value().v, C.mrb_int(stackKeep))}
+	return Value{C.mrb_vm_run(mrb.state(), proc.p, self.Value().v, C.mrb_int(stackKeep))}
 }
 
 // VMExec executes ISeq bytecode in mruby VM
 // NOTE: this does not pass slice pointer to C. As in
 //
-//	C.mrb_vm_exec(mrb.p, proc.p, (*C.mrb_code)(&iseq[0]))
+//	C.mrb_vm_exec(mrb.state(), proc.p, (*C.mrb_code)(&iseq[0]))
 //
 // It probably can be that way since:
 //
@@ -1663,7 +1700,7 @@ func (mrb *MrbState) VMExec(proc RProc, iseq []MrbCode) Value {
 	ciseq := C.CBytes(iseq)
 	defer C.free(ciseq)
 
-	return Value{C.mrb_vm_exec(mrb.p, proc.p, (*C.mrb_code)(ciseq))}
+	return Value{C.mrb_vm_exec(mrb.state(), proc.p, (*C.mrb_code)(ciseq))}
 }
 
 // ContextRun proc is an alias for VMRun()
@@ -1672,29 +1709,29 @@ func (mrb *MrbState) ContextRun(p RProc, v MrbValue, stackKeep int) Value {
 }
 
 // P kernel#p print function
-func (mrb *MrbState) P(v MrbValue) { C.mrb_p(mrb.p, v.Value().v) }
+func (mrb *MrbState) P(v MrbValue) { C.mrb_p(mrb.state(), v.Value().v) }
 
 // ObjID returns oruby value id
 func (mrb *MrbState) ObjID(obj MrbValue) int { return int(C.mrb_obj_id(obj.Value().v)) }
 
 // ObjToSym get oruby symbol value
 func (mrb *MrbState) ObjToSym(obj MrbValue) MrbSym {
-	return MrbSym(C.mrb_obj_to_sym(mrb.p, obj.Value().v))
+	return MrbSym(C.mrb_obj_to_sym(mrb.state(), obj.Value().v))
 }
 
 // ObjEq checks if objects are equal
 func (mrb *MrbState) ObjEq(v1, v2 MrbValue) bool {
-	return C.mrb_obj_eq(mrb.p, v1.Value().v, v2.Value().v) != false
+	return C.mrb_obj_eq(mrb.state(), v1.Value().v, v2.Value().v) != false
 }
 
 // ObjEqual checks if objects are equal
 func (mrb *MrbState) ObjEqual(v1, v2 MrbValue) bool {
-	return C.mrb_obj_equal(mrb.p, v1.Value().v, v2.Value().v) != false
+	return C.mrb_obj_equal(mrb.state(), v1.Value().v, v2.Value().v) != false
 }
 
 // Equal check if values are equal
 func (mrb *MrbState) Equal(v1, v2 MrbValue) bool {
-	return C.mrb_equal(mrb.p, v1.Value().v, v2.Value().v) != false
+	return C.mrb_equal(mrb.state(), v1.Value().v, v2.Value().v) != false
 }
 
 // EnsureIntegerType ensures returned Value is integer type, or error is returned
@@ -1727,7 +1764,7 @@ func (mrb *MrbState) EnsureFloatType(val MrbValue) (Value, error) {
 
 // CheckStringType checks string type
 func (mrb *MrbState) CheckStringType(str MrbValue) Value {
-	return Value{C.mrb_check_string_type(mrb.p, str.Value().v)}
+	return Value{C.mrb_check_string_type(mrb.state(), str.Value().v)}
 }
 
 // Integer returns integer from value
@@ -1750,56 +1787,56 @@ func (mrb *MrbState) AsFloat(val MrbValue) (float64, error) {
 
 // Inspect returns object info
 func (mrb *MrbState) Inspect(obj MrbValue) Value {
-	return Value{C.mrb_inspect(mrb.p, obj.Value().v)}
+	return Value{C.mrb_inspect(mrb.state(), obj.Value().v)}
 }
 
 // Eql checks if values are equal
 func (mrb *MrbState) Eql(obj1, obj2 MrbValue) bool {
-	return C.mrb_eql(mrb.p, obj1.Value().v, obj2.Value().v) != false
+	return C.mrb_eql(mrb.state(), obj1.Value().v, obj2.Value().v) != false
 }
 
 // Cmp compares oruby object values
 func (mrb *MrbState) Cmp(obj1, obj2 MrbValue) int {
-	return int(C.mrb_cmp(mrb.p, obj1.Value().v, obj2.Value().v))
+	return int(C.mrb_cmp(mrb.state(), obj1.Value().v, obj2.Value().v))
 }
 
 // GarbageCollect collect garbage
-func (mrb *MrbState) GarbageCollect() { C.mrb_garbage_collect(mrb.p) }
+func (mrb *MrbState) GarbageCollect() { C.mrb_garbage_collect(mrb.state()) }
 
 // FullGC Full garbage collection
 func (mrb *MrbState) FullGC() {
-	C.mrb_full_gc(mrb.p)
+	C.mrb_full_gc(mrb.state())
 }
 
 // IncrementalGC incremental garbage collection
-func (mrb *MrbState) IncrementalGC() { C.mrb_incremental_gc(mrb.p) }
+func (mrb *MrbState) IncrementalGC() { C.mrb_incremental_gc(mrb.state()) }
 
 // GCArenaSave save GC arena
-func (mrb *MrbState) GCArenaSave() int32 { return int32(C.mrb_gc_arena_save(mrb.p)) }
+func (mrb *MrbState) GCArenaSave() int32 { return int32(C.mrb_gc_arena_save(mrb.state())) }
 
 // GCArenaRestore restore GC arena
-func (mrb *MrbState) GCArenaRestore(n int32) { C.mrb_gc_arena_restore(mrb.p, C.int(n)) }
+func (mrb *MrbState) GCArenaRestore(n int32) { C.mrb_gc_arena_restore(mrb.state(), C.int(n)) }
 
 // GCMark mark GC
-func (mrb *MrbState) GCMark(o RBasic) { C.mrb_gc_mark(mrb.p, o.p) }
+func (mrb *MrbState) GCMark(o RBasic) { C.mrb_gc_mark(mrb.state(), o.p) }
 
 // GCMarkValue marks GC ov values
 func (mrb *MrbState) GCMarkValue(val MrbValue) {
 	v := val.Value()
 	if v.HasBasic() {
-		C.mrb_gc_mark(mrb.p, v.RBasic().p)
+		C.mrb_gc_mark(mrb.state(), v.RBasic().p)
 	}
 }
 
 // FieldWriteBarrier Paint obj(Black) -> value(White) to obj(Black) -> value(Gray).
 func (mrb *MrbState) FieldWriteBarrier(obj1, obj2 RBasic) {
-	C.mrb_field_write_barrier(mrb.p, obj1.p, obj2.p)
+	C.mrb_field_write_barrier(mrb.state(), obj1.p, obj2.p)
 }
 
 // FieldWriteBarrierValue write barrier vale
 func (mrb *MrbState) FieldWriteBarrierValue(obj RBasic, val MrbValue) {
 	if val.Type() >= MrbTTHasBasic {
-		C.mrb_field_write_barrier(mrb.p, obj.p, (*C.struct_RBasic)(MrbBasicPtr(val).p))
+		C.mrb_field_write_barrier(mrb.state(), obj.p, (*C.struct_RBasic)(MrbBasicPtr(val).p))
 	}
 }
 
@@ -1807,11 +1844,11 @@ func (mrb *MrbState) FieldWriteBarrierValue(obj RBasic, val MrbValue) {
 // The object that is painted gray will be traversed atomically in final
 // mark phase. So you use this write barrier if it's frequency written spot.
 // e.g. Set element on Array.
-func (mrb *MrbState) WriteBarrier(o RBasic) { C.mrb_write_barrier(mrb.p, o.p) }
+func (mrb *MrbState) WriteBarrier(o RBasic) { C.mrb_write_barrier(mrb.state(), o.p) }
 
 // TypeConvertCheck check type conversion, returns Nil if conversion is not possible
 func (mrb *MrbState) TypeConvertCheck(val MrbValue, mrbtype uint32, method MrbSym) Value {
-	return Value{C.mrb_type_convert_check(mrb.p, val.Value().v, mrbtype, C.mrb_sym(method))}
+	return Value{C.mrb_type_convert_check(mrb.state(), val.Value().v, mrbtype, C.mrb_sym(method))}
 }
 
 // CheckConvertType check type conversion, returns Nil if conversion is not possible
@@ -1825,25 +1862,25 @@ func (mrb *MrbState) CheckConvertType(val MrbValue, mrbtype uint32, tname, metho
 //	object id. As a special case, the top-level object that is the
 //	initial execution context of Ruby programs returns "main."
 func (mrb *MrbState) AnyToS(obj MrbValue) Value {
-	return Value{C.mrb_any_to_s(mrb.p, obj.Value().v)}
+	return Value{C.mrb_any_to_s(mrb.state(), obj.Value().v)}
 }
 
 // ObjClassname returns class name of object
 func (mrb *MrbState) ObjClassname(obj MrbValue) string {
-	return C.GoString(C.mrb_obj_classname(mrb.p, obj.Value().v))
+	return C.GoString(C.mrb_obj_classname(mrb.state(), obj.Value().v))
 }
 
 // ObjClass returns class of object
 func (mrb *MrbState) ObjClass(obj MrbValue) RClass {
-	return RClass{C.mrb_obj_class(mrb.p, obj.Value().v), mrb}
+	return RClass{C.mrb_obj_class(mrb.state(), obj.Value().v), mrb}
 }
 
 // ClassPath returns class path
-func (mrb *MrbState) ClassPath(c RClass) Value { return Value{C.mrb_class_path(mrb.p, c.p)} }
+func (mrb *MrbState) ClassPath(c RClass) Value { return Value{C.mrb_class_path(mrb.state(), c.p)} }
 
 // TypeConvert using method
 func (mrb *MrbState) TypeConvert(val MrbValue, mrbtype Type, method MrbSym) (Value, error) {
-	ret := Value{C.mrb_type_convert_check(mrb.p, val.Value().v, uint32(mrbtype), C.mrb_sym(method))}
+	ret := Value{C.mrb_type_convert_check(mrb.state(), val.Value().v, uint32(mrbtype), C.mrb_sym(method))}
 	if ret.IsNil() && ret.Type() != mrbtype {
 		return ret, ETypeError("%v cannot be converted to %v by #%v", mrb.Intf(val), TypeName(mrbtype), mrb.SymName(method))
 	}
@@ -1883,7 +1920,7 @@ func (mrb *MrbState) ConvertType(val MrbValue, mrbtype Type, tname, method strin
 func (mrb *MrbState) ObjIsKindOf(obj MrbValue, c RClass) bool {
 	switch c.Type() {
 	case MrbTTModule, MrbTTClass, MrbTTIClass, MrbTTSClass:
-		return C.mrb_obj_is_kind_of(mrb.p, obj.Value().v, c.p) != false
+		return C.mrb_obj_is_kind_of(mrb.state(), obj.Value().v, c.p) != false
 	default:
 		panic(fmt.Sprintf("class or module required but got %v", c.Name()))
 	}
@@ -1903,7 +1940,7 @@ func (mrb *MrbState) ObjIsKindOf(obj MrbValue, c RClass) bool {
 //	[ 1, 2, 3..4, 'five' ].inspect   #=> "[1, 2, 3..4, \"five\"]"
 //	Time.new.inspect                 #=> "2008-03-08 19:43:39 +0900"
 func (mrb *MrbState) ObjInspect(oself MrbValue) Value {
-	return Value{C.mrb_obj_inspect(mrb.p, oself.Value().v)}
+	return Value{C.mrb_obj_inspect(mrb.state(), oself.Value().v)}
 }
 
 // ObjClone clones object
@@ -1932,7 +1969,7 @@ func (mrb *MrbState) ObjInspect(oself MrbValue) Value {
 //
 // Some Class(True False Nil Symbol Fixnum Float) Object  cannot clone.
 func (mrb *MrbState) ObjClone(oself MrbValue) Value {
-	return Value{C.mrb_obj_clone(mrb.p, oself.Value().v)}
+	return Value{C.mrb_obj_clone(mrb.state(), oself.Value().v)}
 }
 
 //* need to include <ctype.h> to use these macros */
@@ -1956,7 +1993,7 @@ func (mrb *MrbState) ObjClone(oself MrbValue) Value {
 func (mrb *MrbState) ExcNew(c RClass, msg string) Value {
 	cmsg := C.CString(msg)
 	defer C.free(unsafe.Pointer(cmsg))
-	return Value{C.mrb_exc_new(mrb.p, c.p, cmsg, C.mrb_int(len(msg)))}
+	return Value{C.mrb_exc_new(mrb.state(), c.p, cmsg, C.mrb_int(len(msg)))}
 }
 
 // ExcRaise raises Ruby exception. This function is likeley to cause
@@ -1969,7 +2006,7 @@ func (mrb *MrbState) ExcNew(c RClass, msg string) Value {
 //
 //	This will return Exception from Go, and oruby will raise it on C side
 func (mrb *MrbState) ExcRaise(exc MrbValue) {
-	C.mrb_exc_raise(mrb.p, exc.Value().v)
+	C.mrb_exc_raise(mrb.state(), exc.Value().v)
 }
 
 // Raise raises Exception from err class.
@@ -1983,7 +2020,7 @@ func (mrb *MrbState) ExcRaise(exc MrbValue) {
 func (mrb *MrbState) Raise(err RClass, msg string) Value {
 	e := err
 	for !e.IsNil() {
-		if e.p == mrb.p.eException_class {
+		if e.p == mrb.state().eException_class {
 			e = err
 			break
 		}
@@ -1995,7 +2032,7 @@ func (mrb *MrbState) Raise(err RClass, msg string) Value {
 	}
 
 	ret := mrb.ExcNew(e, msg)
-	mrb.p.exc = C._mrb_obj_ptr(ret.v)
+	mrb.state().exc = C._mrb_obj_ptr(ret.v)
 
 	return ret
 }
@@ -2047,7 +2084,7 @@ func (mrb *MrbState) Warn(format string, args ...interface{}) {
 	msg := fmt.Sprintf(format, args...)
 	cmsg := C.CString(msg)
 	defer C.free(unsafe.Pointer(cmsg))
-	C._mrb_warn(mrb.p, cmsg)
+	C._mrb_warn(mrb.state(), cmsg)
 	// pure C.mrb_warn() is never called
 }
 
@@ -2056,15 +2093,15 @@ func (mrb *MrbState) Bug(format string, args ...interface{}) {
 	msg := fmt.Sprintf(format, args...)
 	cmsg := C.CString(msg)
 	defer C.free(unsafe.Pointer(cmsg))
-	C._mrb_bug(mrb.p, cmsg)
+	C._mrb_bug(mrb.state(), cmsg)
 	// pure C.mrb_bug() is never called
 }
 
 // PrintBacktrace print backtrace
-func (mrb *MrbState) PrintBacktrace() { C.mrb_print_backtrace(mrb.p) }
+func (mrb *MrbState) PrintBacktrace() { C.mrb_print_backtrace(mrb.state()) }
 
 // PrintError prints error
-func (mrb *MrbState) PrintError() { C.mrb_print_error(mrb.p) }
+func (mrb *MrbState) PrintError() { C.mrb_print_error(mrb.state()) }
 
 // ERuntimeError oruby error
 func (mrb *MrbState) ERuntimeError() RClass { return mrb.ExcGet("RuntimeError") }
@@ -2122,7 +2159,7 @@ func (mrb *MrbState) ESystemCallError() RClass { return mrb.ExcGet("SystemCallEr
 
 // Yield block with value
 func (mrb *MrbState) Yield(b, arg MrbValue) Value {
-	return Value{C._mrb_yield(mrb.p, b.Value().v, arg.Value().v)}
+	return Value{C._mrb_yield(mrb.state(), b.Value().v, arg.Value().v)}
 	// C.mrb_yield() is called from MRB_TRY/MRB_CATCH wrapped helper to avoid Go fatal throws on C side jumps
 }
 
@@ -2131,7 +2168,7 @@ func (mrb *MrbState) YieldArgv(b MrbValue, argv ...interface{}) Value {
 	argc := len(argv)
 
 	if argc == 0 {
-		return Value{C._mrb_yield_argv(mrb.p, b.Value().v, 0, nil)}
+		return Value{C._mrb_yield_argv(mrb.state(), b.Value().v, 0, nil)}
 	}
 
 	args := make([]C.mrb_value, argc)
@@ -2139,14 +2176,14 @@ func (mrb *MrbState) YieldArgv(b MrbValue, argv ...interface{}) Value {
 		args[i] = mrb.Value(argv[i]).v
 	}
 
-	return Value{C._mrb_yield_argv(mrb.p, b.Value().v, C.mrb_int(argc), (*C.mrb_value)(&args[0]))}
+	return Value{C._mrb_yield_argv(mrb.state(), b.Value().v, C.mrb_int(argc), (*C.mrb_value)(&args[0]))}
 }
 
 // YieldWithClass yields with class
 func (mrb *MrbState) YieldWithClass(b MrbValue, self MrbValue, c RClass, args ...interface{}) Value {
 	argc := len(args)
 	if argc == 0 {
-		return Value{C._mrb_yield_with_class(mrb.p, b.Value().v, 0, nil, self.Value().v, c.p)}
+		return Value{C._mrb_yield_with_class(mrb.state(), b.Value().v, 0, nil, self.Value().v, c.p)}
 	}
 
 	argv := make([]C.mrb_value, argc)
@@ -2154,7 +2191,7 @@ func (mrb *MrbState) YieldWithClass(b MrbValue, self MrbValue, c RClass, args ..
 		argv[i] = mrb.Value(args[i]).v
 	}
 
-	return Value{C._mrb_yield_with_class(mrb.p, b.Value().v, C.mrb_int(argc), &argv[0], self.Value().v, c.p)}
+	return Value{C._mrb_yield_with_class(mrb.state(), b.Value().v, C.mrb_int(argc), &argv[0], self.Value().v, c.p)}
 }
 
 // YieldCont continue execution to the proc
@@ -2169,7 +2206,7 @@ func (mrb *MrbState) YieldCont(b RProc, self MrbValue, args ...interface{}) Valu
 
 	argc := len(args)
 	if argc == 0 {
-		return Value{C.mrb_yield_cont(mrb.p, b.Value().v, self.Value().v, 0, nil)}
+		return Value{C.mrb_yield_cont(mrb.state(), b.Value().v, self.Value().v, 0, nil)}
 	}
 
 	argv := make([]C.mrb_value, argc)
@@ -2177,20 +2214,20 @@ func (mrb *MrbState) YieldCont(b RProc, self MrbValue, args ...interface{}) Valu
 		argv[i] = mrb.Value(args[i]).v
 	}
 
-	return Value{C.mrb_yield_cont(mrb.p, b.Value().v, self.Value().v, C.mrb_int(argc), &argv[0])}
+	return Value{C.mrb_yield_cont(mrb.state(), b.Value().v, self.Value().v, C.mrb_int(argc), &argv[0])}
 }
 
 // GCProtect protect value from GC
-func (mrb *MrbState) GCProtect(obj MrbValue) { C.mrb_gc_protect(mrb.p, obj.Value().v) }
+func (mrb *MrbState) GCProtect(obj MrbValue) { C.mrb_gc_protect(mrb.state(), obj.Value().v) }
 
 // GCRegister keeps the object from GC. */
 func (mrb *MrbState) GCRegister(obj MrbValue) {
-	C.mrb_gc_register(mrb.p, obj.Value().v)
+	C.mrb_gc_register(mrb.state(), obj.Value().v)
 }
 
 // GCUnregister removes the object from GC root. */
 func (mrb *MrbState) GCUnregister(obj MrbValue) {
-	C.mrb_gc_unregister(mrb.p, obj.Value().v)
+	C.mrb_gc_unregister(mrb.state(), obj.Value().v)
 }
 
 // CheckType check type and raise error on mismatch
@@ -2233,39 +2270,39 @@ func (mrb *MrbState) DefineAlias(klass RClass, name1, name2 string) {
 	defer C.free(unsafe.Pointer(cname1))
 	cname2 := C.CString(name2)
 	defer C.free(unsafe.Pointer(cname2))
-	C.mrb_define_alias(mrb.p, klass.p, cname1, cname2)
+	C.mrb_define_alias(mrb.state(), klass.p, cname1, cname2)
 }
 
 // DefineAliasID defines alias for method name1. Exception is raised if name1 does not exist
 func (mrb *MrbState) DefineAliasID(klass RClass, name1, name2 MrbSym) {
-	C.mrb_define_alias_id(mrb.p, klass.p, C.mrb_sym(name1), C.mrb_sym(name2))
+	C.mrb_define_alias_id(mrb.state(), klass.p, C.mrb_sym(name1), C.mrb_sym(name2))
 }
 
 // ClassName returns name of oruby class
 func (mrb *MrbState) ClassName(klass RClass) string {
-	return C.GoString(C.mrb_class_name(mrb.p, klass.p))
+	return C.GoString(C.mrb_class_name(mrb.state(), klass.p))
 }
 
 // DefineGlobalConst defines global const
 func (mrb *MrbState) DefineGlobalConst(name string, val MrbValue) {
 	cname := C.CString(name)
 	defer C.free(unsafe.Pointer(cname))
-	C.mrb_define_global_const(mrb.p, cname, val.Value().v)
+	C.mrb_define_global_const(mrb.state(), cname, val.Value().v)
 }
 
 // AttrGet sets attr_get :symbol for attribute getter
 func (mrb *MrbState) AttrGet(obj MrbValue, id MrbSym) Value {
-	return Value{C.mrb_attr_get(mrb.p, obj.Value().v, C.mrb_sym(id))}
+	return Value{C.mrb_attr_get(mrb.state(), obj.Value().v, C.mrb_sym(id))}
 }
 
 // RespondTo checks if object responds to method id
 func (mrb *MrbState) RespondTo(obj MrbValue, mid MrbSym) bool {
-	return C.mrb_respond_to(mrb.p, obj.Value().v, C.mrb_sym(mid)) != false
+	return C.mrb_respond_to(mrb.state(), obj.Value().v, C.mrb_sym(mid)) != false
 }
 
 // ObjIsInstanceOf checks if oruby object is direct instance of class
 func (mrb *MrbState) ObjIsInstanceOf(obj MrbValue, klass RClass) bool {
-	return C.mrb_obj_is_instance_of(mrb.p, obj.Value().v, klass.p) != false
+	return C.mrb_obj_is_instance_of(mrb.state(), obj.Value().v, klass.p) != false
 }
 
 // FuncBasicP returns true if function is basic method id
@@ -2273,12 +2310,12 @@ func (mrb *MrbState) FuncBasicP(obj MrbValue, mid MrbSym, f MrbFuncT) bool {
 	m := mrb.MethodSearchVM(mrb.ClassOf(obj), mid)
 
 	p := RProc{C._MRB_METHOD_PROC(m.m), mrb}
-	if p.IsNil() || !p.IsCFunc() || p.HasEnv() {
+	if p.IsNil() || !p.IsCFunc() {
 		return false
 	}
 
-	f2, err := mrb.getFunc(uint(p.EnvGet(0).Int()))
-	if err != nil {
+	f2 := mrb.procFunc(p.p)
+	if f2 == nil {
 		return false
 	}
 
@@ -2295,7 +2332,7 @@ func (mrb *MrbState) FiberResume(fib MrbValue, args ...interface{}) Value {
 		cargs[i] = mrb.Value(args[i-1]).v
 	}
 
-	v := C.mrb_fiber_resume(mrb.p, fib.Value().v, C.mrb_int(argc), &cargs[0])
+	v := C.mrb_fiber_resume(mrb.state(), fib.Value().v, C.mrb_int(argc), &cargs[0])
 	runtime.KeepAlive(cargs)
 
 	return Value{v}
@@ -2307,7 +2344,7 @@ func (mrb *MrbState) FiberYield(args ...interface{}) Value {
 	l := len(args)
 
 	if l == 0 {
-		return Value{C.mrb_fiber_yield(mrb.p, 0, nil)}
+		return Value{C.mrb_fiber_yield(mrb.state(), 0, nil)}
 	}
 
 	a := make([]C.mrb_value, l)
@@ -2315,12 +2352,12 @@ func (mrb *MrbState) FiberYield(args ...interface{}) Value {
 		a[i] = mrb.Value(args[i]).v
 	}
 
-	return Value{C.mrb_fiber_yield(mrb.p, C.mrb_int(l), (*C.mrb_value)(&a[0]))}
+	return Value{C.mrb_fiber_yield(mrb.state(), C.mrb_int(l), (*C.mrb_value)(&a[0]))}
 }
 
 // FiberAliveP check if fiber is alive
 func (mrb *MrbState) FiberAliveP(fib MrbValue) Value {
-	return Value{C.mrb_fiber_alive_p(mrb.p, fib.Value().v)}
+	return Value{C.mrb_fiber_alive_p(mrb.state(), fib.Value().v)}
 }
 
 // EFiberError reference. Implemented in oruby-fiber
@@ -2328,14 +2365,14 @@ func (mrb *MrbState) EFiberError() RClass { return mrb.ExcGet("FiberError") }
 
 // StackExtend extend stack. Error is raised if new stack size > C.MRB_STACK_MAX
 func (mrb *MrbState) StackExtend(size int) {
-	C.mrb_stack_extend(mrb.p, C.mrb_int(size))
+	C.mrb_stack_extend(mrb.state(), C.mrb_int(size))
 }
 
 // MrbPool struct
 type MrbPool struct{ p *C.struct_mrb_pool }
 
 // PoolOpen opens new pool
-func (mrb *MrbState) PoolOpen() MrbPool { return MrbPool{C.mrb_pool_open(mrb.p)} }
+func (mrb *MrbState) PoolOpen() MrbPool { return MrbPool{C.mrb_pool_open(mrb.state())} }
 
 // Close closes pool
 func (pool *MrbPool) Close() { C.mrb_pool_close(pool.p) }
@@ -2357,39 +2394,39 @@ func (pool *MrbPool) CanRealloc(buffer Buff, size uint) bool {
 
 // Alloca temporary memory allocation, only effective while GC arena is kept
 func (mrb *MrbState) Alloca(size uint) Buff {
-	return Buff{C.mrb_alloca(mrb.p, C.size_t(size))}
+	return Buff{C.mrb_alloca(mrb.state(), C.size_t(size))}
 }
 
 // StateAtextit set exis func
 //func (mrb *MrbState) StateAtextit(f MrbAtexitFunc) {
-// C.mrb_state_atexit(mrb.p, f);
+// C.mrb_state_atexit(mrb.state(), f);
 // Unsupported in oruby - use gem with Inject() on exiting
 // visit signal gem from oruby repository for example
 //}
 
 // ShowVersion print oruby version
 func (mrb *MrbState) ShowVersion() {
-	C.mrb_show_version(mrb.p)
+	C.mrb_show_version(mrb.state())
 }
 
 // ShowCopyright print oruby copyright
 func (mrb *MrbState) ShowCopyright() {
-	C.mrb_show_copyright(mrb.p)
+	C.mrb_show_copyright(mrb.state())
 }
 
 // C.mrb_format() is unsupported
 
 // mrb_assert(p) assert(p)
-//func (mrb *MrbState) GC_mark_mt(cl RClass)           { C.mrb_gc_mark_mt(mrb.p, cl.p) }
-//func (mrb *MrbState) GC_mark_mt_size(cl RClass) uint { return uint(C.mrb_gc_mark_mt_size(mrb.p, cl.p)) }
-//func (mrb *MrbState) GC_free_mt(cl RClass)           { C.mrb_gc_free_mt(mrb.p, cl.p) }
+//func (mrb *MrbState) GC_mark_mt(cl RClass)           { C.mrb_gc_mark_mt(mrb.state(), cl.p) }
+//func (mrb *MrbState) GC_mark_mt_size(cl RClass) uint { return uint(C.mrb_gc_mark_mt_size(mrb.state(), cl.p)) }
+//func (mrb *MrbState) GC_free_mt(cl RClass)           { C.mrb_gc_free_mt(mrb.state(), cl.p) }
 
 // GC functions
-//func (mrb *MrbState) GC_mark_hash(hash RHash) { C.mrb_gc_mark_hash(mrb.p, hash.p) }
+//func (mrb *MrbState) GC_mark_hash(hash RHash) { C.mrb_gc_mark_hash(mrb.state(), hash.p) }
 //func (mrb *MrbState) GC_mark_hash_size(hash RHash) int {
-//	return int(C.mrb_gc_mark_hash_size(mrb.p, hash.p))
+//	return int(C.mrb_gc_mark_hash_size(mrb.state(), hash.p))
 //}
-//func (mrb *MrbState) GC_free_hash(hash RHash) { C.mrb_gc_free_hash(mrb.p, hash.p) }
+//func (mrb *MrbState) GC_free_hash(hash RHash) { C.mrb_gc_free_hash(mrb.state(), hash.p) }
 
 //func calc_crc_16_ccitt(src *uint8, nbytes uint, crc uint16) uint16 {
 //	return uint16(C.calc_crc_16_ccitt((*C.uint8_t)(src), C.size_t(nbytes), C.uint16_t(crc)))
@@ -2407,22 +2444,26 @@ func (c MrbContext) CiBase() MrbCallInfo {
 	return MrbCallInfo{c.p.cibase}
 }
 
-func (c MrbContext) Free() { C.mrb_free_context(c.mrb.p, c.p) }
+func (c MrbContext) Free() { C.mrb_free_context(c.mrb.state(), c.p) }
 
 // FreeContext free context
-func (mrb *MrbState) FreeContext(c MrbContext) { C.mrb_free_context(mrb.p, c.p) }
+func (mrb *MrbState) FreeContext(c MrbContext) { C.mrb_free_context(mrb.state(), c.p) }
 
 // Go specific
 
 //export mrb_free_goref
 func mrb_free_goref(cmrb *C.mrb_state, p unsafe.Pointer) {
-	mrb := states[int(C._mrb_get_idx(cmrb))]
+	mrb := getMrbState(cmrb)
+	if f, ok := mrb.getHook(p).(dataFreer); ok {
+		f.dataFree()
+	}
 	mrb.setHook(p, nil)
 }
 
 //export go_gofunc_callback
 func go_gofunc_callback(mrbidx C.mrb_int, self C.mrb_value, idx C.int) C.mrb_value {
-	mrb := states[int(mrbidx)]
+	mrb := getMrbStateIndex(int(mrbidx))
+	mrb.runJobs()
 	var result []reflect.Value
 	var err error
 
@@ -2433,10 +2474,13 @@ func go_gofunc_callback(mrbidx C.mrb_int, self C.mrb_value, idx C.int) C.mrb_val
 	if f.Kind() != reflect.Func {
 		return mrb.ERuntimeError().Raisef("go_gofunc_callback: '%v' reference invalid", mrb.SymString(mrb.GetMID())).v
 	}
+	if p := mrb.profile.Load(); p != nil {
+		defer p.sampleGo(ff)
+	}
 
 	// fetch args
-	args := C.mrb_get_argv(mrb.p)
-	argc := int(C.mrb_get_argc(mrb.p))
+	args := C.mrb_get_argv(mrb.state())
+	argc := int(C.mrb_get_argc(mrb.state()))
 	//argsSlice := (*[1 << 28]C.mrb_value)(unsafe.Pointer(args))[:argc:argc]
 
 	var goself interface{}
@@ -2473,16 +2517,17 @@ func go_gofunc_callback(mrbidx C.mrb_int, self C.mrb_value, idx C.int) C.mrb_val
 
 	// Others as passed
 	for i := rcvr; i < argc+rcvr; i++ {
-		arg := mrb.Intf(Value{C._mrb_get_arg(args, C.int(i-rcvr))})
+		arg := Value{C._mrb_get_arg(args, C.int(i-rcvr))}
 
+		var inType reflect.Type
 		if variadic == 1 && i >= numIn-1 {
-			in[i] = reflect.ValueOf(arg)
-			continue
+			inType = fType.In(numIn - 1).Elem()
+		} else {
+			inType = fType.In(i)
 		}
-
-		inType := fType.In(i)
-		in[i], err = assignValue(arg, inType)
+		in[i], err = mrb.convertValue(arg, inType)
 		if err != nil {
+			err = errorWithContext(err, fmt.Sprintf("%v: argument %d", mrb.SymString(mrb.GetMID()), i-rcvr+1))
 			return mrb.RaiseError(err).v
 		}
 	}
@@ -2506,16 +2551,12 @@ func (mrb *MrbState) DefineModuleFunc(klass RClass, name string, f interface{})
 		panic(fmt.Sprintf("DefineModuleFunc: Expected function, got '%v'", v.Kind()))
 	}
 
-	cname := C.CString(name)
-	defer C.free(unsafe.Pointer(cname))
-
-	argc := v.NumIn()
-	// function reference is set as oruby function env
-	env := mrb.registerFunc(f)
-
-	proc := C.mrb_proc_new_cfunc_with_env(mrb.p, (*[0]byte)(C.set_gofunc_callback), C.mrb_int(1), &env)
-	m := C._MRB_PROC_CFUNC(proc)
-	C.mrb_define_module_function(mrb.p, klass.p, cname, (*[0]byte)(m), C.mrb_aspec(ArgsReq(uint32(argc))))
+	// each proc gets own function handle, released when the proc is garbage collected
+	mid := C.mrb_sym(mrb.Intern(name))
+	aspec := C.mrb_aspec(ArgsReq(uint32(v.NumIn())))
+	C._mrb_proc_new_cfunc(mrb.state(), mrb.SingletonClass(klass).p, mid, C.int(mrb.registerFuncIndex(f)), aspec)
+	C._mrb_proc_new_cfunc(mrb.state(), klass.p, mid, C.int(mrb.registerFuncIndex(f)), aspec)
+	// C.mrb_define_module_function() called through helper
 }
 
 // DefineClassFunc define class func
@@ -2526,15 +2567,10 @@ func (mrb *MrbState) DefineClassFunc(klass RClass, name string, f interface{}) {
 		panic(fmt.Sprintf("DefineClassFunc: Expected func type, got %v", v.Kind()))
 	}
 
-	cname := C.CString(name)
-	defer C.free(unsafe.Pointer(cname))
-
-	argc := v.NumIn()
-	env := mrb.registerFunc(f)
-
-	proc := C.mrb_proc_new_cfunc_with_env(mrb.p, (*[0]byte)(C.set_gofunc_callback), C.mrb_int(1), &env)
-	m := C._MRB_PROC_CFUNC(proc)
-	C.mrb_define_class_method(mrb.p, klass.p, cname, (*[0]byte)(m), C.mrb_aspec(ArgsReq(uint32(argc))))
+	mid := C.mrb_sym(mrb.Intern(name))
+	aspec := C.mrb_aspec(ArgsReq(uint32(v.NumIn())))
+	C._mrb_proc_new_cfunc(mrb.state(), mrb.SingletonClass(klass).p, mid, C.int(mrb.registerFuncIndex(f)), aspec)
+	// C.mrb_define_class_method() called through helper
 }
 
 // DefineSingletonFunc gefine golang singleton func
@@ -2545,22 +2581,17 @@ func (mrb *MrbState) DefineSingletonFunc(obj RValue, name string, f interface{})
 		panic(fmt.Sprintf("DefineSingletonFunc: Expected func type, got %v", v.Kind()))
 	}
 
-	cname := C.CString(name)
-	defer C.free(unsafe.Pointer(cname))
-
-	argc := v.NumIn()
-	env := mrb.registerFunc(f)
-
-	proc := C.mrb_proc_new_cfunc_with_env(mrb.p, (*[0]byte)(C.set_gofunc_callback), C.mrb_int(1), &env)
-	m := C._MRB_PROC_CFUNC(proc)
-	C.mrb_define_singleton_method(mrb.p, obj.RObject().p, cname, (*[0]byte)(m), C.mrb_aspec(ArgsReq(uint32(argc))))
+	mid := C.mrb_sym(mrb.Intern(name))
+	aspec := C.mrb_aspec(ArgsReq(uint32(v.NumIn())))
+	C._mrb_proc_new_cfunc(mrb.state(), mrb.SingletonClass(obj).p, mid, C.int(mrb.registerFuncIndex(f)), aspec)
+	// C.mrb_define_singleton_method() called through helper
 }
 
 // State returns uintptr of C.mrb_state pointer
-func (mrb *MrbState) State() uintptr { return uintptr(unsafe.Pointer(mrb.p)) }
+func (mrb *MrbState) State() uintptr { return uintptr(unsafe.Pointer(mrb.state())) }
 
 // Context returns context
-func (mrb *MrbState) Context() MrbContext { return MrbContext{mrb.p.c, mrb} }
+func (mrb *MrbState) Context() MrbContext { return MrbContext{mrb.state().c, mrb} }
 
 // NilValue helper
 func (mrb *MrbState) NilValue() Value { return nilValue }
@@ -3,6 +3,7 @@ package oruby
 import (
 	"errors"
 	"fmt"
+	"strings"
 	"testing"
 	"time"
 	"unsafe"
@@ -465,3 +466,71 @@ func ExampleMrbState_RunCode() {
 	//"bar"
 	//"baz"
 }
+
+type TestNumbers struct {
+	Small uint8
+	Count int
+}
+
+func TestCheckedConversion(t *testing.T) {
+	mrb, _ := New()
+	defer mrb.Close()
+
+	mrb.DefineGoClass("TestNumbers", &TestNumbers{})
+
+	_, err := mrb.Eval(`n = TestNumbers.new; n.small = 200; n.count = 2**40; n.small`)
+	ExpectNilError(t, err)
+
+	_, err = mrb.Eval(`TestNumbers.new.small = 300`)
+	ExpectErr(t, err, "overflow must raise")
+	Expect(t, err != nil && strings.Contains(err.Error(), "RangeError"), "RangeError expected, got %v", err)
+	Expect(t, err != nil && strings.Contains(err.Error(), "Small"), "field name expected in %v", err)
+
+	_, err = mrb.Eval(`TestNumbers.new.small = -1`)
+	ExpectErr(t, err, "negative value must raise")
+
+	_, err = mrb.Eval(`TestNumbers.new.count = "ten"`)
+	Expect(t, err != nil && strings.Contains(err.Error(), "TypeError"), "TypeError expected, got %v", err)
+
+	// Function arguments
+	mrb.GVSet(mrb.Intern("$byte"), mrb.Value(func(b byte) int { return int(b) }))
+	v, err := mrb.Eval(`$byte.call(255)`)
+	ExpectNilError(t, err)
+	ExpectEql(t, mrb.Intf(v), 255)
+
+	_, err = mrb.Eval(`$byte.call(256)`)
+	Expect(t, err != nil && strings.Contains(err.Error(), "RangeError"), "RangeError expected, got %v", err)
+
+	_, err = mrb.Eval(`$byte.call("x")`)
+	Expect(t, err != nil && strings.Contains(err.Error(), "TypeError"), "TypeError expected, got %v", err)
+
+	// Unsigned values which do not fit into Integer
+	mrb.GVSet(mrb.Intern("$big"), mrb.Value(func() uint64 { return 1 << 63 }))
+	_, err = mrb.Eval(`$big.call`)
+	Expect(t, err != nil && strings.Contains(err.Error(), "RangeError"), "RangeError expected, got %v", err)
+
+	var u8 uint8
+	ExpectErr(t, mrb.Scan(mrb.Value(1000), &u8), "scan overflow must fail")
+}
+
+func TestSnakeCase(t *testing.T) {
+	for in, out := range map[string]string{
+		"ServeHTTP": "serve_http",
+		"IsZero":    "is_zero",
+		"IsNaN":     "is_na_n",
+		"ID":        "id",
+		"X":         "x",
+	} {
+		ExpectEql(t, SnakeCase(in), out)
+	}
+
+	for in, out := range map[string]string{
+		"ServeHTTP": "serve_http",
+		"IsNaN":     "is_nan",
+		"NaN":       "nan",
+		"ParseIPv4": "parse_ipv4",
+		"is_NaN":    "is_nan",
+	} {
+		ExpectEql(t, PackageSnakeCase(in), out)
+	}
+}
@@ -15,34 +15,43 @@ func NEGFIXABLE(f int) bool { return (f >= C.MRB_INT_MIN) }
 // FIXABLE is number fixable
 func FIXABLE(f int) bool { return POSFIXABLE(f) && NEGFIXABLE(f) }
 
+// uintValue converts unsigned integer to Integer value.
+// RangeError is raised if value does not fit into Integer
+func (mrb *MrbState) uintValue(u uint64) Value {
+	if u > C.MRB_INT_MAX {
+		return mrb.ERangeError().Raisef("integer %v too big to convert to Integer", u)
+	}
+	return Value{C.mrb_fixnum_value(C.mrb_int(u))}
+}
+
 // FloatToInteger converts float to fixnum
 func (mrb *MrbState) FloatToInteger(val MrbValue) Value {
-	return Value{C.mrb_float_to_integer(mrb.p, val.Value().v)}
+	return Value{C.mrb_float_to_integer(mrb.state(), val.Value().v)}
 }
 
 // FixnumToStr convert fixnum to strng
 func (mrb *MrbState) IntegerToStr(x MrbValue, base int) Value {
-	return Value{C.mrb_integer_to_str(mrb.p, x.Value().v, C.mrb_int(base))}
+	return Value{C.mrb_integer_to_str(mrb.state(), x.Value().v, C.mrb_int(base))}
 }
 
 // NumAdd fixnum addition
 func (mrb *MrbState) NumAdd(x, y MrbValue) Value {
-	return Value{C.mrb_num_add(mrb.p, x.Value().v, y.Value().v)}
+	return Value{C.mrb_num_add(mrb.state(), x.Value().v, y.Value().v)}
 }
 
 // NumSub fixnum substraction
 func (mrb *MrbState) NumSub(x, y MrbValue) Value {
-	return Value{C.mrb_num_sub(mrb.p, x.Value().v, y.Value().v)}
+	return Value{C.mrb_num_sub(mrb.state(), x.Value().v, y.Value().v)}
 }
 
 // NumMul fixnum multiplication
 func (mrb *MrbState) NumMul(x, y MrbValue) Value {
-	return Value{C.mrb_num_mul(mrb.p, x.Value().v, y.Value().v)}
+	return Value{C.mrb_num_mul(mrb.state(), x.Value().v, y.Value().v)}
 }
 
 // FloatToStr convert fixnum to string
 func (mrb *MrbState) FloatToStr(x MrbValue, fmt string) Value {
 	cfmt := C.CString(fmt)
 	defer C.free(unsafe.Pointer(cfmt))
-	return Value{C.mrb_float_to_str(mrb.p, x.Value().v, cfmt)}
+	return Value{C.mrb_float_to_str(mrb.state(), x.Value().v, cfmt)}
 }
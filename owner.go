@@ -0,0 +1,330 @@
+package oruby
+
+// #include "go-mrb.h"
+import "C"
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// mruby VM is not thread safe, so oruby state must be used by one goroutine at a time.
+// By default that is left to the caller. Starting owner executor or enabling owner check
+// turns on state lock, which is held by goroutine running oruby code. State entry points
+// (Eval, Load*, Funcall) and Do called from other goroutines then take the lock when state
+// is idle. When state is used, their code is passed to goroutine holding the lock, which
+// runs it when oruby code calls Go function, so long running oruby code does not block them.
+// Owner executor holds state lock on one goroutine, running code passed from others.
+// With owner check enabled, use of state from other goroutines panics instead of corrupting memory.
+
+// ownerState keeps owner goroutine of oruby state
+type ownerState struct {
+	sync.Mutex
+	id      int64 // owner goroutine id, used by owner check
+	check   int32 // panic when state is used from other goroutine
+	locking int32 // entry points take state lock
+	exec    *ownerExecutor
+	lock    chan struct{} // state lock, held while code is run
+	holder  uintptr       // OS thread of goroutine holding state lock
+	jobs    chan func()   // code of other goroutines, run by lock holder
+	idle    chan func()   // code run by executor only when state is idle
+	waiting int32         // number of jobs waiting for lock holder
+	closed  chan struct{} // closed with state
+}
+
+// ownerExecutor is goroutine running functions on oruby state
+type ownerExecutor struct {
+	id   int64
+	quit chan struct{}
+	done chan struct{}
+}
+
+func newOwnerState() ownerState {
+	return ownerState{
+		lock:   make(chan struct{}, 1),
+		jobs:   make(chan func()),
+		idle:   make(chan func()),
+		closed: make(chan struct{}),
+	}
+}
+
+// goroutineID returns id of current goroutine, parsed from stack trace header
+func goroutineID() int64 {
+	var buf [64]byte
+	b := buf[:runtime.Stack(buf[:], false)]
+	b = bytes.TrimPrefix(b, []byte("goroutine "))
+	if i := bytes.IndexByte(b, ' '); i > 0 {
+		b = b[:i]
+	}
+	id, _ := strconv.ParseInt(string(b), 10, 64)
+	return id
+}
+
+// StartExecutor starts owner goroutine of oruby state. State entry points Eval, LoadString,
+// LoadBytesCxt, Funcall and FuncallWithBlock called from other goroutines are run on owner
+// goroutine, and callers wait for result. Other code can be run on owner goroutine with Do.
+// It must be called when state is not used by other goroutines, as it turns on state lock.
+// Executor is stopped when state is closed
+func (mrb *MrbState) StartExecutor() {
+	mrb.owner.Lock()
+	defer mrb.owner.Unlock()
+
+	if mrb.owner.exec != nil {
+		return
+	}
+
+	mrb.lockState()
+
+	e := &ownerExecutor{quit: make(chan struct{}), done: make(chan struct{})}
+	ready := make(chan struct{})
+
+	go func() {
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+		defer close(e.done)
+
+		e.id = goroutineID()
+		close(ready)
+
+		// executor waits for state used by other goroutine, running its jobs meanwhile
+		mrb.acquire()
+		defer mrb.release()
+
+		for {
+			select {
+			case job := <-mrb.owner.jobs:
+				job()
+			case job := <-mrb.owner.idle:
+				job()
+			case <-e.quit:
+				return
+			}
+		}
+	}()
+	<-ready
+
+	mrb.owner.exec = e
+	atomic.StoreInt64(&mrb.owner.id, e.id)
+}
+
+// stopExecutor stops owner goroutine and waits until it releases state lock
+func (mrb *MrbState) stopExecutor() {
+	mrb.owner.Lock()
+	e := mrb.owner.exec
+	mrb.owner.exec = nil
+	mrb.owner.Unlock()
+
+	if e != nil {
+		close(e.quit)
+		<-e.done
+		atomic.StoreInt64(&mrb.owner.id, 0)
+	}
+}
+
+func (mrb *MrbState) executor() *ownerExecutor {
+	mrb.owner.Lock()
+	defer mrb.owner.Unlock()
+
+	return mrb.owner.exec
+}
+
+// SetOwnerCheck enables or disables owner goroutine check, which is debug mode
+// for finding unsafe use of state. When enabled, use of state from goroutine other than
+// owner panics, unless the goroutine holds state lock, like Go function called by oruby.
+// Owner is executor goroutine if it is started, or goroutine enabling the check.
+// Enabling the check turns on state lock, which stays on when check is disabled
+func (mrb *MrbState) SetOwnerCheck(enabled bool) {
+	if !enabled {
+		atomic.StoreInt32(&mrb.owner.check, 0)
+		return
+	}
+
+	mrb.lockState()
+	if mrb.executor() == nil {
+		atomic.StoreInt64(&mrb.owner.id, goroutineID())
+	}
+	atomic.StoreInt32(&mrb.owner.check, 1)
+}
+
+// lockState turns on state lock, so entry points called from other goroutines
+// wait for goroutine using the state
+func (mrb *MrbState) lockState() {
+	atomic.StoreInt32(&mrb.owner.locking, 1)
+}
+
+// IsOwner returns true if state is used from its owner goroutine,
+// or if state has no owner
+func (mrb *MrbState) IsOwner() bool {
+	owner := atomic.LoadInt64(&mrb.owner.id)
+	return owner == 0 || owner == goroutineID()
+}
+
+// state returns C state. It is choke point of state use, which is checked
+// when owner check is enabled
+func (mrb *MrbState) state() *C.mrb_state {
+	if atomic.LoadInt32(&mrb.owner.check) != 0 {
+		mrb.checkOwner()
+	}
+	return mrb.p
+}
+
+// checkOwner panics if owner check is enabled and state is used from other goroutine,
+// which does not hold state lock
+func (mrb *MrbState) checkOwner() {
+	if atomic.LoadInt32(&mrb.owner.check) == 0 || mrb.holding() {
+		return
+	}
+
+	owner := atomic.LoadInt64(&mrb.owner.id)
+	if id := goroutineID(); owner != 0 && id != owner {
+		panic(fmt.Sprintf("oruby: state owned by goroutine %d used from goroutine %d, run code on owner goroutine with mrb.Do", owner, id))
+	}
+}
+
+// Do runs f on goroutine using the state and waits for it to finish, when state lock is
+// turned on by StartExecutor or SetOwnerCheck. f is called directly when state lock is off,
+// or when Do is called from goroutine running oruby code, like Go function called by oruby,
+// or from owner goroutine. Panic in f is raised again in calling goroutine.
+//
+// Go function called by oruby must not wait for other goroutine which uses the state, as
+// that goroutine waits for the state until the function returns to oruby code
+func (mrb *MrbState) Do(f func(mrb *MrbState)) {
+	if !mrb.remote(func() { f(mrb) }) {
+		f(mrb)
+	}
+}
+
+// remote runs f holding state lock and returns true, when state lock is on and state is
+// used from goroutine which does not hold the lock. Lock is taken when state is idle, or f
+// is run by goroutine holding the lock. Otherwise false is returned, so caller continues
+// on current goroutine
+func (mrb *MrbState) remote(f func()) bool {
+	if atomic.LoadInt32(&mrb.owner.locking) == 0 || mrb.holding() {
+		return false
+	}
+	mrb.wait(f, mrb.owner.jobs, true)
+	return true
+}
+
+// exclusive runs f holding state lock when oruby code is not executed,
+// like closing the state
+func (mrb *MrbState) exclusive(f func()) {
+	if atomic.LoadInt32(&mrb.owner.locking) == 0 || mrb.holding() {
+		f()
+		return
+	}
+	mrb.wait(f, mrb.owner.idle, false)
+}
+
+// wait runs f when state lock is taken, or passes it to lock holder through jobs.
+// When interrupt is set, lock holder runs f when oruby code calls Go function
+func (mrb *MrbState) wait(f func(), jobs chan func(), interrupt bool) {
+	var p interface{}
+	done := make(chan struct{})
+	job := func() {
+		defer close(done)
+		defer func() { p = recover() }()
+		f()
+	}
+
+	if interrupt && !mrb.waitJob(1) {
+		panic("oruby: state is closed")
+	}
+	select {
+	case mrb.owner.lock <- struct{}{}:
+		if interrupt {
+			mrb.waitJob(-1)
+		}
+		select {
+		case <-mrb.owner.closed:
+			<-mrb.owner.lock
+			panic("oruby: state is closed")
+		default:
+		}
+		mrb.hold()
+		job()
+		mrb.release()
+	case jobs <- job:
+		if interrupt {
+			mrb.waitJob(-1)
+		}
+		<-done
+	case <-mrb.owner.closed:
+		panic("oruby: state is closed")
+	}
+
+	if p != nil {
+		panic(p)
+	}
+}
+
+// holding returns true if current goroutine holds state lock. Holder is locked to its
+// OS thread while lock is held, so no other goroutine runs on the same thread
+func (mrb *MrbState) holding() bool {
+	holder := atomic.LoadUintptr(&mrb.owner.holder)
+	return holder != 0 && holder == uintptr(C._gomrb_thread())
+}
+
+// acquire waits for state lock, running jobs of other goroutines while state is used
+func (mrb *MrbState) acquire() {
+	mrb.owner.lock <- struct{}{}
+	mrb.hold()
+}
+
+// hold marks current goroutine as holder of taken state lock
+func (mrb *MrbState) hold() {
+	runtime.LockOSThread()
+	atomic.StoreUintptr(&mrb.owner.holder, uintptr(C._gomrb_thread()))
+}
+
+// release releases state lock held by current goroutine
+func (mrb *MrbState) release() {
+	atomic.StoreUintptr(&mrb.owner.holder, 0)
+	runtime.UnlockOSThread()
+	<-mrb.owner.lock
+}
+
+// waitJob counts jobs waiting for lock holder, so they are run while code is executed.
+// It returns false when state is closed
+func (mrb *MrbState) waitJob(n int32) bool {
+	select {
+	case <-mrb.owner.closed:
+		return false
+	default:
+	}
+
+	atomic.AddInt32(&mrb.owner.waiting, n)
+	return true
+}
+
+// closeOwner marks state closed, so waiting goroutines panic instead of using it
+func (mrb *MrbState) closeOwner() {
+	mrb.owner.Lock()
+	defer mrb.owner.Unlock()
+
+	close(mrb.owner.closed)
+}
+
+// runJobs runs waiting jobs of other goroutines. It is called by lock holder when oruby
+// code calls Go function, where VM can run other code. Pending exception of calling code
+// is kept
+func (mrb *MrbState) runJobs() {
+	if atomic.LoadInt32(&mrb.owner.waiting) == 0 {
+		return
+	}
+
+	exc := mrb.p.exc
+	defer func() { mrb.p.exc = exc }()
+
+	for {
+		select {
+		case job := <-mrb.owner.jobs:
+			job()
+		default:
+			return
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package oruby
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestMrbState_Executor(t *testing.T) {
+	mrb, _ := NewCore()
+	defer mrb.Close()
+
+	mrb.StartExecutor()
+	Expect(t, !mrb.IsOwner(), "test goroutine should not be owner")
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				v, err := mrb.Eval(fmt.Sprintf("%d * %d", g, i))
+				if err != nil || v.Int() != g*i {
+					t.Errorf("expected %v, got %v (%v)", g*i, v.Int(), err)
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	owner := false
+	mrb.Do(func(mrb *MrbState) {
+		owner = mrb.IsOwner()
+		mrb.Do(func(mrb *MrbState) { mrb.GVSet(mrb.Intern("$x"), mrb.StringValue("nested")) })
+	})
+	Expect(t, owner, "Do should run on owner goroutine")
+
+	v, err := mrb.Eval("$x")
+	ExpectNilError(t, err)
+	ExpectEql(t, v.String(), "nested")
+}
+
+func TestMrbState_ExecutorPanic(t *testing.T) {
+	mrb, _ := NewCore()
+	defer mrb.Close()
+
+	mrb.StartExecutor()
+
+	defer func() {
+		r := recover()
+		ExpectEql(t, r, "boom")
+	}()
+	mrb.Do(func(mrb *MrbState) { panic("boom") })
+}
+
+func TestMrbState_OwnerCheck(t *testing.T) {
+	mrb, _ := NewCore()
+	defer mrb.Close()
+
+	mrb.SetOwnerCheck(true)
+	_, err := mrb.Eval("1")
+	ExpectNilError(t, err)
+
+	// entry points take state lock
+	var r interface{}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer func() { r = recover() }()
+		v, err := mrb.Eval("1")
+		ExpectNilError(t, err)
+		ExpectEql(t, v.Int(), 1)
+		mrb.Value("x")
+	}()
+	<-done
+
+	msg, _ := r.(string)
+	Expect(t, strings.Contains(msg, "mrb.Do"), "use from other goroutine should panic, got %v", r)
+
+	mrb.SetOwnerCheck(false)
+	done = make(chan struct{})
+	go func() {
+		defer close(done)
+		mrb.Value("x")
+	}()
+	<-done
+}
+
+func TestMrbState_Unlocked(t *testing.T) {
+	mrb, _ := NewCore()
+	defer mrb.Close()
+
+	// without executor and owner check, Do runs f on calling goroutine
+	id := goroutineID()
+	mrb.Do(func(mrb *MrbState) {
+		ExpectEql(t, goroutineID(), id)
+		Expect(t, !mrb.holding(), "state lock should not be taken")
+	})
+	ExpectEql(t, atomic.LoadInt32(&mrb.owner.locking), int32(0))
+}
+
+func TestMrbState_DoInterrupt(t *testing.T) {
+	mrb, _ := NewCore()
+	defer mrb.Close()
+
+	started := make(chan struct{})
+	mrb.KernelModule().DefineMethod("started", func(mrb *MrbState, self Value) MrbValue {
+		close(started)
+		return nilValue
+	}, mrb.ArgsNone())
+	mrb.KernelModule().DefineMethod("poll", func(mrb *MrbState, self Value) MrbValue {
+		return nilValue
+	}, mrb.ArgsNone())
+	mrb.StartExecutor()
+
+	result := make(chan RValue)
+	go func() {
+		v, err := mrb.Eval("started; i = 0; (i += 1; poll) until $stop; i")
+		ExpectNilError(t, err)
+		result <- v
+	}()
+	<-started
+
+	// Do is run by goroutine executing the loop, when it calls Go function
+	mrb.Do(func(mrb *MrbState) { mrb.GVSet(mrb.Intern("$stop"), mrb.TrueValue()) })
+	Expect(t, (<-result).Int() > 0, "loop should run until interrupted")
+
+	v, err := mrb.Eval("$stop")
+	ExpectNilError(t, err)
+	ExpectEql(t, v.Bool(), true)
+}
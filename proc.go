@@ -27,7 +27,7 @@ func (e REnv) IsNil() bool { return e.p == nil }
 func (e REnv) Len() int { return int(C._MRB_ENV_LEN(e.p)) }
 
 // Unshare stack unshares Env
-func (e REnv) Unshare() { C.mrb_env_unshare(e.mrb.p, e.p, true) }
+func (e REnv) Unshare() { C.mrb_env_unshare(e.mrb.state(), e.p, true) }
 
 // Stack stack unshares Env
 func (e REnv) Stack(index int) Value {
@@ -41,17 +41,17 @@ func (e REnv) Stack(index int) Value {
 
 // EnvUnshare unshares Env
 func (mrb *MrbState) EnvUnshare(env REnv, noraise bool) {
-	C.mrb_env_unshare(mrb.p, env.p, C.mrb_bool(noraise))
+	C.mrb_env_unshare(mrb.state(), env.p, C.mrb_bool(noraise))
 }
 
 // SetEnv creates and sets new Env object with stack Values
 func (p RProc) SetEnv(stackItems ...Value) {
 	if len(stackItems) == 0 {
-		C._mrb_create_env(p.mrb.p, p.p, 0, nil)
+		C._mrb_create_env(p.mrb.state(), p.p, 0, nil)
 		return
 	}
 
-	C._mrb_create_env(p.mrb.p, p.p, C.mrb_int(len(stackItems)), &(stackItems[0].v))
+	C._mrb_create_env(p.mrb.state(), p.p, C.mrb_int(len(stackItems)), &(stackItems[0].v))
 }
 
 // AdjustStackLength of toplevel environment. Used in imrb
@@ -150,22 +150,12 @@ func (p RProc) Data() interface{} {
 		return nil
 	}
 
-	if p.HasEnv() {
-		i := p.mrb.ProcCFuncEnvGet(0)
-		if i.IsInteger() {
-			return p.mrb.getMrbFuncT(uint(i.Int()))
-		}
-	}
-
-	p.mrb.Lock()
-	defer p.mrb.Unlock()
-
-	return p.mrb.mrbProcs[unsafe.Pointer(p.p)]
+	return p.mrb.procFunc(p.p)
 }
 
 // SetTargetClass sets target class for proc
 func (p RProc) SetTargetClass(c RClass) {
-	C._MRB_PROC_SET_TARGET_CLASS(c.mrb.p, p.p, c.p)
+	C._MRB_PROC_SET_TARGET_CLASS(c.mrb.state(), p.p, c.p)
 }
 
 // TargetClass sets target class for proc
@@ -175,7 +165,7 @@ func (p RProc) TargetClass() RClass {
 
 // Load procedure
 func (p RProc) Load() Value {
-	return Value{C.mrb_load_proc(p.mrb.p, p.p)}
+	return Value{C.mrb_load_proc(p.mrb.state(), p.p)}
 }
 
 // MrbAspecReq required
@@ -234,7 +224,9 @@ func MrbProcPtr(v MrbValue) RProcPtr { return RProcPtr{(*C.struct_RProc)(C._mrb_
 func MrbProcValue(p RProc) Value { return p.Value() }
 
 // ProcNew creates new RProc from irep
-func (mrb *MrbState) ProcNew(irep MrbIrep) RProc { return RProc{C.mrb_proc_new(mrb.p, irep.p), mrb} }
+func (mrb *MrbState) ProcNew(irep MrbIrep) RProc {
+	return RProc{C.mrb_proc_new(mrb.state(), irep.p), mrb}
+}
 
 // RProc returns RProc struct from proc ruby value, or nil if vale is not proc
 func (mrb *MrbState) RProc(v MrbValue) RProc {
@@ -258,12 +250,10 @@ func (p RProc) NLocals() int {
 	return int(C._mrb_rproc_nlocals(p.p))
 }
 
-// ProcNewCFunc creaetes new RProc from go function
+// ProcNewCFunc creaetes new RProc from go function.
+// Function reference is kept in proc env, and released when proc is garbage collected
 func (mrb *MrbState) ProcNewCFunc(f MrbFuncT) RProc {
-	p := C.mrb_proc_new_cfunc(mrb.p, (*[0]byte)(C.set_mrb_proc_callback))
-	mrb.Lock()
-	mrb.mrbProcs[unsafe.Pointer(p)] = f
-	mrb.Unlock()
+	p := C._mrb_proc_new_mrbfunc(mrb.state(), C.int(mrb.registerFuncIndex(f)), 0, nil)
 	return RProc{p, mrb}
 }
 
@@ -277,10 +267,8 @@ func (mrb *MrbState) ProcNewGofunc(f interface{}) RProc {
 
 // ClosureNewCfunc creates new closure from Go function
 func (mrb *MrbState) ClosureNewCfunc(f MrbFuncT, nlocals int32) RProc {
-	p := C.mrb_closure_new_cfunc(mrb.p, (*[0]byte)(C.set_mrb_proc_callback), C.int(nlocals))
-	mrb.Lock()
-	mrb.mrbProcs[unsafe.Pointer(p)] = f
-	mrb.Unlock()
+	// C.mrb_closure_new_cfunc() called through helper, locals are followed by function reference
+	p := C._mrb_proc_new_mrbfunc(mrb.state(), C.int(mrb.registerFuncIndex(f)), C.mrb_int(nlocals), nil)
 	return RProc{p, mrb}
 }
 
@@ -296,18 +284,16 @@ func (mrb *MrbState) ProcNewCFuncWithEnv(f MrbFuncT, env ...MrbValue) RProc {
 		argv[i] = mrb.Value(env[i]).v
 	}
 
-	p := C.mrb_proc_new_cfunc_with_env(mrb.p, (*[0]byte)(C.set_mrb_proc_callback), C.mrb_int(argc), &argv[0])
+	// env values are followed by function reference
+	p := C._mrb_proc_new_mrbfunc(mrb.state(), C.int(mrb.registerFuncIndex(f)), C.mrb_int(argc), &argv[0])
 	runtime.KeepAlive(argv)
 
-	mrb.Lock()
-	mrb.mrbProcs[unsafe.Pointer(p)] = f
-	mrb.Unlock()
 	return RProc{p, mrb}
 }
 
 // ProcCFuncEnvGet retreive function env
 func (mrb *MrbState) ProcCFuncEnvGet(index int) Value {
-	return Value{C.mrb_proc_cfunc_env_get(mrb.p, C.mrb_int(index))}
+	return Value{C.mrb_proc_cfunc_env_get(mrb.state(), C.mrb_int(index))}
 }
 
 // ProcNewGofuncWithEnv new Go function with env
@@ -328,7 +314,7 @@ func (mrb *MrbState) ProcNewGofuncWithEnv(f interface{}, env ...interface{}) (RP
 		args[i] = mrb.Value(env[i-1]).v
 	}
 
-	proc := C.mrb_proc_new_cfunc_with_env(mrb.p, (*[0]byte)(C.set_gofunc_callback), C.mrb_int(argc), &args[0])
+	proc := C.mrb_proc_new_cfunc_with_env(mrb.state(), (*[0]byte)(C.set_gofunc_callback), C.mrb_int(argc), &args[0])
 
 	runtime.KeepAlive(args)
 	return RProc{proc, mrb}, ArgsReq(uint32(v.Type().NumIn()))
@@ -336,7 +322,7 @@ func (mrb *MrbState) ProcNewGofuncWithEnv(f interface{}, env ...interface{}) (RP
 
 // LoadProc loads and executes proc
 func (mrb *MrbState) LoadProc(proc RProc) Value {
-	return Value{C.mrb_load_proc(mrb.p, proc.p)}
+	return Value{C.mrb_load_proc(mrb.state(), proc.p)}
 }
 
 // ProcSet sets proc on call info
@@ -0,0 +1,329 @@
+package oruby
+
+// #include "go-mrb.h"
+import "C"
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"reflect"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unsafe"
+)
+
+// ProfileRate is sampling rate of oruby profiler in samples per second
+const ProfileRate = 100
+
+// profileMaxDepth is maximal number of sampled call stack frames
+const profileMaxDepth = 64
+
+// profileFrame is call stack frame of profile sample
+type profileFrame struct {
+	name     string
+	filename string
+	line     int
+}
+
+// profileSample is call stack, with number of ticks it was sampled
+type profileSample struct {
+	stack []profileFrame // leaf frame first
+	ticks int64
+}
+
+// cpuProfile collects call stack samples of oruby code
+type cpuProfile struct {
+	mrb      *MrbState
+	w        io.Writer
+	start    time.Time
+	end      time.Time
+	stop     chan struct{}
+	mu       sync.Mutex
+	samples  map[string]*profileSample
+	interval time.Duration
+}
+
+// StartCPUProfile starts profiling oruby code, sampling call stack of oruby
+// methods and bound Go functions. Profile is written to w in pprof format when
+// StopCPUProfile is called, so it can be viewed with go tool pprof. Time spent
+// in Go functions called from oruby is reported with Go function as leaf frame.
+// Time while VM does not run oruby code is not sampled
+func (mrb *MrbState) StartCPUProfile(w io.Writer) error {
+	p := &cpuProfile{
+		mrb:      mrb,
+		w:        w,
+		start:    time.Now(),
+		stop:     make(chan struct{}),
+		samples:  make(map[string]*profileSample),
+		interval: time.Second / ProfileRate,
+	}
+	if !mrb.profile.CompareAndSwap(nil, p) {
+		return errors.New("oruby profiling already in use")
+	}
+
+	start := func() { C._mrb_set_profile(mrb.state(), iifmb(true)) }
+	if !mrb.remote(start) {
+		start()
+	}
+
+	// Ticker is stopped before state is closed, as ticks are counted in C state
+	mrb.WaitGroup.Add(1)
+	go func() {
+		defer mrb.WaitGroup.Done()
+		t := time.NewTicker(p.interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+				// tick only increments atomic counter, so it is not checked for state owner
+				C._mrb_profile_tick(mrb.p)
+			case <-p.stop:
+				return
+			case <-mrb.exitChan:
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// StopCPUProfile stops profiling started with StartCPUProfile, and writes profile
+func (mrb *MrbState) StopCPUProfile() error {
+	// Only caller which swaps profile out stops it, so concurrent calls close it once
+	p := mrb.profile.Swap(nil)
+	if p == nil {
+		return nil
+	}
+
+	close(p.stop)
+	if mrb.p != nil {
+		stop := func() { C._mrb_set_profile(mrb.state(), iifmb(false)) }
+		if !mrb.remote(stop) {
+			stop()
+		}
+	}
+
+	p.end = time.Now()
+	return p.write()
+}
+
+// add adds ticks to samples of call stack
+func (p *cpuProfile) add(stack []profileFrame, ticks int64) {
+	var key strings.Builder
+	for _, f := range stack {
+		key.WriteString(f.name)
+		key.WriteByte(0)
+		key.WriteString(f.filename)
+		key.WriteByte(0)
+		key.WriteString(strconv.Itoa(f.line))
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if s, ok := p.samples[key.String()]; ok {
+		s.ticks += ticks
+		return
+	}
+	p.samples[key.String()] = &profileSample{stack, ticks}
+}
+
+// callStack returns oruby call stack. irep and pc are current instruction,
+// or nil irep when C or Go function is running
+func (mrb *MrbState) callStack(irep *C.mrb_irep, pc uint32) []profileFrame {
+	var frames [profileMaxDepth]C.gomrb_frame
+	n := int(C._mrb_callstack(mrb.state(), irep, C.uint32_t(pc), &frames[0], C.int(len(frames))))
+
+	stack := make([]profileFrame, 0, n)
+	for i, f := range frames[:n] {
+		name := "<main>"
+		if f.mid != 0 {
+			name = mrb.SymString(MrbSym(f.mid))
+			if f.klass != nil {
+				name = C.GoString(C.mrb_class_name(mrb.state(), f.klass)) + "#" + name
+			}
+		} else if f.klass != nil && f.klass != mrb.state().object_class && i < n-1 {
+			name = "<class:" + C.GoString(C.mrb_class_name(mrb.state(), f.klass)) + ">"
+		}
+		if f.block {
+			name = "block in " + name
+		}
+
+		frame := profileFrame{name: name}
+		if f.irep != nil {
+			irep := MrbIrep{(*C.mrb_irep)(unsafe.Pointer(f.irep)), mrb}
+			frame.filename = mrb.DebugGetFilename(irep, uint32(f.pc))
+			if line := int32(mrb.DebugGetLine(irep, uint32(f.pc))); line > 0 {
+				frame.line = int(line)
+			}
+		}
+		stack = append(stack, frame)
+	}
+	return stack
+}
+
+//export go_profile_callback
+func go_profile_callback(mrbidx C.mrb_int, ticks C.uint32_t, irep *C.mrb_irep, pc C.uint32_t) {
+	mrb := getMrbStateIndex(int(mrbidx))
+	if p := mrb.profile.Load(); p != nil {
+		p.add(mrb.callStack(irep, uint32(pc)), int64(ticks))
+	}
+}
+
+// sampleGo samples ticks counted while Go function f called from oruby was running
+func (p *cpuProfile) sampleGo(f interface{}) {
+	ticks := int64(C._mrb_profile_ticks(p.mrb.state()))
+	if ticks == 0 {
+		return
+	}
+
+	stack := p.mrb.callStack(nil, 0)
+	if fn := runtime.FuncForPC(reflect.ValueOf(f).Pointer()); fn != nil {
+		file, line := fn.FileLine(fn.Entry())
+		stack = append([]profileFrame{{fn.Name(), file, line}}, stack...)
+	}
+	p.add(stack, ticks)
+}
+
+// write writes profile in gzipped profile.proto format
+func (p *cpuProfile) write() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	b := &profileBuilder{strings: map[string]int{"": 0}, table: []string{""}}
+	b.functions = map[[2]string]uint64{}
+	b.locations = map[profileFrame]uint64{}
+
+	// Profile.sample_type, samples/count and cpu/nanoseconds as in Go CPU profiles
+	b.valueType(1, "samples", "count")
+	b.valueType(1, "cpu", "nanoseconds")
+
+	keys := make([]string, 0, len(p.samples))
+	for k := range p.samples {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		s := p.samples[k]
+		var sample protoBuffer
+		ids := make([]uint64, len(s.stack))
+		for i, f := range s.stack {
+			ids[i] = b.location(f)
+		}
+		sample.packed(1, ids)
+		sample.packed(2, []uint64{uint64(s.ticks), uint64(s.ticks * int64(p.interval))})
+		b.buf.bytes(2, sample.data)
+	}
+
+	b.buf.data = append(b.buf.data, b.locs.data...)
+	b.buf.data = append(b.buf.data, b.funcs.data...)
+	for _, s := range b.table {
+		b.buf.bytes(6, []byte(s))
+	}
+	b.buf.varint(9, uint64(p.start.UnixNano()))
+	b.buf.varint(10, uint64(p.end.Sub(p.start)))
+	b.valueType(11, "cpu", "nanoseconds")
+	b.buf.varint(12, uint64(p.interval))
+
+	zw := gzip.NewWriter(p.w)
+	if _, err := zw.Write(b.buf.data); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// profileBuilder encodes profile.proto messages
+type profileBuilder struct {
+	buf       protoBuffer // Profile
+	locs      protoBuffer // Profile.location messages
+	funcs     protoBuffer // Profile.function messages
+	strings   map[string]int
+	table     []string
+	functions map[[2]string]uint64
+	locations map[profileFrame]uint64
+}
+
+func (b *profileBuilder) str(s string) uint64 {
+	if i, ok := b.strings[s]; ok {
+		return uint64(i)
+	}
+	b.strings[s] = len(b.table)
+	b.table = append(b.table, s)
+	return uint64(len(b.table) - 1)
+}
+
+func (b *profileBuilder) valueType(field int, typ, unit string) {
+	var vt protoBuffer
+	vt.varint(1, b.str(typ))
+	vt.varint(2, b.str(unit))
+	b.buf.bytes(field, vt.data)
+}
+
+// location returns id of Location of frame, adding Location and Function if needed
+func (b *profileBuilder) location(f profileFrame) uint64 {
+	if id, ok := b.locations[f]; ok {
+		return id
+	}
+
+	fk := [2]string{f.name, f.filename}
+	fid, ok := b.functions[fk]
+	if !ok {
+		fid = uint64(len(b.functions) + 1)
+		b.functions[fk] = fid
+		var fn protoBuffer
+		fn.varint(1, fid)
+		fn.varint(2, b.str(f.name))
+		fn.varint(3, b.str(f.name))
+		fn.varint(4, b.str(f.filename))
+		b.funcs.bytes(5, fn.data)
+	}
+
+	id := uint64(len(b.locations) + 1)
+	b.locations[f] = id
+	var line, loc protoBuffer
+	line.varint(1, fid)
+	line.varint(2, uint64(f.line))
+	loc.varint(1, id)
+	loc.bytes(4, line.data)
+	b.locs.bytes(4, loc.data)
+	return id
+}
+
+// protoBuffer encodes protocol buffer fields
+type protoBuffer struct {
+	data []byte
+}
+
+func (b *protoBuffer) uvarint(x uint64) {
+	for x >= 0x80 {
+		b.data = append(b.data, byte(x)|0x80)
+		x >>= 7
+	}
+	b.data = append(b.data, byte(x))
+}
+
+func (b *protoBuffer) varint(field int, x uint64) {
+	if x == 0 {
+		return
+	}
+	b.uvarint(uint64(field) << 3)
+	b.uvarint(x)
+}
+
+func (b *protoBuffer) bytes(field int, data []byte) {
+	b.uvarint(uint64(field)<<3 | 2)
+	b.uvarint(uint64(len(data)))
+	b.data = append(b.data, data...)
+}
+
+func (b *protoBuffer) packed(field int, xs []uint64) {
+	var p protoBuffer
+	for _, x := range xs {
+		p.uvarint(x)
+	}
+	b.bytes(field, p.data)
+}
@@ -0,0 +1,70 @@
+package oruby
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func profileSleep() {
+	time.Sleep(100 * time.Millisecond)
+}
+
+func TestMrbState_StartCPUProfile(t *testing.T) {
+	mrb, _ := NewCore()
+	defer mrb.Close()
+
+	mrb.DefineMethodFunc(mrb.KernelModule(), "go_sleep", profileSleep)
+
+	var buf bytes.Buffer
+	ExpectNilError(t, mrb.StartCPUProfile(&buf))
+	ExpectErr(t, mrb.StartCPUProfile(io.Discard), "second profile should fail")
+
+	_, err := mrb.LoadString("def busy\n  t = 0\n  100000.times { |i| t += i }\n  go_sleep\nend\nbusy\n")
+	ExpectNilError(t, err)
+	ExpectNilError(t, mrb.StopCPUProfile())
+
+	zr, err := gzip.NewReader(&buf)
+	ExpectNilError(t, err)
+	data, err := io.ReadAll(zr)
+	ExpectNilError(t, err)
+
+	for _, s := range []string{"Object#busy", "Kernel#go_sleep", "oruby.profileSleep", "profile_test.go", "nanoseconds"} {
+		Expect(t, strings.Contains(string(data), s), "profile should contain %v", s)
+	}
+}
+
+func TestMrbState_StopCPUProfileConcurrent(t *testing.T) {
+	mrb, _ := NewCore()
+	defer mrb.Close()
+
+	ExpectNilError(t, mrb.StartCPUProfile(io.Discard))
+
+	// Profile is stopped once, other calls see it already stopped
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := mrb.StopCPUProfile(); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	ExpectNilError(t, mrb.StartCPUProfile(io.Discard))
+	ExpectNilError(t, mrb.StopCPUProfile())
+}
+
+func TestProtoBuffer(t *testing.T) {
+	var b protoBuffer
+	b.varint(1, 150)
+	b.bytes(2, []byte("ab"))
+	b.packed(3, []uint64{1, 300})
+	ExpectEql(t, b.data, []byte{0x08, 0x96, 0x01, 0x12, 2, 'a', 'b', 0x1a, 3, 1, 0xac, 0x02})
+}
@@ -0,0 +1,135 @@
+package oruby
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// Program is oruby script compiled to RITE bytecode. Program is not bound to oruby state
+// and it is not modified after compilation, so it can be shared by goroutines and run
+// in many states without parsing script again
+type Program struct {
+	filename string
+	code     []byte
+	locals   []string
+}
+
+// Compile parses and compiles oruby source once, returning Program which can be run in any state.
+// Filename is kept in debug info, so it is shown in backtraces. Locals are names of local
+// variables which are declared to parser, as in EvalWith, and which are set by Run
+func Compile(source, filename string, locals ...string) (*Program, error) {
+	if err := checkLocals(locals); err != nil {
+		return nil, err
+	}
+
+	mrb, err := NewCore()
+	if err != nil {
+		return nil, err
+	}
+	defer mrb.Close()
+
+	cxt := mrb.MrbcContextNew()
+	defer cxt.Free()
+	cxt.SetCaptureErrors(true)
+	if filename != "" {
+		cxt.Filename(filename)
+	}
+	cxt.SetLocals(locals...)
+
+	p, err := mrb.ParseString(source, cxt)
+	if err != nil {
+		return nil, err
+	}
+	defer p.Free()
+
+	if err := parserErrors(p, filename); err != nil {
+		return nil, err
+	}
+
+	proc, err := mrb.GenerateCode(p)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if _, err := mrb.DumpIrep(proc.IRep(), DumpDebugInfo, &buf); err != nil {
+		return nil, err
+	}
+
+	return &Program{filename, buf.Bytes(), append([]string(nil), locals...)}, nil
+}
+
+// NewProgram creates Program from RITE bytecode, as created by DumpIrep or orubyc.
+// Locals are names of locals, in order they were declared when code was compiled
+func NewProgram(code []byte, filename string, locals ...string) (*Program, error) {
+	if !bytes.HasPrefix(code, []byte(RiteBinaryIdent)) {
+		return nil, errors.New("program is not RITE bytecode")
+	}
+	if err := checkLocals(locals); err != nil {
+		return nil, err
+	}
+	return &Program{filename, append([]byte(nil), code...), append([]string(nil), locals...)}, nil
+}
+
+// checkLocals checks names of locals declared to parser
+func checkLocals(locals []string) error {
+	if len(locals) > MaxEvalLocals {
+		return EArgumentError("too many local variables (%d for %d)", len(locals), MaxEvalLocals)
+	}
+	for _, name := range locals {
+		if !isLocalName(name) {
+			return EArgumentError("invalid local variable name '%v'", name)
+		}
+	}
+	return nil
+}
+
+// Filename returns source filename of program
+func (prog *Program) Filename() string { return prog.filename }
+
+// Bytecode returns copy of program RITE bytecode, which can be stored and loaded with NewProgram
+func (prog *Program) Bytecode() []byte { return append([]byte(nil), prog.code...) }
+
+// Locals returns names of locals declared when program was compiled
+func (prog *Program) Locals() []string { return append([]string(nil), prog.locals...) }
+
+// Run loads program into oruby state and runs it on top level.
+// Locals are values of locals declared when program was compiled. Values are passed to
+// program as block arguments, so they are set in registers of declared locals, and locals
+// which are not given are nil
+func (prog *Program) Run(mrb *MrbState, locals map[string]interface{}) (ret Value, err error) {
+	if mrb.remote(func() { ret, err = prog.Run(mrb, locals) }) {
+		return ret, err
+	}
+
+	args := make([]interface{}, len(prog.locals))
+	declared := 0
+	for i, name := range prog.locals {
+		v, ok := locals[name]
+		if ok {
+			declared++
+		}
+		args[i] = mrb.Value(v)
+	}
+	if declared < len(locals) {
+		return nilValue, EArgumentError("%v: undeclared local variables given, program declares %v", prog.filename, prog.locals)
+	}
+
+	irep, err := mrb.ReadIrep(prog.code)
+	if err != nil {
+		return nilValue, fmt.Errorf("%v: %v", prog.filename, err)
+	}
+
+	proc := mrb.ProcNew(irep)
+	irep.Decref()
+	proc.SetTargetClass(mrb.ObjectClass())
+
+	mrb.ExcClear()
+	if len(args) == 0 {
+		ret = mrb.TopRun(proc, mrb.TopSelf(), 0)
+	} else {
+		ret = mrb.YieldWithClass(proc, mrb.TopSelf(), mrb.ObjectClass(), args...)
+	}
+	return ret, mrb.Err()
+}
@@ -0,0 +1,114 @@
+package oruby
+
+import (
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestCompile(t *testing.T) {
+	prog, err := Compile("def twice(x)\n  x * 2\nend\ntwice(21)", "rules.rb")
+	ExpectNilError(t, err)
+	ExpectEql(t, prog.Filename(), "rules.rb")
+
+	for i := 0; i < 3; i++ {
+		mrb, _ := NewCore()
+		v, err := prog.Run(mrb, nil)
+		ExpectNilError(t, err)
+		ExpectEql(t, v.Int(), 42)
+
+		r, err := mrb.Eval("twice(2)")
+		ExpectNilError(t, err)
+		ExpectEql(t, r.Int(), 4)
+		mrb.Close()
+	}
+}
+
+func TestCompileError(t *testing.T) {
+	_, err := Compile("def x(\n", "broken.rb")
+	ExpectErr(t, err, "parse error expected")
+	Expect(t, strings.HasPrefix(err.Error(), "broken.rb:"), "parse error should start with filename, got %v", err)
+}
+
+func TestProgram_RunLocals(t *testing.T) {
+	prog, err := Compile("price * qty", "total.rb", "price", "qty")
+	ExpectNilError(t, err)
+
+	mrb, _ := NewCore()
+	defer mrb.Close()
+
+	v, err := prog.Run(mrb, map[string]interface{}{"price": 5, "qty": 3})
+	ExpectNilError(t, err)
+	ExpectEql(t, v.Int(), 15)
+
+	_, err = prog.Run(mrb, nil)
+	ExpectErr(t, err, "locals should be nil without values")
+
+	_, err = prog.Run(mrb, map[string]interface{}{"price": 5, "tax": 1})
+	ExpectErr(t, err, "undeclared local should fail")
+
+	// locals are not visible as methods of top self
+	_, err = mrb.Eval("price")
+	ExpectErr(t, err, "locals should not be defined outside program")
+
+	_, err = Compile("x", "bad.rb", "Bad")
+	ExpectErr(t, err, "invalid local name should fail")
+}
+
+func TestProgram_RunLocalsAssign(t *testing.T) {
+	prog, err := Compile("n += 1\n[n, defined?(n)]", "inc.rb", "n")
+	ExpectNilError(t, err)
+	ExpectEql(t, prog.Locals(), []string{"n"})
+
+	code, err := NewProgram(prog.Bytecode(), "inc.rb", prog.Locals()...)
+	ExpectNilError(t, err)
+
+	mrb, _ := NewCore()
+	defer mrb.Close()
+
+	v, err := code.Run(mrb, map[string]interface{}{"n": 41})
+	ExpectNilError(t, err)
+	ExpectEql(t, mrb.String(mrb.Inspect(v)), `[42, "local-variable"]`)
+}
+
+func TestProgram_Backtrace(t *testing.T) {
+	prog, err := Compile("\nraise 'failed'", "fail.rb")
+	ExpectNilError(t, err)
+
+	mrb, _ := NewCore()
+	defer mrb.Close()
+
+	_, err = prog.Run(mrb, nil)
+	ExpectErr(t, err, "exception expected")
+
+	exc := mrb.Exc()
+	Expect(t, exc != nil, "exception should be raised")
+	bt := mrb.String(mrb.Inspect(exc.Backtrace()))
+	Expect(t, strings.Contains(bt, "fail.rb:2"), "backtrace should contain filename, got %v", bt)
+}
+
+func TestProgram_Shared(t *testing.T) {
+	prog, err := Compile("(1 + 2 + 3) * n", "sum.rb", "n")
+	ExpectNilError(t, err)
+
+	code, err := NewProgram(prog.Bytecode(), "sum.rb", "n")
+	ExpectNilError(t, err)
+
+	var wg sync.WaitGroup
+	for g := 1; g <= 4; g++ {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			mrb, _ := NewCore()
+			defer mrb.Close()
+
+			for _, p := range []*Program{prog, code} {
+				v, err := p.Run(mrb, map[string]interface{}{"n": n})
+				if err != nil || v.Int() != 6*n {
+					t.Errorf("expected %v, got %v (%v)", 6*n, v.Int(), err)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+}
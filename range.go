@@ -30,7 +30,7 @@ func (r RRange) Exclusive() bool { return C._RANGE_EXCL(r.ptr()) != false }
 
 // RangeNew creates new range, n include or not end value
 func (mrb *MrbState) RangeNew(v1, v2 MrbValue, n bool) Value {
-	return Value{C.mrb_range_new(mrb.p, v1.Value().v, v2.Value().v, iifmb(n))}
+	return Value{C.mrb_range_new(mrb.state(), v1.Value().v, v2.Value().v, iifmb(n))}
 }
 
 // MrbRangePtr retrieve RRange from Value. This method returns error if range is uninitialized
@@ -69,7 +69,7 @@ func (mrb *MrbState) RangeBegLen(r MrbValue, length int, trunc bool) (int, int,
 	lenp := C.mrb_int(0)
 
 	ret := int(C.mrb_range_beg_len(
-		mrb.p,
+		mrb.state(),
 		r.Value().v,
 		&begp,
 		&lenp,
@@ -6,7 +6,6 @@ import (
 	"fmt"
 	"reflect"
 	"strings"
-	"time"
 	"unsafe"
 )
 
@@ -20,10 +19,14 @@ func (c RClass) attrGetter(idx int) MrbFuncT {
 
 func (c RClass) attrSetter(idx int) MrbFuncT {
 	return func(mrb *MrbState, self Value) MrbValue {
-		strct := mrb.DataCheckGetInterface(self)
-		field := reflect.ValueOf(strct).Elem().Field(idx)
+		strct := reflect.ValueOf(mrb.DataCheckGetInterface(self)).Elem()
+		field := strct.Field(idx)
 		v := mrb.GetArgsFirst()
-		field.Set(reflect.ValueOf(mrb.Intf(v)).Convert(field.Type()))
+		fv, err := mrb.convertValue(v, field.Type())
+		if err != nil {
+			return mrb.RaiseError(errorWithContext(err, "field "+strct.Type().Field(idx).Name))
+		}
+		field.Set(fv)
 
 		return v
 	}
@@ -292,7 +295,8 @@ func (mrb *MrbState) GetModuleData(module Value) interface{} {
 	return mrb.getHook(unsafe.Pointer(mrb.ClassPtr(module).p))
 }
 
-// Scan oruby Value to pointed variable
+// Scan oruby Value to pointed variable. Hashes and plain objects can be scanned into Go
+// structs, fields are matched by `ruby:"key"` tag, snake_case or Go field name
 func (mrb *MrbState) Scan(o MrbValue, in interface{}) (err error) {
 	defer errorHandler(&err)
 
@@ -301,11 +305,11 @@ func (mrb *MrbState) Scan(o MrbValue, in interface{}) (err error) {
 		return errors.New("scaned interface must be pointer to value")
 	}
 
-	return mrb.scanValue(o, v.Elem())
+	return mrb.scanValue(o, v.Elem(), false)
 }
 
 // Scan oruby value to pointed variable
-func (mrb *MrbState) scanValue(o MrbValue, vel reflect.Value) (err error) {
+func (mrb *MrbState) scanValue(o MrbValue, vel reflect.Value, strict bool) (err error) {
 	velType := vel.Type()
 
 	// generic interface
@@ -314,8 +318,43 @@ func (mrb *MrbState) scanValue(o MrbValue, vel reflect.Value) (err error) {
 		return nil
 	}
 
+	// registered converter
+	if c := mrb.converterFor(velType); c != nil && c.fromRuby != nil {
+		cv, err := c.fromValue(mrb, o.Value(), velType)
+		if err != nil {
+			return err
+		}
+		vel.Set(cv)
+		return nil
+	}
+
+	// Go value stored in oruby object
+	if o.Type() == MrbTTCData {
+		if data := reflect.ValueOf(mrb.Data(o)); data.IsValid() && data.Type().AssignableTo(velType) {
+			vel.Set(data)
+			return nil
+		}
+	}
+
+	// nil leaves pointers nil, and other values zero
+	if o.IsNil() {
+		vel.Set(reflect.Zero(velType))
+		return nil
+	}
+
+	if velType.Kind() == reflect.Ptr {
+		if vel.IsNil() {
+			vel.Set(reflect.New(velType.Elem()))
+		}
+		return mrb.scanValue(o, vel.Elem(), strict)
+	}
+
+	if velType == timeType {
+		return mrb.scanTime(o, vel)
+	}
+
 	if velType.Kind() == reflect.String {
-		s := reflect.ValueOf(mrb.String(o))
+		s := reflect.ValueOf(mrb.String(o)).Convert(velType)
 		vel.Set(s)
 		return nil
 	}
@@ -330,32 +369,28 @@ func (mrb *MrbState) scanValue(o MrbValue, vel reflect.Value) (err error) {
 	switch o.Type() {
 	case MrbTTArray:
 		{
-			if velType.Kind() != reflect.Slice {
-				return errors.New("slice type required")
-			}
-
-			var f func(MrbValue) reflect.Value
+			n := RArrayLen(o)
 
-			switch velType.Elem().Kind() {
-			case reflect.String:
-				f = func(v MrbValue) reflect.Value {
-					return reflect.ValueOf(mrb.String(v))
-				}
-			case reflect.Int, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Int8,
-				reflect.Uint, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uint8:
-				f = func(v MrbValue) reflect.Value {
-					in, _ := mrb.Integer(v)
-					return reflect.ValueOf(MrbFixnum(in)).Convert(velType.Elem())
+			switch velType.Kind() {
+			case reflect.Slice:
+				vel.Set(reflect.MakeSlice(velType, n, n))
+			case reflect.Array:
+				if n > velType.Len() {
+					return fmt.Errorf("array of %v elements does not fit into %v", n, velType)
 				}
 			default:
-				f = func(v MrbValue) reflect.Value {
-					return reflect.ValueOf(mrb.Intf(v)).Convert(velType.Elem())
+				return errors.New("slice type required")
+			}
+
+			for i := 0; i < n; i++ {
+				if err := mrb.scanValue(mrb.AryRef(o, i), vel.Index(i), strict); err != nil {
+					return errorWithContext(err, fmt.Sprintf("[%v]", i))
 				}
 			}
 
-			for i := 0; i < RArrayLen(o); i++ {
-				av := mrb.AryRef(o, i)
-				vel.Set(reflect.Append(vel, f(av)))
+			// Go array elements past shorter oruby array are zeroed
+			for i := n; i < vel.Len(); i++ {
+				vel.Index(i).Set(reflect.Zero(velType.Elem()))
 			}
 
 			return nil
@@ -363,45 +398,38 @@ func (mrb *MrbState) scanValue(o MrbValue, vel reflect.Value) (err error) {
 
 	case MrbTTHash:
 		{
-			keys := mrb.HashKeys(o)
-			kcnt := RArrayLen(keys)
-
 			// Unmarshall to map
 			switch velType.Kind() {
 			case reflect.Map:
-				switch m := vel.Interface().(type) {
-				case map[string]interface{}:
-					for i := 0; i < kcnt; i++ {
-						key := mrb.AryRef(keys, i)
-						val := mrb.HashGet(o, key)
-						m[mrb.String(key)] = mrb.Intf(val)
-					}
+				keys := mrb.HashKeys(o)
+				kcnt := RArrayLen(keys)
 
-				case map[interface{}]interface{}:
-					for i := 0; i < kcnt; i++ {
-						key := mrb.AryRef(keys, i)
-						val := mrb.HashGet(o, key)
-						m[mrb.Intf(key)] = mrb.Intf(val)
-					}
-
-				default:
-					return errors.New("unmarshaling maps is supported to [string]interface{} or [interface{}]interface{}")
+				if vel.IsNil() {
+					vel.Set(reflect.MakeMapWithSize(velType, kcnt))
 				}
 
-			case reflect.Struct:
-
 				for i := 0; i < kcnt; i++ {
 					key := mrb.AryRef(keys, i)
 					val := mrb.HashGet(o, key)
-					field := vel.FieldByName(mrb.String(key))
 
-					if field.IsValid() && field.CanSet() && field.Type().PkgPath() == "" {
-						field.Set(reflect.ValueOf(mrb.Intf(val)).Convert(field.Type()))
+					k := reflect.New(velType.Key()).Elem()
+					if err := mrb.scanValue(key, k, strict); err != nil {
+						return err
+					}
+
+					e := reflect.New(velType.Elem()).Elem()
+					if err := mrb.scanValue(val, e, strict); err != nil {
+						return errorWithContext(err, fmt.Sprintf("[%v]", mrb.String(key)))
 					}
+
+					vel.SetMapIndex(k, e)
 				}
 
+			case reflect.Struct:
+				return mrb.scanStruct(o, vel, strict)
+
 			default:
-				return errors.New("supported types for hash scans are Go maps with string or interface keys and structs, not " + velType.Kind().String())
+				return errors.New("supported types for hash scans are Go maps and structs, not " + velType.Kind().String())
 			}
 
 			return nil
@@ -410,36 +438,27 @@ func (mrb *MrbState) scanValue(o MrbValue, vel reflect.Value) (err error) {
 	case MrbTTObject:
 		{
 			unmarshalSym := mrb.Intern("unmarshal")
-			if !mrb.MethodExists(mrb.ClassOf(o), unmarshalSym) {
-				return errors.New("oruby Object does not support unmarshaling")
+			if mrb.MethodExists(mrb.ClassOf(o), unmarshalSym) {
+				_, err = mrb.Funcall(o, unmarshalSym, vel.Interface())
+				return err
 			}
 
-			_, err = mrb.Funcall(o, unmarshalSym, vel.Interface())
-			return err
+			// plain object is scanned via its instance variables
+			if velType.Kind() == reflect.Struct {
+				return mrb.scanStruct(o, vel, strict)
+			}
+
+			return errors.New("oruby Object does not support unmarshaling")
 		}
 	case MrbTTCData:
 		{
-			data := reflect.ValueOf(mrb.Data(o))
-			if velType == data.Type() {
-				vel.Set(data)
-				return nil
-			}
-
-			if mrb.ObjIsKindOf(o, mrb.ClassGet("Time")) && (velType == reflect.TypeOf(time.Time{})) {
-				vtoi := mrb.Call(o, "to_i")
-				vusec := mrb.Call(o, "usec")
-				t := time.Unix(int64(MrbFixnum(vtoi)), int64(MrbFixnum(vusec))*1000)
-				vel.Set(reflect.ValueOf(t))
-				return nil
-			}
-
 			unmarshalSym := mrb.Intern("unmarshal")
 			if mrb.MethodExists(mrb.ClassOf(o), unmarshalSym) {
 				_, err = mrb.Funcall(o, unmarshalSym, vel.Interface())
 				return err
 			}
 
-			return fmt.Errorf("unsupported interface '%v' for class '%v'", data.Type(), mrb.ClassOf(o).Name())
+			return fmt.Errorf("unsupported interface '%v' for class '%v'", velType, mrb.ClassOf(o).Name())
 		}
 
 	//case C.MRB_TT_UNDEF,
@@ -449,8 +468,11 @@ func (mrb *MrbState) scanValue(o MrbValue, vel reflect.Value) (err error) {
 	//case C.MRB_TT_SYMBOL:
 
 	default:
-		govalue := mrb.Intf(o)
-		vel.Set(reflect.ValueOf(govalue).Convert(velType))
+		cv, err := assignValue(mrb.Intf(o), velType)
+		if err != nil {
+			return err
+		}
+		vel.Set(cv)
 	}
 	return nil
 }
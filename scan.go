@@ -0,0 +1,282 @@
+package oruby
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rubyTag is the struct field tag used for hash keys, as in `ruby:"name,omitempty"`.
+// Field tagged with `ruby:"-"` is ignored. Untagged fields use snake_case of field name.
+const rubyTag = "ruby"
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// structField describes Go struct field mapped to oruby hash key
+type structField struct {
+	index     []int
+	key       string // oruby hash key, from tag or snake_case field name
+	name      string // Go field name
+	omitEmpty bool
+}
+
+var structFieldsCache sync.Map // reflect.Type -> []structField
+
+// structFields returns exported fields of struct type t, embedded structs are flattened
+func structFields(t reflect.Type) []structField {
+	if f, ok := structFieldsCache.Load(t); ok {
+		return f.([]structField)
+	}
+
+	fields := appendStructFields(nil, t, nil)
+	f, _ := structFieldsCache.LoadOrStore(t, fields)
+	return f.([]structField)
+}
+
+func appendStructFields(fields []structField, t reflect.Type, index []int) []structField {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag, hasTag := sf.Tag.Lookup(rubyTag)
+		if tag == "-" {
+			continue
+		}
+
+		idx := make([]int, len(index)+1)
+		copy(idx, index)
+		idx[len(index)] = i
+
+		// untagged embedded struct fields are flattened into parent
+		if sf.Anonymous && !hasTag && sf.Type.Kind() == reflect.Struct {
+			fields = appendStructFields(fields, sf.Type, idx)
+			continue
+		}
+
+		if !sf.IsExported() {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = SnakeCase(sf.Name)
+		}
+
+		fields = append(fields, structField{
+			index:     idx,
+			key:       name,
+			name:      sf.Name,
+			omitEmpty: opts == "omitempty",
+		})
+	}
+	return fields
+}
+
+// ScanStrict works as Scan, but fails when Hash or object has keys
+// which do not map to any field of scanned Go struct
+func (mrb *MrbState) ScanStrict(o MrbValue, in interface{}) (err error) {
+	defer errorHandler(&err)
+
+	v := reflect.ValueOf(in)
+	if v.Kind() != reflect.Ptr {
+		return errors.New("scaned interface must be pointer to value")
+	}
+
+	return mrb.scanValue(o, v.Elem(), true)
+}
+
+// scanTime scans oruby Time, Integer unix time or RFC3339 string to time.Time
+func (mrb *MrbState) scanTime(o MrbValue, vel reflect.Value) error {
+	var t time.Time
+
+	switch o.Type() {
+	case MrbTTInteger:
+		t = time.Unix(int64(MrbFixnum(o)), 0)
+	case MrbTTFloat:
+		f := o.Value().Float64()
+		sec := int64(f)
+		t = time.Unix(sec, int64((f-float64(sec))*1e9))
+	case MrbTTString:
+		var err error
+		if t, err = time.Parse(time.RFC3339Nano, mrb.String(o)); err != nil {
+			return err
+		}
+	default:
+		if data, ok := mrb.Intf(o).(time.Time); ok {
+			t = data
+			break
+		}
+		if !mrb.RespondTo(o, mrb.Intern("to_i")) || !mrb.RespondTo(o, mrb.Intern("usec")) {
+			return ETypeError("can't convert %v into time.Time", mrb.TypeName(o))
+		}
+		vtoi := mrb.Call(o, "to_i")
+		vusec := mrb.Call(o, "usec")
+		t = time.Unix(int64(MrbFixnum(vtoi)), int64(MrbFixnum(vusec))*1000)
+	}
+
+	vel.Set(reflect.ValueOf(t))
+	return nil
+}
+
+// scanSource collects keys and values of oruby Hash or instance variables of an object.
+// When Hash has both Symbol and String key with same name, Symbol key is used, or
+// error is returned in strict mode
+func (mrb *MrbState) scanSource(o MrbValue, strict bool) (map[string]Value, []string, error) {
+	if o.Type() == MrbTTHash {
+		keys := mrb.HashKeys(o)
+		kcnt := RArrayLen(keys)
+		src := make(map[string]Value, kcnt)
+		names := make([]string, 0, kcnt)
+		for i := 0; i < kcnt; i++ {
+			key := mrb.AryRef(keys, i)
+			if key.Type() != MrbTTSymbol && key.Type() != MrbTTString {
+				return nil, nil, ETypeError("hash key %v is not a Symbol or String", mrb.String(mrb.Inspect(key)))
+			}
+			name := mrb.String(key)
+			if _, dup := src[name]; dup {
+				if strict {
+					return nil, nil, EArgumentError("duplicate key '%v' as Symbol and String", name)
+				}
+				if key.Type() != MrbTTSymbol {
+					continue
+				}
+			} else {
+				names = append(names, name)
+			}
+			src[name] = mrb.HashGet(o, key)
+		}
+		return src, names, nil
+	}
+
+	vars := mrb.Call(o, "instance_variables")
+	kcnt := RArrayLen(vars)
+	src := make(map[string]Value, kcnt)
+	names := make([]string, 0, kcnt)
+	for i := 0; i < kcnt; i++ {
+		key := mrb.AryRef(vars, i)
+		name := strings.TrimPrefix(mrb.String(key), "@")
+		src[name] = mrb.IVGet(o, mrb.ObjToSym(key))
+		names = append(names, name)
+	}
+	return src, names, nil
+}
+
+// scanStruct scans oruby Hash or instance variables of an object into Go struct.
+// Key is looked up by tag name (or snake_case field name), then by Go field name
+func (mrb *MrbState) scanStruct(o MrbValue, vel reflect.Value, strict bool) error {
+	src, names, err := mrb.scanSource(o, strict)
+	if err != nil {
+		return err
+	}
+
+	velType := vel.Type()
+	used := make(map[string]bool, len(src))
+
+	for _, f := range structFields(velType) {
+		key := f.key
+		val, ok := src[key]
+		if !ok {
+			key = f.name
+			if val, ok = src[key]; !ok {
+				continue
+			}
+		}
+		used[key] = true
+
+		if err := mrb.scanValue(val, vel.FieldByIndex(f.index), strict); err != nil {
+			return errorWithContext(err, "field "+f.name)
+		}
+	}
+
+	if strict {
+		for _, name := range names {
+			if !used[name] {
+				return EArgumentError("unknown key '%v' for %v", name, velType)
+			}
+		}
+	}
+
+	return nil
+}
+
+// StructToHash encodes Go struct to oruby Hash with symbol keys. Keys follow
+// same rules as Scan: `ruby:"key"` tag or snake_case field name, `ruby:"-"` fields
+// are skipped and `omitempty` fields are skipped when zero. Nested structs,
+// slices and maps are encoded recursively, time.Time is kept as Time
+func (mrb *MrbState) StructToHash(obj interface{}) (Value, error) {
+	v := reflect.ValueOf(obj)
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nilValue, nil
+		}
+		v = v.Elem()
+	}
+
+	if v.Kind() != reflect.Struct {
+		return nilValue, ETypeError("%v is not a struct", v.Type())
+	}
+
+	return mrb.encodeValue(v), nil
+}
+
+// encodeValue is valueValue which turns structs into hashes
+func (mrb *MrbState) encodeValue(v reflect.Value) Value {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nilValue
+		}
+		if _, ok := v.Interface().(MrbValue); ok {
+			return mrb.Value(v.Interface())
+		}
+		return mrb.encodeValue(v.Elem())
+
+	case reflect.Struct:
+		if v.Type() == timeType {
+			return mrb.Value(v.Interface())
+		}
+
+		fields := structFields(v.Type())
+		hash := mrb.HashNewCapa(len(fields))
+		for _, f := range fields {
+			field, err := v.FieldByIndexErr(f.index)
+			if err != nil {
+				continue
+			}
+			if f.omitEmpty && field.IsZero() {
+				continue
+			}
+			hash.Set(mrb.SymbolValue(mrb.Intern(f.key)), mrb.encodeValue(field))
+		}
+		return hash.Value()
+
+	case reflect.Slice:
+		if v.IsNil() {
+			return nilValue
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return mrb.Value(v.Interface())
+		}
+		fallthrough
+
+	case reflect.Array:
+		ary := mrb.AryNewCapa(v.Len())
+		for i := 0; i < v.Len(); i++ {
+			ary.Push(mrb.encodeValue(v.Index(i)))
+		}
+		return ary.Value()
+
+	case reflect.Map:
+		if v.IsNil() {
+			return nilValue
+		}
+		hash := mrb.HashNewCapa(v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			hash.Set(mrb.encodeValue(iter.Key()), mrb.encodeValue(iter.Value()))
+		}
+		return hash.Value()
+	}
+
+	return mrb.valueValue(v)
+}
@@ -0,0 +1,138 @@
+package oruby
+
+import (
+	"testing"
+	"time"
+)
+
+type scanAddress struct {
+	City    string
+	ZipCode string `ruby:"zip"`
+}
+
+type scanPerson struct {
+	Name      string
+	Age       int
+	Tags      []string
+	Address   scanAddress
+	Previous  []scanAddress `ruby:"previous,omitempty"`
+	Nickname  *string       `ruby:",omitempty"`
+	Born      time.Time
+	Secret    string `ruby:"-"`
+	CreatedAt time.Time
+}
+
+func TestMrbState_ScanStruct(t *testing.T) {
+	mrb := MrbOpen()
+	defer mrb.Close()
+
+	v, err := mrb.Eval(`{
+		name: "Ann", "age" => 42, tags: ["a", "b"],
+		address: {city: "Split", zip: "21000"},
+		previous: [{city: "Zagreb"}],
+		born: 0, created_at: "2020-01-02T03:04:05Z", secret: "x"
+	}`)
+	ExpectNilError(t, err)
+
+	var p scanPerson
+	ExpectNilError(t, mrb.Scan(v, &p))
+	ExpectEql(t, p.Name, "Ann")
+	ExpectEql(t, p.Age, 42)
+	ExpectEql(t, p.Tags, []string{"a", "b"})
+	ExpectEql(t, p.Address, scanAddress{"Split", "21000"})
+	ExpectEql(t, p.Previous, []scanAddress{{City: "Zagreb"}})
+	Expect(t, p.Nickname == nil, "nickname should stay nil")
+	ExpectEql(t, p.Born.Unix(), int64(0))
+	ExpectEql(t, p.CreatedAt.Year(), 2020)
+	ExpectEql(t, p.Secret, "")
+
+	v, _ = mrb.Eval(`{name: "Ann", nickname: "an"}`)
+	ExpectNilError(t, mrb.Scan(v, &p))
+	Expect(t, p.Nickname != nil && *p.Nickname == "an", "nickname should be set")
+
+	// Go field names are accepted as keys
+	v, _ = mrb.Eval(`{Name: "Bob"}`)
+	ExpectNilError(t, mrb.Scan(v, &p))
+	ExpectEql(t, p.Name, "Bob")
+}
+
+func TestMrbState_ScanObject(t *testing.T) {
+	mrb := MrbOpen()
+	defer mrb.Close()
+
+	v, err := mrb.Eval(`
+		class ScanAddress
+			def initialize; @city = "Split"; @zip = "21000"; end
+		end
+		ScanAddress.new
+	`)
+	ExpectNilError(t, err)
+
+	var a scanAddress
+	ExpectNilError(t, mrb.Scan(v, &a))
+	ExpectEql(t, a, scanAddress{"Split", "21000"})
+}
+
+func TestMrbState_ScanStrict(t *testing.T) {
+	mrb := MrbOpen()
+	defer mrb.Close()
+
+	v, _ := mrb.Eval(`{city: "Split", street: "Riva"}`)
+
+	var a scanAddress
+	ExpectNilError(t, mrb.Scan(v, &a))
+	ExpectErr(t, mrb.ScanStrict(v, &a), "unknown key must fail in strict mode")
+
+	v, _ = mrb.Eval(`{address: {city: "Split", street: "Riva"}}`)
+	var p scanPerson
+	ExpectErr(t, mrb.ScanStrict(v, &p), "unknown nested key must fail in strict mode")
+
+	// Symbol key is used over String key with same name
+	v, _ = mrb.Eval(`{"city" => "Zagreb", city: "Split"}`)
+	ExpectNilError(t, mrb.Scan(v, &a))
+	ExpectEql(t, a.City, "Split")
+	ExpectErr(t, mrb.ScanStrict(v, &a), "duplicate key must fail in strict mode")
+}
+
+func TestMrbState_ScanArray(t *testing.T) {
+	mrb := MrbOpen()
+	defer mrb.Close()
+
+	v, _ := mrb.Eval(`[1, 2]`)
+	a := [3]int{7, 8, 9}
+	ExpectNilError(t, mrb.Scan(v, &a))
+	ExpectEql(t, a, [3]int{1, 2, 0})
+
+	v, _ = mrb.Eval(`[1, 2, 3, 4]`)
+	ExpectErr(t, mrb.Scan(v, &a), "longer array must not fit")
+}
+
+func TestMrbState_StructToHash(t *testing.T) {
+	mrb := MrbOpen()
+	defer mrb.Close()
+
+	p := scanPerson{
+		Name:    "Ann",
+		Age:     42,
+		Tags:    []string{"a"},
+		Address: scanAddress{"Split", "21000"},
+		Secret:  "x",
+	}
+
+	h, err := mrb.StructToHash(&p)
+	ExpectNilError(t, err)
+	ExpectEql(t, h.Type(), MrbTTHash)
+
+	mrb.GVSet(mrb.Intern("$h"), h)
+	res, err := mrb.Eval(`[$h[:name], $h[:age], $h[:tags], $h[:address][:zip], $h.key?(:previous), $h.key?(:secret)]`)
+	ExpectNilError(t, err)
+	ExpectEql(t, mrb.Intf(res), []interface{}{"Ann", 42, []interface{}{"a"}, "21000", false, false})
+
+	// Round trip
+	var back scanPerson
+	ExpectNilError(t, mrb.Scan(h, &back))
+	ExpectEql(t, back.Address, p.Address)
+
+	_, err = mrb.StructToHash(1)
+	ExpectErr(t, err, "only structs can be encoded")
+}
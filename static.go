@@ -0,0 +1,141 @@
+package oruby
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// Argument conversions for static bindings generated by cmd/orubygen.
+// They follow the same rules as reflection based bindings,
+// so generated and populated classes behave alike.
+
+// intTypeName returns Go type name for integer of bit size, 0 stands for int/uint
+func intTypeName(prefix string, bits int) string {
+	if bits == 0 {
+		return prefix
+	}
+	return prefix + strconv.Itoa(bits)
+}
+
+// IntArg converts Integer or Float value to int64 which fits into signed integer of bits size.
+// Zero bits size stands for int
+func (mrb *MrbState) IntArg(v Value, bits int) (int64, error) {
+	name := intTypeName("int", bits)
+	if bits == 0 {
+		bits = strconv.IntSize
+	}
+
+	var i int64
+	switch v.Type() {
+	case MrbTTInteger:
+		i = v.Int64()
+	case MrbTTFloat:
+		f := v.Float64()
+		if math.IsNaN(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+			return 0, ERangeError("float %v out of range of %v", f, name)
+		}
+		i = int64(f)
+	default:
+		return 0, ETypeError("no implicit conversion of %v into %v", mrb.TypeName(v), name)
+	}
+
+	if bits < 64 && (i < -1<<(bits-1) || i > 1<<(bits-1)-1) {
+		return 0, ERangeError("integer %v too big to convert to %v", i, name)
+	}
+	return i, nil
+}
+
+// UintArg converts non-negative Integer or Float value to uint64 which fits into unsigned integer
+// of bits size. Zero bits size stands for uint
+func (mrb *MrbState) UintArg(v Value, bits int) (uint64, error) {
+	name := intTypeName("uint", bits)
+	if bits == 0 {
+		bits = strconv.IntSize
+	}
+
+	var u uint64
+	switch v.Type() {
+	case MrbTTInteger:
+		i := v.Int64()
+		if i < 0 {
+			return 0, ERangeError("can't convert negative integer %v to %v", i, name)
+		}
+		u = uint64(i)
+	case MrbTTFloat:
+		f := v.Float64()
+		if math.IsNaN(f) || f < 0 || f >= math.MaxUint64 {
+			return 0, ERangeError("float %v out of range of %v", f, name)
+		}
+		u = uint64(f)
+	default:
+		return 0, ETypeError("no implicit conversion of %v into %v", mrb.TypeName(v), name)
+	}
+
+	if bits < 64 && u > 1<<bits-1 {
+		return 0, ERangeError("integer %v too big to convert to %v", u, name)
+	}
+	return u, nil
+}
+
+// FloatArg converts Float or Integer value to float64 which fits into float of bits size
+func (mrb *MrbState) FloatArg(v Value, bits int) (float64, error) {
+	name := intTypeName("float", bits)
+
+	var f float64
+	switch v.Type() {
+	case MrbTTFloat:
+		f = v.Float64()
+	case MrbTTInteger:
+		f = float64(v.Int64())
+	default:
+		return 0, ETypeError("no implicit conversion of %v into %v", mrb.TypeName(v), name)
+	}
+
+	if bits == 32 && !math.IsInf(f, 0) && !math.IsNaN(f) && math.Abs(f) > math.MaxFloat32 {
+		return 0, ERangeError("float %v out of range of %v", f, name)
+	}
+	return f, nil
+}
+
+// StringArg converts String or Symbol value to string
+func (mrb *MrbState) StringArg(v Value) (string, error) {
+	switch v.Type() {
+	case MrbTTString:
+		return mrb.String(v), nil
+	case MrbTTSymbol:
+		return mrb.SymName(MrbSymbol(v)), nil
+	}
+	return "", ETypeError("no implicit conversion of %v into string", mrb.TypeName(v))
+}
+
+// BytesArg converts String value to byte slice. nil is converted to nil slice
+func (mrb *MrbState) BytesArg(v Value) ([]byte, error) {
+	switch v.Type() {
+	case MrbTTString:
+		return v.Bytes(), nil
+	case MrbTTFalse:
+		if v.IsNil() {
+			return nil, nil
+		}
+	}
+	return nil, ETypeError("no implicit conversion of %v into []byte", mrb.TypeName(v))
+}
+
+// DataArg returns Go value wrapped in oruby value of registered Go class.
+// nil is returned for nil value, type of result is checked by caller
+func (mrb *MrbState) DataArg(v Value) (interface{}, error) {
+	switch {
+	case v.IsNil():
+		return nil, nil
+	case v.Type() == MrbTTCData:
+		return mrb.DataGetInterface(v), nil
+	}
+	return nil, ETypeError("no implicit conversion of %v into Go value", mrb.TypeName(v))
+}
+
+// RaiseArgError raises err with method name and argument position as context,
+// same as reflection based bindings do
+func (mrb *MrbState) RaiseArgError(err error, method string, pos int) Value {
+	return mrb.RaiseError(errorWithContext(err, fmt.Sprintf("%v: argument %d", method, pos)))
+}
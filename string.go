@@ -45,33 +45,37 @@ const (
 	MrbStrTypeMask      = 15
 )
 
-//func (mrb *MrbState) GCFreeStr(s RString) { C.mrb_gc_free_str(mrb.p, s.Ptr().p) }
+//func (mrb *MrbState) GCFreeStr(s RString) { C.mrb_gc_free_str(mrb.state(), s.Ptr().p) }
 
 // StrModify modify string
-func (mrb *MrbState) StrModify(s RString) { C.mrb_str_modify(mrb.p, s.Ptr().p) }
+func (mrb *MrbState) StrModify(s RString) { C.mrb_str_modify(mrb.state(), s.Ptr().p) }
 
 // StrModifyKeepASCII modify stringwith keeping ASCII flag if set
-func (mrb *MrbState) StrModifyKeepASCII(s RString) { C.mrb_str_modify_keep_ascii(mrb.p, s.Ptr().p) }
+func (mrb *MrbState) StrModifyKeepASCII(s RString) {
+	C.mrb_str_modify_keep_ascii(mrb.state(), s.Ptr().p)
+}
 
 // MrbStrIndex finds the index of a substring in a string
 func (mrb *MrbState) MrbStrIndex(str MrbValue, s string, offset int) int {
 	cstr := C.CString(s)
 	defer C.free(unsafe.Pointer(cstr))
-	return int(C.mrb_str_index(mrb.p, str.Value().v, cstr, C.mrb_int(len(s)), C.mrb_int(offset)))
+	return int(C.mrb_str_index(mrb.state(), str.Value().v, cstr, C.mrb_int(len(s)), C.mrb_int(offset)))
 }
 
 // StrConcat appends self to other string. Returns self as a concatenated string
-func (mrb *MrbState) StrConcat(s1, s2 MrbValue) { C.mrb_str_concat(mrb.p, s1.Value().v, s2.Value().v) }
+func (mrb *MrbState) StrConcat(s1, s2 MrbValue) {
+	C.mrb_str_concat(mrb.state(), s1.Value().v, s2.Value().v)
+}
 
 // StrPlus Adds two strings together.
 func (mrb *MrbState) StrPlus(s1, s2 MrbValue) Value {
-	return Value{C.mrb_str_plus(mrb.p, s1.Value().v, s2.Value().v)}
+	return Value{C.mrb_str_plus(mrb.state(), s1.Value().v, s2.Value().v)}
 }
 
 // PtrToStr represents pointer as a string
 func (mrb *MrbState) PtrToStr(p uintptr) RString {
 	return RString{RValue{
-		C._mrb_ptr_to_str(mrb.p, C.uintptr_t(p)),
+		C._mrb_ptr_to_str(mrb.state(), C.uintptr_t(p)),
 		mrb,
 	}}
 	// C.mrb_ptr_to_str() API is called via underscore helper
@@ -80,19 +84,19 @@ func (mrb *MrbState) PtrToStr(p uintptr) RString {
 // ObjAsString Returns an object as a Ruby string
 func (mrb *MrbState) ObjAsString(obj MrbValue) RString {
 	return RString{RValue{
-		C.mrb_obj_as_string(mrb.p, obj.Value().v),
+		C.mrb_obj_as_string(mrb.state(), obj.Value().v),
 		mrb,
 	}}
 }
 
 // StrResize Resizes the string. Returns the amount of characters in the specified by len
 func (mrb *MrbState) StrResize(str MrbValue, len int) Value {
-	return Value{C.mrb_str_resize(mrb.p, str.Value().v, C.mrb_int(len))}
+	return Value{C.mrb_str_resize(mrb.state(), str.Value().v, C.mrb_int(len))}
 }
 
 // StrSubstr Returns a sub string.
 func (mrb *MrbState) StrSubstr(str MrbValue, beg, len int) Value {
-	return Value{C.mrb_str_substr(mrb.p, str.Value().v, C.mrb_int(beg), C.mrb_int(len))}
+	return Value{C.mrb_str_substr(mrb.state(), str.Value().v, C.mrb_int(beg), C.mrb_int(len))}
 }
 
 // EnsureStringType  Returns a Ruby string type
@@ -123,7 +127,7 @@ func (mrb *MrbState) StrBufNew(capa int) RString {
 // StrNewCapa string new with buffer
 func (mrb *MrbState) StrNewCapa(capa int) RString {
 	return RString{RValue{
-		C.mrb_str_new_capa(mrb.p, C.mrb_int(capa)),
+		C.mrb_str_new_capa(mrb.state(), C.mrb_int(capa)),
 		mrb,
 	}}
 }
@@ -131,52 +135,52 @@ func (mrb *MrbState) StrNewCapa(capa int) RString {
 // StringCstr string from mrb_value
 func (mrb *MrbState) StringCstr(str MrbValue) string {
 	v := str.Value().v
-	return C.GoString(C.mrb_string_cstr(mrb.p, v))
+	return C.GoString(C.mrb_string_cstr(mrb.state(), v))
 }
 
 // StringValueCstr string from mrb_value; `str` will be updated
 func (mrb *MrbState) StringValueCstr(str MrbValue) string {
 	v := str.Value().v
-	return C.GoString(C.mrb_string_value_cstr(mrb.p, &v))
+	return C.GoString(C.mrb_string_value_cstr(mrb.state(), &v))
 }
 
 // StrDup Duplicates a string object.
 func (mrb *MrbState) StrDup(str MrbValue) Value {
-	return Value{C.mrb_str_dup(mrb.p, str.Value().v)}
+	return Value{C.mrb_str_dup(mrb.state(), str.Value().v)}
 }
 
 // StrIntern Returns a Symbol Value from a Ruby string
 func (mrb *MrbState) StrIntern(str MrbValue) Value {
-	return Value{C.mrb_str_intern(mrb.p, str.Value().v)}
+	return Value{C.mrb_str_intern(mrb.state(), str.Value().v)}
 }
 
 // StrToInteger str value to integer value
 func (mrb *MrbState) StrToInteger(str MrbValue, base int, badcheck bool) Value {
-	return Value{C.mrb_str_to_integer(mrb.p, str.Value().v, C.mrb_int(base), iifmb(badcheck))}
+	return Value{C.mrb_str_to_integer(mrb.state(), str.Value().v, C.mrb_int(base), iifmb(badcheck))}
 }
 
 // StrToDbl str value to float64
 func (mrb *MrbState) StrToDbl(str MrbValue, badcheck bool) float64 {
-	return float64(C.mrb_str_to_dbl(mrb.p, str.Value().v, iifmb(badcheck)))
+	return float64(C.mrb_str_to_dbl(mrb.state(), str.Value().v, iifmb(badcheck)))
 }
 
 // StrToStr Returns a converted string type.
 // for type checking, non converting `mrb_to_str` is recommended.
 // obsolete: use `mrb_obj_as_string()` instead.
 func (mrb *MrbState) StrToStr(str MrbValue) Value {
-	return Value{C.mrb_obj_as_string(mrb.p, str.Value().v)}
+	return Value{C.mrb_obj_as_string(mrb.state(), str.Value().v)}
 }
 
 // StrEqual  Returns true if the strings match and false if the strings don't match
 func (mrb *MrbState) StrEqual(str1, str2 MrbValue) bool {
-	return C.mrb_str_equal(mrb.p, str1.Value().v, str2.Value().v) != false
+	return C.mrb_str_equal(mrb.state(), str1.Value().v, str2.Value().v) != false
 }
 
 // StrCat Returns a concatenated string comprised of a Ruby string and a C string.
 func (mrb *MrbState) StrCat(str MrbValue, s string) Value {
 	cs := C.CString(s)
 	defer C.free(unsafe.Pointer(cs))
-	return Value{C.mrb_str_cat(mrb.p, str.Value().v, cs, C.size_t(len(s)))}
+	return Value{C.mrb_str_cat(mrb.state(), str.Value().v, cs, C.size_t(len(s)))}
 }
 
 // StrCatBytes Returns a concatenated string comprised of a Ruby string and []byte
@@ -184,7 +188,7 @@ func (mrb *MrbState) StrCatBytes(str MrbValue, b []byte) Value {
 	if len(b) == 0 {
 		return str.Value()
 	}
-	return Value{C.mrb_str_cat(mrb.p, str.Value().v, (*C.char)(unsafe.Pointer(&b[0])), C.size_t(len(b)))}
+	return Value{C.mrb_str_cat(mrb.state(), str.Value().v, (*C.char)(unsafe.Pointer(&b[0])), C.size_t(len(b)))}
 }
 
 // StrCatCstr Returns a concatenated string comprised of a Ruby string and a C string.
@@ -195,18 +199,18 @@ func (mrb *MrbState) StrCatCstr(v MrbValue, s string) Value {
 
 // StrCatStr concat
 func (mrb *MrbState) StrCatStr(str, str2 MrbValue) Value {
-	return Value{C.mrb_str_cat_str(mrb.p, str.Value().v, str2.Value().v)}
+	return Value{C.mrb_str_cat_str(mrb.state(), str.Value().v, str2.Value().v)}
 }
 
 // StrAppend Adds str2 to the end of str1
 func (mrb *MrbState) StrAppend(str, str2 MrbValue) Value {
-	return Value{C.mrb_str_append(mrb.p, str.Value().v, str2.Value().v)}
+	return Value{C.mrb_str_append(mrb.state(), str.Value().v, str2.Value().v)}
 }
 
 // StrCmp  Returns 0 if both Ruby strings are equal. Returns a value < 0 if Ruby
 // str1 is less than Ruby str2. Returns a value > 0 if Ruby str2 is greater than Ruby str1.
 func (mrb *MrbState) StrCmp(str1, str2 MrbValue) int {
-	return int(C.mrb_str_cmp(mrb.p, str1.Value().v, str2.Value().v))
+	return int(C.mrb_str_cmp(mrb.state(), str1.Value().v, str2.Value().v))
 }
 
 // StrToCstr Returns a newly allocated string from a Ruby string.
@@ -216,7 +220,7 @@ func (mrb *MrbState) StrToCstr(str MrbValue) string {
 		return ""
 	}
 
-	return C.GoStringN(C.mrb_str_to_cstr(mrb.p, str.Value().v), C.int(RStringLen(str)))
+	return C.GoStringN(C.mrb_str_to_cstr(mrb.state(), str.Value().v), C.int(RStringLen(str)))
 }
 
 // Bytes returns a bytes from ruby MrbValue interface
@@ -225,7 +229,7 @@ func (mrb *MrbState) Bytes(str MrbValue) []byte {
 	if !MrbStringP(str) {
 		panic("expected String value")
 	}
-	cstr := C.mrb_str_to_cstr(mrb.p, str.Value().v)
+	cstr := C.mrb_str_to_cstr(mrb.state(), str.Value().v)
 	return C.GoBytes(unsafe.Pointer(cstr), C.int(RStringLen(str)))
 }
 
@@ -0,0 +1,217 @@
+package oruby
+
+// #include "go-mrb.h"
+import "C"
+import (
+	"strings"
+	"sync"
+	"unsafe"
+)
+
+// TraceEventType is VM event traced by trace hooks. Events can be combined as bit set
+type TraceEventType uint32
+
+// Trace events
+const (
+	TraceLine   TraceEventType = C.GOMRB_TRACE_LINE   // new source line is executed
+	TraceCall   TraceEventType = C.GOMRB_TRACE_CALL   // oruby method is called
+	TraceReturn TraceEventType = C.GOMRB_TRACE_RETURN // method or block returns
+	TraceCCall  TraceEventType = C.GOMRB_TRACE_C_CALL // C or Go function is called
+	TraceRaise  TraceEventType = C.GOMRB_TRACE_RAISE  // exception is raised
+	TraceClass  TraceEventType = C.GOMRB_TRACE_CLASS  // class or module body is entered
+	TraceAll                   = TraceLine | TraceCall | TraceReturn | TraceCCall | TraceRaise | TraceClass
+)
+
+var traceEventNames = []struct {
+	event TraceEventType
+	name  string
+}{
+	{TraceLine, "line"},
+	{TraceCall, "call"},
+	{TraceReturn, "return"},
+	{TraceCCall, "c_call"},
+	{TraceRaise, "raise"},
+	{TraceClass, "class"},
+}
+
+// String returns event name, as used by TracePoint, like "c_call".
+// Names of combined events are joined with "|"
+func (e TraceEventType) String() string {
+	var names []string
+	for _, n := range traceEventNames {
+		if e&n.event != 0 {
+			names = append(names, n.name)
+		}
+	}
+	return strings.Join(names, "|")
+}
+
+// ParseTraceEvent returns trace event by its name
+func ParseTraceEvent(name string) (TraceEventType, error) {
+	for _, n := range traceEventNames {
+		if n.name == name {
+			return n.event, nil
+		}
+	}
+	return 0, EArgumentError("unknown event: %v", name)
+}
+
+// TraceEvent describes traced VM event. Values are valid only while trace hook runs
+type TraceEvent struct {
+	Event       TraceEventType
+	Method      string // called method for call events, returning method for return event
+	Class       RClass // class defining called or returning method, or entered class
+	Self        Value  // receiver of called method, or self of traced code
+	Filename    string
+	Line        int
+	Irep        MrbIrep // irep of traced code
+	PC          int     // offset of instruction in irep
+	Depth       int     // call frame depth of traced code
+	ReturnValue Value   // value returned by return event
+	Exception   Value   // exception of raise event
+}
+
+// TraceHook is function called on traced VM events
+type TraceHook struct {
+	mrb    *MrbState
+	events TraceEventType
+	f      func(TraceEvent)
+}
+
+// traceHooks are trace hooks of state
+type traceHooks struct {
+	sync.Mutex
+	hooks []*TraceHook
+	user  *TraceHook // hook set by SetTraceHook
+}
+
+// SetTraceHook sets function called on traced events, replacing previous hook set by
+// SetTraceHook. Hook is removed if f is nil. Events are traced on VM debug hook, so while
+// hook runs, events of code it calls are not traced and exceptions it raises are ignored.
+//
+// Call and c_call events are traced before method is called, raise event when VM
+// continues with exception in rescue or ensure code
+func (mrb *MrbState) SetTraceHook(events TraceEventType, f func(TraceEvent)) {
+	mrb.trace.Lock()
+	user := mrb.trace.user
+	mrb.trace.user = nil
+	mrb.trace.Unlock()
+
+	if user != nil {
+		user.Remove()
+	}
+	if f != nil {
+		h := mrb.AddTraceHook(events, f)
+		mrb.trace.Lock()
+		mrb.trace.user = h
+		mrb.trace.Unlock()
+	}
+}
+
+// AddTraceHook adds function called on traced events. Unlike SetTraceHook, hooks added with
+// AddTraceHook are kept until removed with TraceHook.Remove
+func (mrb *MrbState) AddTraceHook(events TraceEventType, f func(TraceEvent)) *TraceHook {
+	h := &TraceHook{mrb, events & TraceAll, f}
+
+	mrb.trace.Lock()
+	mrb.trace.hooks = append(mrb.trace.hooks, h)
+	mrb.trace.Unlock()
+
+	mrb.updateTrace()
+	return h
+}
+
+// Events returns events traced by hook
+func (h *TraceHook) Events() TraceEventType {
+	return h.events
+}
+
+// Remove removes trace hook
+func (h *TraceHook) Remove() {
+	t := &h.mrb.trace
+	t.Lock()
+	for i, th := range t.hooks {
+		if th == h {
+			t.hooks = append(t.hooks[:i:i], t.hooks[i+1:]...)
+			break
+		}
+	}
+	t.Unlock()
+
+	h.mrb.updateTrace()
+}
+
+// updateTrace sets events traced by VM to events of all hooks
+func (mrb *MrbState) updateTrace() {
+	if mrb.p == nil {
+		return
+	}
+
+	mrb.trace.Lock()
+	var events TraceEventType
+	for _, h := range mrb.trace.hooks {
+		events |= h.events
+	}
+	mrb.trace.Unlock()
+
+	set := func() { C._mrb_set_trace(mrb.state(), C.uint32_t(events)) }
+	if !mrb.remote(set) {
+		set()
+	}
+}
+
+// matching returns hooks tracing event
+func (t *traceHooks) matching(event TraceEventType) []*TraceHook {
+	t.Lock()
+	defer t.Unlock()
+
+	var hooks []*TraceHook
+	for _, h := range t.hooks {
+		if h.events&event != 0 {
+			hooks = append(hooks, h)
+		}
+	}
+	return hooks
+}
+
+//export go_trace_callback
+func go_trace_callback(mrbidx C.mrb_int, t *C.gomrb_trace) {
+	mrb := getMrbStateIndex(int(mrbidx))
+	event := TraceEventType(t.event)
+	hooks := mrb.trace.matching(event)
+	if len(hooks) == 0 {
+		return
+	}
+
+	ai := mrb.GCArenaSave()
+	defer mrb.GCArenaRestore(ai)
+
+	irep := MrbIrep{(*C.mrb_irep)(unsafe.Pointer(t.irep)), mrb}
+	e := TraceEvent{
+		Event:       event,
+		Class:       RClass{t.klass, mrb},
+		Self:        Value{t.self},
+		Filename:    mrb.DebugGetFilename(irep, uint32(t.pc)),
+		Irep:        irep,
+		PC:          int(t.pc),
+		Depth:       int(t.depth),
+		ReturnValue: nilValue,
+		Exception:   nilValue,
+	}
+	if t.line > 0 {
+		e.Line = int(t.line)
+	}
+	if t.mid != 0 {
+		e.Method = mrb.SymString(MrbSym(t.mid))
+	}
+	switch event {
+	case TraceReturn:
+		e.ReturnValue = Value{t.value}
+	case TraceRaise:
+		e.Exception = Value{t.value}
+	}
+
+	for _, h := range hooks {
+		h.f(e)
+	}
+}
@@ -0,0 +1,82 @@
+package oruby
+
+import (
+	"testing"
+)
+
+func TestMrbState_SetTraceHook(t *testing.T) {
+	mrb, _ := NewCore()
+	defer mrb.Close()
+
+	var events []TraceEvent
+	var returned []int
+	mrb.SetTraceHook(TraceCall|TraceReturn|TraceCCall, func(e TraceEvent) {
+		if e.Method != "twice" && e.Method != "to_s" {
+			return
+		}
+		events = append(events, e)
+		if e.Event == TraceReturn {
+			returned = append(returned, e.ReturnValue.Int())
+		}
+	})
+
+	cxt := mrb.MrbcContextNew()
+	defer cxt.Free()
+	cxt.Filename("twice.rb")
+
+	_, err := mrb.LoadStringCxt("def twice(n)\n  n.to_s\n  n * 2\nend\ntwice(21)\n", cxt)
+	ExpectNilError(t, err)
+	mrb.SetTraceHook(0, nil)
+
+	ExpectEql(t, len(events), 3)
+	if len(events) == 3 {
+		ExpectEql(t, events[0].Event, TraceCall)
+		ExpectEql(t, events[0].Filename, "twice.rb")
+		ExpectEql(t, events[0].Line, 5)
+		ExpectEql(t, events[1].Event, TraceCCall)
+		ExpectEql(t, events[1].Class.Name(), "Integer")
+		ExpectEql(t, events[2].Event, TraceReturn)
+	}
+	ExpectEql(t, returned, []int{42})
+}
+
+func TestMrbState_TraceLineAndRaise(t *testing.T) {
+	mrb, _ := NewCore()
+	defer mrb.Close()
+
+	var lines []int
+	var raised []string
+	h := mrb.AddTraceHook(TraceLine|TraceRaise, func(e TraceEvent) {
+		switch e.Event {
+		case TraceLine:
+			lines = append(lines, e.Line)
+		case TraceRaise:
+			raised = append(raised, mrb.ClassOf(e.Exception).Name())
+		}
+	})
+
+	_, err := mrb.LoadString("a = 1\nbegin\n  raise ArgumentError\nrescue\n  a = 2\nend\n")
+	ExpectNilError(t, err)
+	h.Remove()
+
+	ExpectEql(t, raised, []string{"ArgumentError"})
+	Expect(t, len(lines) >= 3 && lines[0] == 1, "line events expected, got %v", lines)
+
+	// removed hooks are not called
+	n := len(lines)
+	_, err = mrb.LoadString("b = 1")
+	ExpectNilError(t, err)
+	ExpectEql(t, len(lines), n)
+}
+
+func TestTraceEventType_String(t *testing.T) {
+	ExpectEql(t, TraceCCall.String(), "c_call")
+	ExpectEql(t, (TraceLine | TraceReturn).String(), "line|return")
+
+	e, err := ParseTraceEvent("raise")
+	ExpectNilError(t, err)
+	ExpectEql(t, e, TraceRaise)
+
+	_, err = ParseTraceEvent("end")
+	ExpectErr(t, err, "unknown event should fail")
+}
@@ -4,7 +4,9 @@ import "C"
 import (
 	"errors"
 	"fmt"
+	"math"
 	"reflect"
+	"strings"
 	"unicode"
 )
 
@@ -38,6 +40,44 @@ func SnakeCase(s string) string {
 	return string(buffer)
 }
 
+// packageAcronyms are mixed case initialisms which PackageSnakeCase keeps as one word
+var packageAcronyms = []string{"NaN", "IPv4", "IPv6"}
+
+// PackageSnakeCase converts name of Go package member to snake_case, as DefineGoPackage
+// names functions. Unlike SnakeCase, mixed case initialisms are kept as one word,
+// so IsNaN is is_nan
+func PackageSnakeCase(s string) string {
+	var sb strings.Builder
+	word := func(w string) {
+		if w == "" {
+			return
+		}
+		if sb.Len() > 0 && !strings.HasSuffix(sb.String(), "_") && !strings.HasPrefix(w, "_") {
+			sb.WriteByte('_')
+		}
+		sb.WriteString(w)
+	}
+
+	start := 0
+	for i := 0; i < len(s); i++ {
+		for _, a := range packageAcronyms {
+			if !strings.HasPrefix(s[i:], a) {
+				continue
+			}
+			// acronym followed by lowercase letter is part of other word, as in NaNs
+			if rest := s[i+len(a):]; rest == "" || !unicode.IsLower(rune(rest[0])) {
+				word(SnakeCase(s[start:i]))
+				word(strings.ToLower(a))
+				i += len(a) - 1
+				start = i + 1
+				break
+			}
+		}
+	}
+	word(SnakeCase(s[start:]))
+	return sb.String()
+}
+
 // CamelCase converts underscore delimited string to CamelCase
 func CamelCase(s string) string {
 	buffer := make([]rune, 0, len(s))
@@ -102,10 +142,9 @@ func (mrb *MrbState) callFunc(fn reflect.Value, args Arguments) []reflect.Value
 			continue
 		}
 
-		arg := args.Get(mrb, i)
-		params[i], err = assignValue(arg, inType)
+		params[i], err = mrb.argValue(args, i, inType)
 		if err != nil {
-			return reflectErr(err)
+			return reflectErr(errorWithContext(err, fmt.Sprintf("argument %d", i+1)))
 		}
 	}
 
@@ -118,15 +157,104 @@ func assignValue(arg interface{}, outType reflect.Type) (reflect.Value, error) {
 
 	if arg == nil {
 		return reflect.Zero(outType), nil
-	} else if argValue.Type().ConvertibleTo(outType) {
+	}
+
+	argType := argValue.Type()
+	switch {
+	case isNumberKind(argType.Kind()) && isNumberKind(outType.Kind()):
+		return convertNumber(argValue, outType)
+	case isNumberKind(argType.Kind()) && outType.Kind() == reflect.String,
+		argType.Kind() == reflect.String && isNumberKind(outType.Kind()):
+		// Go allows int to string conversion, but it is type mismatch for oruby
+	case argType.ConvertibleTo(outType):
 		return argValue.Convert(outType), nil
-	} else if argValue.Type().ConvertibleTo(outType.Elem()) {
+	case outType.Kind() == reflect.Ptr && isNumberKind(argType.Kind()) && isNumberKind(outType.Elem().Kind()):
+		n, err := convertNumber(argValue, outType.Elem())
+		if err != nil {
+			return reflect.Zero(outType), err
+		}
+		v := reflect.New(outType.Elem())
+		v.Elem().Set(n)
+		return v, nil
+	case outType.Kind() == reflect.Ptr && argType.ConvertibleTo(outType.Elem()):
 		v := reflect.New(outType.Elem())
 		reflect.Indirect(v).Set(argValue.Convert(outType.Elem()))
 		return v, nil
 	}
 
-	return reflect.Zero(outType), EArgumentError("value %v cannot be converted to type %v", arg, outType)
+	return reflect.Zero(outType), ETypeError("no implicit conversion of %v into %v", argType, outType)
+}
+
+func isNumberKind(k reflect.Kind) bool {
+	return k >= reflect.Int && k <= reflect.Float64
+}
+
+// convertNumber converts numeric value to numeric type, checking for overflow
+func convertNumber(v reflect.Value, outType reflect.Type) (reflect.Value, error) {
+	out := reflect.New(outType).Elem()
+
+	switch outType.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var i int64
+		switch {
+		case v.CanInt():
+			i = v.Int()
+		case v.CanUint():
+			if v.Uint() > math.MaxInt64 {
+				return out, ERangeError("integer %v too big to convert to %v", v.Uint(), outType)
+			}
+			i = int64(v.Uint())
+		default:
+			f := v.Float()
+			if math.IsNaN(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+				return out, ERangeError("float %v out of range of %v", f, outType)
+			}
+			i = int64(f)
+		}
+		if out.OverflowInt(i) {
+			return out, ERangeError("integer %v too big to convert to %v", i, outType)
+		}
+		out.SetInt(i)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		var u uint64
+		switch {
+		case v.CanUint():
+			u = v.Uint()
+		case v.CanInt():
+			if v.Int() < 0 {
+				return out, ERangeError("can't convert negative integer %v to %v", v.Int(), outType)
+			}
+			u = uint64(v.Int())
+		default:
+			f := v.Float()
+			if math.IsNaN(f) || f < 0 || f >= math.MaxUint64 {
+				return out, ERangeError("float %v out of range of %v", f, outType)
+			}
+			u = uint64(f)
+		}
+		if out.OverflowUint(u) {
+			return out, ERangeError("integer %v too big to convert to %v", u, outType)
+		}
+		out.SetUint(u)
+
+	default:
+		var f float64
+		switch {
+		case v.CanInt():
+			f = float64(v.Int())
+		case v.CanUint():
+			f = float64(v.Uint())
+		default:
+			f = v.Float()
+		}
+		if !math.IsInf(f, 0) && out.OverflowFloat(f) {
+			return out, ERangeError("float %v out of range of %v", f, outType)
+		}
+		out.SetFloat(f)
+	}
+
+	return out, nil
 }
 
 func (mrb *MrbState) handleResults(result []reflect.Value) (Value, error) {
@@ -159,17 +287,18 @@ func (mrb *MrbState) handleResults(result []reflect.Value) (Value, error) {
 		return nilValue, nil
 
 	case 1:
-		// One result - return one Value
-		return mrb.valueValue(result[0]), nil
+		// One result - return one Value, converter error is raised
+		return mrb.valueErr(func() Value { return mrb.valueValue(result[0]) })
 	}
 
 	// Multiple results - return RArray
-	out := mrb.AryNewCapa(lenres)
-	for _, v := range result[:lenres] {
-		mrb.AryPush(out, mrb.valueValue(v))
-	}
-
-	return out.Value(), nil
+	return mrb.valueErr(func() Value {
+		out := mrb.AryNewCapa(lenres)
+		for _, v := range result[:lenres] {
+			mrb.AryPush(out, mrb.valueValue(v))
+		}
+		return out.Value()
+	})
 }
 
 func toMapSI(mrb *MrbState, v Value) map[string]interface{} {
@@ -199,7 +328,7 @@ func toMapII(mrb *MrbState, v Value) map[interface{}]interface{} {
 	for i := 0; i < keyCount; i++ {
 		key := keys.Item(i)
 		val := mrb.HashGet(v, key)
-		ret[mrb.Intf(key)] = mrb.Intf(val)
+		ret[mrb.mapKey(key, mrb.Intf(key))] = mrb.Intf(val)
 	}
 	return ret
 }
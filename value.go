@@ -292,7 +292,7 @@ func (mrb *MrbState) Test(v MrbValue) bool { return v.Type() != C.MRB_TT_FALSE }
 
 // FloatValue float to oruby value
 func (mrb *MrbState) FloatValue(f float64) Value {
-	return Value{C.mrb_float_value(mrb.p, C.mrb_float(f))}
+	return Value{C.mrb_float_value(mrb.state(), C.mrb_float(f))}
 }
 
 // StringValue float to oruby value
@@ -300,7 +300,7 @@ func (mrb *MrbState) StringValue(s string) RString {
 	ptr := C.CString(s)
 	defer C.free(unsafe.Pointer(ptr))
 	return RString{RValue{
-		C.mrb_str_new(mrb.p, ptr, C.mrb_int(len(s))),
+		C.mrb_str_new(mrb.state(), ptr, C.mrb_int(len(s))),
 		mrb,
 	}}
 }
@@ -308,9 +308,9 @@ func (mrb *MrbState) StringValue(s string) RString {
 // BytesValue bytes to oruby string value
 func (mrb *MrbState) BytesValue(buf []byte) Value {
 	if len(buf) == 0 {
-		return Value{C.mrb_str_new(mrb.p, nil, C.mrb_int(0))}
+		return Value{C.mrb_str_new(mrb.state(), nil, C.mrb_int(0))}
 	}
-	v := Value{C.mrb_str_new(mrb.p, (*C.char)(unsafe.Pointer((&buf[0]))), C.mrb_int(len(buf)))}
+	v := Value{C.mrb_str_new(mrb.state(), (*C.char)(unsafe.Pointer((&buf[0]))), C.mrb_int(len(buf)))}
 
 	runtime.KeepAlive(buf)
 	return v
@@ -360,7 +360,7 @@ func MrbObjValue(p interface{}) (Value, error) {
 
 // CPtrValue value from  Pointer
 func (mrb *MrbState) CPtrValue(p uintptr) Value {
-	return Value{C._mrb_uintptr_value(mrb.p, (C.uintptr_t)(p))}
+	return Value{C._mrb_uintptr_value(mrb.state(), (C.uintptr_t)(p))}
 }
 
 // VoidpValue value from pointer
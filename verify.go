@@ -0,0 +1,468 @@
+package oruby
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"unsafe"
+)
+
+// ErrInvalidBytecode is returned by VerifyIrep for malformed RITE bytecode
+var ErrInvalidBytecode = errors.New("invalid RITE bytecode")
+
+// sizes of RITE binary structures
+const (
+	riteCatchHandlerSize = 13 // type, begin, end and target of catch handler
+	riteSectionSize      = int(unsafe.Sizeof(RiteSectionHeader{}))
+	riteIrepHeaderSize   = int(unsafe.Sizeof(RiteSectionIrepHeader{}))
+	riteBinaryHeaderSize = int(unsafe.Sizeof(RiteBinaryHeader{}))
+)
+
+// VerifyIrep checks RITE bytecode before it is loaded, so malformed or hostile .mrb file
+// is rejected instead of crashing the VM. It checks binary header and section sizes,
+// irep records, opcodes, register, pool, symbol, upvar and child irep operands, registers
+// used implicitly by instructions, jump targets, catch handlers, and local variable and
+// debug info records. RITE format 03 has no checksum, so content is checked by structure only
+func VerifyIrep(code []byte) error {
+	if len(code) < riteBinaryHeaderSize {
+		return verifyError("binary is shorter than header")
+	}
+
+	var h RiteBinaryHeader
+	binary.Read(bytes.NewReader(code), binary.BigEndian, &h)
+	if string(h.BinaryIdent[:]) != RiteBinaryIdent {
+		return verifyError("missing %s identifier", RiteBinaryIdent)
+	}
+	if string(h.MajorVersion[:]) != RiteBinaryMajorVer {
+		return verifyError("unsupported binary format version %s", h.MajorVersion[:])
+	}
+
+	size := int(binary.BigEndian.Uint32(h.BinarySize[:]))
+	if size > len(code) || size < riteBinaryHeaderSize+riteSectionSize {
+		return verifyError("binary size %d does not match data size %d", size, len(code))
+	}
+
+	var root *irepInfo
+	for pos := riteBinaryHeaderSize; ; {
+		if pos+riteSectionSize > size {
+			return verifyError("missing %q section", RiteBinaryEOF)
+		}
+		ident := string(code[pos : pos+4])
+		secSize := int(binary.BigEndian.Uint32(code[pos+4 : pos+8]))
+		if secSize < riteSectionSize || pos+secSize > size {
+			return verifyError("section %q size %d out of binary", ident, secSize)
+		}
+
+		sec := code[pos : pos+secSize]
+		switch ident {
+		case RiteBinaryEOF:
+			if root == nil {
+				return verifyError("missing %s section", RiteSectionIrepIdent)
+			}
+			return nil
+		case RiteSectionIrepIdent:
+			if root != nil {
+				return verifyError("more than one %s section", RiteSectionIrepIdent)
+			}
+			var err error
+			if root, err = verifyIrepSection(sec); err != nil {
+				return err
+			}
+		case RiteSectionLvIdent, RiteSectionDebugIdent:
+			if root == nil {
+				return verifyError("section %q before %s section", ident, RiteSectionIrepIdent)
+			}
+			verify := verifyLvSection
+			if ident == RiteSectionDebugIdent {
+				verify = verifyDebugSection
+			}
+			if err := verify(sec, root); err != nil {
+				return err
+			}
+		}
+		pos += secSize
+	}
+}
+
+func verifyError(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrInvalidBytecode, fmt.Sprintf(format, args...))
+}
+
+// irepInfo is irep tree read from IREP section, used to check records of other sections
+type irepInfo struct {
+	id       string
+	nlocals  int
+	ilen     int
+	children []*irepInfo
+}
+
+// verifyIrepSection checks IREP section with its irep records
+func verifyIrepSection(sec []byte) (*irepInfo, error) {
+	if len(sec) < riteIrepHeaderSize {
+		return nil, verifyError("%s section is shorter than its header", RiteSectionIrepIdent)
+	}
+	if v := string(sec[riteSectionSize:riteIrepHeaderSize]); v != RiteVMVer {
+		return nil, verifyError("unsupported VM version %s", v)
+	}
+
+	r := &irepReader{data: sec, pos: riteIrepHeaderSize}
+	root, err := r.record("0", nil)
+	if err != nil {
+		return nil, err
+	}
+	if r.pos != len(sec) {
+		return nil, verifyError("%d bytes after last irep record", len(sec)-r.pos)
+	}
+	return root, nil
+}
+
+// verifyLvSection checks local variable names of LVAR section. Each irep has
+// symbol index of its locals, except self
+func verifyLvSection(sec []byte, root *irepInfo) error {
+	r := &irepReader{data: sec, pos: riteSectionSize}
+	slen, err := r.u32("symbols length", RiteSectionLvIdent)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < slen; i++ {
+		l, err := r.u16("symbol", RiteSectionLvIdent)
+		if err != nil {
+			return err
+		}
+		if _, err := r.bytes(l, "symbol", RiteSectionLvIdent); err != nil {
+			return err
+		}
+	}
+
+	var record func(irep *irepInfo) error
+	record = func(irep *irepInfo) error {
+		for i := 1; i < irep.nlocals; i++ {
+			sym, err := r.u16("local variable", irep.id)
+			if err != nil {
+				return err
+			}
+			if sym != RiteLVNullMark && sym >= slen {
+				return verifyError("irep %s: local variable symbol %d out of range (%d)", irep.id, sym, slen)
+			}
+		}
+		for _, child := range irep.children {
+			if err := record(child); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := record(root); err != nil {
+		return err
+	}
+	if r.pos != len(sec) {
+		return verifyError("%d bytes after last %s record", len(sec)-r.pos, RiteSectionLvIdent)
+	}
+	return nil
+}
+
+// debug info line table types
+const (
+	debugLineAry = iota
+	debugLineFlatMap
+	debugLinePackedMap
+)
+
+// verifyDebugSection checks debug info records of DBG section. Record size of each
+// irep must match its file and line entries
+func verifyDebugSection(sec []byte, root *irepInfo) error {
+	r := &irepReader{data: sec, pos: riteSectionSize}
+	flen, err := r.u16("filenames length", "debug info")
+	if err != nil {
+		return err
+	}
+	for i := 0; i < flen; i++ {
+		l, err := r.u16("filename", "debug info")
+		if err != nil {
+			return err
+		}
+		if _, err := r.bytes(l, "filename", "debug info"); err != nil {
+			return err
+		}
+	}
+
+	var record func(irep *irepInfo) error
+	record = func(irep *irepInfo) error {
+		start := r.pos
+		size, err := r.u32("debug record size", irep.id)
+		if err != nil {
+			return err
+		}
+		files, err := r.u16("debug files", irep.id)
+		if err != nil {
+			return err
+		}
+		for i := 0; i < files; i++ {
+			var f [4]int
+			for j, what := range []string{"debug start", "debug filename", "debug lines", "debug line type"} {
+				switch j {
+				case 1:
+					f[j], err = r.u16(what, irep.id)
+				case 3:
+					f[j], err = r.u8(what, irep.id)
+				default:
+					f[j], err = r.u32(what, irep.id)
+				}
+				if err != nil {
+					return err
+				}
+			}
+			pos, filename, lines, lineType := f[0], f[1], f[2], f[3]
+			if pos > irep.ilen || filename >= flen {
+				return verifyError("irep %s: invalid debug file %d", irep.id, i)
+			}
+
+			entry := 0
+			switch lineType {
+			case debugLineAry:
+				entry = 2
+			case debugLineFlatMap:
+				entry = 6
+			case debugLinePackedMap:
+				entry = 1
+			default:
+				return verifyError("irep %s: invalid debug line type %d", irep.id, lineType)
+			}
+			if lines > len(sec) {
+				return verifyError("irep %s: truncated debug lines", irep.id)
+			}
+			if _, err := r.bytes(lines*entry, "debug lines", irep.id); err != nil {
+				return err
+			}
+		}
+		if r.pos-start != size {
+			return verifyError("irep %s: debug record size %d does not match its content size %d", irep.id, size, r.pos-start)
+		}
+
+		for _, child := range irep.children {
+			if err := record(child); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := record(root); err != nil {
+		return err
+	}
+	if r.pos != len(sec) {
+		return verifyError("%d bytes after last debug record", len(sec)-r.pos)
+	}
+	return nil
+}
+
+// irepReader reads irep records in RITE IREP section
+type irepReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *irepReader) bytes(n int, what, id string) ([]byte, error) {
+	if n < 0 || r.pos+n > len(r.data) {
+		return nil, verifyError("irep %s: truncated %s", id, what)
+	}
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return b, nil
+}
+
+func (r *irepReader) u8(what, id string) (int, error) {
+	b, err := r.bytes(1, what, id)
+	if err != nil {
+		return 0, err
+	}
+	return int(b[0]), nil
+}
+
+func (r *irepReader) u16(what, id string) (int, error) {
+	b, err := r.bytes(2, what, id)
+	if err != nil {
+		return 0, err
+	}
+	return int(binary.BigEndian.Uint16(b)), nil
+}
+
+func (r *irepReader) u32(what, id string) (int, error) {
+	b, err := r.bytes(4, what, id)
+	if err != nil {
+		return 0, err
+	}
+	return int(binary.BigEndian.Uint32(b)), nil
+}
+
+// record checks irep record and records of its child ireps. parents are nlocals
+// of enclosing ireps, innermost last, which are accessed as upvars
+func (r *irepReader) record(id string, parents []int) (*irepInfo, error) {
+	start := r.pos
+	var hdr [6]int
+	for i, what := range []string{"record size", "nlocals", "nregs", "rlen", "clen", "ilen"} {
+		var err error
+		if i == 0 || i == 5 {
+			hdr[i], err = r.u32(what, id)
+		} else {
+			hdr[i], err = r.u16(what, id)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	nlocals, nregs, rlen, clen, ilen := hdr[1], hdr[2], hdr[3], hdr[4], hdr[5]
+	if nlocals > nregs {
+		return nil, verifyError("irep %s: nlocals %d exceeds nregs %d", id, nlocals, nregs)
+	}
+
+	iseq, err := r.bytes(ilen, "iseq", id)
+	if err != nil {
+		return nil, err
+	}
+	handlers, err := r.bytes(clen*riteCatchHandlerSize, "catch handlers", id)
+	if err != nil {
+		return nil, err
+	}
+
+	plen, err := r.u16("pool length", id)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < plen; i++ {
+		if err := r.poolValue(id); err != nil {
+			return nil, err
+		}
+	}
+
+	slen, err := r.u16("symbols length", id)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < slen; i++ {
+		l, err := r.u16("symbol", id)
+		if err != nil {
+			return nil, err
+		}
+		if l == MrbDumpNullSymLen {
+			continue
+		}
+		if _, err := r.bytes(l+1, "symbol", id); err != nil {
+			return nil, err
+		}
+	}
+
+	if size := r.pos - start; size != hdr[0] {
+		return nil, verifyError("irep %s: record size %d does not match its content size %d", id, hdr[0], size)
+	}
+
+	if err := verifyISeq(id, iseq, handlers, nregs, plen, slen, rlen, parents); err != nil {
+		return nil, err
+	}
+
+	info := &irepInfo{id: id, nlocals: nlocals, ilen: ilen}
+	parents = append(parents[:len(parents):len(parents)], nlocals)
+	for i := 0; i < rlen; i++ {
+		child, err := r.record(fmt.Sprintf("%s.%d", id, i), parents)
+		if err != nil {
+			return nil, err
+		}
+		info.children = append(info.children, child)
+	}
+	return info, nil
+}
+
+// poolValue skips pool value, checking its type and size
+func (r *irepReader) poolValue(id string) error {
+	tt, err := r.u8("pool value", id)
+	if err != nil {
+		return err
+	}
+
+	switch IrepPoolType(tt) {
+	case IrepTtInt32:
+		_, err = r.bytes(4, "pool value", id)
+	case IrepTtInt64, IrepTtFloat:
+		_, err = r.bytes(8, "pool value", id)
+	case IrepTtBigint:
+		var l int
+		if l, err = r.u8("pool value", id); err == nil {
+			_, err = r.bytes(l+1, "pool value", id)
+		}
+	case IrepTtStr:
+		var l int
+		if l, err = r.u16("pool value", id); err == nil {
+			_, err = r.bytes(l+1, "pool value", id)
+		}
+	default:
+		return verifyError("irep %s: invalid pool value type %d", id, tt)
+	}
+	return err
+}
+
+// verifyISeq checks instructions and catch handlers of irep
+func verifyISeq(id string, iseq, handlers []byte, nregs, plen, slen, rlen int, parents []int) error {
+	code, err := decodeISeq(iseq)
+	if err != nil {
+		return verifyError("irep %s: %v", id, err)
+	}
+
+	starts := make(map[int]bool, len(code))
+	for _, ins := range code {
+		starts[ins.PC] = true
+	}
+
+	for _, ins := range code {
+		roles := ins.roles()
+		for i, v := range ins.Operands {
+			if i >= len(roles) {
+				break
+			}
+			limit, what := 0, ""
+			switch roles[i] {
+			case 'L':
+				limit, what = len(parents), "upvar level"
+			case 'U':
+				// upvar index is checked with locals of irep at upvar level
+				if l := strings.IndexByte(roles, 'L'); l >= 0 && l < len(ins.Operands) && ins.Operands[l] < len(parents) {
+					limit, what = parents[len(parents)-1-ins.Operands[l]], "upvar index"
+				} else {
+					continue
+				}
+			case 'R':
+				limit, what = nregs, "register"
+			case 'P':
+				limit, what = plen, "pool index"
+			case 'S':
+				limit, what = slen, "symbol index"
+			case 'I':
+				limit, what = rlen, "child irep index"
+			case 'J':
+				if t := ins.Target(); !starts[t] {
+					return verifyError("irep %s: %v at %03d jumps to %d, outside of instructions", id, ins.Name, ins.PC, t)
+				}
+				continue
+			default:
+				continue
+			}
+			if v >= limit {
+				return verifyError("irep %s: %v at %03d: %s %d out of range (%d)", id, ins.Name, ins.PC, what, v, limit)
+			}
+		}
+		if last := ins.lastReg(); last >= nregs {
+			return verifyError("irep %s: %v at %03d: uses register %d out of range (%d)", id, ins.Name, ins.PC, last, nregs)
+		}
+	}
+
+	for i := 0; i+riteCatchHandlerSize <= len(handlers); i += riteCatchHandlerSize {
+		h := handlers[i : i+riteCatchHandlerSize]
+		begin := int(binary.BigEndian.Uint32(h[1:5]))
+		end := int(binary.BigEndian.Uint32(h[5:9]))
+		target := int(binary.BigEndian.Uint32(h[9:13]))
+		if h[0] > MrbCatchEnsure || begin > end || end > len(iseq) || !starts[target] {
+			return verifyError("irep %s: invalid catch handler %d", id, i/riteCatchHandlerSize)
+		}
+	}
+	return nil
+}
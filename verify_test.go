@@ -0,0 +1,162 @@
+package oruby
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+func TestVerifyIrep(t *testing.T) {
+	prog, err := Compile("s = 'text'\nf = 1.5\nn = 1 << 40\n[1, 2].map { |i| i * f }\nbegin\n  raise s\nrescue => e\n  e\nend\n", "ok.rb")
+	ExpectNilError(t, err)
+	ExpectNilError(t, VerifyIrep(prog.Bytecode()))
+
+	// iseq of top irep starts after binary header, IREP section header and irep record header
+	const nregsPos, iseqPos = 38, 48
+
+	for name, corrupt := range map[string]func([]byte) []byte{
+		"empty":       func(b []byte) []byte { return nil },
+		"truncated":   func(b []byte) []byte { return b[:len(b)/2] },
+		"ident":       func(b []byte) []byte { b[0] = 'X'; return b },
+		"binary size": func(b []byte) []byte { b[11]++; return b },
+		"vm version":  func(b []byte) []byte { b[31] = '9'; return b },
+		"nregs":       func(b []byte) []byte { b[nregsPos], b[nregsPos+1] = 0, 0; return b },
+		"opcode":      func(b []byte) []byte { b[iseqPos] = 0xff; return b },
+	} {
+		err := VerifyIrep(corrupt(prog.Bytecode()))
+		Expect(t, errors.Is(err, ErrInvalidBytecode), "%v: invalid bytecode error expected, got %v", name, err)
+	}
+}
+
+// testOp returns instruction bytes of opcode with byte operands
+func testOp(t *testing.T, name string, operands ...byte) []byte {
+	for op := 0; op < opcodeCount(); op++ {
+		if n, _ := opcodeInfo(op); n == name {
+			return append([]byte{byte(op)}, operands...)
+		}
+	}
+	t.Fatalf("unknown opcode %v", name)
+	return nil
+}
+
+// testIrepRecord returns irep record with empty pool and one symbol, followed by records of children
+func testIrepRecord(nlocals, nregs int, iseq []byte, children ...[]byte) []byte {
+	var b bytes.Buffer
+	size := 16 + len(iseq) + 8
+	for _, v := range []interface{}{uint32(size), uint16(nlocals), uint16(nregs), uint16(len(children)), uint16(0), uint32(len(iseq))} {
+		binary.Write(&b, binary.BigEndian, v)
+	}
+	b.Write(iseq)
+	b.Write([]byte{0, 0, 0, 1, 0, 1, 'x', 0})
+	for _, c := range children {
+		b.Write(c)
+	}
+	return b.Bytes()
+}
+
+// testRiteBinary returns RITE binary with IREP section of irep records
+func testRiteBinary(records []byte) []byte {
+	var b bytes.Buffer
+	b.WriteString(RiteBinaryIdent + RiteBinaryFormatVer)
+	binary.Write(&b, binary.BigEndian, uint32(riteBinaryHeaderSize+riteIrepHeaderSize+len(records)+riteSectionSize))
+	b.WriteString(RiteCompilerName + RiteCompilerVersion)
+	b.WriteString(RiteSectionIrepIdent)
+	binary.Write(&b, binary.BigEndian, uint32(riteIrepHeaderSize+len(records)))
+	b.WriteString(RiteVMVer)
+	b.Write(records)
+	b.WriteString(RiteBinaryEOF)
+	binary.Write(&b, binary.BigEndian, uint32(riteSectionSize))
+	return b.Bytes()
+}
+
+func TestVerifyIrep_Operands(t *testing.T) {
+	ret := testOp(t, "RETURN", 0)
+	iseq := func(ops ...[]byte) []byte { return bytes.Join(append(ops, ret), nil) }
+
+	valid := testRiteBinary(testIrepRecord(2, 4, iseq(testOp(t, "MOVE", 1, 2)),
+		testIrepRecord(1, 3, iseq(testOp(t, "GETUPVAR", 2, 1, 0)))))
+	ExpectNilError(t, VerifyIrep(valid))
+
+	badSize := testIrepRecord(1, 4, iseq())
+	badSize[3]++
+
+	for name, record := range map[string][]byte{
+		"MOVE source":    testIrepRecord(1, 4, iseq(testOp(t, "MOVE", 1, 4))),
+		"ARRAY2 range":   testIrepRecord(1, 4, iseq(testOp(t, "ARRAY2", 1, 2, 3))),
+		"SEND arguments": testIrepRecord(1, 4, iseq(testOp(t, "SEND", 1, 0, 2))),
+		"SENDB block":    testIrepRecord(1, 4, iseq(testOp(t, "SENDB", 3, 0, 0))),
+		"ADD operand":    testIrepRecord(1, 4, iseq(testOp(t, "ADD", 3))),
+		"GETIDX index":   testIrepRecord(1, 4, iseq(testOp(t, "GETIDX", 3))),
+		"STRCAT operand": testIrepRecord(1, 4, iseq(testOp(t, "STRCAT", 3))),
+		"HASH pairs":     testIrepRecord(1, 4, iseq(testOp(t, "HASH", 1, 2))),
+		"ERR pool":       testIrepRecord(1, 4, iseq(testOp(t, "ERR", 0))),
+		"LOADSYM symbol": testIrepRecord(1, 4, iseq(testOp(t, "LOADSYM", 1, 1))),
+		"top upvar":      testIrepRecord(1, 4, iseq(testOp(t, "GETUPVAR", 1, 0, 0))),
+		"SETUPVAR level": testIrepRecord(2, 4, iseq(), testIrepRecord(1, 3, iseq(testOp(t, "SETUPVAR", 1, 1, 1)))),
+		"GETUPVAR index": testIrepRecord(2, 4, iseq(), testIrepRecord(1, 3, iseq(testOp(t, "GETUPVAR", 1, 2, 0)))),
+		"LAMBDA child":   testIrepRecord(1, 4, iseq(testOp(t, "LAMBDA", 1, 0))),
+		"JMP target":     testIrepRecord(1, 4, iseq(testOp(t, "JMP", 0, 9))),
+		"record size":    badSize,
+		"record extra":   append(testIrepRecord(1, 4, iseq()), 0),
+	} {
+		err := VerifyIrep(testRiteBinary(record))
+		Expect(t, errors.Is(err, ErrInvalidBytecode), "%v: invalid bytecode error expected, got %v", name, err)
+	}
+}
+
+// testSection returns offset of section in RITE binary
+func testSection(t *testing.T, b []byte, ident string) int {
+	for pos := riteBinaryHeaderSize; pos+riteSectionSize <= len(b); {
+		if string(b[pos:pos+4]) == ident {
+			return pos
+		}
+		pos += int(binary.BigEndian.Uint32(b[pos+4 : pos+8]))
+	}
+	t.Fatalf("missing section %q", ident)
+	return 0
+}
+
+func TestVerifyIrep_Sections(t *testing.T) {
+	prog, err := Compile("a = 1\nb = [a].map { |x| x + a }\n", "sections.rb")
+	ExpectNilError(t, err)
+	ExpectNilError(t, VerifyIrep(prog.Bytecode()))
+
+	// symbol index of first local variable follows LVAR symbols
+	lvSym := func(b []byte) int {
+		pos := testSection(t, b, RiteSectionLvIdent) + riteSectionSize
+		n := int(binary.BigEndian.Uint32(b[pos:]))
+		pos += 4
+		for i := 0; i < n; i++ {
+			pos += 2 + int(binary.BigEndian.Uint16(b[pos:]))
+		}
+		return pos
+	}
+	// first debug record follows DBG filenames
+	dbgRecord := func(b []byte) int {
+		pos := testSection(t, b, RiteSectionDebugIdent) + riteSectionSize
+		n := int(binary.BigEndian.Uint16(b[pos:]))
+		pos += 2
+		for i := 0; i < n; i++ {
+			pos += 2 + int(binary.BigEndian.Uint16(b[pos:]))
+		}
+		return pos
+	}
+
+	for name, corrupt := range map[string]func([]byte){
+		"lvar symbol": func(b []byte) { binary.BigEndian.PutUint16(b[lvSym(b):], 0x7fff) },
+		"lvar symbols length": func(b []byte) {
+			pos := testSection(t, b, RiteSectionLvIdent) + riteSectionSize
+			binary.BigEndian.PutUint32(b[pos:], 0)
+		},
+		"debug record size": func(b []byte) { b[dbgRecord(b)+3]++ },
+		"debug filename":    func(b []byte) { binary.BigEndian.PutUint16(b[dbgRecord(b)+10:], 0x7fff) },
+		"debug line type":   func(b []byte) { b[dbgRecord(b)+16] = 9 },
+		"debug lines":       func(b []byte) { binary.BigEndian.PutUint32(b[dbgRecord(b)+12:], 0x7fffffff) },
+	} {
+		b := prog.Bytecode()
+		corrupt(b)
+		err := VerifyIrep(b)
+		Expect(t, errors.Is(err, ErrInvalidBytecode), "%v: invalid bytecode error expected, got %v", name, err)
+	}
+}